@@ -0,0 +1,91 @@
+package resources
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	db "Rivall-Backend/db"
+	"Rivall-Backend/globals"
+	"Rivall-Backend/util/api_error"
+	"Rivall-Backend/util/mailer"
+
+	"github.com/gorilla/mux"
+	"github.com/rs/zerolog/log"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// ACCOUNT_DELETION_GRACE is how long a user has to change their mind about deleting
+// their account
+const ACCOUNT_DELETION_GRACE = time.Hour * 24 * 7
+
+type DeleteUserReq struct {
+	Password string `json:"password"`
+	// EmailCode confirms the deletion instead of Password for users without one
+	EmailCode string `json:"email_code" form:"max=64"`
+	// Code is an authenticator or recovery code, needed with two-factor authentication
+	Code string `json:"code" form:"max=64"`
+}
+
+type DeleteUserRes struct {
+	DeletionScheduledAt time.Time `json:"deletion_scheduled_at"`
+}
+
+func DeleteUser(w http.ResponseWriter, r *http.Request) {
+	log.Info().Msg("DELETE user")
+
+	userID := mux.Vars(r)["user_id"]
+	user := db.ReadByUserId(userID)
+	if user.ID == bson.NilObjectID {
+		log.Error().Msg("User does not exist")
+		api_error.Write(w, r, http.StatusBadRequest, api_error.USER_NOT_FOUND, "User does not exist.")
+		return
+	}
+
+	req := DeleteUserReq{}
+	if !decodeRequest(w, r, &req) {
+		return
+	}
+
+	// a stolen access token alone can't delete the account
+	if !confirmIdentity(w, r, user, req.Password, req.EmailCode) {
+		return
+	}
+	if !confirmSecondFactor(w, r, user, req.Code) {
+		return
+	}
+
+	deleteAt, err := db.ScheduleUserDeletion(userID, time.Now().Add(ACCOUNT_DELETION_GRACE))
+	if err != nil {
+		api_error.Write(w, r, http.StatusInternalServerError, api_error.INTERNAL, "Failed to delete account")
+		return
+	}
+
+	err = globals.Mailer.Send(user.Email, mailer.TEMPLATE_NOTIFICATION, mailer.NotificationData{
+		FirstName: user.FirstName,
+		Title:     "Your Rivall account will be deleted",
+		Body:      "Your Rivall account and everything tied to it will be deleted on " + deleteAt.UTC().Format("January 2, 2006 at 15:04 UTC") + ". Log in and cancel the deletion before then if you change your mind, or if this wasn't you.",
+	})
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to queue account deletion notification")
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(DeleteUserRes{DeletionScheduledAt: deleteAt})
+}
+
+func CancelUserDeletion(w http.ResponseWriter, r *http.Request) {
+	log.Info().Msg("DELETE user deletion")
+
+	cancelled, err := db.CancelUserDeletion(mux.Vars(r)["user_id"])
+	if err != nil {
+		api_error.Write(w, r, http.StatusInternalServerError, api_error.INTERNAL, "Failed to cancel account deletion")
+		return
+	}
+	if !cancelled {
+		api_error.Write(w, r, http.StatusNotFound, api_error.NOT_FOUND, "No account deletion is scheduled.")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,29 @@
+package resources_test
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	db "Rivall-Backend/db"
+	"Rivall-Backend/util/test"
+)
+
+func TestLoginRefusedOnceDeletionIsDue(t *testing.T) {
+	setupHandlers(t)
+	user := createUser(t, "sam@example.com")
+
+	// during the grace period logging in is how the deletion gets cancelled
+	_, err := db.ScheduleUserDeletion(user.ID.Hex(), time.Now().Add(time.Hour))
+	test.NoError(t, err)
+	status, _ := login(t, "sam@example.com")
+	test.Equal(t, status, http.StatusAccepted)
+
+	// once it's over the account is being taken apart, new sessions would outlive it
+	_, err = db.CancelUserDeletion(user.ID.Hex())
+	test.NoError(t, err)
+	_, err = db.ScheduleUserDeletion(user.ID.Hex(), time.Now().Add(-time.Minute))
+	test.NoError(t, err)
+	status, _ = login(t, "sam@example.com")
+	test.Equal(t, status, http.StatusGone)
+}
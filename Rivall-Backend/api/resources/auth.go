@@ -2,6 +2,7 @@ package resources
 
 import (
 	db "Rivall-Backend/db"
+	"Rivall-Backend/util/api_error"
 	"context"
 	"encoding/json"
 	"errors"
@@ -11,20 +12,15 @@ import (
 
 	"Rivall-Backend/api/websocket"
 	"Rivall-Backend/globals"
+	"Rivall-Backend/util/mailer"
+	"Rivall-Backend/util/otp"
+	"Rivall-Backend/util/session_manager"
 
 	"github.com/gorilla/mux"
 	"github.com/rs/zerolog/log"
 	"go.mongodb.org/mongo-driver/v2/bson"
 )
 
-type UserRes struct {
-	ID          string `json:"_id"`
-	FirstName   string `json:"first_name"`
-	LastName    string `json:"last_name"`
-	Email       string `json:"email"`
-	AvatarImage string `json:"avatar_image"`
-}
-
 func getUserIDFromContext(ctx context.Context) (string, error) {
 	userID, ok := ctx.Value("user_id").(string)
 	if !ok {
@@ -34,56 +30,71 @@ func getUserIDFromContext(ctx context.Context) (string, error) {
 	return userID, nil
 }
 
+type RegisterUserReq struct {
+	FirstName   string `json:"first_name"   form:"required"`
+	LastName    string `json:"last_name"    form:"required"`
+	Email       string `json:"email"        form:"required,email,max=254"`
+	Password    string `json:"password"     form:"required,password_length,password_classes,password_personal,password_common"`
+	AvatarImage string `json:"avatar_image"`
+}
+
+// NewPassword checks a password against the policy and the details of the user setting it
+type NewPassword struct {
+	FirstName string `json:"first_name"`
+	LastName  string `json:"last_name"`
+	Email     string `json:"email"`
+	Password  string `json:"password" form:"required,password_length,password_classes,password_personal,password_common"`
+}
+
 func RegisterNewUser(w http.ResponseWriter, r *http.Request) {
 	log.Info().Msg("POST new user")
 
-	// get user data
-	user := db.User{}
-	err := json.NewDecoder(r.Body).Decode(&user)
-	user.Email = strings.ToLower(user.Email)
-	if err != nil {
-		log.Error().Err(err).Msg("Failed to decode user, invalid JSON request")
-		w.WriteHeader(http.StatusBadRequest)
-		w.Write([]byte("Failed to decode user, invalid JSON request."))
+	// every registration counts against the client, not just failed ones
+	if !checkRateLimit(w, r, globals.RegistrationLimiter, "") {
 		return
 	}
+	failRateLimit(r, globals.RegistrationLimiter, "")
 
-	// check user has all required fields
-	if user.FirstName == "" || user.LastName == "" || user.Email == "" || user.Password == "" {
-		log.Error().Msg("User must have all required fields")
-		w.WriteHeader(http.StatusBadRequest)
-		w.Write([]byte("User must have all required fields."))
-		log.Error().Msg(user.FirstName)
-		log.Error().Msg(user.LastName)
-		log.Error().Msg(user.Email)
-		log.Error().Msg(user.Password)
+	// get user data, it needs all required fields and a strong enough password
+	req := RegisterUserReq{}
+	if !decodeRequest(w, r, &req) {
 		return
 	}
+	req.Email = strings.ToLower(req.Email)
+	user := db.User{
+		FirstName:   req.FirstName,
+		LastName:    req.LastName,
+		Email:       req.Email,
+		Password:    req.Password,
+		AvatarImage: req.AvatarImage,
+	}
 
 	// check user does not already exist
 	if db.ReadByUserEmail(user.Email).ID != bson.NilObjectID {
 		log.Error().Msg("User already exists")
-		w.WriteHeader(http.StatusForbidden)
-		w.Write([]byte("User already exists."))
+		api_error.Write(w, r, http.StatusForbidden, api_error.EMAIL_TAKEN, "User already exists.")
 		return
 	}
 
 	// insert user data
-	err = db.CreateUser(user)
-	if err != nil {
+	if err := db.CreateUser(user); err != nil {
 		log.Error().Err(err).Msg("Failed to insert user")
-		w.WriteHeader(http.StatusInternalServerError)
-		w.Write([]byte("Failed to insert user"))
+		api_error.Write(w, r, http.StatusInternalServerError, api_error.INTERNAL, "Failed to insert user")
 		return
 	}
 
+	// the account starts unverified, a failed send can be retried with a resend
+	if err := sendVerificationCode(user, otp.PURPOSE_EMAIL_VERIFICATION, user.Email); err != nil {
+		log.Error().Err(err).Msg("Failed to send verification email")
+	}
+
 	// return success
 	w.WriteHeader(http.StatusCreated)
 }
 
 type LoginUserReq struct {
-	Email    string `json:"email"`
-	Password string `json:"password"`
+	Email    string `json:"email"    form:"required,email,max=254"`
+	Password string `json:"password" form:"required"`
 }
 
 type LoginUserRes struct {
@@ -92,45 +103,99 @@ type LoginUserRes struct {
 	AccessTokenExpiresAt  time.Time `json:"access_token_expires_at"`
 	RefreshTokenExpiresAt time.Time `json:"refresh_token_expires_at"`
 	User                  UserRes   `json:"user"`
+	// PasswordResetToken is only set after account recovery, it lets the user set a new
+	// password without their current one
+	PasswordResetToken          string     `json:"password_reset_token,omitempty"`
+	PasswordResetTokenExpiresAt *time.Time `json:"password_reset_token_expires_at,omitempty"`
+}
+
+type TwoFactorChallengeRes struct {
+	TwoFactorRequired  bool      `json:"two_factor_required"`
+	ChallengeToken     string    `json:"challenge_token"`
+	ChallengeExpiresAt time.Time `json:"challenge_expires_at"`
 }
 
 func LoginUser(w http.ResponseWriter, r *http.Request) {
 	log.Info().Msg("GET user by username")
 
-	userLogin := db.User{}
-	err := json.NewDecoder(r.Body).Decode(&userLogin)
+	userLogin := LoginUserReq{}
+	if !decodeRequest(w, r, &userLogin) {
+		return
+	}
 	userLogin.Email = strings.ToLower(userLogin.Email)
-	if err != nil {
-		log.Error().Err(err).Msg("Failed to decode user")
-		w.WriteHeader(http.StatusBadRequest)
-		w.Write([]byte("Failed to decode user"))
+
+	if !checkRateLimit(w, r, globals.LoginLimiter, userLogin.Email) {
 		return
 	}
 
+	// unknown emails and wrong passwords get the same answer in the same time
 	user := db.ReadByUserEmail(userLogin.Email)
+	var valid bool
 	if user.ID == bson.NilObjectID {
 		log.Warn().Msg("User not found")
-		w.WriteHeader(http.StatusNotFound)
-		w.Write([]byte("User not found"))
+		db.ComparePasswords(dummyPasswordHash(), userLogin.Password)
+	} else {
+		// outdated hashes are upgraded here, while the plain password is known
+		valid = db.CheckUserPassword(user, userLogin.Password)
+	}
+	if !valid {
+		log.Warn().Msg("Invalid email or password")
+		failRateLimit(r, globals.LoginLimiter, userLogin.Email)
+		api_error.Write(w, r, http.StatusUnauthorized, api_error.INVALID_CREDENTIALS, "Invalid email or password")
 		return
 	}
 
-	if !db.ComparePasswords(user.Password, userLogin.Password) {
-		log.Warn().Msg("Invalid password")
-		w.WriteHeader(http.StatusUnauthorized)
-		w.Write([]byte("Invalid password"))
+	// with two-factor authentication the password alone proves nothing, failed codes keep
+	// counting against the account until the second factor is answered
+	if !user.TwoFactor.Enabled {
+		resetRateLimit(r, globals.LoginLimiter, userLogin.Email)
+	}
+	startLogin(w, r, user, false)
+}
+
+// refuseDeletedLogin stops users whose grace period is over from logging in, their account
+// is being deleted and sessions made now would outlive it
+func refuseDeletedLogin(w http.ResponseWriter, r *http.Request, user db.User) bool {
+	if user.DeletionScheduledAt == nil || user.DeletionScheduledAt.After(time.Now()) {
+		return false
+	}
+	log.Warn().Msg("Login to account being deleted")
+	api_error.Write(w, r, http.StatusGone, api_error.GONE, "Account is being deleted")
+	return true
+}
+
+// startLogin finishes a password or recovery login, users with two-factor authentication
+// get a challenge to answer at /auth/login/2fa instead of sessions
+func startLogin(w http.ResponseWriter, r *http.Request, user db.User, recovered bool) {
+	if refuseDeletedLogin(w, r, user) {
+		return
+	}
+	if !user.TwoFactor.Enabled {
+		writeLoginSessions(w, r, user, recovered)
 		return
 	}
 
-	accessSession := globals.SessionManager.NewAccessSession(user.ID.Hex())
-	refreshSession := globals.SessionManager.NewRefreshSession(user.ID.Hex())
+	challenge, err := globals.SessionManager.NewChallenge(user.ID.Hex(), recovered)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to create two factor challenge")
+		api_error.Write(w, r, http.StatusInternalServerError, api_error.INTERNAL, "Failed to create session")
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(TwoFactorChallengeRes{
+		TwoFactorRequired:  true,
+		ChallengeToken:     challenge.Token,
+		ChallengeExpiresAt: challenge.TokenExpiresAt,
+	})
+}
 
-	su := UserRes{
-		ID:          user.ID.Hex(),
-		FirstName:   user.FirstName,
-		LastName:    user.LastName,
-		Email:       user.Email,
-		AvatarImage: user.AvatarImage,
+func writeLoginSessions(w http.ResponseWriter, r *http.Request, user db.User, recovered bool) {
+	accessSession, refreshSession, err := globals.SessionManager.NewSessionFamily(user.ID.Hex(), deviceFromRequest(r))
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to create sessions")
+		api_error.Write(w, r, http.StatusInternalServerError, api_error.INTERNAL, "Failed to create session")
+		return
 	}
 
 	res := LoginUserRes{
@@ -138,7 +203,18 @@ func LoginUser(w http.ResponseWriter, r *http.Request) {
 		RefreshToken:          refreshSession.Token,
 		AccessTokenExpiresAt:  accessSession.TokenExpiresAt,
 		RefreshTokenExpiresAt: refreshSession.TokenExpiresAt,
-		User:                  su,
+		User:                  newUserRes(user),
+	}
+
+	if recovered {
+		reset, err := globals.SessionManager.NewResetToken(user.ID.Hex())
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to create password reset token")
+			api_error.Write(w, r, http.StatusInternalServerError, api_error.INTERNAL, "Failed to create session")
+			return
+		}
+		res.PasswordResetToken = reset.Token
+		res.PasswordResetTokenExpiresAt = &reset.TokenExpiresAt
 	}
 
 	w.WriteHeader(http.StatusAccepted)
@@ -146,7 +222,7 @@ func LoginUser(w http.ResponseWriter, r *http.Request) {
 }
 
 type RecoveryCodeReq struct {
-	Email string `json:"email"`
+	Email string `json:"email" form:"required,email,max=254"`
 }
 
 func SendAccountRecoveryEmail(w http.ResponseWriter, r *http.Request) {
@@ -154,37 +230,57 @@ func SendAccountRecoveryEmail(w http.ResponseWriter, r *http.Request) {
 
 	// get user data
 	emailReq := RecoveryCodeReq{}
+	if !decodeRequest(w, r, &emailReq) {
+		return
+	}
 	emailReq.Email = strings.ToLower(emailReq.Email)
-	err := json.NewDecoder(r.Body).Decode(&emailReq)
-	if err != nil {
-		log.Error().Err(err).Msg("Failed to decode user")
-		w.WriteHeader(http.StatusBadRequest)
-		w.Write([]byte("Failed to decode user"))
+
+	// every code sent counts, recovery mail must not be usable to flood an inbox
+	if !checkRateLimit(w, r, globals.RecoveryLimiter, emailReq.Email) {
 		return
 	}
+	failRateLimit(r, globals.RecoveryLimiter, emailReq.Email)
 
-	// check email is valid
-	if db.ReadByUserEmail(emailReq.Email).ID == bson.NilObjectID {
-		log.Error().Msg("User not found")
-		w.WriteHeader(http.StatusNotFound)
-		w.Write([]byte("User not found"))
+	// unknown emails get the same answer as known ones
+	user := db.ReadByUserEmail(emailReq.Email)
+	if user.ID == bson.NilObjectID {
+		log.Warn().Msg("User not found")
+		w.WriteHeader(http.StatusCreated)
 		return
 	}
 
-	// create recovery code
-	recoveryCode := globals.PasswordRecoveryMap.NewRecoveryOTP(emailReq.Email)
+	// create recovery code, replacing any code sent before
+	recoveryCode, _, err := globals.OTP.Issue(otp.PURPOSE_RECOVERY, emailReq.Email)
+	if errors.Is(err, otp.ErrTooSoon) {
+		// the last code is still on its way and valid
+		w.WriteHeader(http.StatusCreated)
+		return
+	}
+	if err != nil {
+		writeOTPError(w, r, err)
+		return
+	}
 
 	// send email with code
-	// TODO: make email service, log recovery code to console for now
-	log.Info().Str("recovery_code", recoveryCode.Code).Msg("Recovery code sent")
+	err = globals.Mailer.Send(user.Email, mailer.TEMPLATE_RECOVERY_CODE, mailer.RecoveryCodeData{
+		FirstName:        user.FirstName,
+		Code:             recoveryCode,
+		ExpiresInMinutes: int(globals.OTP.CodeTimeout().Minutes()),
+	})
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to queue recovery email")
+		api_error.Write(w, r, http.StatusServiceUnavailable, api_error.INTERNAL, "Failed to send recovery email")
+		return
+	}
+	log.Info().Msg("Recovery code sent")
 
 	// return success
 	w.WriteHeader(http.StatusCreated)
 }
 
 type RecoveryCodeValidationReq struct {
-	Email string `json:"email"`
-	Code  string `json:"code"`
+	Email string `json:"email" form:"required,email,max=254"`
+	Code  string `json:"code"  form:"required,max=64"`
 }
 
 func ValidateAccountRecoveryCode(w http.ResponseWriter, r *http.Request) {
@@ -192,37 +288,21 @@ func ValidateAccountRecoveryCode(w http.ResponseWriter, r *http.Request) {
 
 	// get body data
 	req := RecoveryCodeValidationReq{}
-	err := json.NewDecoder(r.Body).Decode(&req)
-	if err != nil {
-		log.Error().Err(err).Msg("Failed to decode request")
-		w.WriteHeader(http.StatusBadRequest)
-		w.Write([]byte("Failed to decode request"))
-		return
-	}
-
-	// get code
-	code := strings.ToUpper(req.Code)
-	if code == "" {
-		log.Error().Msg("Code must be provided")
-		w.WriteHeader(http.StatusBadRequest)
-		w.Write([]byte("Code must be provided"))
+	if !decodeRequest(w, r, &req) {
 		return
 	}
+	code := req.Code
+	email := strings.ToLower(req.Email)
 
-	// get email
-	email := req.Email
-	if email == "" {
-		log.Error().Msg("Email must be provided")
-		w.WriteHeader(http.StatusBadRequest)
-		w.Write([]byte("Email must be provided"))
+	if !checkRateLimit(w, r, globals.RecoveryLimiter, email) {
 		return
 	}
 
 	// check code is valid for that email
-	if !globals.PasswordRecoveryMap.VerifyRecoveryOTP(code, email) {
-		log.Error().Msg("Invalid code")
-		w.WriteHeader(http.StatusUnauthorized)
-		w.Write([]byte("Invalid code"))
+	err := globals.OTP.Verify(otp.PURPOSE_RECOVERY, email, clientIP(r), code)
+	if err != nil {
+		failRateLimit(r, globals.RecoveryLimiter, email)
+		writeOTPError(w, r, err)
 		return
 	}
 
@@ -231,37 +311,21 @@ func ValidateAccountRecoveryCode(w http.ResponseWriter, r *http.Request) {
 	user := db.ReadByUserEmail(email)
 	if user.ID == bson.NilObjectID {
 		log.Warn().Msg("User not found")
-		w.WriteHeader(http.StatusNotFound)
-		w.Write([]byte("User not found"))
+		api_error.Write(w, r, http.StatusNotFound, api_error.USER_NOT_FOUND, "User not found")
 		return
 	}
 
-	// Create Access Session
-	accessSession := globals.SessionManager.NewAccessSession(user.ID.Hex())
-
-	// Create Refresh Session
-	refreshSession := globals.SessionManager.NewRefreshSession(user.ID.Hex())
-
-	// Clean Response Data
-	su := UserRes{
-		ID:          user.ID.Hex(),
-		FirstName:   user.FirstName,
-		LastName:    user.LastName,
-		Email:       user.Email,
-		AvatarImage: user.AvatarImage,
-	}
-
-	res := LoginUserRes{
-		AccessToken:           accessSession.Token,
-		RefreshToken:          refreshSession.Token,
-		AccessTokenExpiresAt:  accessSession.TokenExpiresAt,
-		RefreshTokenExpiresAt: refreshSession.TokenExpiresAt,
-		User:                  su,
-	}
+	// a recovered account still has to pass its second factor
+	startLogin(w, r, user, true)
+}
 
-	// return user
-	w.WriteHeader(http.StatusAccepted)
-	json.NewEncoder(w).Encode(res)
+type UpdatePasswordReq struct {
+	Password string `json:"password" form:"required"`
+	// CurrentPassword is required unless ResetToken from account recovery is given, users
+	// without a password send EmailCode instead
+	CurrentPassword string `json:"current_password"`
+	ResetToken      string `json:"reset_token"`
+	EmailCode       string `json:"email_code" form:"max=64"`
 }
 
 func UpdateUserPassword(w http.ResponseWriter, r *http.Request) {
@@ -272,52 +336,52 @@ func UpdateUserPassword(w http.ResponseWriter, r *http.Request) {
 	userID := vars["user_id"]
 	if userID == "" {
 		log.Error().Msg("User ID must be provided")
-		w.WriteHeader(http.StatusBadRequest)
-		w.Write([]byte("User ID must be provided"))
+		api_error.Write(w, r, http.StatusBadRequest, api_error.BAD_REQUEST, "User ID must be provided")
 		return
 	}
 
-	// get user data
-	user := db.User{}
-	err := json.NewDecoder(r.Body).Decode(&user)
-	if err != nil {
-		log.Error().Err(err).Msg("Failed to decode user, invalid JSON request")
-		w.WriteHeader(http.StatusBadRequest)
-		w.Write([]byte("Failed to decode user, invalid JSON request."))
+	// get request data
+	req := UpdatePasswordReq{}
+	if !decodeRequest(w, r, &req) {
 		return
 	}
 
-	// check user has all required fields
-	if user.ID == bson.NilObjectID || user.Password == "" {
-		log.Error().Msg("User must have all required fields")
-		w.WriteHeader(http.StatusBadRequest)
-		w.Write([]byte("User must have all required fields."))
+	// check user exists
+	userFromDB := db.ReadByUserId(userID)
+	if userFromDB.ID == bson.NilObjectID {
+		log.Error().Msg("User not found")
+		api_error.Write(w, r, http.StatusNotFound, api_error.USER_NOT_FOUND, "User not found")
 		return
 	}
 
-	// check user id matches the id in the path
-	if user.ID.Hex() != userID {
-		log.Error().Msg("User ID does not match path")
-		w.WriteHeader(http.StatusBadRequest)
-		w.Write([]byte("User ID does not match path"))
+	// check the new password before spending a reset token on it
+	newPassword := NewPassword{
+		FirstName: userFromDB.FirstName,
+		LastName:  userFromDB.LastName,
+		Email:     userFromDB.Email,
+		Password:  req.Password,
+	}
+	if err := globals.Validator.Struct(newPassword); err != nil {
+		log.Error().Err(err).Msg("Invalid password")
+		api_error.WriteValidation(w, r, err)
 		return
 	}
 
-	// check user exists
-	userFromDB := db.ReadByUserId(user.ID.Hex())
-	if userFromDB.ID == bson.NilObjectID {
-		log.Error().Msg("User not found")
-		w.WriteHeader(http.StatusNotFound)
-		w.Write([]byte("User not found"))
+	// only account recovery can change a password without the current one
+	if req.ResetToken != "" {
+		if err := globals.SessionManager.UseResetToken(req.ResetToken, userID); err != nil {
+			log.Warn().Err(err).Msg("Invalid password reset token")
+			api_error.Write(w, r, http.StatusUnauthorized, api_error.INVALID_TOKEN, "Invalid or expired reset token")
+			return
+		}
+	} else if !confirmIdentity(w, r, userFromDB, req.CurrentPassword, req.EmailCode) {
 		return
 	}
 
 	// update password
-	err = db.UpdateUserPassword(user.ID.Hex(), user.Password)
-	if err != nil {
+	if err := db.UpdateUserPassword(userID, req.Password); err != nil {
 		log.Error().Err(err).Msg("Failed to update password")
-		w.WriteHeader(http.StatusInternalServerError)
-		w.Write([]byte("Failed to update password"))
+		api_error.Write(w, r, http.StatusInternalServerError, api_error.INTERNAL, "Failed to update password")
 		return
 	}
 
@@ -326,12 +390,14 @@ func UpdateUserPassword(w http.ResponseWriter, r *http.Request) {
 }
 
 type RenewAccessTokenReq struct {
-	RefreshToken string `json:"refresh_token"`
+	RefreshToken string `json:"refresh_token" form:"required"`
 }
 
 type RenewAccessTokenRes struct {
-	AccessToken          string    `json:"access_token"`
-	AccessTokenExpiresAt time.Time `json:"access_token_expires_at"`
+	AccessToken           string    `json:"access_token"`
+	RefreshToken          string    `json:"refresh_token"`
+	AccessTokenExpiresAt  time.Time `json:"access_token_expires_at"`
+	RefreshTokenExpiresAt time.Time `json:"refresh_token_expires_at"`
 }
 
 func RenewAccessToken(w http.ResponseWriter, r *http.Request) {
@@ -342,71 +408,55 @@ func RenewAccessToken(w http.ResponseWriter, r *http.Request) {
 	userID := vars["user_id"]
 	if userID == "" {
 		log.Error().Msg("User ID must be provided")
-		w.WriteHeader(http.StatusBadRequest)
-		w.Write([]byte("User ID must be provided"))
+		api_error.Write(w, r, http.StatusBadRequest, api_error.BAD_REQUEST, "User ID must be provided")
 		return
 	}
 
 	// get user data
 	req := RenewAccessTokenReq{}
-	err := json.NewDecoder(r.Body).Decode(&req)
-	if err != nil {
-		log.Error().Err(err).Msg("Failed to decode request")
-		w.WriteHeader(http.StatusBadRequest)
-		w.Write([]byte("Failed to decode request"))
-		return
-	}
-
-	// check user has all required fields
-	if req.RefreshToken == "" {
-		log.Error().Msg("Refresh token must be provided")
-		w.WriteHeader(http.StatusBadRequest)
-		w.Write([]byte("Refresh token must be provided"))
+	if !decodeRequest(w, r, &req) {
 		return
 	}
 
-	// check user id matches the id in the path
-	if userID != userID {
+	// check the path user is the authenticated user
+	authUserID, err := getUserIDFromContext(r.Context())
+	if err != nil || authUserID != userID {
 		log.Error().Msg("User ID does not match path")
-		w.WriteHeader(http.StatusBadRequest)
-		w.Write([]byte("User ID does not match path"))
+		api_error.Write(w, r, http.StatusUnauthorized, api_error.UNAUTHORIZED, "User ID does not match path")
 		return
 	}
 
-	// get session from refresh token
-	session, ok := globals.SessionManager.GetSession(req.RefreshToken)
-
-	// check if session exists
-	if !ok {
+	// Trade the refresh token for a new access and refresh token
+	accessSession, refreshSession, err := globals.SessionManager.RotateRefreshSession(req.RefreshToken, authUserID)
+	switch {
+	case errors.Is(err, session_manager.ErrRefreshTokenReused):
+		log.Warn().Str("user_id", authUserID).Msg("Refresh token reused, session family revoked")
+		api_error.Write(w, r, http.StatusUnauthorized, api_error.INVALID_TOKEN, "Refresh token was already used, log in again")
+		return
+	case errors.Is(err, session_manager.ErrSessionNotFound):
 		log.Error().Msg("Session not found")
-		w.WriteHeader(http.StatusUnauthorized)
-		w.Write([]byte("Session not found"))
+		api_error.Write(w, r, http.StatusUnauthorized, api_error.UNAUTHORIZED, "Session not found")
 		return
-	}
-
-	// check session is a refresh token
-	if session.Type != "refresh" {
+	case errors.Is(err, session_manager.ErrNotRefreshSession):
 		log.Error().Msg("Session is not a refresh token")
-		w.WriteHeader(http.StatusUnauthorized)
-		w.Write([]byte("Session is not a refresh token"))
+		api_error.Write(w, r, http.StatusUnauthorized, api_error.INVALID_TOKEN, "Session is not a refresh token")
 		return
-	}
-
-	// check session matches the user id
-	if session.UserID != session.UserID {
+	case errors.Is(err, session_manager.ErrSessionUserMismatch):
 		log.Error().Msg("Session does not match user ID")
-		w.WriteHeader(http.StatusUnauthorized)
-		w.Write([]byte("Session does not match user ID"))
+		api_error.Write(w, r, http.StatusUnauthorized, api_error.UNAUTHORIZED, "Session does not match user ID")
+		return
+	case err != nil:
+		log.Error().Err(err).Msg("Failed to rotate refresh session")
+		api_error.Write(w, r, http.StatusInternalServerError, api_error.INTERNAL, "Failed to create session")
 		return
 	}
 
-	// Create Access Session
-	accessSession := globals.SessionManager.NewAccessSession(session.UserID)
-
 	// Clean Response Data
 	res := RenewAccessTokenRes{
-		AccessToken:          accessSession.Token,
-		AccessTokenExpiresAt: accessSession.TokenExpiresAt,
+		AccessToken:           accessSession.Token,
+		RefreshToken:          refreshSession.Token,
+		AccessTokenExpiresAt:  accessSession.TokenExpiresAt,
+		RefreshTokenExpiresAt: refreshSession.TokenExpiresAt,
 	}
 
 	w.WriteHeader(http.StatusCreated)
@@ -422,32 +472,25 @@ func LogoutUser(w http.ResponseWriter, r *http.Request) {
 	userID := vars["user_id"]
 	if userID == "" {
 		log.Error().Msg("User ID must be provided")
-		w.WriteHeader(http.StatusBadRequest)
-		w.Write([]byte("User ID must be provided"))
+		api_error.Write(w, r, http.StatusBadRequest, api_error.BAD_REQUEST, "User ID must be provided")
 		return
 	}
 
-	// Get Tokens from Header
-	tokenString := r.Header.Get("Authorization")
-	if tokenString == "" {
-		log.Error().Msg("Token not found in header")
-		w.WriteHeader(http.StatusUnauthorized)
-		w.Write([]byte("Token not found in header"))
+	// The auth middleware found the login the access token belongs to
+	sessionID := getSessionIDFromContext(r)
+	if sessionID == "" {
+		log.Error().Msg("Session not found in context")
+		api_error.Write(w, r, http.StatusUnauthorized, api_error.INVALID_TOKEN, "Session not found")
 		return
 	}
 
-	RefreshtokenString := r.Header.Get("Refresh-Auth")
-	if tokenString == "" {
-		log.Error().Msg("Token not found in header")
-		w.WriteHeader(http.StatusUnauthorized)
-		w.Write([]byte("Token not found in header"))
+	// Delete every session of this login, including its refresh tokens
+	if err := globals.SessionManager.RevokeSessionFamily(sessionID); err != nil {
+		log.Error().Err(err).Msg("Failed to revoke sessions")
+		api_error.Write(w, r, http.StatusInternalServerError, api_error.INTERNAL, "Failed to log out")
 		return
 	}
 
-	// Delete Session
-	globals.SessionManager.DeleteSession(tokenString)
-	globals.SessionManager.DeleteSession(RefreshtokenString)
-
 	// remove token from header, the token will eventually expire
 	w.Header().Set("Authorization", "")
 	w.WriteHeader(http.StatusOK)
@@ -0,0 +1,42 @@
+package resources_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"Rivall-Backend/api/resources"
+	"Rivall-Backend/api/router/middleware"
+	"Rivall-Backend/globals"
+	"Rivall-Backend/util/test"
+
+	"github.com/gorilla/mux"
+)
+
+func TestLogoutRevokesRefreshToken(t *testing.T) {
+	setupHandlers(t)
+	userID := createUser(t, "sam@example.com").ID.Hex()
+	vars := map[string]string{"user_id": userID}
+
+	body := fmt.Sprintf(`{"email":"sam@example.com","password":%q}`, PASSWORD)
+	w := serve(resources.LoginUser, http.MethodPost, body, "", nil)
+	test.Equal(t, w.Code, http.StatusAccepted)
+	sessions := resources.LoginUserRes{}
+	test.NoError(t, json.NewDecoder(w.Body).Decode(&sessions))
+
+	// log out the way the docs say to, through the auth middleware
+	r := httptest.NewRequest(http.MethodDelete, "/", nil)
+	r.Header.Set("Authorization", "Bearer "+sessions.AccessToken)
+	r = mux.SetURLVars(r, vars)
+	w = httptest.NewRecorder()
+	middleware.AuthMiddleware(http.HandlerFunc(resources.LogoutUser)).ServeHTTP(w, r)
+	test.Equal(t, w.Code, http.StatusOK)
+
+	_, ok := globals.SessionManager.GetSession(sessions.AccessToken)
+	test.Equal(t, ok, false)
+	body = fmt.Sprintf(`{"refresh_token":%q}`, sessions.RefreshToken)
+	w = serve(resources.RenewAccessToken, http.MethodPost, body, userID, vars)
+	test.Equal(t, w.Code, http.StatusUnauthorized)
+}
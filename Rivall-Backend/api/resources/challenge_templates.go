@@ -0,0 +1,250 @@
+package resources
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"slices"
+
+	db "Rivall-Backend/db"
+	"Rivall-Backend/util/api_error"
+	"Rivall-Backend/util/recommender"
+	"Rivall-Backend/util/validator"
+
+	"github.com/gorilla/mux"
+	"github.com/rs/zerolog/log"
+)
+
+const DEFAULT_TEMPLATE_PAGE_SIZE = 20
+const MAX_TEMPLATE_PAGE_SIZE = 100
+
+// PublishChallengeTemplateReq rewords the template, empty fields keep the challenge's own
+type PublishChallengeTemplateReq struct {
+	Title       string `json:"title"       form:"max=100"`
+	Description string `json:"description" form:"max=1000"`
+}
+
+type ScoredChallengeTemplateRes struct {
+	db.ChallengeTemplate
+	Score float64 `json:"score"`
+}
+
+func PublishChallengeTemplate(w http.ResponseWriter, r *http.Request) {
+	log.Info().Msg("POST publish challenge template")
+
+	// get ids from url parameters
+	vars := mux.Vars(r)
+	userID := vars["user_id"]
+	challengeID := vars["challenge_id"]
+
+	// check the challenge exists
+	challenge, err := db.ReadChallengeById(challengeID)
+	if err != nil {
+		log.Error().Msg("Challenge does not exist")
+		api_error.Write(w, r, http.StatusNotFound, api_error.NOT_FOUND, "Challenge does not exist.")
+		return
+	}
+
+	// only members of the group that ran the challenge may publish it
+	if !db.UserInGroup(challenge.GroupID.Hex(), userID) {
+		log.Error().Msg("User is not in the challenge group")
+		api_error.Write(w, r, http.StatusForbidden, api_error.FORBIDDEN, "User is not in the challenge group.")
+		return
+	}
+
+	// only successful, finished challenges become templates
+	if challenge.Status != db.ChallengeStatusCompleted {
+		log.Error().Msg("Challenge is not completed")
+		api_error.Write(w, r, http.StatusConflict, api_error.CONFLICT, "Challenge is not completed.")
+		return
+	}
+	if len(challenge.Ratings) < db.MIN_TEMPLATE_RATING_COUNT || challenge.AverageRating() < db.MIN_TEMPLATE_RATING {
+		log.Error().Msg("Challenge is not rated well enough to publish")
+		api_error.Write(w, r, http.StatusConflict, api_error.CONFLICT, "Challenge is not rated well enough to publish.")
+		return
+	}
+	if db.ChallengeTemplateExistsForChallenge(challengeID) {
+		log.Error().Msg("Challenge has already been published")
+		api_error.Write(w, r, http.StatusConflict, api_error.CONFLICT, "Challenge has already been published.")
+		return
+	}
+
+	// the title and description may be reworded to remove anything identifying the group
+	req := PublishChallengeTemplateReq{}
+	if r.ContentLength != 0 {
+		if !decodeRequest(w, r, &req) {
+			return
+		}
+	}
+
+	template := db.NewChallengeTemplate(challenge, req.Title, req.Description)
+
+	// naming the group would tell who ran the challenge, the wording has to leave it out
+	groupName := db.ReadByGroupId(challenge.GroupID.Hex()).GroupName
+	fields := []validator.FieldError{}
+	if db.NamesGroup(template.Title, groupName) {
+		fields = append(fields, validator.FieldError{Field: "title", Message: "title must not name the group, reword it"})
+	}
+	if db.NamesGroup(template.Description, groupName) {
+		fields = append(fields, validator.FieldError{Field: "description", Message: "description must not name the group, reword it"})
+	}
+	if len(fields) > 0 {
+		log.Error().Msg("Challenge template names its group")
+		api_error.WriteError(w, r, http.StatusUnprocessableEntity, api_error.Error{
+			Code:    api_error.VALIDATION_FAILED,
+			Message: "The template names the group it came from.",
+			Fields:  fields,
+		})
+		return
+	}
+
+	_, err = db.CreateChallengeTemplate(template)
+	if errors.Is(err, db.ErrChallengeTemplateExists) {
+		log.Error().Msg("Challenge has already been published")
+		api_error.Write(w, r, http.StatusConflict, api_error.CONFLICT, "Challenge has already been published.")
+		return
+	}
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to publish challenge template")
+		api_error.Write(w, r, http.StatusInternalServerError, api_error.INTERNAL, "Failed to publish challenge template.")
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(template)
+}
+
+func GetChallengeTemplates(w http.ResponseWriter, r *http.Request) {
+	log.Info().Msg("GET challenge templates")
+
+	query := r.URL.Query()
+	metricType := query.Get("metric_type")
+	if metricType != "" && !slices.Contains(db.MetricTypes, metricType) {
+		log.Error().Msg("Invalid metric type")
+		api_error.Write(w, r, http.StatusBadRequest, api_error.BAD_REQUEST, "Invalid metric type.")
+		return
+	}
+
+	limit, offset, ok := getPagination(w, r, DEFAULT_TEMPLATE_PAGE_SIZE, MAX_TEMPLATE_PAGE_SIZE)
+	if !ok {
+		return
+	}
+
+	templates, err := db.SearchChallengeTemplates(query.Get("q"), metricType, offset, limit)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to search challenge templates")
+		api_error.Write(w, r, http.StatusInternalServerError, api_error.INTERNAL, "Failed to search challenge templates.")
+		return
+	}
+
+	json.NewEncoder(w).Encode(templates)
+}
+
+func GetChallengeTemplate(w http.ResponseWriter, r *http.Request) {
+	log.Info().Msg("GET challenge template")
+
+	vars := mux.Vars(r)
+	template, err := db.ReadChallengeTemplateById(vars["template_id"])
+	if err != nil {
+		log.Error().Msg("Challenge template does not exist")
+		api_error.Write(w, r, http.StatusNotFound, api_error.NOT_FOUND, "Challenge template does not exist.")
+		return
+	}
+
+	json.NewEncoder(w).Encode(template)
+}
+
+func GetRecommendedChallengeTemplates(w http.ResponseWriter, r *http.Request) {
+	log.Info().Msg("GET recommended challenge templates")
+
+	// get ids from url parameters
+	vars := mux.Vars(r)
+	userID := vars["user_id"]
+	groupID := vars["group_id"]
+
+	// check the user belongs to the group
+	if !db.GroupExists(groupID) {
+		log.Error().Msg("Group does not exist")
+		api_error.Write(w, r, http.StatusNotFound, api_error.GROUP_NOT_FOUND, "Group does not exist.")
+		return
+	}
+	if !db.UserInGroup(groupID, userID) {
+		log.Error().Msg("User is not in the group")
+		api_error.Write(w, r, http.StatusForbidden, api_error.NOT_GROUP_MEMBER, "User is not in the group.")
+		return
+	}
+
+	limit, _, ok := getPagination(w, r, DEFAULT_TEMPLATE_PAGE_SIZE, MAX_TEMPLATE_PAGE_SIZE)
+	if !ok {
+		return
+	}
+
+	// gather the group's history
+	members, err := db.GetGroupMembers(groupID)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to get group members")
+		api_error.Write(w, r, http.StatusInternalServerError, api_error.INTERNAL, "Failed to get group members.")
+		return
+	}
+	challenges, err := db.ReadChallengesByGroupId(groupID)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to get group challenges")
+		api_error.Write(w, r, http.StatusInternalServerError, api_error.INTERNAL, "Failed to get group challenges.")
+		return
+	}
+
+	history := recommender.GroupHistory{MemberCount: len(members)}
+	for _, challenge := range challenges {
+		if challenge.Status != db.ChallengeStatusCompleted {
+			continue
+		}
+		pc := recommender.PastChallenge{
+			MetricType:       challenge.MetricType,
+			ParticipantCount: len(challenge.Participants),
+			CompletionRate:   challenge.CompletionRate(),
+			Completed:        true,
+		}
+		if !challenge.TemplateID.IsZero() {
+			pc.TemplateID = challenge.TemplateID.Hex()
+		}
+		history.Challenges = append(history.Challenges, pc)
+	}
+
+	// rank candidate templates for the group
+	candidates, err := db.ReadRecommendationCandidates()
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to read challenge templates")
+		api_error.Write(w, r, http.StatusInternalServerError, api_error.INTERNAL, "Failed to read challenge templates.")
+		return
+	}
+
+	byID := make(map[string]db.ChallengeTemplate, len(candidates))
+	templates := make([]recommender.Template, 0, len(candidates))
+	for _, candidate := range candidates {
+		byID[candidate.ID.Hex()] = candidate
+		templates = append(templates, recommender.Template{
+			ID:               candidate.ID.Hex(),
+			MetricType:       candidate.MetricType,
+			AverageRating:    candidate.AverageRating,
+			RatingCount:      candidate.RatingCount,
+			ParticipantCount: candidate.ParticipantCount,
+			CompletionRate:   candidate.CompletionRate,
+			TimesUsed:        candidate.TimesUsed,
+		})
+	}
+
+	ranked := recommender.Rank(templates, history)
+	if int64(len(ranked)) > limit {
+		ranked = ranked[:limit]
+	}
+
+	res := make([]ScoredChallengeTemplateRes, 0, len(ranked))
+	for _, scored := range ranked {
+		res = append(res, ScoredChallengeTemplateRes{
+			ChallengeTemplate: byID[scored.Template.ID],
+			Score:             scored.Score,
+		})
+	}
+
+	json.NewEncoder(w).Encode(res)
+}
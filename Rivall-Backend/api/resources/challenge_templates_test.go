@@ -0,0 +1,54 @@
+package resources_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"Rivall-Backend/api/resources"
+	db "Rivall-Backend/db"
+	"Rivall-Backend/globals"
+	"Rivall-Backend/util/api_error"
+	"Rivall-Backend/util/test"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+func TestPublishTemplateMustNotNameGroup(t *testing.T) {
+	setupHandlers(t)
+	sam := createUser(t, "sam@example.com")
+	groupID, err := db.CreateGroup("Office Climbers", sam.ID.Hex())
+	test.NoError(t, err)
+	bsonGroupID, _ := bson.ObjectIDFromHex(groupID)
+
+	challenge := db.Challenge{
+		ID:           bson.NewObjectID(),
+		GroupID:      bsonGroupID,
+		Title:        "Office Climbers push-up month",
+		Description:  "A month of push-ups",
+		MetricType:   db.MetricTypeCount,
+		Goal:         100,
+		DurationDays: 30,
+		Status:       db.ChallengeStatusCompleted,
+		Ratings:      []db.ChallengeRating{{UserID: sam.ID, Score: 5}, {UserID: sam.ID, Score: 5}, {UserID: sam.ID, Score: 4}},
+	}
+	_, err = globals.MongoClient.Database(db.Database).Collection("Challenges").InsertOne(context.Background(), challenge)
+	test.NoError(t, err)
+
+	vars := map[string]string{"user_id": sam.ID.Hex(), "challenge_id": challenge.ID.Hex()}
+	w := serve(resources.PublishChallengeTemplate, http.MethodPost, "", sam.ID.Hex(), vars)
+	test.Equal(t, w.Code, http.StatusUnprocessableEntity)
+	res := api_error.Response{}
+	test.NoError(t, json.NewDecoder(w.Body).Decode(&res))
+	test.Equal(t, len(res.Error.Fields), 1)
+	test.Equal(t, res.Error.Fields[0].Field, "title")
+	test.Equal(t, db.ChallengeTemplateExistsForChallenge(challenge.ID.Hex()), false)
+
+	w = serve(resources.PublishChallengeTemplate, http.MethodPost, `{"title":"Push-up month"}`, sam.ID.Hex(), vars)
+	test.Equal(t, w.Code, http.StatusCreated)
+	template := db.ChallengeTemplate{}
+	test.NoError(t, json.NewDecoder(w.Body).Decode(&template))
+	test.Equal(t, template.Title, "Push-up month")
+	test.Equal(t, template.TimesUsed, 0)
+}
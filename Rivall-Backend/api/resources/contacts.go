@@ -1,7 +1,9 @@
 package resources
 
 import (
+	"Rivall-Backend/api/websocket"
 	db "Rivall-Backend/db"
+	"Rivall-Backend/util/api_error"
 	"encoding/json"
 	"net/http"
 
@@ -10,12 +12,14 @@ import (
 	"go.mongodb.org/mongo-driver/v2/bson"
 )
 
-type FullContact struct {
-	ID          bson.ObjectID `json:"_id" bson:"_id"`
-	FirstName   string        `json:"first_name" bson:"first_name"`
-	LastName    string        `json:"last_name" bson:"last_name"`
-	Email       string        `json:"email" bson:"email"`
-	AvatarImage string        `json:"avatar_image" bson:"avatar_image"`
+type NewContactReq struct {
+	ContactID string `json:"contact_id" form:"required,len=24,hexadecimal"`
+}
+
+// ChatRes is a direct message conversation, group_members is keyed by user id
+type ChatRes struct {
+	GroupMembers map[string]ContactUserRes `json:"group_members"`
+	Messages     []db.Message              `json:"messages"`
 }
 
 func GetContact(w http.ResponseWriter, r *http.Request) {
@@ -23,24 +27,18 @@ func GetContact(w http.ResponseWriter, r *http.Request) {
 	userID := vars["user_id"]
 	log.Debug().Msgf("User ID: %s", userID)
 
-	// check user exists
+	// check user exists, accounts can't be found until their email is verified or when
+	// they chose not to be
 	user := db.ReadByUserId(userID)
-	if user.ID == bson.NilObjectID {
+	if user.ID == bson.NilObjectID || !user.EmailVerified || !user.CurrentSettings().Discoverable {
 		log.Error().Msg("User does not exist")
-		w.WriteHeader(http.StatusBadRequest)
-		w.Write([]byte("User does not exist."))
+		api_error.Write(w, r, http.StatusBadRequest, api_error.USER_NOT_FOUND, "User does not exist.")
 		return
 	}
 
-	var contact FullContact
-	contact.ID = user.ID
-	contact.FirstName = user.FirstName
-	contact.LastName = user.LastName
-	contact.Email = user.Email
-	contact.AvatarImage = user.AvatarImage
-
+	// anyone can look a user up, so only their public view is returned
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(contact)
+	json.NewEncoder(w).Encode(newPublicUserRes(user))
 }
 
 func PostUserContact(w http.ResponseWriter, r *http.Request) {
@@ -51,29 +49,29 @@ func PostUserContact(w http.ResponseWriter, r *http.Request) {
 	userID := vars["user_id"]
 
 	// Check the user exists
-	if db.ReadByUserId(userID).ID == bson.NilObjectID {
+	owner := db.ReadByUserId(userID)
+	if owner.ID == bson.NilObjectID {
 		log.Error().Msg("User does not exist")
-		w.WriteHeader(http.StatusBadRequest)
-		w.Write([]byte("User does not exist."))
+		api_error.Write(w, r, http.StatusBadRequest, api_error.USER_NOT_FOUND, "User does not exist.")
+		return
+	}
+	if !owner.EmailVerified {
+		writeEmailNotVerified(w, r)
 		return
 	}
 
 	// get contact id from content body
-	vars = make(map[string]string)
-	err := json.NewDecoder(r.Body).Decode(&vars)
-	if err != nil {
-		log.Error().Err(err).Msg("Failed to decode contact ID, invalid JSON request")
-		w.WriteHeader(http.StatusBadRequest)
-		w.Write([]byte("Failed to decode contact ID, invalid JSON request."))
+	req := NewContactReq{}
+	if !decodeRequest(w, r, &req) {
 		return
 	}
-	contactID := vars["contact_id"]
+	contactID := req.ContactID
 
-	// check the contact exists
-	if db.ReadByUserId(contactID).ID == bson.NilObjectID {
+	// check the contact exists and can be found
+	contact := db.ReadByUserId(contactID)
+	if contact.ID == bson.NilObjectID || !contact.EmailVerified {
 		log.Error().Msg("Contact does not exist")
-		w.WriteHeader(http.StatusBadRequest)
-		w.Write([]byte("Contact does not exist."))
+		api_error.Write(w, r, http.StatusBadRequest, api_error.USER_NOT_FOUND, "Contact does not exist.")
 		return
 	}
 
@@ -81,32 +79,40 @@ func PostUserContact(w http.ResponseWriter, r *http.Request) {
 	user := db.ReadByUserIdWithPopulatedFields(userID)
 	if user.ID == bson.NilObjectID {
 		log.Error().Msg("User does not exist")
-		w.WriteHeader(http.StatusBadRequest)
-		w.Write([]byte("User does not exist."))
+		api_error.Write(w, r, http.StatusBadRequest, api_error.USER_NOT_FOUND, "User does not exist.")
 		return
 	}
 	contacts := user.Contacts
 	for _, contact := range contacts {
 		if contact.ContactID.Hex() == contactID {
 			log.Error().Msg("User already has this contact")
-			w.WriteHeader(http.StatusBadRequest)
-			w.Write([]byte("User already has this contact."))
+			api_error.Write(w, r, http.StatusBadRequest, api_error.BAD_REQUEST, "User already has this contact.")
 			return
 		}
 	}
 
+	// check the contact accepts requests from the user
+	if !contact.Allows(contact.CurrentSettings().ContactRequests, owner) {
+		log.Error().Msg("Contact does not accept contact requests from the user")
+		api_error.Write(w, r, http.StatusForbidden, api_error.FORBIDDEN, "This user does not accept contact requests from you.")
+		return
+	}
+
 	// create user contact with new contact
-	err = db.CreateContact(userID, contactID)
+	err := db.CreateContact(userID, contactID)
 	if err != nil {
 		log.Error().Err(err).Msg("Failed to set user contact")
-		w.WriteHeader(http.StatusInternalServerError)
-		w.Write([]byte("Failed to set user contact."))
+		api_error.Write(w, r, http.StatusInternalServerError, api_error.INTERNAL, "Failed to set user contact.")
 		return
 	}
 
-	w.WriteHeader(http.StatusCreated)
-	w.Write([]byte("Contact added successfully."))
+	websocket.WSManager.NotifyOffline(contact, contact.CurrentSettings().Notifications.NewContacts,
+		owner.FirstName+" "+owner.LastName+" added you on Rivall",
+		owner.FirstName+" "+owner.LastName+" added you as a contact, you can now message each other.")
+
 	log.Info().Msg("Contact added successfully.")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(newContactUserRes(contact))
 }
 
 func GetChat(w http.ResponseWriter, r *http.Request) {
@@ -119,8 +125,7 @@ func GetChat(w http.ResponseWriter, r *http.Request) {
 	// Check the user exists
 	if db.ReadByUserId(userID).ID == bson.NilObjectID {
 		log.Error().Msg("User does not exist")
-		w.WriteHeader(http.StatusBadRequest)
-		w.Write([]byte("User does not exist."))
+		api_error.Write(w, r, http.StatusBadRequest, api_error.USER_NOT_FOUND, "User does not exist.")
 		return
 	}
 
@@ -131,52 +136,25 @@ func GetChat(w http.ResponseWriter, r *http.Request) {
 	dm, err := db.ReadDirectMessages(chatID)
 	if err != nil {
 		log.Error().Msg("Contact does not exist")
-		w.WriteHeader(http.StatusBadRequest)
-		w.Write([]byte("Contact does not exist."))
+		api_error.Write(w, r, http.StatusBadRequest, api_error.USER_NOT_FOUND, "Contact does not exist.")
 		return
 	}
 
 	// check if user is in the direct message group
 	if !db.UserInDirectMessage(chatID, userID) {
 		log.Error().Msg("User is not in the direct message group")
-		w.WriteHeader(http.StatusBadRequest)
-		w.Write([]byte("User is not in the direct message group."))
+		api_error.Write(w, r, http.StatusBadRequest, api_error.BAD_REQUEST, "User is not in the direct message group.")
 		return
 	}
 
-	type ChatUser struct {
-		ID          bson.ObjectID `json:"_id" bson:"_id"`
-		FirstName   string        `json:"first_name" bson:"first_name"`
-		LastName    string        `json:"last_name" bson:"last_name"`
-		Email       string        `json:"email" bson:"email"`
-		AvatarImage string        `json:"avatar_image" bson:"avatar_image"`
-	}
-
-	type messageData struct {
-		GroupMembers map[string]ChatUser `json:"group_members"`
-		Messages     []db.Message        `json:"messages"`
-	}
-
 	var userA = db.ReadByUserId(dm.UserAID.Hex())
 	var userB = db.ReadByUserId(dm.UserBID.Hex())
 
-	var data messageData
-	data.GroupMembers = make(map[string]ChatUser)
-	data.GroupMembers[userA.ID.Hex()] = ChatUser{
-		ID:          userA.ID,
-		FirstName:   userA.FirstName,
-		LastName:    userA.LastName,
-		Email:       userA.Email,
-		AvatarImage: userA.AvatarImage,
-	}
-	data.GroupMembers[userB.ID.Hex()] = ChatUser{
-		ID:          userB.ID,
-		FirstName:   userB.FirstName,
-		LastName:    userB.LastName,
-		Email:       userB.Email,
-		AvatarImage: userB.AvatarImage,
-	}
-	data.Messages = dm.Messages
+	var data ChatRes
+	data.GroupMembers = make(map[string]ContactUserRes)
+	data.GroupMembers[userA.ID.Hex()] = newContactUserRes(userA)
+	data.GroupMembers[userB.ID.Hex()] = newContactUserRes(userB)
+	data.Messages = db.HideReadReceipts(dm.Messages, userID)
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(data)
 	w.WriteHeader(http.StatusOK)
@@ -0,0 +1,355 @@
+package resources
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	db "Rivall-Backend/db"
+	"Rivall-Backend/globals"
+	"Rivall-Backend/util/api_error"
+	"Rivall-Backend/util/data_export"
+	"Rivall-Backend/util/mailer"
+
+	"github.com/gorilla/mux"
+	"github.com/rs/zerolog/log"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// DATA_EXPORT_POLL_INTERVAL is how often workers look for exports queued on other replicas
+const DATA_EXPORT_POLL_INTERVAL = time.Minute
+
+// DATA_EXPORT_RETENTION is how long a finished export can be downloaded
+const DATA_EXPORT_RETENTION = time.Hour * 24 * 7
+
+// DATA_EXPORT_STALE_AFTER hands an export to another worker when its worker stopped
+const DATA_EXPORT_STALE_AFTER = time.Minute * 30
+
+// dataExportRequested wakes this replica's worker when an export is queued here
+var dataExportRequested = make(chan struct{}, 1)
+
+type exportProfile struct {
+	ID                  string          `json:"_id"`
+	FirstName           string          `json:"first_name"`
+	LastName            string          `json:"last_name"`
+	Email               string          `json:"email"`
+	EmailVerified       bool            `json:"email_verified"`
+	PendingEmail        string          `json:"pending_email,omitempty"`
+	AvatarImage         string          `json:"avatar_image"`
+	Bio                 string          `json:"bio"`
+	TwoFactorEnabled    bool            `json:"two_factor_enabled"`
+	Identities          []db.Identity   `json:"identities"`
+	DeletionScheduledAt *time.Time      `json:"deletion_scheduled_at,omitempty"`
+	Settings            db.UserSettings `json:"settings"`
+}
+
+type exportContact struct {
+	ContactID       string `json:"contact_id"`
+	DirectMessageID string `json:"direct_message_id"`
+	FirstName       string `json:"first_name"`
+	LastName        string `json:"last_name"`
+}
+
+type exportDirectMessages struct {
+	DirectMessageID string       `json:"direct_message_id"`
+	ContactID       string       `json:"contact_id"`
+	Messages        []db.Message `json:"messages"`
+}
+
+type exportGroupMessages struct {
+	GroupID   string       `json:"group_id"`
+	GroupName string       `json:"group_name"`
+	Messages  []db.Message `json:"messages"`
+}
+
+type exportGroup struct {
+	GroupID     string `json:"group_id"`
+	GroupName   string `json:"group_name"`
+	Role        string `json:"role"`
+	MemberCount int    `json:"member_count"`
+}
+
+// exportChallenge keeps only the user's own progress and rating, not the other participants'
+type exportChallenge struct {
+	ChallengeID   string                   `json:"challenge_id"`
+	GroupID       string                   `json:"group_id"`
+	Title         string                   `json:"title"`
+	Description   string                   `json:"description"`
+	MetricType    string                   `json:"metric_type"`
+	MetricUnit    string                   `json:"metric_unit"`
+	Goal          float64                  `json:"goal"`
+	Status        string                   `json:"status"`
+	StartDate     time.Time                `json:"start_date"`
+	EndDate       time.Time                `json:"end_date"`
+	Creator       bool                     `json:"creator"`
+	Participation *db.ChallengeParticipant `json:"participation,omitempty"`
+	Rating        *db.ChallengeRating      `json:"rating,omitempty"`
+}
+
+// collectDataExport gathers everything held about a user into the files of an export
+func collectDataExport(userID string) ([]data_export.File, error) {
+	user := db.ReadByUserId(userID)
+	if user.ID == bson.NilObjectID {
+		return nil, db.ErrUserNotFound
+	}
+
+	profile := exportProfile{
+		ID:                  user.ID.Hex(),
+		FirstName:           user.FirstName,
+		LastName:            user.LastName,
+		Email:               user.Email,
+		EmailVerified:       user.EmailVerified,
+		PendingEmail:        user.PendingEmail,
+		AvatarImage:         user.AvatarImage,
+		Bio:                 user.Bio,
+		TwoFactorEnabled:    user.TwoFactor.Enabled,
+		Identities:          user.Identities,
+		DeletionScheduledAt: user.DeletionScheduledAt,
+		Settings:            user.CurrentSettings(),
+	}
+
+	contacts := []exportContact{}
+	for _, contact := range user.Contacts {
+		other := db.ReadByUserId(contact.ContactID.Hex())
+		contacts = append(contacts, exportContact{
+			ContactID:       contact.ContactID.Hex(),
+			DirectMessageID: contact.DirectMessageID.Hex(),
+			FirstName:       other.FirstName,
+			LastName:        other.LastName,
+		})
+	}
+
+	sentDirect, err := db.ReadSentDirectMessages(userID)
+	if err != nil {
+		return nil, err
+	}
+	directMessages := []exportDirectMessages{}
+	for _, line := range sentDirect {
+		contactID := line.UserAID
+		if contactID == user.ID {
+			contactID = line.UserBID
+		}
+		directMessages = append(directMessages, exportDirectMessages{
+			DirectMessageID: line.ID.Hex(),
+			ContactID:       contactID.Hex(),
+			Messages:        line.Messages,
+		})
+	}
+
+	sentGroup, err := db.ReadSentGroupMessages(userID)
+	if err != nil {
+		return nil, err
+	}
+	groupMessages := []exportGroupMessages{}
+	for _, group := range sentGroup {
+		groupMessages = append(groupMessages, exportGroupMessages{
+			GroupID:   group.ID.Hex(),
+			GroupName: group.GroupName,
+			Messages:  group.Messages,
+		})
+	}
+
+	userGroups, err := db.ReadGroupsByUserId(userID)
+	if err != nil {
+		return nil, err
+	}
+	groups := []exportGroup{}
+	for _, group := range userGroups {
+		groups = append(groups, exportGroup{
+			GroupID:     group.ID.Hex(),
+			GroupName:   group.GroupName,
+			Role:        group.Role(userID),
+			MemberCount: len(group.GroupMembers),
+		})
+	}
+
+	userChallenges, err := db.ReadChallengesByUserId(userID)
+	if err != nil {
+		return nil, err
+	}
+	challenges := []exportChallenge{}
+	for _, challenge := range userChallenges {
+		entry := exportChallenge{
+			ChallengeID: challenge.ID.Hex(),
+			GroupID:     challenge.GroupID.Hex(),
+			Title:       challenge.Title,
+			Description: challenge.Description,
+			MetricType:  challenge.MetricType,
+			MetricUnit:  challenge.MetricUnit,
+			Goal:        challenge.Goal,
+			Status:      challenge.Status,
+			StartDate:   challenge.StartDate,
+			EndDate:     challenge.EndDate,
+			Creator:     challenge.CreatorID == user.ID,
+		}
+		for _, participant := range challenge.Participants {
+			if participant.UserID == user.ID {
+				entry.Participation = &participant
+			}
+		}
+		for _, rating := range challenge.Ratings {
+			if rating.UserID == user.ID {
+				entry.Rating = &rating
+			}
+		}
+		challenges = append(challenges, entry)
+	}
+
+	sessions, err := globals.SessionManager.ListDeviceSessions(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return []data_export.File{
+		{Name: "profile.json", Description: "Your account details and settings", Records: 1, Data: profile},
+		{Name: "contacts.json", Description: "Your contacts", Records: len(contacts), Data: contacts},
+		{Name: "direct_messages.json", Description: "Direct messages you sent, by conversation", Records: len(directMessages), Data: directMessages},
+		{Name: "group_messages.json", Description: "Group messages you sent, by group", Records: len(groupMessages), Data: groupMessages},
+		{Name: "groups.json", Description: "Groups you are a member of and your role in them", Records: len(groups), Data: groups},
+		{Name: "challenges.json", Description: "Challenges you created or took part in, with your progress and rating", Records: len(challenges), Data: challenges},
+		{Name: "sessions.json", Description: "Devices you are logged in on", Records: len(sessions), Data: sessions},
+	}, nil
+}
+
+func buildDataExport(export db.DataExport) error {
+	userID := export.UserID.Hex()
+	files, err := collectDataExport(userID)
+	if err != nil {
+		return err
+	}
+
+	var archive bytes.Buffer
+	if _, err := data_export.Write(&archive, userID, time.Now(), files); err != nil {
+		return err
+	}
+	if err := db.CompleteDataExport(export, &archive, time.Now().Add(DATA_EXPORT_RETENTION)); err != nil {
+		return err
+	}
+
+	user := db.ReadByUserId(userID)
+	err = globals.Mailer.Send(user.Email, mailer.TEMPLATE_NOTIFICATION, mailer.NotificationData{
+		FirstName: user.FirstName,
+		Title:     "Your Rivall data export is ready",
+		Body:      "The copy of your Rivall data you asked for is ready. Download it from the app within 7 days.",
+	})
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to queue data export notification")
+	}
+	return nil
+}
+
+// runDataExports builds every waiting export, one at a time
+func runDataExports() {
+	for {
+		export, ok, err := db.ClaimDataExport(time.Now(), DATA_EXPORT_STALE_AFTER)
+		if err != nil || !ok {
+			return
+		}
+
+		if err := buildDataExport(export); err != nil {
+			log.Error().Err(err).Msgf("Failed to build data export %s", export.ID.Hex())
+			db.FailDataExport(export, "The export could not be built, request a new one.")
+		}
+	}
+}
+
+// RunDataExports builds queued exports in the background until ctx is done
+func RunDataExports(ctx context.Context) {
+	ticker := time.NewTicker(DATA_EXPORT_POLL_INTERVAL)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := db.DeleteExpiredDataExports(time.Now()); err != nil {
+				log.Error().Err(err).Msg("Failed to delete expired data exports")
+			}
+			runDataExports()
+		case <-dataExportRequested:
+			runDataExports()
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func writeDataExportError(w http.ResponseWriter, r *http.Request, err error) {
+	if errors.Is(err, db.ErrDataExportNotFound) {
+		api_error.Write(w, r, http.StatusNotFound, api_error.NOT_FOUND, "Data export not found")
+		return
+	}
+	api_error.Write(w, r, http.StatusInternalServerError, api_error.INTERNAL, "Failed to read data export")
+}
+
+func RequestDataExport(w http.ResponseWriter, r *http.Request) {
+	log.Info().Msg("POST data export")
+
+	export, err := db.CreateDataExport(mux.Vars(r)["user_id"])
+	if errors.Is(err, db.ErrDataExportInProgress) {
+		api_error.Write(w, r, http.StatusConflict, api_error.CONFLICT, "A data export is already in progress.")
+		return
+	}
+	if err != nil {
+		api_error.Write(w, r, http.StatusInternalServerError, api_error.INTERNAL, "Failed to request data export")
+		return
+	}
+
+	// wake the worker without waiting on it
+	select {
+	case dataExportRequested <- struct{}{}:
+	default:
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(export)
+}
+
+func GetDataExport(w http.ResponseWriter, r *http.Request) {
+	log.Info().Msg("GET data export")
+
+	vars := mux.Vars(r)
+	export, err := db.ReadDataExport(vars["user_id"], vars["export_id"])
+	if err != nil {
+		writeDataExportError(w, r, err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(export)
+}
+
+func DownloadDataExport(w http.ResponseWriter, r *http.Request) {
+	log.Info().Msg("GET data export download")
+
+	vars := mux.Vars(r)
+	export, err := db.ReadDataExport(vars["user_id"], vars["export_id"])
+	if err != nil {
+		writeDataExportError(w, r, err)
+		return
+	}
+	if export.Status != db.DATA_EXPORT_READY {
+		api_error.Write(w, r, http.StatusConflict, api_error.CONFLICT, "Data export is not ready.")
+		return
+	}
+	if export.ExpiresAt != nil && export.ExpiresAt.Before(time.Now()) {
+		api_error.Write(w, r, http.StatusGone, api_error.GONE, "Data export has expired, request a new one.")
+		return
+	}
+
+	archive, err := db.OpenDataExportArchive(export)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to open data export archive")
+		api_error.Write(w, r, http.StatusInternalServerError, api_error.INTERNAL, "Failed to read data export")
+		return
+	}
+	defer archive.Close()
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", `attachment; filename="rivall-export-`+export.ID.Hex()+`.zip"`)
+	w.WriteHeader(http.StatusOK)
+	if _, err := io.Copy(w, archive); err != nil {
+		log.Error().Err(err).Msg("Failed to send data export archive")
+	}
+}
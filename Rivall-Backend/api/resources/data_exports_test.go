@@ -0,0 +1,132 @@
+package resources_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"Rivall-Backend/api/resources"
+	db "Rivall-Backend/db"
+	"Rivall-Backend/util/test"
+)
+
+func TestOneDataExportAtATime(t *testing.T) {
+	setupHandlers(t)
+	test.NoError(t, db.Migrate(context.Background()))
+	sam := createUser(t, "sam@example.com").ID.Hex()
+	vars := map[string]string{"user_id": sam}
+
+	// requests racing each other still queue a single export
+	var wg sync.WaitGroup
+	codes := make(chan int, 5)
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			codes <- serve(resources.RequestDataExport, http.MethodPost, "", sam, vars).Code
+		}()
+	}
+	wg.Wait()
+	close(codes)
+
+	accepted := 0
+	for code := range codes {
+		if code == http.StatusAccepted {
+			accepted++
+			continue
+		}
+		test.Equal(t, code, http.StatusConflict)
+	}
+	test.Equal(t, accepted, 1)
+
+	// once it finished another can be requested
+	export, ok, err := db.ClaimDataExport(time.Now(), time.Hour)
+	test.NoError(t, err)
+	test.Equal(t, ok, true)
+	test.NoError(t, db.FailDataExport(export, "failed"))
+	w := serve(resources.RequestDataExport, http.MethodPost, "", sam, vars)
+	test.Equal(t, w.Code, http.StatusAccepted)
+}
+
+func TestDataExportOnlyReachableByItsUser(t *testing.T) {
+	setupHandlers(t)
+	sam := createUser(t, "sam@example.com").ID.Hex()
+	alex := createUser(t, "alex@example.com").ID.Hex()
+
+	export, err := db.CreateDataExport(sam)
+	test.NoError(t, err)
+	test.NoError(t, db.CompleteDataExport(export, strings.NewReader("archive"), time.Now().Add(time.Hour)))
+
+	// alex may only use paths under their own user, but knowing the export's ID isn't enough
+	vars := map[string]string{"user_id": alex, "export_id": export.ID.Hex()}
+	w := serve(resources.DownloadDataExport, http.MethodGet, "", alex, vars)
+	test.Equal(t, w.Code, http.StatusNotFound)
+	w = serve(resources.GetDataExport, http.MethodGet, "", alex, vars)
+	test.Equal(t, w.Code, http.StatusNotFound)
+
+	vars["user_id"] = sam
+	w = serve(resources.DownloadDataExport, http.MethodGet, "", sam, vars)
+	test.Equal(t, w.Code, http.StatusOK)
+	test.Equal(t, w.Body.String(), "archive")
+}
+
+func TestDataExportHoldsProfile(t *testing.T) {
+	setupHandlers(t)
+	sam := createUser(t, "sam@example.com").ID.Hex()
+	vars := map[string]string{"user_id": sam}
+
+	body := `{"bio":"Climbing every weekend","avatar_image":"https://example.com/sam.png"}`
+	w := serve(resources.UpdateUser, http.MethodPatch, body, sam, vars)
+	test.Equal(t, w.Code, http.StatusOK)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan struct{})
+	go func() {
+		resources.RunDataExports(ctx)
+		close(stopped)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-stopped
+	})
+
+	w = serve(resources.RequestDataExport, http.MethodPost, "", sam, vars)
+	test.Equal(t, w.Code, http.StatusAccepted)
+	export := db.DataExport{}
+	test.NoError(t, json.NewDecoder(w.Body).Decode(&export))
+	vars["export_id"] = export.ID.Hex()
+
+	for deadline := time.Now().Add(time.Second * 5); export.Status != db.DATA_EXPORT_READY; {
+		if export.Status == db.DATA_EXPORT_FAILED || time.Now().After(deadline) {
+			t.Fatalf("Expected the export to be ready, it is %s", export.Status)
+		}
+		time.Sleep(time.Millisecond * 20)
+		w = serve(resources.GetDataExport, http.MethodGet, "", sam, vars)
+		test.Equal(t, w.Code, http.StatusOK)
+		test.NoError(t, json.NewDecoder(w.Body).Decode(&export))
+	}
+
+	w = serve(resources.DownloadDataExport, http.MethodGet, "", sam, vars)
+	test.Equal(t, w.Code, http.StatusOK)
+	archive, err := zip.NewReader(bytes.NewReader(w.Body.Bytes()), int64(w.Body.Len()))
+	test.NoError(t, err)
+	file, err := archive.Open("profile.json")
+	test.NoError(t, err)
+	defer file.Close()
+
+	profile := struct {
+		Email       string `json:"email"`
+		Bio         string `json:"bio"`
+		AvatarImage string `json:"avatar_image"`
+	}{}
+	test.NoError(t, json.NewDecoder(file).Decode(&profile))
+	test.Equal(t, profile.Email, "sam@example.com")
+	test.Equal(t, profile.Bio, "Climbing every weekend")
+	test.Equal(t, profile.AvatarImage, "https://example.com/sam.png")
+}
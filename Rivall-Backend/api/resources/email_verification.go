@@ -0,0 +1,224 @@
+package resources
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	db "Rivall-Backend/db"
+	"Rivall-Backend/globals"
+	"Rivall-Backend/util/api_error"
+	"Rivall-Backend/util/mailer"
+	"Rivall-Backend/util/otp"
+
+	"github.com/gorilla/mux"
+	"github.com/rs/zerolog/log"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+type VerifyEmailReq struct {
+	Email string `json:"email" form:"required,email,max=254"`
+	Code  string `json:"code"  form:"required,max=64"`
+}
+
+type ResendVerificationReq struct {
+	Email string `json:"email" form:"required,email,max=254"`
+}
+
+type ChangeEmailReq struct {
+	Email    string `json:"email" form:"required,email,max=254"`
+	Password string `json:"password"`
+	// EmailCode confirms the change instead of Password for users without one
+	EmailCode string `json:"email_code" form:"max=64"`
+	// Code is an authenticator or recovery code, needed with two-factor authentication
+	Code string `json:"code" form:"max=64"`
+}
+
+type ConfirmEmailChangeReq struct {
+	Code string `json:"code" form:"required,max=64"`
+}
+
+func writeEmailNotVerified(w http.ResponseWriter, r *http.Request) {
+	log.Warn().Msg("Email address is not verified")
+	api_error.Write(w, r, http.StatusForbidden, api_error.EMAIL_NOT_VERIFIED, "Email address must be verified first.")
+}
+
+func writeOTPError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, otp.ErrLockedOut):
+		log.Warn().Msg("Code locked out")
+		api_error.Write(w, r, http.StatusTooManyRequests, api_error.RATE_LIMITED, "Too many failed attempts, try again later")
+	case errors.Is(err, otp.ErrTooSoon):
+		log.Warn().Msg("Code requested too soon")
+		api_error.Write(w, r, http.StatusTooManyRequests, api_error.RATE_LIMITED, "A code was sent recently, try again later")
+	case errors.Is(err, otp.ErrInvalidCode):
+		log.Error().Msg("Invalid code")
+		api_error.Write(w, r, http.StatusUnauthorized, api_error.INVALID_CODE, "Invalid code")
+	default:
+		log.Error().Err(err).Msg("Failed to create code")
+		api_error.Write(w, r, http.StatusInternalServerError, api_error.INTERNAL, "Failed to create code")
+	}
+}
+
+// sendVerificationCode mails a code proving user owns address, which is their email or
+// the one they are changing to
+func sendVerificationCode(user db.User, purpose string, address string) error {
+	code, _, err := globals.OTP.Issue(purpose, address)
+	if err != nil {
+		return err
+	}
+
+	return globals.Mailer.Send(address, mailer.TEMPLATE_EMAIL_VERIFICATION, mailer.EmailVerificationData{
+		FirstName:        user.FirstName,
+		Code:             code,
+		ExpiresInMinutes: int(globals.OTP.CodeTimeout().Minutes()),
+	})
+}
+
+func VerifyEmail(w http.ResponseWriter, r *http.Request) {
+	log.Info().Msg("POST verify email")
+
+	req := VerifyEmailReq{}
+	if !decodeRequest(w, r, &req) {
+		return
+	}
+	email := strings.ToLower(req.Email)
+
+	if !checkRateLimit(w, r, globals.VerificationLimiter, email) {
+		return
+	}
+	if err := globals.OTP.Verify(otp.PURPOSE_EMAIL_VERIFICATION, email, clientIP(r), req.Code); err != nil {
+		failRateLimit(r, globals.VerificationLimiter, email)
+		writeOTPError(w, r, err)
+		return
+	}
+
+	if err := db.VerifyUserEmail(email); err != nil {
+		if errors.Is(err, db.ErrUserNotFound) {
+			api_error.Write(w, r, http.StatusNotFound, api_error.USER_NOT_FOUND, "User not found")
+			return
+		}
+		api_error.Write(w, r, http.StatusInternalServerError, api_error.INTERNAL, "Failed to verify email")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func ResendVerificationEmail(w http.ResponseWriter, r *http.Request) {
+	log.Info().Msg("POST resend verification email")
+
+	req := ResendVerificationReq{}
+	if !decodeRequest(w, r, &req) {
+		return
+	}
+	email := strings.ToLower(req.Email)
+
+	// every code sent counts, verification mail must not be usable to flood an inbox
+	if !checkRateLimit(w, r, globals.VerificationLimiter, email) {
+		return
+	}
+	failRateLimit(r, globals.VerificationLimiter, email)
+
+	user := db.ReadByUserEmail(email)
+	if user.ID == bson.NilObjectID || user.EmailVerified {
+		log.Warn().Msg("No unverified user with this email")
+	} else if err := sendVerificationCode(user, otp.PURPOSE_EMAIL_VERIFICATION, user.Email); err != nil {
+		log.Error().Err(err).Msg("Failed to send verification email")
+	}
+
+	// the answer is the same whether or not the email belongs to an unverified account, so
+	// it can't be used to find out who has one
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func ChangeUserEmail(w http.ResponseWriter, r *http.Request) {
+	log.Info().Msg("PUT user email")
+
+	userID := mux.Vars(r)["user_id"]
+	user := db.ReadByUserId(userID)
+	if user.ID == bson.NilObjectID {
+		log.Error().Msg("User does not exist")
+		api_error.Write(w, r, http.StatusBadRequest, api_error.USER_NOT_FOUND, "User does not exist.")
+		return
+	}
+
+	req := ChangeEmailReq{}
+	if !decodeRequest(w, r, &req) {
+		return
+	}
+
+	// with a stolen access token alone the email could be moved to an address that then
+	// recovers the account
+	if !confirmIdentity(w, r, user, req.Password, req.EmailCode) {
+		return
+	}
+	if !confirmSecondFactor(w, r, user, req.Code) {
+		return
+	}
+
+	email := strings.ToLower(req.Email)
+	if db.ReadByUserEmail(email).ID != bson.NilObjectID {
+		api_error.Write(w, r, http.StatusConflict, api_error.EMAIL_TAKEN, "Email is already in use.")
+		return
+	}
+
+	// the current email stays in use until the new one is verified
+	if err := db.SetUserPendingEmail(userID, email); err != nil {
+		api_error.Write(w, r, http.StatusInternalServerError, api_error.INTERNAL, "Failed to change email")
+		return
+	}
+	if err := sendVerificationCode(user, otp.PURPOSE_EMAIL_CHANGE, email); err != nil {
+		writeOTPError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func ConfirmUserEmailChange(w http.ResponseWriter, r *http.Request) {
+	log.Info().Msg("POST confirm user email change")
+
+	userID := mux.Vars(r)["user_id"]
+	user := db.ReadByUserId(userID)
+	if user.ID == bson.NilObjectID {
+		log.Error().Msg("User does not exist")
+		api_error.Write(w, r, http.StatusBadRequest, api_error.USER_NOT_FOUND, "User does not exist.")
+		return
+	}
+	if user.PendingEmail == "" {
+		api_error.Write(w, r, http.StatusNotFound, api_error.NOT_FOUND, "No email change is pending.")
+		return
+	}
+
+	req := ConfirmEmailChangeReq{}
+	if !decodeRequest(w, r, &req) {
+		return
+	}
+
+	if err := globals.OTP.Verify(otp.PURPOSE_EMAIL_CHANGE, user.PendingEmail, clientIP(r), req.Code); err != nil {
+		writeOTPError(w, r, err)
+		return
+	}
+
+	if err := db.SwapUserPendingEmail(userID, user.PendingEmail); err != nil {
+		if errors.Is(err, db.ErrEmailTaken) {
+			api_error.Write(w, r, http.StatusConflict, api_error.EMAIL_TAKEN, "Email is already in use.")
+			return
+		}
+		api_error.Write(w, r, http.StatusInternalServerError, api_error.INTERNAL, "Failed to change email")
+		return
+	}
+
+	// let the old address know in case the change wasn't theirs
+	err := globals.Mailer.Send(user.Email, mailer.TEMPLATE_NOTIFICATION, mailer.NotificationData{
+		FirstName: user.FirstName,
+		Title:     "Your Rivall email was changed",
+		Body:      "The email address on your Rivall account was changed to " + user.PendingEmail + ". If this wasn't you, recover your account or contact support.",
+	})
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to queue email change notification")
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,160 @@
+package resources_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"Rivall-Backend/api/resources"
+	db "Rivall-Backend/db"
+	"Rivall-Backend/globals"
+	"Rivall-Backend/util/otp"
+	"Rivall-Backend/util/rate_limiter"
+	"Rivall-Backend/util/test"
+	"Rivall-Backend/util/totp"
+	"Rivall-Backend/util/validator"
+)
+
+func TestRotatedForwardedForIsLockedOut(t *testing.T) {
+	globals.Validator = validator.New()
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	config := otp.DefaultConfig()
+	config.MaxIPAttempts = 3
+	codes, err := otp.New(config, otp.NewMemoryStore(ctx), []byte(OTP_SECRET))
+	test.NoError(t, err)
+	globals.OTP = codes
+	// only the code lockout is under test
+	globals.VerificationLimiter = rate_limiter.New(rate_limiter.NewMemoryStore(ctx), "verification", rate_limiter.Policy{}, rate_limiter.Policy{}, nil)
+
+	// every guess claims another address and targets another email, only the IP is shared
+	for i := 0; i <= config.MaxIPAttempts; i++ {
+		body := fmt.Sprintf(`{"email":"sam%d@example.com","code":"AAAAAAAA"}`, i)
+		r := httptest.NewRequest(http.MethodPost, "/api/v1/auth/verify-email", strings.NewReader(body))
+		r.Header.Set("X-Forwarded-For", fmt.Sprintf("203.0.113.%d", i))
+		w := httptest.NewRecorder()
+		resources.VerifyEmail(w, r)
+
+		if i < config.MaxIPAttempts {
+			test.Equal(t, w.Code, http.StatusUnauthorized)
+		} else {
+			test.Equal(t, w.Code, http.StatusTooManyRequests)
+		}
+	}
+}
+
+func TestVerifyEmail(t *testing.T) {
+	backend := setupHandlers(t)
+	test.NoError(t, db.CreateUser(db.User{FirstName: "Sam", LastName: "Doe", Email: "sam@example.com", Password: PASSWORD}))
+
+	w := serve(resources.ResendVerificationEmail, http.MethodPost, `{"email":"sam@example.com"}`, "", nil)
+	test.Equal(t, w.Code, http.StatusAccepted)
+	code := mailedCode(t, backend, "sam@example.com")
+
+	w = serve(resources.VerifyEmail, http.MethodPost, `{"email":"sam@example.com","code":"AAAAAAAA"}`, "", nil)
+	test.Equal(t, w.Code, http.StatusUnauthorized)
+	test.Equal(t, db.ReadByUserEmail("sam@example.com").EmailVerified, false)
+
+	body := fmt.Sprintf(`{"email":"SAM@example.com","code":%q}`, code)
+	w = serve(resources.VerifyEmail, http.MethodPost, body, "", nil)
+	test.Equal(t, w.Code, http.StatusNoContent)
+	test.Equal(t, db.ReadByUserEmail("sam@example.com").EmailVerified, true)
+
+	// codes are single use
+	w = serve(resources.VerifyEmail, http.MethodPost, body, "", nil)
+	test.Equal(t, w.Code, http.StatusUnauthorized)
+}
+
+func TestResendVerificationAnswersTheSame(t *testing.T) {
+	backend := setupHandlers(t)
+	test.NoError(t, db.CreateUser(db.User{FirstName: "Sam", LastName: "Doe", Email: "unverified@example.com", Password: PASSWORD}))
+	createUser(t, "verified@example.com")
+
+	for _, email := range []string{"unverified@example.com", "verified@example.com", "unknown@example.com"} {
+		body := fmt.Sprintf(`{"email":%q}`, email)
+		w := serve(resources.ResendVerificationEmail, http.MethodPost, body, "", nil)
+		test.Equal(t, w.Code, http.StatusAccepted)
+		test.Equal(t, w.Body.Len(), 0)
+	}
+
+	// only the account that needs it gets mail
+	test.Equal(t, len(sentMail(t, backend, "unverified@example.com")), 1)
+	test.Equal(t, len(sentMail(t, backend, "verified@example.com")), 0)
+	test.Equal(t, len(sentMail(t, backend, "unknown@example.com")), 0)
+}
+
+func TestChangeUserEmail(t *testing.T) {
+	backend := setupHandlers(t)
+	user := createUser(t, "sam@example.com")
+	createUser(t, "taken@example.com")
+	userID := user.ID.Hex()
+	vars := map[string]string{"user_id": userID}
+
+	// a stolen session alone can't move the account to another email
+	w := serve(resources.ChangeUserEmail, http.MethodPut, `{"email":"new@example.com"}`, userID, vars)
+	test.Equal(t, w.Code, http.StatusUnauthorized)
+	w = serve(resources.ChangeUserEmail, http.MethodPut, `{"email":"new@example.com","password":"wrong"}`, userID, vars)
+	test.Equal(t, w.Code, http.StatusUnauthorized)
+	test.Equal(t, db.ReadByUserId(userID).PendingEmail, "")
+	test.Equal(t, len(sentMail(t, backend, "new@example.com")), 0)
+
+	body := fmt.Sprintf(`{"email":"taken@example.com","password":%q}`, PASSWORD)
+	w = serve(resources.ChangeUserEmail, http.MethodPut, body, userID, vars)
+	test.Equal(t, w.Code, http.StatusConflict)
+
+	w = serve(resources.ConfirmUserEmailChange, http.MethodPost, `{"code":"AAAAAAAA"}`, userID, vars)
+	test.Equal(t, w.Code, http.StatusNotFound)
+
+	body = fmt.Sprintf(`{"email":"New@example.com","password":%q}`, PASSWORD)
+	w = serve(resources.ChangeUserEmail, http.MethodPut, body, userID, vars)
+	test.Equal(t, w.Code, http.StatusAccepted)
+	code := mailedCode(t, backend, "new@example.com")
+
+	// the old email stays in use until the new one is confirmed
+	user = db.ReadByUserId(userID)
+	test.Equal(t, user.Email, "sam@example.com")
+	test.Equal(t, user.PendingEmail, "new@example.com")
+
+	w = serve(resources.ConfirmUserEmailChange, http.MethodPost, `{"code":"AAAAAAAA"}`, userID, vars)
+	test.Equal(t, w.Code, http.StatusUnauthorized)
+
+	w = serve(resources.ConfirmUserEmailChange, http.MethodPost, fmt.Sprintf(`{"code":%q}`, code), userID, vars)
+	test.Equal(t, w.Code, http.StatusNoContent)
+
+	user = db.ReadByUserId(userID)
+	test.Equal(t, user.Email, "new@example.com")
+	test.Equal(t, user.PendingEmail, "")
+	test.Equal(t, user.EmailVerified, true)
+
+	// the old address hears about it
+	notified := sentMail(t, backend, "sam@example.com")
+	test.Equal(t, len(notified), 1)
+	test.Equal(t, notified[0].Subject, "Your Rivall email was changed")
+}
+
+func TestChangeUserEmailNeedsSecondFactor(t *testing.T) {
+	backend := setupHandlers(t)
+	user, secret := createTwoFactorUser(t, "sam@example.com")
+	userID := user.ID.Hex()
+	vars := map[string]string{"user_id": userID}
+
+	body := fmt.Sprintf(`{"email":"new@example.com","password":%q}`, PASSWORD)
+	w := serve(resources.ChangeUserEmail, http.MethodPut, body, userID, vars)
+	test.Equal(t, w.Code, http.StatusUnauthorized)
+	body = fmt.Sprintf(`{"email":"new@example.com","password":%q,"code":"00000000"}`, PASSWORD)
+	w = serve(resources.ChangeUserEmail, http.MethodPut, body, userID, vars)
+	test.Equal(t, w.Code, http.StatusUnauthorized)
+	test.Equal(t, len(sentMail(t, backend, "new@example.com")), 0)
+
+	code, err := totp.CodeAt(secret, totp.Step(time.Now()))
+	test.NoError(t, err)
+	body = fmt.Sprintf(`{"email":"new@example.com","password":%q,"code":%q}`, PASSWORD, code)
+	w = serve(resources.ChangeUserEmail, http.MethodPut, body, userID, vars)
+	test.Equal(t, w.Code, http.StatusAccepted)
+	test.Equal(t, db.ReadByUserId(userID).PendingEmail, "new@example.com")
+}
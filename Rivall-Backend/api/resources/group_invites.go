@@ -0,0 +1,232 @@
+package resources
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"Rivall-Backend/api/websocket"
+	db "Rivall-Backend/db"
+	"Rivall-Backend/util/api_error"
+
+	"github.com/gorilla/mux"
+	"github.com/rs/zerolog/log"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+type NewGroupInviteReq struct {
+	ExpiresInHours   int  `json:"expires_in_hours"  form:"omitempty,min=1,max=8760"`
+	MaxUses          int  `json:"max_uses"          form:"omitempty,min=1,max=10000"`
+	RequiresApproval bool `json:"requires_approval"`
+}
+
+type GroupInvitePreviewRes struct {
+	GroupID          string     `json:"group_id"`
+	GroupName        string     `json:"group_name"`
+	MemberCount      int        `json:"member_count"`
+	RequiresApproval bool       `json:"requires_approval"`
+	ExpiresAt        *time.Time `json:"expires_at"`
+}
+
+type JoinGroupInviteRes struct {
+	GroupID string `json:"group_id"`
+	Joined  bool   `json:"joined"`
+}
+
+func readGroupAsManager(w http.ResponseWriter, r *http.Request, groupID string, userID string) (db.Group, bool) {
+	// read a group, writing an error unless the user is one of its admins
+	group, ok := readGroupAsMember(w, r, groupID, userID)
+	if !ok {
+		return group, false
+	}
+
+	if !db.CanManageGroup(group.Role(userID)) {
+		log.Error().Msg("User is not a group admin")
+		api_error.Write(w, r, http.StatusForbidden, api_error.FORBIDDEN, "Only group admins can manage invites.")
+		return group, false
+	}
+
+	return group, true
+}
+
+func writeGroupInviteError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, db.ErrInviteNotFound):
+		api_error.Write(w, r, http.StatusNotFound, api_error.NOT_FOUND, "Invite does not exist.")
+	case errors.Is(err, db.ErrInviteNotUsable):
+		api_error.Write(w, r, http.StatusGone, api_error.GONE, "Invite is expired, revoked or used up.")
+	case errors.Is(err, websocket.ErrAlreadyGroupMember):
+		api_error.Write(w, r, http.StatusConflict, api_error.CONFLICT, "User is already in the group.")
+	case errors.Is(err, db.ErrJoinRequestFound):
+		api_error.Write(w, r, http.StatusConflict, api_error.CONFLICT, "User already asked to join the group.")
+	case errors.Is(err, db.ErrEmailNotVerified):
+		writeEmailNotVerified(w, r)
+	case errors.Is(err, websocket.ErrNoPendingJoinRequest):
+		api_error.Write(w, r, http.StatusNotFound, api_error.NOT_FOUND, "User has not asked to join the group.")
+	default:
+		writeGroupActionError(w, r, err)
+	}
+}
+
+func CreateGroupInvite(w http.ResponseWriter, r *http.Request) {
+	log.Info().Msg("POST group invite")
+
+	vars := mux.Vars(r)
+	userID := vars["user_id"]
+	groupID := vars["group_id"]
+
+	if _, ok := readGroupAsManager(w, r, groupID, userID); !ok {
+		return
+	}
+	if !db.ReadByUserId(userID).EmailVerified {
+		writeEmailNotVerified(w, r)
+		return
+	}
+
+	req := NewGroupInviteReq{}
+	if !decodeRequest(w, r, &req) {
+		return
+	}
+
+	var expiresAt *time.Time
+	if req.ExpiresInHours > 0 {
+		expiry := time.Now().Add(time.Duration(req.ExpiresInHours) * time.Hour)
+		expiresAt = &expiry
+	}
+
+	invite, err := db.CreateGroupInvite(groupID, userID, expiresAt, req.MaxUses, req.RequiresApproval)
+	if err != nil {
+		api_error.Write(w, r, http.StatusInternalServerError, api_error.INTERNAL, "Failed to create invite.")
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(invite)
+}
+
+func GetGroupInvites(w http.ResponseWriter, r *http.Request) {
+	log.Info().Msg("GET group invites")
+
+	vars := mux.Vars(r)
+	userID := vars["user_id"]
+	groupID := vars["group_id"]
+
+	if _, ok := readGroupAsManager(w, r, groupID, userID); !ok {
+		return
+	}
+
+	invites, err := db.ReadActiveGroupInvitesByGroupId(groupID)
+	if err != nil {
+		api_error.Write(w, r, http.StatusInternalServerError, api_error.INTERNAL, "Failed to read invites.")
+		return
+	}
+
+	json.NewEncoder(w).Encode(invites)
+}
+
+func RevokeGroupInvite(w http.ResponseWriter, r *http.Request) {
+	log.Info().Msg("DELETE group invite")
+
+	vars := mux.Vars(r)
+	userID := vars["user_id"]
+	groupID := vars["group_id"]
+
+	if _, ok := readGroupAsManager(w, r, groupID, userID); !ok {
+		return
+	}
+
+	if err := db.RevokeGroupInvite(groupID, vars["invite_id"]); err != nil {
+		writeGroupInviteError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func GetGroupInvitePreview(w http.ResponseWriter, r *http.Request) {
+	log.Info().Msg("GET group invite preview")
+
+	invite, err := db.ReadGroupInviteByCode(mux.Vars(r)["code"])
+	if err != nil {
+		writeGroupInviteError(w, r, err)
+		return
+	}
+	if !invite.Usable(time.Now()) {
+		writeGroupInviteError(w, r, db.ErrInviteNotUsable)
+		return
+	}
+
+	group := db.ReadByGroupId(invite.GroupID.Hex())
+	if group.ID == bson.NilObjectID {
+		writeGroupInviteError(w, r, db.ErrInviteNotFound)
+		return
+	}
+
+	json.NewEncoder(w).Encode(GroupInvitePreviewRes{
+		GroupID:          group.ID.Hex(),
+		GroupName:        group.GroupName,
+		MemberCount:      len(group.GroupMembers),
+		RequiresApproval: invite.RequiresApproval,
+		ExpiresAt:        invite.ExpiresAt,
+	})
+}
+
+func JoinGroupWithInvite(w http.ResponseWriter, r *http.Request) {
+	log.Info().Msg("POST join group with invite")
+
+	vars := mux.Vars(r)
+	invite, joined, err := websocket.JoinGroupWithInvite(websocket.WSManager, vars["user_id"], vars["code"])
+	if err != nil {
+		writeGroupInviteError(w, r, err)
+		return
+	}
+
+	// an invite that needs approval leaves the request waiting on the admins
+	if joined {
+		w.WriteHeader(http.StatusOK)
+	} else {
+		w.WriteHeader(http.StatusAccepted)
+	}
+	json.NewEncoder(w).Encode(JoinGroupInviteRes{
+		GroupID: invite.GroupID.Hex(),
+		Joined:  joined,
+	})
+}
+
+func GetGroupJoinRequests(w http.ResponseWriter, r *http.Request) {
+	log.Info().Msg("GET group join requests")
+
+	vars := mux.Vars(r)
+	group, ok := readGroupAsManager(w, r, vars["group_id"], vars["user_id"])
+	if !ok {
+		return
+	}
+
+	requests := group.JoinRequests
+	if requests == nil {
+		requests = []db.GroupJoinRequest{}
+	}
+	json.NewEncoder(w).Encode(requests)
+}
+
+func ApproveGroupJoinRequest(w http.ResponseWriter, r *http.Request) {
+	log.Info().Msg("POST approve group join request")
+	answerGroupJoinRequest(w, r, true)
+}
+
+func RejectGroupJoinRequest(w http.ResponseWriter, r *http.Request) {
+	log.Info().Msg("POST reject group join request")
+	answerGroupJoinRequest(w, r, false)
+}
+
+func answerGroupJoinRequest(w http.ResponseWriter, r *http.Request, approve bool) {
+	vars := mux.Vars(r)
+	err := websocket.AnswerJoinRequest(websocket.WSManager, vars["user_id"], vars["group_id"], vars["member_id"], approve)
+	if err != nil {
+		writeGroupInviteError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
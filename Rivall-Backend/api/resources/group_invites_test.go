@@ -0,0 +1,114 @@
+package resources_test
+
+import (
+	"net/http"
+	"testing"
+
+	"Rivall-Backend/api/resources"
+	db "Rivall-Backend/db"
+	"Rivall-Backend/util/test"
+)
+
+// inviteGroup makes a group owned by a new user with an invite into it, returning the
+// owner, the group and the invite code
+func inviteGroup(t *testing.T, maxUses int, requiresApproval bool) (string, string, string) {
+	t.Helper()
+
+	owner := createUser(t, "owner@example.com").ID.Hex()
+	groupID, err := db.CreateGroup("Climbers", owner)
+	test.NoError(t, err)
+	invite, err := db.CreateGroupInvite(groupID, owner, nil, maxUses, requiresApproval)
+	test.NoError(t, err)
+	return owner, groupID, invite.Code
+}
+
+func joinWithInvite(userID string, code string) int {
+	vars := map[string]string{"user_id": userID, "code": code}
+	return serve(resources.JoinGroupWithInvite, http.MethodPost, "", userID, vars).Code
+}
+
+func answerJoinRequest(handler http.HandlerFunc, ownerID string, groupID string, memberID string) int {
+	vars := map[string]string{"user_id": ownerID, "group_id": groupID, "member_id": memberID}
+	return serve(handler, http.MethodPost, "", ownerID, vars).Code
+}
+
+func inviteUses(t *testing.T, code string) int {
+	t.Helper()
+
+	invite, err := db.ReadGroupInviteByCode(code)
+	test.NoError(t, err)
+	return invite.Uses
+}
+
+func TestJoinWithInviteCountsUse(t *testing.T) {
+	setupHandlers(t)
+	_, groupID, code := inviteGroup(t, 1, false)
+	alex := createUser(t, "alex@example.com").ID.Hex()
+	kim := createUser(t, "kim@example.com").ID.Hex()
+
+	test.Equal(t, joinWithInvite(alex, code), http.StatusOK)
+	test.Equal(t, inviteUses(t, code), 1)
+	test.Equal(t, db.UserInGroup(groupID, alex), true)
+
+	test.Equal(t, joinWithInvite(kim, code), http.StatusGone)
+	test.Equal(t, db.UserInGroup(groupID, kim), false)
+}
+
+func TestApproveJoinRequest(t *testing.T) {
+	setupHandlers(t)
+	owner, groupID, code := inviteGroup(t, 1, true)
+	alex := createUser(t, "alex@example.com").ID.Hex()
+	kim := createUser(t, "kim@example.com").ID.Hex()
+
+	// asking to join doesn't use the invite up, so more people can ask than it lets in
+	test.Equal(t, joinWithInvite(alex, code), http.StatusAccepted)
+	test.Equal(t, joinWithInvite(kim, code), http.StatusAccepted)
+	test.Equal(t, inviteUses(t, code), 0)
+	test.Equal(t, db.UserInGroup(groupID, alex), false)
+
+	test.Equal(t, answerJoinRequest(resources.ApproveGroupJoinRequest, owner, groupID, alex), http.StatusNoContent)
+	test.Equal(t, inviteUses(t, code), 1)
+	test.Equal(t, db.UserInGroup(groupID, alex), true)
+	test.Equal(t, answerJoinRequest(resources.ApproveGroupJoinRequest, owner, groupID, alex), http.StatusNotFound)
+
+	// the invite is used up, kim's request stays for the admins to turn down
+	test.Equal(t, answerJoinRequest(resources.ApproveGroupJoinRequest, owner, groupID, kim), http.StatusGone)
+	test.Equal(t, db.UserInGroup(groupID, kim), false)
+	test.Equal(t, len(db.ReadByGroupId(groupID).JoinRequests), 1)
+	test.Equal(t, inviteUses(t, code), 1)
+}
+
+func TestRejectJoinRequest(t *testing.T) {
+	setupHandlers(t)
+	owner, groupID, code := inviteGroup(t, 1, true)
+	alex := createUser(t, "alex@example.com").ID.Hex()
+	kim := createUser(t, "kim@example.com").ID.Hex()
+	test.NoError(t, db.AddUserToGroup(groupID, kim))
+
+	// only admins answer requests
+	test.Equal(t, joinWithInvite(alex, code), http.StatusAccepted)
+	test.Equal(t, answerJoinRequest(resources.RejectGroupJoinRequest, kim, groupID, alex), http.StatusForbidden)
+	test.Equal(t, answerJoinRequest(resources.RejectGroupJoinRequest, owner, groupID, alex), http.StatusNoContent)
+	test.Equal(t, db.UserInGroup(groupID, alex), false)
+	test.Equal(t, len(db.ReadByGroupId(groupID).JoinRequests), 0)
+
+	// a rejected request took nothing from the invite
+	test.Equal(t, inviteUses(t, code), 0)
+	test.Equal(t, joinWithInvite(alex, code), http.StatusAccepted)
+}
+
+func TestDeletingGroupDeletesInvites(t *testing.T) {
+	setupHandlers(t)
+	owner, groupID, code := inviteGroup(t, 0, false)
+	alex := createUser(t, "alex@example.com").ID.Hex()
+
+	vars := map[string]string{"user_id": owner, "group_id": groupID}
+	w := serve(resources.DeleteGroup, http.MethodDelete, "", owner, vars)
+	test.Equal(t, w.Code, http.StatusNoContent)
+
+	w = serve(resources.GetGroupInvitePreview, http.MethodGet, "", "", map[string]string{"code": code})
+	test.Equal(t, w.Code, http.StatusNotFound)
+	test.Equal(t, joinWithInvite(alex, code), http.StatusNotFound)
+	_, err := db.ReadGroupInviteByCode(code)
+	test.Equal(t, err, db.ErrInviteNotFound)
+}
@@ -0,0 +1,106 @@
+package resources
+
+import (
+	"errors"
+	"net/http"
+
+	"Rivall-Backend/api/websocket"
+	db "Rivall-Backend/db"
+	"Rivall-Backend/util/api_error"
+
+	"github.com/gorilla/mux"
+	"github.com/rs/zerolog/log"
+)
+
+type UpdateGroupMemberRoleReq struct {
+	Role string `json:"role" form:"required,oneof=admin member"`
+}
+
+type TransferGroupOwnershipReq struct {
+	NewOwnerID string `json:"new_owner_id" form:"required,len=24,hexadecimal"`
+}
+
+func writeGroupActionError(w http.ResponseWriter, r *http.Request, err error) {
+	// map the errors of the websocket group actions onto responses
+	switch {
+	case errors.Is(err, websocket.ErrGroupDoesNotExist):
+		api_error.Write(w, r, http.StatusNotFound, api_error.GROUP_NOT_FOUND, "Group does not exist.")
+	case errors.Is(err, websocket.ErrNotGroupMember):
+		api_error.Write(w, r, http.StatusNotFound, api_error.NOT_GROUP_MEMBER, "User is not in the group.")
+	case errors.Is(err, websocket.ErrGroupPermission):
+		api_error.Write(w, r, http.StatusForbidden, api_error.FORBIDDEN, "User does not have permission for this group action.")
+	case errors.Is(err, websocket.ErrUseLeaveGroup):
+		api_error.Write(w, r, http.StatusBadRequest, api_error.BAD_REQUEST, "Use leave to remove yourself from a group.")
+	case errors.Is(err, websocket.ErrAlreadyGroupOwner):
+		api_error.Write(w, r, http.StatusBadRequest, api_error.BAD_REQUEST, "User already owns the group.")
+	case errors.Is(err, db.ErrGroupChanged):
+		api_error.Write(w, r, http.StatusConflict, api_error.CONFLICT, "Group changed, try again.")
+	default:
+		log.Error().Err(err).Msg("Failed group action")
+		api_error.Write(w, r, http.StatusInternalServerError, api_error.INTERNAL, "Failed to update group.")
+	}
+}
+
+func KickGroupMember(w http.ResponseWriter, r *http.Request) {
+	log.Info().Msg("DELETE group member")
+
+	vars := mux.Vars(r)
+	err := websocket.KickGroupMember(websocket.WSManager, vars["user_id"], vars["group_id"], vars["member_id"])
+	if err != nil {
+		writeGroupActionError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func LeaveGroup(w http.ResponseWriter, r *http.Request) {
+	log.Info().Msg("POST leave group")
+
+	vars := mux.Vars(r)
+	err := websocket.LeaveGroup(websocket.WSManager, vars["user_id"], vars["group_id"])
+	if err != nil {
+		writeGroupActionError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func UpdateGroupMemberRole(w http.ResponseWriter, r *http.Request) {
+	log.Info().Msg("PUT group member role")
+
+	vars := mux.Vars(r)
+
+	req := UpdateGroupMemberRoleReq{}
+	if !decodeRequest(w, r, &req) {
+		return
+	}
+
+	err := websocket.ChangeGroupRole(websocket.WSManager, vars["user_id"], vars["group_id"], vars["member_id"], req.Role)
+	if err != nil {
+		writeGroupActionError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func TransferGroupOwnership(w http.ResponseWriter, r *http.Request) {
+	log.Info().Msg("PUT group owner")
+
+	vars := mux.Vars(r)
+
+	req := TransferGroupOwnershipReq{}
+	if !decodeRequest(w, r, &req) {
+		return
+	}
+
+	err := websocket.TransferGroupOwnership(websocket.WSManager, vars["user_id"], vars["group_id"], req.NewOwnerID)
+	if err != nil {
+		writeGroupActionError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -2,9 +2,13 @@ package resources
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
+	"time"
 
+	"Rivall-Backend/api/websocket"
 	db "Rivall-Backend/db"
+	"Rivall-Backend/util/api_error"
 
 	"github.com/gorilla/mux"
 	"github.com/rs/zerolog/log"
@@ -12,183 +16,366 @@ import (
 )
 
 type NewGroupReq struct {
-	GroupName      string   `json:"group_name"`
-	RequestMessage string   `json:"message"`
-	UserIDs        []string `json:"user_ids"`
+	GroupName      string   `json:"group_name" form:"required,max=50"`
+	RequestMessage string   `json:"message"    form:"max=280"`
+	UserIDs        []string `json:"user_ids"   form:"max=50,unique,dive,len=24,hexadecimal"`
+}
+
+type UpdateGroupReq struct {
+	GroupName string `json:"group_name" form:"required,max=50"`
+}
+
+type GroupRes struct {
+	ID          string     `json:"_id"`
+	GroupName   string     `json:"group_name"`
+	AdminID     string     `json:"admin_id"`
+	AdminIDs    []string   `json:"admin_ids"`
+	MemberIDs   []string   `json:"users"`
+	LastMessage db.Message `json:"last_message"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
+// DEFAULT_MESSAGE_PAGE_SIZE and MAX_MESSAGE_PAGE_SIZE bound the messages GetGroup returns
+const DEFAULT_MESSAGE_PAGE_SIZE = 50
+const MAX_MESSAGE_PAGE_SIZE = 200
+
+type GroupWithMessagesRes struct {
+	GroupRes
+	// Messages is a page of the history, oldest first, counted back from the newest message
+	Messages []db.Message `json:"messages"`
+	// HasMoreMessages is set when older messages are left for a later page
+	HasMoreMessages bool `json:"has_more_messages"`
+}
+
+type GroupMemberRes struct {
+	PublicUserRes
+	// Email is only shown to the member's contacts
+	Email string `json:"email,omitempty"`
+	Role  string `json:"role"`
+	// Online is left out when the member's presence setting hides it
+	Online *bool `json:"online,omitempty"`
+}
+
+type NewGroupRes struct {
+	Group    GroupRes                     `json:"group"`
+	Requests []websocket.JoinGroupRequest `json:"requests"`
+}
+
+func newGroupRes(group db.Group) GroupRes {
+	members := make([]string, len(group.GroupMembers))
+	for i, member := range group.GroupMembers {
+		members[i] = member.Hex()
+	}
+	admins := make([]string, len(group.AdminIDs))
+	for i, admin := range group.AdminIDs {
+		admins[i] = admin.Hex()
+	}
+
+	return GroupRes{
+		ID:          group.ID.Hex(),
+		GroupName:   group.GroupName,
+		AdminID:     group.AdminID.Hex(),
+		AdminIDs:    admins,
+		MemberIDs:   members,
+		LastMessage: group.LastMessage,
+		CreatedAt:   time.Unix(int64(group.CreatedAt.T), 0),
+	}
+}
+
+func readGroupAsMember(w http.ResponseWriter, r *http.Request, groupID string, userID string) (db.Group, bool) {
+	// read a group, writing an error unless the user is one of its members
+	group := db.ReadByGroupId(groupID)
+	if group.ID == bson.NilObjectID {
+		log.Error().Msg("Group does not exist")
+		api_error.Write(w, r, http.StatusNotFound, api_error.GROUP_NOT_FOUND, "Group does not exist.")
+		return group, false
+	}
+
+	if !db.UserInGroup(groupID, userID) {
+		log.Error().Msg("User is not in the group")
+		api_error.Write(w, r, http.StatusForbidden, api_error.NOT_GROUP_MEMBER, "User is not in the group.")
+		return group, false
+	}
+
+	return group, true
 }
 
 func WriteNewMessageGroup(w http.ResponseWriter, r *http.Request) {
-	// Create new message group in database
+	log.Info().Msg("POST new message group")
 
-	// Validate request
 	// Check the admin user is a valid logged in user
 	vars := mux.Vars(r)
 	adminUserID := vars["user_id"]
 	if db.ReadByUserId(adminUserID).ID == bson.NilObjectID {
 		log.Error().Msg("Admin user does not exist")
-		w.WriteHeader(http.StatusBadRequest)
-		w.Write([]byte("Admin user does not exist."))
+		api_error.Write(w, r, http.StatusBadRequest, api_error.USER_NOT_FOUND, "Admin user does not exist.")
 		return
 	}
 
-	// Check all users requested to be added to the group are valid users
-	body := make(map[string][]string)
-	err := json.NewDecoder(r.Body).Decode(&body)
-	if err != nil {
-		log.Error().Err(err).Msg("Failed to decode user IDs, invalid JSON request")
-		w.WriteHeader(http.StatusBadRequest)
-		w.Write([]byte("Failed to decode user IDs, invalid JSON request."))
+	// Decode and validate the request
+	req := NewGroupReq{}
+	if !decodeRequest(w, r, &req) {
 		return
 	}
-	for _, userID := range body["user_ids"] {
-		if db.ReadByUserId(userID).ID == bson.NilObjectID {
-			log.Error().Msg("User does not exist")
-			w.WriteHeader(http.StatusBadRequest)
-			w.Write([]byte("User does not exist."))
-			return
+
+	// Create the group and send the requests the same way the create_group event does
+	payload := websocket.CreateGroupPayload{
+		GroupName: req.GroupName,
+		UserIDs:   req.UserIDs,
+		Message:   req.RequestMessage,
+	}
+	groupID, requests, err := websocket.CreateGroupWithRequests(websocket.WSManager, adminUserID, payload)
+	if err != nil {
+		switch {
+		case errors.Is(err, websocket.ErrUserDoesNotExist):
+			api_error.Write(w, r, http.StatusBadRequest, api_error.USER_NOT_FOUND, "User does not exist.")
+		case errors.Is(err, db.ErrEmailNotVerified):
+			writeEmailNotVerified(w, r)
+		case errors.Is(err, websocket.ErrCannotInviteSelf):
+			api_error.Write(w, r, http.StatusBadRequest, api_error.BAD_REQUEST, "Users can not send a group request to themselves.")
+		case errors.Is(err, websocket.ErrGroupRequestsNotAllowed):
+			api_error.Write(w, r, http.StatusForbidden, api_error.FORBIDDEN, "A user does not accept group requests from you.")
+		default:
+			log.Error().Err(err).Msg("Failed to create new message group")
+			api_error.Write(w, r, http.StatusInternalServerError, api_error.INTERNAL, "Failed to create new message group.")
 		}
+		return
+	}
+
+	res := NewGroupRes{
+		Group:    newGroupRes(db.ReadByGroupId(groupID)),
+		Requests: requests,
 	}
 
-	// Check the group name is valid
-	if len(body["group_name"]) == 0 {
-		log.Error().Msg("Group name is empty")
-		w.WriteHeader(http.StatusBadRequest)
-		w.Write([]byte("Group name is empty."))
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(res)
+}
+
+func GetUserGroups(w http.ResponseWriter, r *http.Request) {
+	log.Info().Msg("GET user groups")
+
+	vars := mux.Vars(r)
+	userID := vars["user_id"]
+
+	groups, err := db.ReadGroupsByUserId(userID)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to read user groups")
+		api_error.Write(w, r, http.StatusInternalServerError, api_error.INTERNAL, "Failed to read user groups.")
 		return
 	}
 
-	// Check the message is valid
-	if len(body["message"]) == 0 {
-		log.Error().Msg("Message is empty")
-		w.WriteHeader(http.StatusBadRequest)
-		w.Write([]byte("Message is empty."))
+	res := make([]GroupRes, len(groups))
+	for i, group := range groups {
+		res[i] = newGroupRes(group)
+	}
+
+	json.NewEncoder(w).Encode(res)
+}
+
+func GetGroup(w http.ResponseWriter, r *http.Request) {
+	log.Info().Msg("GET group")
+
+	vars := mux.Vars(r)
+	limit, offset, ok := getPagination(w, r, DEFAULT_MESSAGE_PAGE_SIZE, MAX_MESSAGE_PAGE_SIZE)
+	if !ok {
 		return
 	}
+	group, ok := readGroupAsMember(w, r, vars["group_id"], vars["user_id"])
+	if !ok {
+		return
+	}
+
+	messages, more := pageMessages(group.Messages, limit, offset)
+	res := GroupWithMessagesRes{
+		GroupRes:        newGroupRes(group),
+		Messages:        db.HideReadReceipts(messages, vars["user_id"]),
+		HasMoreMessages: more,
+	}
+
+	json.NewEncoder(w).Encode(res)
+}
+
+// pageMessages picks limit messages, skipping the offset newest, and reports if older ones
+// are left. Messages are stored oldest first and stay in that order.
+func pageMessages(messages []db.Message, limit int64, offset int64) ([]db.Message, bool) {
+	end := int64(len(messages)) - offset
+	if end <= 0 {
+		return []db.Message{}, false
+	}
+	start := max(end-limit, 0)
+	return messages[start:end], start > 0
+}
+
+func UpdateGroup(w http.ResponseWriter, r *http.Request) {
+	log.Info().Msg("PATCH group")
 
-	// Create new message group in database, Only add the Admin user to the group
-	insertID, err2 := db.CreateGroup(body["group_name"][0], adminUserID)
-	if err2 != nil { // check insertID is type string
-		log.Error().Err(err).Msg("Failed to create new message group")
-		w.WriteHeader(http.StatusInternalServerError)
-		w.Write([]byte("Failed to create new message group."))
+	vars := mux.Vars(r)
+	userID := vars["user_id"]
+	groupID := vars["group_id"]
+
+	group, ok := readGroupAsMember(w, r, groupID, userID)
+	if !ok {
 		return
 	}
 
-	// Send Group Request to all users requested to be added to the group
-	failedRequests := make([]map[string]interface{}, 0)
-	for _, userID := range body["user_ids"] {
-		_, err3 := db.CreateGroupRequest(adminUserID, userID, insertID, body["group_name"][0], body["message"][0])
-		if err3 != nil {
-			log.Error().Err(err).Msg("Failed to send group request")
-			r := map[string]interface{}{
-				"userID":  userID,
-				"error":   err3,
-				"message": "Failed to send group request",
-			}
-			failedRequests = append(failedRequests, r)
-		}
+	// only admins may rename the group
+	if !db.CanManageGroup(group.Role(userID)) {
+		log.Error().Msg("User is not a group admin")
+		api_error.Write(w, r, http.StatusForbidden, api_error.FORBIDDEN, "Only group admins can rename the group.")
+		return
 	}
-	if len(failedRequests) > 0 {
-		log.Error().Msg("Failed to send group request to some users")
-		w.WriteHeader(http.StatusInternalServerError)
-		response, err := json.Marshal(failedRequests)
-		if err != nil {
-			log.Error().Err(err).Msg("Failed to marshal failed requests")
-			w.WriteHeader(http.StatusInternalServerError)
-			w.Write([]byte("Failed to marshal failed requests."))
-			return
-		}
-		w.Write(response)
+
+	req := UpdateGroupReq{}
+	if !decodeRequest(w, r, &req) {
 		return
 	}
 
-	// Return success response
-	w.WriteHeader(http.StatusOK)
-	w.Write([]byte("Message group created."))
+	if err := db.RenameGroup(groupID, req.GroupName); err != nil {
+		log.Error().Err(err).Msg("Failed to rename group")
+		api_error.Write(w, r, http.StatusInternalServerError, api_error.INTERNAL, "Failed to rename group.")
+		return
+	}
+
+	group.GroupName = req.GroupName
+	json.NewEncoder(w).Encode(newGroupRes(group))
 }
 
-func AcceptGroupRequest(w http.ResponseWriter, r *http.Request) {
-	// Accept group request
+func DeleteGroup(w http.ResponseWriter, r *http.Request) {
+	log.Info().Msg("DELETE group")
 
-	// Validate request, Check the user is a valid logged in user
-	body := make(map[string][]string)
-	err := json.NewDecoder(r.Body).Decode(&body)
-	if err != nil {
-		log.Error().Err(err).Msg("Failed to decode user IDs, invalid JSON request")
-		w.WriteHeader(http.StatusBadRequest)
-		w.Write([]byte("Failed to decode user IDs, invalid JSON request."))
+	vars := mux.Vars(r)
+	userID := vars["user_id"]
+	groupID := vars["group_id"]
+
+	group, ok := readGroupAsMember(w, r, groupID, userID)
+	if !ok {
 		return
 	}
-	// Check the group is a valid group
-	groupID := body["group_id"][0]
-	groupObj := db.ReadByGroupId(groupID)
-	if groupObj.ID == bson.NilObjectID {
-		log.Error().Msg("Group does not exist")
-		w.WriteHeader(http.StatusBadRequest)
-		w.Write([]byte("Group does not exist."))
+
+	// only the owner may delete the group
+	if group.Role(userID) != db.GROUP_ROLE_OWNER {
+		log.Error().Msg("User is not the group owner")
+		api_error.Write(w, r, http.StatusForbidden, api_error.FORBIDDEN, "Only the group owner can delete the group.")
 		return
 	}
-	// Check the user is a valid user
-	if db.ReadByUserId(body["user_id"][0]).ID == bson.NilObjectID {
-		log.Error().Msg("User does not exist")
-		w.WriteHeader(http.StatusBadRequest)
-		w.Write([]byte("User does not exist."))
+
+	if err := db.DeleteGroup(groupID); err != nil {
+		log.Error().Err(err).Msg("Failed to delete group")
+		api_error.Write(w, r, http.StatusInternalServerError, api_error.INTERNAL, "Failed to delete group.")
 		return
 	}
 
-	// Add the group to the user's groups and update the message request status
-	err2 := db.AcceptGroupRequest(body["user_id"][0], groupID)
-	if err2 != nil {
-		log.Error().Err(err2).Msg("Failed to accept group request")
-		w.WriteHeader(http.StatusInternalServerError)
-		w.Write([]byte("Failed to accept group request."))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func GetGroupMembers(w http.ResponseWriter, r *http.Request) {
+	log.Info().Msg("GET group members")
+
+	vars := mux.Vars(r)
+	group, ok := readGroupAsMember(w, r, vars["group_id"], vars["user_id"])
+	if !ok {
 		return
 	}
 
-	// Add the user to the group
-	err3 := db.AddUserToGroup(body["user_id"][0], groupID)
-	if err3 != nil {
-		log.Error().Err(err3).Msg("Failed to add user to group")
-		w.WriteHeader(http.StatusInternalServerError)
-		w.Write([]byte("Failed to add user to group."))
-		return
+	viewer := db.ReadByUserId(vars["user_id"])
+	members := []GroupMemberRes{}
+	for _, memberID := range group.GroupMembers {
+		member := db.ReadByUserId(memberID.Hex())
+		if member.ID == bson.NilObjectID {
+			log.Error().Msgf("Group member does not exist: %s", memberID.Hex())
+			continue
+		}
+		res := GroupMemberRes{
+			PublicUserRes: newPublicUserRes(member),
+			Role:          group.Role(member.ID.Hex()),
+		}
+		// sharing a group doesn't share an email address, only being contacts does
+		if member.ID == viewer.ID || viewer.HasContact(member.ID) {
+			res.Email = member.Email
+		}
+		if online, ok := websocket.WSManager.PresenceFor(member, viewer); ok {
+			res.Online = &online
+		}
+		members = append(members, res)
 	}
 
-	// Return success response
-	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(members)
 }
 
-func RejectGroupRequest(w http.ResponseWriter, r *http.Request) {
-	// Reject group request
+func GetGroupPendingRequests(w http.ResponseWriter, r *http.Request) {
+	log.Info().Msg("GET group pending requests")
+
+	vars := mux.Vars(r)
+	userID := vars["user_id"]
+	groupID := vars["group_id"]
 
-	// Validate request, Check the user is a valid logged in user
-	// Check the group is a valid group
-	body := make(map[string][]string)
-	err := json.NewDecoder(r.Body).Decode(&body)
+	group, ok := readGroupAsMember(w, r, groupID, userID)
+	if !ok {
+		return
+	}
+
+	// only admins see who was invited
+	if !db.CanManageGroup(group.Role(userID)) {
+		log.Error().Msg("User is not a group admin")
+		api_error.Write(w, r, http.StatusForbidden, api_error.FORBIDDEN, "Only group admins can see pending requests.")
+		return
+	}
+
+	requests, err := db.ReadPendingGroupRequestsByGroupId(groupID)
 	if err != nil {
-		log.Error().Err(err).Msg("Failed to decode user IDs, invalid JSON request")
-		w.WriteHeader(http.StatusBadRequest)
-		w.Write([]byte("Failed to decode user IDs, invalid JSON request."))
+		log.Error().Err(err).Msg("Failed to read group requests")
+		api_error.Write(w, r, http.StatusInternalServerError, api_error.INTERNAL, "Failed to read group requests.")
 		return
 	}
 
-	// Check the user is a valid user
-	if db.ReadByUserId(body["user_id"][0]).ID == bson.NilObjectID {
+	json.NewEncoder(w).Encode(requests)
+}
+
+func GetUserGroupRequests(w http.ResponseWriter, r *http.Request) {
+	log.Info().Msg("GET user pending group requests")
+
+	vars := mux.Vars(r)
+	user := db.ReadByUserId(vars["user_id"])
+	if user.ID == bson.NilObjectID {
 		log.Error().Msg("User does not exist")
-		w.WriteHeader(http.StatusBadRequest)
-		w.Write([]byte("User does not exist."))
+		api_error.Write(w, r, http.StatusBadRequest, api_error.USER_NOT_FOUND, "User does not exist.")
 		return
 	}
 
-	// Update the group request status
-	err2 := db.RejectGroupRequest(body["user_id"][0], body["group_id"][0])
-	if err2 != nil {
-		log.Error().Err(err2).Msg("Failed to reject group request")
-		w.WriteHeader(http.StatusInternalServerError)
-		w.Write([]byte("Failed to reject group request."))
-		return
+	requests := []db.GroupRequest{}
+	for _, request := range user.GroupRequests {
+		if request.Status == db.GROUP_REQUEST_PENDING {
+			requests = append(requests, request)
+		}
 	}
 
-	// Return success response
-	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(requests)
+}
+
+func AcceptGroupRequest(w http.ResponseWriter, r *http.Request) {
+	log.Info().Msg("POST accept group request")
+	answerGroupRequest(w, r, true)
+}
+
+func RejectGroupRequest(w http.ResponseWriter, r *http.Request) {
+	log.Info().Msg("POST reject group request")
+	answerGroupRequest(w, r, false)
+}
+
+func answerGroupRequest(w http.ResponseWriter, r *http.Request, accept bool) {
+	vars := mux.Vars(r)
+
+	// answer the request the same way the websocket events do
+	err := websocket.AnswerGroupRequest(websocket.WSManager, vars["user_id"], vars["group_id"], accept)
+	switch {
+	case err == nil:
+		w.WriteHeader(http.StatusOK)
+	case errors.Is(err, websocket.ErrGroupDoesNotExist):
+		api_error.Write(w, r, http.StatusNotFound, api_error.GROUP_NOT_FOUND, "Group does not exist.")
+	case errors.Is(err, websocket.ErrNoPendingGroupRequest):
+		api_error.Write(w, r, http.StatusNotFound, api_error.NOT_FOUND, "No pending request for this group.")
+	default:
+		log.Error().Err(err).Msg("Failed to answer group request")
+		api_error.Write(w, r, http.StatusInternalServerError, api_error.INTERNAL, "Failed to answer group request.")
+	}
 }
@@ -0,0 +1,105 @@
+package resources_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"Rivall-Backend/api/resources"
+	db "Rivall-Backend/db"
+	"Rivall-Backend/util/test"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// groupMembers lists a group's members as userID sees them, keyed by their ID
+func groupMembers(t *testing.T, userID string, groupID string) map[string]resources.GroupMemberRes {
+	t.Helper()
+
+	vars := map[string]string{"user_id": userID, "group_id": groupID}
+	w := serve(resources.GetGroupMembers, http.MethodGet, "", userID, vars)
+	test.Equal(t, w.Code, http.StatusOK)
+	members := []resources.GroupMemberRes{}
+	test.NoError(t, json.NewDecoder(w.Body).Decode(&members))
+
+	byID := make(map[string]resources.GroupMemberRes)
+	for _, member := range members {
+		byID[member.ID] = member
+	}
+	return byID
+}
+
+func TestGroupMembersShowEmailsToContacts(t *testing.T) {
+	setupHandlers(t)
+	sam := createUser(t, "sam@example.com").ID.Hex()
+	alex := createUser(t, "alex@example.com").ID.Hex()
+	kim := createUser(t, "kim@example.com").ID.Hex()
+	groupID, err := db.CreateGroup("Climbers", sam)
+	test.NoError(t, err)
+	test.NoError(t, db.AddUserToGroup(groupID, alex))
+	test.NoError(t, db.AddUserToGroup(groupID, kim))
+	test.NoError(t, db.CreateContact(sam, alex))
+
+	members := groupMembers(t, sam, groupID)
+	test.Equal(t, len(members), 3)
+	test.Equal(t, members[sam].Email, "sam@example.com")
+	test.Equal(t, members[alex].Email, "alex@example.com")
+	test.Equal(t, members[kim].Email, "")
+	test.Equal(t, members[kim].FirstName, "Sam")
+	test.Equal(t, members[sam].Role, db.GROUP_ROLE_OWNER)
+
+	// kim shares the group with them, not their contacts
+	members = groupMembers(t, kim, groupID)
+	test.Equal(t, members[kim].Email, "kim@example.com")
+	test.Equal(t, members[sam].Email, "")
+	test.Equal(t, members[alex].Email, "")
+}
+
+func TestGetGroupPagesMessages(t *testing.T) {
+	setupHandlers(t)
+	sam := createUser(t, "sam@example.com")
+	groupID, err := db.CreateGroup("Climbers", sam.ID.Hex())
+	test.NoError(t, err)
+	for i := 0; i < 5; i++ {
+		test.NoError(t, db.InsertGroupMessage(groupID, db.Message{
+			ID:          bson.NewObjectID(),
+			UserID:      sam.ID,
+			MessageData: fmt.Sprintf("message %d", i),
+			SeenBy:      []bson.ObjectID{},
+		}))
+	}
+
+	getGroup := func(query string) (int, resources.GroupWithMessagesRes) {
+		vars := map[string]string{"user_id": sam.ID.Hex(), "group_id": groupID}
+		w := serveQuery(resources.GetGroup, http.MethodGet, query, "", sam.ID.Hex(), vars)
+		res := resources.GroupWithMessagesRes{}
+		if w.Code == http.StatusOK {
+			test.NoError(t, json.NewDecoder(w.Body).Decode(&res))
+		}
+		return w.Code, res
+	}
+
+	// the newest messages come first, oldest first within the page
+	status, res := getGroup("limit=2")
+	test.Equal(t, status, http.StatusOK)
+	test.Equal(t, len(res.Messages), 2)
+	test.Equal(t, res.Messages[0].MessageData, "message 3")
+	test.Equal(t, res.Messages[1].MessageData, "message 4")
+	test.Equal(t, res.HasMoreMessages, true)
+
+	_, res = getGroup("limit=2&offset=4")
+	test.Equal(t, len(res.Messages), 1)
+	test.Equal(t, res.Messages[0].MessageData, "message 0")
+	test.Equal(t, res.HasMoreMessages, false)
+
+	_, res = getGroup("offset=10")
+	test.Equal(t, len(res.Messages), 0)
+
+	_, res = getGroup("")
+	test.Equal(t, len(res.Messages), 5)
+	test.Equal(t, res.HasMoreMessages, false)
+
+	status, _ = getGroup(fmt.Sprintf("limit=%d", resources.MAX_MESSAGE_PAGE_SIZE+1))
+	test.Equal(t, status, http.StatusBadRequest)
+}
@@ -1,16 +1,15 @@
 package resources
 
 import (
+	"encoding/json"
 	"net/http"
 )
 
-// Read godoc
-//
-//	@summary		Read health
-//	@description	Read health
-//	@tags			health
-//	@success		200
-//	@router			/../livez [get]
+// HealthRes answers health checks, like every response it is JSON
+type HealthRes struct {
+	Status string `json:"status"`
+}
+
 func Read(w http.ResponseWriter, _ *http.Request) {
 
 	// Send a ping to confirm a healthy db connection
@@ -23,5 +22,5 @@ func Read(w http.ResponseWriter, _ *http.Request) {
 	// }
 	// log.Info().Msg("Pinged your deployment. MongoDB is connected.  API is Healthy.")
 
-	w.Write([]byte("API is Healthy"))
+	json.NewEncoder(w).Encode(HealthRes{Status: "healthy"})
 }
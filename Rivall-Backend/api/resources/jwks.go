@@ -0,0 +1,20 @@
+package resources
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"Rivall-Backend/globals"
+
+	"github.com/rs/zerolog/log"
+)
+
+func GetJWKS(w http.ResponseWriter, r *http.Request) {
+	log.Info().Msg("GET JWKS")
+
+	// verifiers may cache the set briefly and refetch it when they meet an unknown kid
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	json.NewEncoder(w).Encode(globals.Keyring.JWKS(time.Now()))
+}
@@ -0,0 +1,258 @@
+package resources
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	db "Rivall-Backend/db"
+	"Rivall-Backend/globals"
+	"Rivall-Backend/util/api_error"
+	"Rivall-Backend/util/oidc"
+	"Rivall-Backend/util/session_manager"
+
+	"github.com/gorilla/mux"
+	"github.com/rs/zerolog/log"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+type OIDCAuthorizationRes struct {
+	AuthorizationURL string    `json:"authorization_url"`
+	State            string    `json:"state"`
+	ExpiresAt        time.Time `json:"expires_at"`
+}
+
+// OIDCCallbackReq carries what the provider sent back to the client app's redirect URL
+type OIDCCallbackReq struct {
+	State string `json:"state" form:"required"`
+	Code  string `json:"code"  form:"required"`
+}
+
+func oidcProvider(w http.ResponseWriter, r *http.Request) (*oidc.Provider, bool) {
+	provider, ok := globals.OIDCProviders[mux.Vars(r)["provider"]]
+	if !ok {
+		log.Error().Msg("Unknown OIDC provider")
+		api_error.Write(w, r, http.StatusNotFound, api_error.NOT_FOUND, "Unknown provider")
+	}
+	return provider, ok
+}
+
+// startOIDC sends the client to the provider, userID is set when linking an account
+func startOIDC(w http.ResponseWriter, r *http.Request, provider *oidc.Provider, userID string) {
+	verifier, challenge, err := oidc.NewPKCE()
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to create PKCE verifier")
+		api_error.Write(w, r, http.StatusInternalServerError, api_error.INTERNAL, "Failed to start sign in")
+		return
+	}
+	nonce, err := oidc.NewNonce()
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to create nonce")
+		api_error.Write(w, r, http.StatusInternalServerError, api_error.INTERNAL, "Failed to start sign in")
+		return
+	}
+
+	state, err := globals.SessionManager.NewOIDCState(provider.Name(), userID, nonce, verifier)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to save OIDC state")
+		api_error.Write(w, r, http.StatusInternalServerError, api_error.INTERNAL, "Failed to start sign in")
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(OIDCAuthorizationRes{
+		AuthorizationURL: provider.AuthCodeURL(state.Token, nonce, challenge),
+		State:            state.Token,
+		ExpiresAt:        state.TokenExpiresAt,
+	})
+}
+
+// finishOIDC spends the state and trades the code for the provider's verified claims
+func finishOIDC(w http.ResponseWriter, r *http.Request, provider *oidc.Provider, userID string) (oidc.Claims, bool) {
+	req := OIDCCallbackReq{}
+	if !decodeRequest(w, r, &req) {
+		return oidc.Claims{}, false
+	}
+
+	state, err := globals.SessionManager.UseOIDCState(req.State, provider.Name(), userID)
+	if errors.Is(err, session_manager.ErrOIDCStateNotFound) {
+		log.Warn().Msg("Invalid OIDC state")
+		api_error.Write(w, r, http.StatusUnauthorized, api_error.INVALID_TOKEN, "Sign in is invalid or expired, try again")
+		return oidc.Claims{}, false
+	}
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to read OIDC state")
+		api_error.Write(w, r, http.StatusInternalServerError, api_error.INTERNAL, "Failed to finish sign in")
+		return oidc.Claims{}, false
+	}
+
+	idToken, err := provider.Exchange(r.Context(), req.Code, state.CodeVerifier)
+	if err != nil {
+		log.Warn().Err(err).Msg("Failed to exchange OIDC code")
+		api_error.Write(w, r, http.StatusUnauthorized, api_error.UNAUTHORIZED, "Sign in with the provider failed")
+		return oidc.Claims{}, false
+	}
+	claims, err := provider.Verify(r.Context(), idToken, state.Nonce)
+	if err != nil {
+		log.Warn().Err(err).Msg("Invalid OIDC id token")
+		api_error.Write(w, r, http.StatusUnauthorized, api_error.UNAUTHORIZED, "Sign in with the provider failed")
+		return oidc.Claims{}, false
+	}
+	return claims, true
+}
+
+func newIdentity(provider *oidc.Provider, claims oidc.Claims) db.Identity {
+	return db.Identity{
+		Provider: provider.Name(),
+		Subject:  claims.Subject,
+		Email:    claims.Email,
+		LinkedAt: time.Now(),
+	}
+}
+
+func StartOIDCLogin(w http.ResponseWriter, r *http.Request) {
+	log.Info().Msg("GET start OIDC login")
+
+	provider, ok := oidcProvider(w, r)
+	if !ok {
+		return
+	}
+	startOIDC(w, r, provider, "")
+}
+
+func OIDCLoginCallback(w http.ResponseWriter, r *http.Request) {
+	log.Info().Msg("POST OIDC login callback")
+
+	provider, ok := oidcProvider(w, r)
+	if !ok {
+		return
+	}
+	claims, ok := finishOIDC(w, r, provider, "")
+	if !ok {
+		return
+	}
+
+	// a linked identity logs in whatever its email is now
+	user := db.ReadByIdentity(provider.Name(), claims.Subject)
+	if user.ID != bson.NilObjectID {
+		startLogin(w, r, user, false)
+		return
+	}
+
+	if !claims.EmailVerified || claims.Email == "" {
+		log.Warn().Msg("OIDC email is not verified")
+		api_error.Write(w, r, http.StatusForbidden, api_error.FORBIDDEN, "The provider has not verified your email address.")
+		return
+	}
+
+	user = db.ReadByUserEmail(claims.Email)
+	if user.ID != bson.NilObjectID {
+		// whoever registered an unverified account may not own the email, they would keep
+		// its password and share the account
+		if !user.EmailVerified {
+			writeEmailNotVerified(w, r)
+			return
+		}
+		if err := db.LinkIdentity(user.ID.Hex(), newIdentity(provider, claims)); err != nil {
+			writeLinkError(w, r, err)
+			return
+		}
+		startLogin(w, r, user, false)
+		return
+	}
+
+	if !checkRateLimit(w, r, globals.RegistrationLimiter, "") {
+		return
+	}
+	failRateLimit(r, globals.RegistrationLimiter, "")
+
+	user, err := db.CreateUserWithIdentity(db.User{
+		FirstName: claims.GivenName,
+		LastName:  claims.FamilyName,
+		Email:     claims.Email,
+	}, newIdentity(provider, claims))
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to insert user")
+		if errors.Is(err, db.ErrEmailTaken) {
+			api_error.Write(w, r, http.StatusConflict, api_error.EMAIL_TAKEN, "Email is already in use.")
+			return
+		}
+		api_error.Write(w, r, http.StatusInternalServerError, api_error.INTERNAL, "Failed to insert user")
+		return
+	}
+
+	startLogin(w, r, user, false)
+}
+
+func writeLinkError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, db.ErrIdentityTaken):
+		api_error.Write(w, r, http.StatusConflict, api_error.CONFLICT, "This provider account is linked to another user.")
+	case errors.Is(err, db.ErrProviderLinked):
+		api_error.Write(w, r, http.StatusConflict, api_error.CONFLICT, "Another account with this provider is already linked.")
+	default:
+		api_error.Write(w, r, http.StatusInternalServerError, api_error.INTERNAL, "Failed to link provider")
+	}
+}
+
+func StartOIDCLink(w http.ResponseWriter, r *http.Request) {
+	log.Info().Msg("POST start OIDC link")
+
+	provider, ok := oidcProvider(w, r)
+	if !ok {
+		return
+	}
+	startOIDC(w, r, provider, mux.Vars(r)["user_id"])
+}
+
+func LinkOIDCCallback(w http.ResponseWriter, r *http.Request) {
+	log.Info().Msg("POST OIDC link callback")
+
+	provider, ok := oidcProvider(w, r)
+	if !ok {
+		return
+	}
+	userID := mux.Vars(r)["user_id"]
+	claims, ok := finishOIDC(w, r, provider, userID)
+	if !ok {
+		return
+	}
+
+	if err := db.LinkIdentity(userID, newIdentity(provider, claims)); err != nil {
+		writeLinkError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func UnlinkOIDC(w http.ResponseWriter, r *http.Request) {
+	log.Info().Msg("DELETE OIDC identity")
+
+	userID := mux.Vars(r)["user_id"]
+	user := db.ReadByUserId(userID)
+	if user.ID == bson.NilObjectID {
+		log.Error().Msg("User does not exist")
+		api_error.Write(w, r, http.StatusBadRequest, api_error.USER_NOT_FOUND, "User does not exist.")
+		return
+	}
+
+	// never remove the last way to log in
+	if user.Password == "" && len(user.Identities) <= 1 {
+		api_error.Write(w, r, http.StatusConflict, api_error.CONFLICT, "Set a password before unlinking your only provider.")
+		return
+	}
+
+	err := db.UnlinkIdentity(userID, mux.Vars(r)["provider"])
+	if errors.Is(err, db.ErrIdentityNotFound) {
+		api_error.Write(w, r, http.StatusNotFound, api_error.NOT_FOUND, "Provider is not linked.")
+		return
+	}
+	if err != nil {
+		api_error.Write(w, r, http.StatusInternalServerError, api_error.INTERNAL, "Failed to unlink provider")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,194 @@
+package resources_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"Rivall-Backend/api/resources"
+	db "Rivall-Backend/db"
+	"Rivall-Backend/globals"
+	"Rivall-Backend/util/oidc"
+	"Rivall-Backend/util/test"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// setupOIDC registers a fake provider as "fake"
+func setupOIDC(t *testing.T) *test.OIDCProvider {
+	fake := test.NewOIDCProvider(t)
+	globals.OIDCProviders = map[string]*oidc.Provider{"fake": fake.Discover(t, "fake")}
+	t.Cleanup(func() { globals.OIDCProviders = nil })
+	return fake
+}
+
+// signIn runs the client's side of the flow, userID is set when linking, and returns the
+// callback's response
+func signIn(t *testing.T, fake *test.OIDCProvider, userID string, claims jwt.MapClaims) (int, []byte) {
+	t.Helper()
+
+	start, callback := resources.StartOIDCLogin, resources.OIDCLoginCallback
+	vars := map[string]string{"provider": "fake"}
+	if userID != "" {
+		start, callback = resources.StartOIDCLink, resources.LinkOIDCCallback
+		vars["user_id"] = userID
+	}
+
+	w := serve(start, http.MethodPost, "", userID, vars)
+	test.Equal(t, w.Code, http.StatusOK)
+	authorization := resources.OIDCAuthorizationRes{}
+	test.NoError(t, json.NewDecoder(w.Body).Decode(&authorization))
+	authURL, err := url.Parse(authorization.AuthorizationURL)
+	test.NoError(t, err)
+
+	// the user signs in at the provider, which redirects back with a code
+	code := fake.Grant(authURL.Query().Get("code_challenge"), authURL.Query().Get("nonce"), claims)
+	body := fmt.Sprintf(`{"state":%q,"code":%q}`, authorization.State, code)
+	w = serve(callback, http.MethodPost, body, userID, vars)
+	return w.Code, w.Body.Bytes()
+}
+
+func verifiedClaims(subject string, email string) jwt.MapClaims {
+	return jwt.MapClaims{"sub": subject, "email": email, "email_verified": true, "given_name": "Sam", "family_name": "Doe"}
+}
+
+func TestOIDCLoginCreatesUser(t *testing.T) {
+	setupHandlers(t)
+	fake := setupOIDC(t)
+
+	status, body := signIn(t, fake, "", verifiedClaims("subject-1", "Sam@Example.com"))
+	test.Equal(t, status, http.StatusAccepted)
+	res := resources.LoginUserRes{}
+	test.NoError(t, json.Unmarshal(body, &res))
+	if res.AccessToken == "" {
+		t.Fatal("Expected sessions for the new user")
+	}
+
+	user := db.ReadByUserEmail("sam@example.com")
+	test.Equal(t, user.ID.Hex(), res.User.ID)
+	test.Equal(t, user.FirstName, "Sam")
+	test.Equal(t, user.Password, "")
+	test.Equal(t, user.EmailVerified, true)
+	test.Equal(t, len(user.Identities), 1)
+	test.Equal(t, user.Identities[0].Provider, "fake")
+	test.Equal(t, user.Identities[0].Subject, "subject-1")
+
+	// signing in again logs in the same user, even after the email changed at the provider
+	status, body = signIn(t, fake, "", verifiedClaims("subject-1", "other@example.com"))
+	test.Equal(t, status, http.StatusAccepted)
+	test.NoError(t, json.Unmarshal(body, &res))
+	test.Equal(t, res.User.ID, user.ID.Hex())
+}
+
+func TestOIDCLoginNeedsVerifiedEmail(t *testing.T) {
+	setupHandlers(t)
+	fake := setupOIDC(t)
+
+	claims := verifiedClaims("subject-1", "sam@example.com")
+	claims["email_verified"] = false
+	status, _ := signIn(t, fake, "", claims)
+	test.Equal(t, status, http.StatusForbidden)
+	test.Equal(t, db.ReadByUserEmail("sam@example.com").ID.IsZero(), true)
+}
+
+func TestOIDCLoginLinksVerifiedAccount(t *testing.T) {
+	setupHandlers(t)
+	fake := setupOIDC(t)
+	user := createUser(t, "sam@example.com")
+	test.NoError(t, db.CreateUser(db.User{FirstName: "Alex", LastName: "Roe", Email: "alex@example.com", Password: PASSWORD}))
+
+	status, body := signIn(t, fake, "", verifiedClaims("subject-1", "sam@example.com"))
+	test.Equal(t, status, http.StatusAccepted)
+	res := resources.LoginUserRes{}
+	test.NoError(t, json.Unmarshal(body, &res))
+	test.Equal(t, res.User.ID, user.ID.Hex())
+	test.Equal(t, len(db.ReadByUserId(user.ID.Hex()).Identities), 1)
+
+	// whoever registered an unverified account may not own its email
+	status, _ = signIn(t, fake, "", verifiedClaims("subject-2", "alex@example.com"))
+	test.Equal(t, status, http.StatusForbidden)
+	test.Equal(t, len(db.ReadByUserEmail("alex@example.com").Identities), 0)
+}
+
+func TestLinkOIDC(t *testing.T) {
+	setupHandlers(t)
+	fake := setupOIDC(t)
+	sam := createUser(t, "sam@example.com")
+	alex := createUser(t, "alex@example.com")
+
+	// the provider account's email doesn't have to match
+	status, _ := signIn(t, fake, sam.ID.Hex(), verifiedClaims("subject-1", "sam.doe@example.net"))
+	test.Equal(t, status, http.StatusNoContent)
+	identities := db.ReadByUserId(sam.ID.Hex()).Identities
+	test.Equal(t, len(identities), 1)
+	test.Equal(t, identities[0].Subject, "subject-1")
+
+	status, _ = signIn(t, fake, alex.ID.Hex(), verifiedClaims("subject-1", "sam.doe@example.net"))
+	test.Equal(t, status, http.StatusConflict)
+
+	status, _ = signIn(t, fake, sam.ID.Hex(), verifiedClaims("subject-2", "sam@example.com"))
+	test.Equal(t, status, http.StatusConflict)
+
+	// the linked account now logs in as its user
+	status, body := signIn(t, fake, "", verifiedClaims("subject-1", "sam.doe@example.net"))
+	test.Equal(t, status, http.StatusAccepted)
+	res := resources.LoginUserRes{}
+	test.NoError(t, json.Unmarshal(body, &res))
+	test.Equal(t, res.User.ID, sam.ID.Hex())
+}
+
+func TestPasswordlessUserConfirmsWithEmailCode(t *testing.T) {
+	backend := setupHandlers(t)
+	fake := setupOIDC(t)
+
+	status, _ := signIn(t, fake, "", verifiedClaims("subject-1", "sam@example.com"))
+	test.Equal(t, status, http.StatusAccepted)
+	userID := db.ReadByUserEmail("sam@example.com").ID.Hex()
+	vars := map[string]string{"user_id": userID}
+
+	// there is no password to confirm with
+	w := serve(resources.DeleteUser, http.MethodDelete, `{"password":""}`, userID, vars)
+	test.Equal(t, w.Code, http.StatusUnauthorized)
+
+	w = serve(resources.SendConfirmationCode, http.MethodPost, "", userID, vars)
+	test.Equal(t, w.Code, http.StatusAccepted)
+	code := mailedCode(t, backend, "sam@example.com")
+
+	body := fmt.Sprintf(`{"password":%q,"email_code":%q}`, PASSWORD, code)
+	w = serve(resources.UpdateUserPassword, http.MethodPut, body, userID, vars)
+	test.Equal(t, w.Code, http.StatusCreated)
+	test.Equal(t, db.CheckUserPassword(db.ReadByUserId(userID), PASSWORD), true)
+
+	// with a password set, the password is what confirms
+	w = serve(resources.SendConfirmationCode, http.MethodPost, "", userID, vars)
+	test.Equal(t, w.Code, http.StatusConflict)
+	w = serve(resources.DeleteUser, http.MethodDelete, fmt.Sprintf(`{"email_code":%q}`, code), userID, vars)
+	test.Equal(t, w.Code, http.StatusUnauthorized)
+	w = serve(resources.DeleteUser, http.MethodDelete, fmt.Sprintf(`{"password":%q}`, PASSWORD), userID, vars)
+	test.Equal(t, w.Code, http.StatusAccepted)
+}
+
+func TestPasswordlessUserDeletesWithEmailCode(t *testing.T) {
+	backend := setupHandlers(t)
+	fake := setupOIDC(t)
+
+	status, _ := signIn(t, fake, "", verifiedClaims("subject-1", "sam@example.com"))
+	test.Equal(t, status, http.StatusAccepted)
+	userID := db.ReadByUserEmail("sam@example.com").ID.Hex()
+	vars := map[string]string{"user_id": userID}
+
+	w := serve(resources.DeleteUser, http.MethodDelete, `{"email_code":"AAAAAAAA"}`, userID, vars)
+	test.Equal(t, w.Code, http.StatusUnauthorized)
+
+	w = serve(resources.SendConfirmationCode, http.MethodPost, "", userID, vars)
+	test.Equal(t, w.Code, http.StatusAccepted)
+	code := mailedCode(t, backend, "sam@example.com")
+
+	w = serve(resources.DeleteUser, http.MethodDelete, fmt.Sprintf(`{"email_code":%q}`, code), userID, vars)
+	test.Equal(t, w.Code, http.StatusAccepted)
+	if db.ReadByUserId(userID).DeletionScheduledAt == nil {
+		t.Fatal("Expected the deletion to be scheduled")
+	}
+}
@@ -0,0 +1,55 @@
+package resources
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"sync"
+
+	db "Rivall-Backend/db"
+	"Rivall-Backend/util/api_error"
+	"Rivall-Backend/util/rate_limiter"
+
+	"github.com/rs/zerolog/log"
+)
+
+// dummyPasswordHash is compared against when no user matches a login, so unknown emails
+// take as long to answer as wrong passwords
+var dummyPasswordHash = sync.OnceValue(func() string {
+	user, err := db.HashUserPassword(db.User{Password: "not a real password"})
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to hash dummy password")
+	}
+	return user.Password
+})
+
+// checkRateLimit writes a 429 and reports false when the client IP or the account is
+// locked out. Lockouts look the same whichever key caused them.
+func checkRateLimit(w http.ResponseWriter, r *http.Request, limiter *rate_limiter.Limiter, account string) bool {
+	wait, err := limiter.Check(r.Context(), clientIP(r), account)
+	if errors.Is(err, rate_limiter.ErrLimited) {
+		log.Warn().Msg("Rate limited")
+		w.Header().Set("Retry-After", fmt.Sprint(int(math.Ceil(wait.Seconds()))))
+		api_error.Write(w, r, http.StatusTooManyRequests, api_error.RATE_LIMITED, "Too many attempts, try again later")
+		return false
+	}
+	if err != nil {
+		// an unavailable store shouldn't lock everyone out
+		log.Error().Err(err).Msg("Failed to check rate limit")
+	}
+	return true
+}
+
+func failRateLimit(r *http.Request, limiter *rate_limiter.Limiter, account string) {
+	if err := limiter.Fail(r.Context(), clientIP(r), account); err != nil {
+		log.Error().Err(err).Msg("Failed to count rate limited attempt")
+	}
+}
+
+// resetRateLimit forgets the account's failures once it fully authenticated
+func resetRateLimit(r *http.Request, limiter *rate_limiter.Limiter, account string) {
+	if err := limiter.Reset(r.Context(), account); err != nil {
+		log.Error().Err(err).Msg("Failed to reset rate limit")
+	}
+}
@@ -0,0 +1,90 @@
+package resources_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"Rivall-Backend/api/resources"
+	"Rivall-Backend/globals"
+	"Rivall-Backend/util/client_ip"
+	"Rivall-Backend/util/rate_limiter"
+	"Rivall-Backend/util/test"
+	"Rivall-Backend/util/validator"
+)
+
+func TestSpoofedForwardedForKeepsIPLimit(t *testing.T) {
+	globals.Validator = validator.New()
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	resolver, err := client_ip.New([]string{"10.0.0.1"})
+	test.NoError(t, err)
+	globals.ClientIP = resolver
+	t.Cleanup(func() { globals.ClientIP = nil })
+
+	tests := []struct {
+		name      string
+		remote    string
+		forwarded string
+	}{
+		{name: "direct", remote: "192.0.2.1:4000", forwarded: "203.0.113.5"},
+		{name: "behind a trusted proxy", remote: "10.0.0.1:4000", forwarded: "203.0.113.5, 192.0.2.1"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			globals.LoginLimiter = rate_limiter.New(rate_limiter.NewMemoryStore(ctx), "login",
+				rate_limiter.Policy{Threshold: 1, BaseDelay: time.Minute, MaxDelay: time.Minute, Window: time.Hour},
+				rate_limiter.Policy{},
+				nil,
+			)
+			test.NoError(t, globals.LoginLimiter.Fail(ctx, "192.0.2.1", ""))
+
+			// the locked out client answers before the database is touched
+			body := `{"email":"sam@example.com","password":"hunter22"}`
+			r := httptest.NewRequest(http.MethodPost, "/api/v1/auth/login", strings.NewReader(body))
+			r.RemoteAddr = tc.remote
+			r.Header.Set("X-Forwarded-For", tc.forwarded)
+			w := httptest.NewRecorder()
+			resources.LoginUser(w, r)
+
+			test.Equal(t, w.Code, http.StatusTooManyRequests)
+		})
+	}
+}
+
+func TestEmailVerificationIsRateLimited(t *testing.T) {
+	globals.Validator = validator.New()
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	tests := []struct {
+		name    string
+		body    string
+		handler http.HandlerFunc
+	}{
+		{name: "verify", body: `{"email":"sam@example.com","code":"AAAAAAAA"}`, handler: resources.VerifyEmail},
+		{name: "resend", body: `{"email":"sam@example.com"}`, handler: resources.ResendVerificationEmail},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			globals.VerificationLimiter = rate_limiter.New(rate_limiter.NewMemoryStore(ctx), "verification",
+				rate_limiter.Policy{},
+				rate_limiter.Policy{Threshold: 1, BaseDelay: time.Minute, MaxDelay: time.Minute, Window: time.Hour},
+				nil,
+			)
+			test.NoError(t, globals.VerificationLimiter.Fail(ctx, "", "sam@example.com"))
+
+			r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(tc.body))
+			w := httptest.NewRecorder()
+			tc.handler(w, r)
+
+			test.Equal(t, w.Code, http.StatusTooManyRequests)
+		})
+	}
+}
@@ -0,0 +1,109 @@
+package resources
+
+import (
+	"errors"
+	"net/http"
+
+	db "Rivall-Backend/db"
+	"Rivall-Backend/globals"
+	"Rivall-Backend/util/api_error"
+	"Rivall-Backend/util/mailer"
+	"Rivall-Backend/util/otp"
+
+	"github.com/gorilla/mux"
+	"github.com/rs/zerolog/log"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// confirmIdentity checks the user is who the access token says before a sensitive change,
+// with their password or, for users without one like those created by an OIDC provider,
+// a code mailed by SendConfirmationCode. Failures count against the account like logins.
+func confirmIdentity(w http.ResponseWriter, r *http.Request, user db.User, password string, emailCode string) bool {
+	if !checkRateLimit(w, r, globals.LoginLimiter, user.Email) {
+		return false
+	}
+
+	if user.Password == "" {
+		err := globals.OTP.Verify(otp.PURPOSE_REAUTHENTICATION, user.Email, clientIP(r), emailCode)
+		if errors.Is(err, otp.ErrLockedOut) {
+			writeOTPError(w, r, err)
+			return false
+		}
+		if err != nil {
+			log.Warn().Msg("Invalid confirmation code")
+			failRateLimit(r, globals.LoginLimiter, user.Email)
+			api_error.Write(w, r, http.StatusUnauthorized, api_error.INVALID_CREDENTIALS, "Invalid password or code")
+			return false
+		}
+		return true
+	}
+
+	if !db.CheckUserPassword(user, password) {
+		log.Warn().Msg("Invalid password")
+		failRateLimit(r, globals.LoginLimiter, user.Email)
+		api_error.Write(w, r, http.StatusUnauthorized, api_error.INVALID_CREDENTIALS, "Invalid password or code")
+		return false
+	}
+	return true
+}
+
+// confirmSecondFactor checks the authenticator or recovery code of users with two-factor
+// authentication after confirmIdentity, users without it pass
+func confirmSecondFactor(w http.ResponseWriter, r *http.Request, user db.User, code string) bool {
+	if !user.TwoFactor.Enabled {
+		return true
+	}
+
+	ok, err := checkSecondFactor(user, code)
+	if err != nil {
+		api_error.Write(w, r, http.StatusInternalServerError, api_error.INTERNAL, "Failed to check two factor code")
+		return false
+	}
+	if !ok {
+		log.Warn().Msg("Invalid two factor code")
+		failRateLimit(r, globals.LoginLimiter, user.Email)
+		api_error.Write(w, r, http.StatusUnauthorized, api_error.INVALID_CREDENTIALS, "Invalid password or code")
+		return false
+	}
+	return true
+}
+
+func SendConfirmationCode(w http.ResponseWriter, r *http.Request) {
+	log.Info().Msg("POST confirmation code")
+
+	userID := mux.Vars(r)["user_id"]
+	user := db.ReadByUserId(userID)
+	if user.ID == bson.NilObjectID {
+		log.Error().Msg("User does not exist")
+		api_error.Write(w, r, http.StatusBadRequest, api_error.USER_NOT_FOUND, "User does not exist.")
+		return
+	}
+	if user.Password != "" {
+		api_error.Write(w, r, http.StatusConflict, api_error.CONFLICT, "Confirm with your password instead.")
+		return
+	}
+
+	// every code sent counts, confirmation mail must not be usable to flood an inbox
+	if !checkRateLimit(w, r, globals.VerificationLimiter, user.Email) {
+		return
+	}
+	failRateLimit(r, globals.VerificationLimiter, user.Email)
+
+	code, _, err := globals.OTP.Issue(otp.PURPOSE_REAUTHENTICATION, user.Email)
+	if err != nil {
+		writeOTPError(w, r, err)
+		return
+	}
+	err = globals.Mailer.Send(user.Email, mailer.TEMPLATE_CONFIRMATION_CODE, mailer.ConfirmationCodeData{
+		FirstName:        user.FirstName,
+		Code:             code,
+		ExpiresInMinutes: int(globals.OTP.CodeTimeout().Minutes()),
+	})
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to queue confirmation email")
+		api_error.Write(w, r, http.StatusServiceUnavailable, api_error.INTERNAL, "Failed to send confirmation email")
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
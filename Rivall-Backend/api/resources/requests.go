@@ -0,0 +1,130 @@
+package resources
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"Rivall-Backend/globals"
+	"Rivall-Backend/util/api_error"
+	"Rivall-Backend/util/validator"
+
+	"github.com/rs/zerolog/log"
+)
+
+// MAX_REQUEST_BODY is the largest JSON body a handler reads, in bytes
+const MAX_REQUEST_BODY = 1 << 20
+
+// normalizer is a request that tidies its fields, like trimming spaces, before validation
+type normalizer interface {
+	normalize()
+}
+
+// decodeRequest decodes the JSON body into req and validates it with its form tags. It
+// writes the error response and reports false when the body is too large, is not JSON,
+// has fields req doesn't know, or fails validation.
+func decodeRequest(w http.ResponseWriter, r *http.Request, req any) bool {
+	r.Body = http.MaxBytesReader(w, r.Body, MAX_REQUEST_BODY)
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+
+	err := decoder.Decode(req)
+	if err == nil && decoder.Decode(&struct{}{}) != io.EOF {
+		err = errors.New("body holds more than one JSON value")
+	}
+	if err != nil {
+		log.Error().Err(err).Msgf("Failed to decode %T", req)
+		writeDecodeError(w, r, err)
+		return false
+	}
+
+	if n, ok := req.(normalizer); ok {
+		n.normalize()
+	}
+	if err := globals.Validator.Struct(req); err != nil {
+		log.Error().Err(err).Msgf("Invalid %T", req)
+		api_error.WriteValidation(w, r, err)
+		return false
+	}
+	return true
+}
+
+func writeDecodeError(w http.ResponseWriter, r *http.Request, err error) {
+	var maxBytesErr *http.MaxBytesError
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &maxBytesErr):
+		api_error.Write(w, r, http.StatusRequestEntityTooLarge, api_error.TOO_LARGE,
+			fmt.Sprintf("Request body must be at most %d bytes.", maxBytesErr.Limit))
+	case errors.As(err, &typeErr):
+		api_error.WriteError(w, r, http.StatusUnprocessableEntity, api_error.Error{
+			Code:    api_error.VALIDATION_FAILED,
+			Message: "The request has invalid fields.",
+			Fields: []validator.FieldError{{
+				Field:   typeErr.Field,
+				Message: fmt.Sprintf("%s must be a %s", typeErr.Field, jsonType(typeErr.Type.Kind().String())),
+			}},
+		})
+	case strings.HasPrefix(err.Error(), "json: unknown field "):
+		// the decoder has no error type for unknown fields
+		field := strings.Trim(strings.TrimPrefix(err.Error(), "json: unknown field "), `"`)
+		api_error.WriteError(w, r, http.StatusUnprocessableEntity, api_error.Error{
+			Code:    api_error.VALIDATION_FAILED,
+			Message: "The request has invalid fields.",
+			Fields:  []validator.FieldError{{Field: field, Message: fmt.Sprintf("%s is not a known field", field)}},
+		})
+	default:
+		api_error.Write(w, r, http.StatusBadRequest, api_error.INVALID_JSON, "Failed to decode request, invalid JSON request.")
+	}
+}
+
+// jsonType names a Go kind the way a JSON client knows it
+func jsonType(kind string) string {
+	switch {
+	case kind == "bool":
+		return "boolean"
+	case kind == "string":
+		return "string"
+	case kind == "slice" || kind == "array":
+		return "list"
+	case kind == "struct" || kind == "map":
+		return "object"
+	case strings.HasPrefix(kind, "int") || strings.HasPrefix(kind, "uint") || strings.HasPrefix(kind, "float"):
+		return "number"
+	default:
+		return kind
+	}
+}
+
+func getPagination(w http.ResponseWriter, r *http.Request, defaultLimit int64, maxLimit int64) (int64, int64, bool) {
+	// read limit and offset query parameters, writing a bad request if they are invalid
+	query := r.URL.Query()
+
+	limit := defaultLimit
+	if v := query.Get("limit"); v != "" {
+		parsed, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || parsed < 1 || parsed > maxLimit {
+			log.Error().Msg("Invalid limit")
+			api_error.Write(w, r, http.StatusBadRequest, api_error.BAD_REQUEST, "Invalid limit.")
+			return 0, 0, false
+		}
+		limit = parsed
+	}
+
+	offset := int64(0)
+	if v := query.Get("offset"); v != "" {
+		parsed, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || parsed < 0 {
+			log.Error().Msg("Invalid offset")
+			api_error.Write(w, r, http.StatusBadRequest, api_error.BAD_REQUEST, "Invalid offset.")
+			return 0, 0, false
+		}
+		offset = parsed
+	}
+
+	return limit, offset, true
+}
@@ -0,0 +1,55 @@
+package resources_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"Rivall-Backend/api/resources"
+	"Rivall-Backend/globals"
+	"Rivall-Backend/util/api_error"
+	"Rivall-Backend/util/test"
+	"Rivall-Backend/util/validator"
+)
+
+func TestDecodeRequest(t *testing.T) {
+	globals.Validator = validator.New()
+
+	tests := []struct {
+		name   string
+		body   string
+		status int
+		code   string
+		field  string
+	}{
+		{name: "invalid json", body: `{"email":`, status: http.StatusBadRequest, code: api_error.INVALID_JSON},
+		{name: "trailing data", body: `{"email":"sam@example.com","password":"x"} {}`, status: http.StatusBadRequest, code: api_error.INVALID_JSON},
+		{name: "too large", body: `{"email":"` + strings.Repeat("a", resources.MAX_REQUEST_BODY) + `"}`, status: http.StatusRequestEntityTooLarge, code: api_error.TOO_LARGE},
+		{name: "unknown field", body: `{"email":"sam@example.com","password":"x","admin":true}`, status: http.StatusUnprocessableEntity, code: api_error.VALIDATION_FAILED, field: "admin"},
+		{name: "wrong type", body: `{"email":"sam@example.com","password":7}`, status: http.StatusUnprocessableEntity, code: api_error.VALIDATION_FAILED, field: "password"},
+		{name: "missing field", body: `{"password":"x"}`, status: http.StatusUnprocessableEntity, code: api_error.VALIDATION_FAILED, field: "email"},
+		{name: "invalid field", body: `{"email":"sam","password":"x"}`, status: http.StatusUnprocessableEntity, code: api_error.VALIDATION_FAILED, field: "email"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			// login answers before touching the database when the body is rejected
+			r := httptest.NewRequest(http.MethodPost, "/api/v1/login", strings.NewReader(tc.body))
+			w := httptest.NewRecorder()
+			resources.LoginUser(w, r)
+
+			test.Equal(t, w.Code, tc.status)
+			var res api_error.Response
+			test.NoError(t, json.NewDecoder(w.Body).Decode(&res))
+			test.Equal(t, res.Error.Code, tc.code)
+			if tc.field == "" {
+				test.Equal(t, len(res.Error.Fields), 0)
+				return
+			}
+			test.Equal(t, len(res.Error.Fields), 1)
+			test.Equal(t, res.Error.Fields[0].Field, tc.field)
+		})
+	}
+}
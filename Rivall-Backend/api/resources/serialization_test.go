@@ -0,0 +1,208 @@
+package resources_test
+
+import (
+	"bufio"
+	"bytes"
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"Rivall-Backend/util/test"
+)
+
+// servingPackages write responses and websocket events
+var servingPackages = []string{"Rivall-Backend/api/resources", "Rivall-Backend/api/websocket"}
+
+// modelPackages hold the types that are stored, they are never serialized when they have secret fields
+var modelPackages = map[string]bool{
+	"Rivall-Backend/db":                   true,
+	"Rivall-Backend/util/session_manager": true,
+}
+
+var secretFields = map[string]bool{
+	"Password":      true,
+	"OTP":           true,
+	"RefreshToken":  true,
+	"Secret":        true,
+	"PendingSecret": true,
+	"RecoveryCodes": true,
+	"Token":         true,
+	"TokenHash":     true,
+	"Nonce":         true,
+	"CodeVerifier":  true,
+	"ClientSecret":  true,
+}
+
+// exportData lists the compiled export data of the serving packages and their dependencies
+func exportData(t *testing.T) map[string]string {
+	args := append([]string{"list", "-export", "-deps", "-f", "{{.ImportPath}} {{.Export}}"}, servingPackages...)
+	out, err := exec.Command("go", args...).Output()
+	test.NoError(t, err)
+
+	exports := make(map[string]string)
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		path, export, _ := strings.Cut(scanner.Text(), " ")
+		exports[path] = export
+	}
+	return exports
+}
+
+func checkPackage(t *testing.T, fset *token.FileSet, imp types.Importer, path string) *types.Info {
+	dir := filepath.Join("..", "..", strings.TrimPrefix(path, "Rivall-Backend/"))
+	entries, err := os.ReadDir(dir)
+	test.NoError(t, err)
+
+	var files []*ast.File
+	for _, entry := range entries {
+		if !strings.HasSuffix(entry.Name(), ".go") || strings.HasSuffix(entry.Name(), "_test.go") {
+			continue
+		}
+		file, err := parser.ParseFile(fset, filepath.Join(dir, entry.Name()), nil, 0)
+		test.NoError(t, err)
+		files = append(files, file)
+	}
+
+	info := &types.Info{
+		Types:     make(map[ast.Expr]types.TypeAndValue),
+		Uses:      make(map[*ast.Ident]types.Object),
+		Instances: make(map[*ast.Ident]types.Instance),
+	}
+	conf := types.Config{Importer: imp}
+	_, err = conf.Check(path, fset, files, info)
+	test.NoError(t, err)
+	return info
+}
+
+// isJSONWrite reports whether the call serializes its argument to JSON
+func isJSONWrite(info *types.Info, call *ast.CallExpr) bool {
+	sel, ok := call.Fun.(*ast.SelectorExpr)
+	if !ok || len(call.Args) == 0 {
+		return false
+	}
+	fn, ok := info.Uses[sel.Sel].(*types.Func)
+	if !ok || fn.Pkg() == nil || fn.Pkg().Path() != "encoding/json" {
+		return false
+	}
+	switch fn.Name() {
+	case "Encode", "Marshal", "MarshalIndent":
+		return true
+	}
+	return false
+}
+
+// isEventPayload reports whether the generic function registers a websocket event payload,
+// websocket.NewEvent serializes the payloads of the registry
+func isEventPayload(obj types.Object) bool {
+	fn, ok := obj.(*types.Func)
+	if !ok || fn.Pkg() == nil || fn.Pkg().Path() != "Rivall-Backend/api/websocket" {
+		return false
+	}
+	return fn.Name() == "inbound" || fn.Name() == "outbound"
+}
+
+// hasSecret reports whether a model struct holds a secret field, serialized or not
+func hasSecret(typ types.Type, seen map[types.Type]bool) bool {
+	if seen[typ] {
+		return false
+	}
+	seen[typ] = true
+
+	switch typ := typ.(type) {
+	case *types.Named:
+		return hasSecret(typ.Underlying(), seen)
+	case *types.Pointer:
+		return hasSecret(typ.Elem(), seen)
+	case *types.Slice:
+		return hasSecret(typ.Elem(), seen)
+	case *types.Array:
+		return hasSecret(typ.Elem(), seen)
+	case *types.Map:
+		return hasSecret(typ.Elem(), seen)
+	case *types.Struct:
+		for i := 0; i < typ.NumFields(); i++ {
+			if secretFields[typ.Field(i).Name()] || hasSecret(typ.Field(i).Type(), seen) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// leakedModel finds a model with secret fields among the serialized parts of typ
+func leakedModel(typ types.Type, seen map[types.Type]bool) string {
+	if seen[typ] {
+		return ""
+	}
+	seen[typ] = true
+
+	switch typ := typ.(type) {
+	case *types.Named:
+		if pkg := typ.Obj().Pkg(); pkg != nil && modelPackages[pkg.Path()] {
+			if hasSecret(typ, make(map[types.Type]bool)) {
+				return typ.String()
+			}
+		}
+		return leakedModel(typ.Underlying(), seen)
+	case *types.Pointer:
+		return leakedModel(typ.Elem(), seen)
+	case *types.Slice:
+		return leakedModel(typ.Elem(), seen)
+	case *types.Array:
+		return leakedModel(typ.Elem(), seen)
+	case *types.Map:
+		return leakedModel(typ.Elem(), seen)
+	case *types.Struct:
+		for i := 0; i < typ.NumFields(); i++ {
+			field := typ.Field(i)
+			name, _, _ := strings.Cut(reflect.StructTag(typ.Tag(i)).Get("json"), ",")
+			if !field.Exported() || name == "-" {
+				continue
+			}
+			if leaked := leakedModel(field.Type(), seen); leaked != "" {
+				return leaked
+			}
+		}
+	}
+	return ""
+}
+
+func TestResponsesDoNotSerializeSecrets(t *testing.T) {
+	exports := exportData(t)
+	fset := token.NewFileSet()
+	imp := importer.ForCompiler(fset, "gc", func(path string) (io.ReadCloser, error) {
+		return os.Open(exports[path])
+	})
+
+	for _, path := range servingPackages {
+		info := checkPackage(t, fset, imp, path)
+		for expr, tv := range info.Types {
+			call, ok := expr.(*ast.CallExpr)
+			if !ok || !tv.IsValue() || !isJSONWrite(info, call) {
+				continue
+			}
+			arg := info.Types[call.Args[0]].Type
+			if leaked := leakedModel(arg, make(map[types.Type]bool)); leaked != "" {
+				t.Errorf("%s serializes %s, which holds secrets", fset.Position(call.Pos()), leaked)
+			}
+		}
+		for ident, instance := range info.Instances {
+			if !isEventPayload(info.Uses[ident]) {
+				continue
+			}
+			payload := instance.TypeArgs.At(0)
+			if leaked := leakedModel(payload, make(map[types.Type]bool)); leaked != "" {
+				t.Errorf("%s registers an event payload serializing %s, which holds secrets", fset.Position(ident.Pos()), leaked)
+			}
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package resources
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"Rivall-Backend/api/websocket"
+	"Rivall-Backend/globals"
+	"Rivall-Backend/util/api_error"
+	"Rivall-Backend/util/session_manager"
+
+	"github.com/gorilla/mux"
+	"github.com/rs/zerolog/log"
+)
+
+const MAX_DEVICE_FIELD_LENGTH = 100
+
+type DeviceSessionRes struct {
+	session_manager.DeviceSession
+	Current bool `json:"current"`
+}
+
+type RevokeSessionsRes struct {
+	Revoked []string `json:"revoked"`
+}
+
+func truncate(s string, n int) string {
+	if len(s) > n {
+		return s[:n]
+	}
+	return s
+}
+
+func deviceFromRequest(r *http.Request) session_manager.Device {
+	// clients name themselves through headers, falling back to the user agent
+	name := r.Header.Get("X-Device-Name")
+	if name == "" {
+		name = r.UserAgent()
+	}
+
+	return session_manager.Device{
+		Name:     truncate(name, MAX_DEVICE_FIELD_LENGTH),
+		Platform: truncate(r.Header.Get("X-Device-Platform"), MAX_DEVICE_FIELD_LENGTH),
+		IP:       truncate(clientIP(r), MAX_DEVICE_FIELD_LENGTH),
+	}
+}
+
+// clientIP is the address rate limits and lockouts are keyed on, forwarded addresses only
+// count when a trusted proxy sent them
+func clientIP(r *http.Request) string {
+	return globals.ClientIP.IP(r)
+}
+
+func getSessionIDFromContext(r *http.Request) string {
+	sessionID, _ := r.Context().Value("session_id").(string)
+	return sessionID
+}
+
+func GetUserSessions(w http.ResponseWriter, r *http.Request) {
+	log.Info().Msg("GET user sessions")
+
+	userID := mux.Vars(r)["user_id"]
+	currentID := getSessionIDFromContext(r)
+
+	devices, err := globals.SessionManager.ListDeviceSessions(userID)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to read sessions")
+		api_error.Write(w, r, http.StatusInternalServerError, api_error.INTERNAL, "Failed to read sessions.")
+		return
+	}
+
+	res := []DeviceSessionRes{}
+	for _, device := range devices {
+		res = append(res, DeviceSessionRes{DeviceSession: device, Current: device.ID == currentID})
+	}
+
+	json.NewEncoder(w).Encode(res)
+}
+
+func RevokeUserSession(w http.ResponseWriter, r *http.Request) {
+	log.Info().Msg("DELETE user session")
+
+	vars := mux.Vars(r)
+	userID := vars["user_id"]
+	sessionID := vars["session_id"]
+
+	err := globals.SessionManager.RevokeDeviceSession(userID, sessionID)
+	if errors.Is(err, session_manager.ErrSessionNotFound) {
+		api_error.Write(w, r, http.StatusNotFound, api_error.NOT_FOUND, "Session not found.")
+		return
+	}
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to revoke session")
+		api_error.Write(w, r, http.StatusInternalServerError, api_error.INTERNAL, "Failed to revoke session.")
+		return
+	}
+
+	websocket.WSManager.RemoveClientBySessionID(userID, sessionID)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func RevokeOtherUserSessions(w http.ResponseWriter, r *http.Request) {
+	log.Info().Msg("DELETE other user sessions")
+
+	userID := mux.Vars(r)["user_id"]
+
+	revoked, err := globals.SessionManager.RevokeOtherDeviceSessions(userID, getSessionIDFromContext(r))
+	for _, sessionID := range revoked {
+		websocket.WSManager.RemoveClientBySessionID(userID, sessionID)
+	}
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to revoke sessions")
+		api_error.Write(w, r, http.StatusInternalServerError, api_error.INTERNAL, "Failed to revoke sessions.")
+		return
+	}
+
+	json.NewEncoder(w).Encode(RevokeSessionsRes{Revoked: revoked})
+}
@@ -0,0 +1,129 @@
+package resources_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	db "Rivall-Backend/db"
+	"Rivall-Backend/globals"
+	"Rivall-Backend/util/keyring"
+	"Rivall-Backend/util/mailer"
+	"Rivall-Backend/util/otp"
+	"Rivall-Backend/util/password_hasher"
+	"Rivall-Backend/util/rate_limiter"
+	"Rivall-Backend/util/session_manager"
+	"Rivall-Backend/util/test"
+	"Rivall-Backend/util/validator"
+
+	"github.com/gorilla/mux"
+	"github.com/rs/zerolog/log"
+)
+
+// PASSWORD is the password of every user made by createUser
+const PASSWORD = "Correct-Horse-Battery-9"
+
+// setupHandlers points the globals handlers use at a throwaway database and in memory
+// stores, and returns the backend that catches the mail they send. Limiters allow
+// LOCKOUT_THRESHOLD failures per account before they lock it.
+func setupHandlers(t *testing.T) *mailer.CaptureBackend {
+	test.Mongo(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	keys, err := keyring.New(ctx, "EdDSA", time.Hour, time.Hour, keyring.NewMemoryStore())
+	test.NoError(t, err)
+
+	globals.Logger = &log.Logger
+	globals.Validator = validator.New()
+	globals.Keyring = keys
+	globals.SessionManager = session_manager.NewSessionsManager(keys, "rivall-test", "rivall-test", session_manager.NewMemoryStore(ctx))
+	codes, err := otp.New(otp.DefaultConfig(), otp.NewMemoryStore(ctx), []byte(OTP_SECRET))
+	test.NoError(t, err)
+	globals.OTP = codes
+	globals.PasswordHasher = password_hasher.New(password_hasher.Bcrypt{Cost: 4})
+	globals.ClientIP = nil
+
+	store := rate_limiter.NewMemoryStore(ctx)
+	account := rate_limiter.Policy{Threshold: LOCKOUT_THRESHOLD, BaseDelay: time.Minute, MaxDelay: time.Minute, Window: time.Hour}
+	globals.LoginLimiter = rate_limiter.New(store, "login", rate_limiter.Policy{}, account, nil)
+	globals.RecoveryLimiter = rate_limiter.New(store, "recovery", rate_limiter.Policy{}, account, nil)
+	globals.VerificationLimiter = rate_limiter.New(store, "verification", rate_limiter.Policy{}, account, nil)
+	globals.RegistrationLimiter = rate_limiter.New(store, "registration", rate_limiter.Policy{}, rate_limiter.Policy{}, nil)
+
+	backend := mailer.NewCaptureBackend()
+	globals.Mailer = mailer.New(backend, 1, time.Millisecond)
+	go globals.Mailer.Run(ctx)
+	return backend
+}
+
+// OTP_SECRET keys the codes setupHandlers' one-time code service mails
+const OTP_SECRET = "rivall-test-one-time-code-secret!"
+
+// LOCKOUT_THRESHOLD is how many failures setupHandlers' limiters allow per account
+const LOCKOUT_THRESHOLD = 3
+
+// createUser stores a verified user with PASSWORD and reads it back
+func createUser(t *testing.T, email string) db.User {
+	t.Helper()
+
+	test.NoError(t, db.CreateUser(db.User{FirstName: "Sam", LastName: "Doe", Email: email, Password: PASSWORD}))
+	test.NoError(t, db.VerifyUserEmail(email))
+	return db.ReadByUserEmail(email)
+}
+
+// serve calls handler like the router would for userID, with vars as the path variables
+func serve(handler http.HandlerFunc, method string, body string, userID string, vars map[string]string) *httptest.ResponseRecorder {
+	return serveQuery(handler, method, "", body, userID, vars)
+}
+
+// serveQuery is serve with a query string
+func serveQuery(handler http.HandlerFunc, method string, query string, body string, userID string, vars map[string]string) *httptest.ResponseRecorder {
+	var reader io.Reader
+	if body != "" {
+		reader = strings.NewReader(body)
+	}
+	r := httptest.NewRequest(method, "/?"+query, reader)
+	if userID != "" {
+		r = r.WithContext(context.WithValue(r.Context(), "user_id", userID))
+	}
+	if vars != nil {
+		r = mux.SetURLVars(r, vars)
+	}
+	w := httptest.NewRecorder()
+	handler(w, r)
+	return w
+}
+
+// sentMail waits for queued mail and returns what was sent to one address
+func sentMail(t *testing.T, backend *mailer.CaptureBackend, to string) []mailer.Message {
+	t.Helper()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	test.NoError(t, globals.Mailer.Flush(ctx))
+	return backend.SentTo(to)
+}
+
+var mailedCodePattern = regexp.MustCompile(`code is (\S+)\.`)
+
+// mailedCode reads the code out of the last mail sent to one address
+func mailedCode(t *testing.T, backend *mailer.CaptureBackend, to string) string {
+	t.Helper()
+
+	sent := sentMail(t, backend, to)
+	if len(sent) == 0 {
+		t.Fatalf("No mail was sent to %s", to)
+	}
+	match := mailedCodePattern.FindStringSubmatch(sent[len(sent)-1].Text)
+	if match == nil {
+		t.Fatalf("No code in the mail sent to %s", to)
+	}
+	return match[1]
+}
@@ -0,0 +1,269 @@
+package resources
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	db "Rivall-Backend/db"
+	"Rivall-Backend/globals"
+	"Rivall-Backend/util/api_error"
+	"Rivall-Backend/util/session_manager"
+	"Rivall-Backend/util/totp"
+
+	"github.com/gorilla/mux"
+	"github.com/rs/zerolog/log"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// TOTP_ISSUER names the account in authenticator apps
+const TOTP_ISSUER = "Rivall"
+
+type TwoFactorEnrollRes struct {
+	Secret          string `json:"secret"`
+	ProvisioningURI string `json:"provisioning_uri"`
+}
+
+type ConfirmTwoFactorReq struct {
+	Password string `json:"password"`
+	Code     string `json:"code"     form:"required,max=64"`
+	// EmailCode confirms instead of Password for users without one
+	EmailCode string `json:"email_code" form:"max=64"`
+}
+
+type TwoFactorRecoveryCodesRes struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+type DisableTwoFactorReq struct {
+	Password string `json:"password"`
+	Code     string `json:"code"     form:"required,max=64"`
+	// EmailCode confirms instead of Password for users without one
+	EmailCode string `json:"email_code" form:"max=64"`
+}
+
+type LoginTwoFactorReq struct {
+	ChallengeToken string `json:"challenge_token" form:"required"`
+	Code           string `json:"code"            form:"required,max=64"`
+}
+
+// checkSecondFactor accepts a current authenticator code or an unused recovery code, each
+// only once
+func checkSecondFactor(user db.User, code string) (bool, error) {
+	userID := user.ID.Hex()
+
+	if step, ok := totp.Validate(user.TwoFactor.Secret, code, time.Now()); ok {
+		return db.UseTwoFactorStep(userID, step)
+	}
+
+	return db.UseTwoFactorRecoveryCode(userID, totp.HashRecoveryCode(code))
+}
+
+func newRecoveryCodes() ([]string, []string, error) {
+	codes, err := totp.NewRecoveryCodes()
+	if err != nil {
+		return nil, nil, err
+	}
+	hashes := make([]string, len(codes))
+	for i, code := range codes {
+		hashes[i] = totp.HashRecoveryCode(code)
+	}
+	return codes, hashes, nil
+}
+
+func EnrollTwoFactor(w http.ResponseWriter, r *http.Request) {
+	log.Info().Msg("POST enroll two factor")
+
+	userID := mux.Vars(r)["user_id"]
+	user := db.ReadByUserId(userID)
+	if user.ID == bson.NilObjectID {
+		log.Error().Msg("User does not exist")
+		api_error.Write(w, r, http.StatusBadRequest, api_error.USER_NOT_FOUND, "User does not exist.")
+		return
+	}
+	if user.TwoFactor.Enabled {
+		api_error.Write(w, r, http.StatusConflict, api_error.CONFLICT, "Two factor authentication is already enabled.")
+		return
+	}
+
+	// enrolling again replaces a secret that was never confirmed
+	secret, err := totp.NewSecret()
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to create two factor secret")
+		api_error.Write(w, r, http.StatusInternalServerError, api_error.INTERNAL, "Failed to enroll two factor authentication.")
+		return
+	}
+	if err := db.SetTwoFactorPendingSecret(userID, secret); err != nil {
+		api_error.Write(w, r, http.StatusInternalServerError, api_error.INTERNAL, "Failed to enroll two factor authentication.")
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(TwoFactorEnrollRes{
+		Secret:          secret,
+		ProvisioningURI: totp.ProvisioningURI(secret, TOTP_ISSUER, user.Email),
+	})
+}
+
+func ConfirmTwoFactor(w http.ResponseWriter, r *http.Request) {
+	log.Info().Msg("POST confirm two factor")
+
+	userID := mux.Vars(r)["user_id"]
+	user := db.ReadByUserId(userID)
+	if user.ID == bson.NilObjectID {
+		log.Error().Msg("User does not exist")
+		api_error.Write(w, r, http.StatusBadRequest, api_error.USER_NOT_FOUND, "User does not exist.")
+		return
+	}
+	if user.TwoFactor.Enabled {
+		api_error.Write(w, r, http.StatusConflict, api_error.CONFLICT, "Two factor authentication is already enabled.")
+		return
+	}
+	if user.TwoFactor.PendingSecret == "" {
+		api_error.Write(w, r, http.StatusNotFound, api_error.NOT_FOUND, "Two factor authentication enrollment was not started.")
+		return
+	}
+
+	req := ConfirmTwoFactorReq{}
+	if !decodeRequest(w, r, &req) {
+		return
+	}
+
+	// with a stolen access token alone a second factor only the attacker holds could be
+	// turned on, locking the user out
+	if !confirmIdentity(w, r, user, req.Password, req.EmailCode) {
+		return
+	}
+
+	// the first code proves the authenticator holds the secret
+	step, ok := totp.Validate(user.TwoFactor.PendingSecret, req.Code, time.Now())
+	if !ok {
+		log.Warn().Msg("Invalid two factor code")
+		api_error.Write(w, r, http.StatusUnauthorized, api_error.INVALID_CODE, "Invalid code")
+		return
+	}
+
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to create recovery codes")
+		api_error.Write(w, r, http.StatusInternalServerError, api_error.INTERNAL, "Failed to enable two factor authentication.")
+		return
+	}
+
+	enabled, err := db.EnableTwoFactor(userID, user.TwoFactor.PendingSecret, step, hashes)
+	if err != nil {
+		api_error.Write(w, r, http.StatusInternalServerError, api_error.INTERNAL, "Failed to enable two factor authentication.")
+		return
+	}
+	if !enabled {
+		api_error.Write(w, r, http.StatusConflict, api_error.CONFLICT, "Two factor authentication enrollment was restarted.")
+		return
+	}
+
+	// recovery codes are only ever shown here
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(TwoFactorRecoveryCodesRes{RecoveryCodes: codes})
+}
+
+func DisableTwoFactor(w http.ResponseWriter, r *http.Request) {
+	log.Info().Msg("DELETE two factor")
+
+	userID := mux.Vars(r)["user_id"]
+	user := db.ReadByUserId(userID)
+	if user.ID == bson.NilObjectID {
+		log.Error().Msg("User does not exist")
+		api_error.Write(w, r, http.StatusBadRequest, api_error.USER_NOT_FOUND, "User does not exist.")
+		return
+	}
+	if !user.TwoFactor.Enabled {
+		api_error.Write(w, r, http.StatusNotFound, api_error.NOT_FOUND, "Two factor authentication is not enabled.")
+		return
+	}
+
+	req := DisableTwoFactorReq{}
+	if !decodeRequest(w, r, &req) {
+		return
+	}
+
+	// a stolen access token alone can't turn the second factor off, guesses count against
+	// the same account as logins do
+	if !confirmIdentity(w, r, user, req.Password, req.EmailCode) {
+		return
+	}
+	ok, err := checkSecondFactor(user, req.Code)
+	if err != nil {
+		api_error.Write(w, r, http.StatusInternalServerError, api_error.INTERNAL, "Failed to disable two factor authentication.")
+		return
+	}
+	if !ok {
+		log.Warn().Msg("Invalid two factor code")
+		failRateLimit(r, globals.LoginLimiter, user.Email)
+		api_error.Write(w, r, http.StatusUnauthorized, api_error.INVALID_CREDENTIALS, "Invalid password or code")
+		return
+	}
+	resetRateLimit(r, globals.LoginLimiter, user.Email)
+
+	if err := db.DisableTwoFactor(userID); err != nil {
+		api_error.Write(w, r, http.StatusInternalServerError, api_error.INTERNAL, "Failed to disable two factor authentication.")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func LoginTwoFactor(w http.ResponseWriter, r *http.Request) {
+	log.Info().Msg("POST login two factor")
+
+	req := LoginTwoFactorReq{}
+	if !decodeRequest(w, r, &req) {
+		return
+	}
+
+	challenge, err := globals.SessionManager.GetChallenge(req.ChallengeToken)
+	if errors.Is(err, session_manager.ErrChallengeNotFound) {
+		api_error.Write(w, r, http.StatusUnauthorized, api_error.INVALID_TOKEN, "Challenge is invalid or expired, log in again")
+		return
+	}
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to read two factor challenge")
+		api_error.Write(w, r, http.StatusInternalServerError, api_error.INTERNAL, "Failed to read challenge")
+		return
+	}
+
+	user := db.ReadByUserId(challenge.UserID)
+	if user.ID == bson.NilObjectID || !user.TwoFactor.Enabled {
+		api_error.Write(w, r, http.StatusUnauthorized, api_error.INVALID_TOKEN, "Challenge is invalid or expired, log in again")
+		return
+	}
+
+	if !checkRateLimit(w, r, globals.LoginLimiter, user.Email) {
+		return
+	}
+
+	ok, err := checkSecondFactor(user, req.Code)
+	if err != nil {
+		api_error.Write(w, r, http.StatusInternalServerError, api_error.INTERNAL, "Failed to check code")
+		return
+	}
+	if !ok {
+		log.Warn().Msg("Invalid two factor code")
+		failRateLimit(r, globals.LoginLimiter, user.Email)
+		if err := globals.SessionManager.FailChallenge(req.ChallengeToken); err != nil {
+			log.Error().Err(err).Msg("Failed to count two factor attempt")
+		}
+		api_error.Write(w, r, http.StatusUnauthorized, api_error.INVALID_CODE, "Invalid code")
+		return
+	}
+
+	if err := globals.SessionManager.CompleteChallenge(req.ChallengeToken); err != nil {
+		api_error.Write(w, r, http.StatusUnauthorized, api_error.INVALID_TOKEN, "Challenge is invalid or expired, log in again")
+		return
+	}
+	resetRateLimit(r, globals.LoginLimiter, user.Email)
+	if refuseDeletedLogin(w, r, user) {
+		return
+	}
+
+	writeLoginSessions(w, r, user, challenge.Type == session_manager.TYPE_RECOVERY_CHALLENGE)
+}
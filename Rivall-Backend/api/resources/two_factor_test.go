@@ -0,0 +1,133 @@
+package resources_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"Rivall-Backend/api/resources"
+	db "Rivall-Backend/db"
+	"Rivall-Backend/util/test"
+	"Rivall-Backend/util/totp"
+)
+
+// createTwoFactorUser stores a user with two-factor authentication enabled and returns
+// its secret
+func createTwoFactorUser(t *testing.T, email string) (db.User, string) {
+	t.Helper()
+
+	user := createUser(t, email)
+	secret, err := totp.NewSecret()
+	test.NoError(t, err)
+	test.NoError(t, db.SetTwoFactorPendingSecret(user.ID.Hex(), secret))
+	enabled, err := db.EnableTwoFactor(user.ID.Hex(), secret, 0, []string{})
+	test.NoError(t, err)
+	test.Equal(t, enabled, true)
+	return db.ReadByUserEmail(email), secret
+}
+
+func login(t *testing.T, email string) (int, string) {
+	t.Helper()
+
+	body := fmt.Sprintf(`{"email":%q,"password":%q}`, email, PASSWORD)
+	w := serve(resources.LoginUser, http.MethodPost, body, "", nil)
+	res := resources.TwoFactorChallengeRes{}
+	if w.Code == http.StatusAccepted {
+		test.NoError(t, json.NewDecoder(w.Body).Decode(&res))
+	}
+	return w.Code, res.ChallengeToken
+}
+
+func loginTwoFactor(challenge string, code string) int {
+	body := fmt.Sprintf(`{"challenge_token":%q,"code":%q}`, challenge, code)
+	return serve(resources.LoginTwoFactor, http.MethodPost, body, "", nil).Code
+}
+
+func TestPasswordDoesNotResetSecondFactorFailures(t *testing.T) {
+	setupHandlers(t)
+	createTwoFactorUser(t, "sam@example.com")
+
+	// logging in again between guesses used to wipe the count
+	for guesses := 0; guesses < LOCKOUT_THRESHOLD; {
+		status, challenge := login(t, "sam@example.com")
+		test.Equal(t, status, http.StatusAccepted)
+
+		for i := 0; i < 2 && guesses < LOCKOUT_THRESHOLD; i++ {
+			test.Equal(t, loginTwoFactor(challenge, "00000000"), http.StatusUnauthorized)
+			guesses++
+		}
+	}
+
+	// the right password doesn't open the lock
+	status, _ := login(t, "sam@example.com")
+	test.Equal(t, status, http.StatusTooManyRequests)
+}
+
+func TestSecondFactorResetsFailures(t *testing.T) {
+	setupHandlers(t)
+	_, secret := createTwoFactorUser(t, "sam@example.com")
+
+	for i := 0; i < LOCKOUT_THRESHOLD-1; i++ {
+		status, challenge := login(t, "sam@example.com")
+		test.Equal(t, status, http.StatusAccepted)
+		test.Equal(t, loginTwoFactor(challenge, "00000000"), http.StatusUnauthorized)
+	}
+
+	code, err := totp.CodeAt(secret, totp.Step(time.Now()))
+	test.NoError(t, err)
+	status, challenge := login(t, "sam@example.com")
+	test.Equal(t, status, http.StatusAccepted)
+	test.Equal(t, loginTwoFactor(challenge, code), http.StatusAccepted)
+
+	// the count starts over once the second factor was answered
+	for i := 0; i < LOCKOUT_THRESHOLD-1; i++ {
+		status, challenge := login(t, "sam@example.com")
+		test.Equal(t, status, http.StatusAccepted)
+		test.Equal(t, loginTwoFactor(challenge, "00000000"), http.StatusUnauthorized)
+	}
+	status, _ = login(t, "sam@example.com")
+	test.Equal(t, status, http.StatusAccepted)
+}
+
+func TestDisableTwoFactorIsRateLimited(t *testing.T) {
+	setupHandlers(t)
+	user, _ := createTwoFactorUser(t, "sam@example.com")
+	userID := user.ID.Hex()
+	vars := map[string]string{"user_id": userID}
+
+	body := fmt.Sprintf(`{"password":%q,"code":"00000000"}`, PASSWORD)
+	for i := 0; i < LOCKOUT_THRESHOLD; i++ {
+		test.Equal(t, serve(resources.DisableTwoFactor, http.MethodPost, body, userID, vars).Code, http.StatusUnauthorized)
+	}
+	test.Equal(t, serve(resources.DisableTwoFactor, http.MethodPost, body, userID, vars).Code, http.StatusTooManyRequests)
+
+	// the same lockout guards the login
+	status, _ := login(t, "sam@example.com")
+	test.Equal(t, status, http.StatusTooManyRequests)
+}
+
+func TestConfirmTwoFactorNeedsPassword(t *testing.T) {
+	setupHandlers(t)
+	userID := createUser(t, "sam@example.com").ID.Hex()
+	vars := map[string]string{"user_id": userID}
+
+	w := serve(resources.EnrollTwoFactor, http.MethodPost, "", userID, vars)
+	test.Equal(t, w.Code, http.StatusCreated)
+	enrollment := resources.TwoFactorEnrollRes{}
+	test.NoError(t, json.NewDecoder(w.Body).Decode(&enrollment))
+	code, err := totp.CodeAt(enrollment.Secret, totp.Step(time.Now()))
+	test.NoError(t, err)
+
+	// a stolen session alone can't turn on a second factor the user doesn't hold
+	body := fmt.Sprintf(`{"code":%q}`, code)
+	test.Equal(t, serve(resources.ConfirmTwoFactor, http.MethodPost, body, userID, vars).Code, http.StatusUnauthorized)
+	body = fmt.Sprintf(`{"password":"wrong","code":%q}`, code)
+	test.Equal(t, serve(resources.ConfirmTwoFactor, http.MethodPost, body, userID, vars).Code, http.StatusUnauthorized)
+	test.Equal(t, db.ReadByUserId(userID).TwoFactor.Enabled, false)
+
+	body = fmt.Sprintf(`{"password":%q,"code":%q}`, PASSWORD, code)
+	test.Equal(t, serve(resources.ConfirmTwoFactor, http.MethodPost, body, userID, vars).Code, http.StatusCreated)
+	test.Equal(t, db.ReadByUserId(userID).TwoFactor.Enabled, true)
+}
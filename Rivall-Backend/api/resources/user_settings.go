@@ -0,0 +1,96 @@
+package resources
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	db "Rivall-Backend/db"
+	"Rivall-Backend/util/api_error"
+
+	"github.com/gorilla/mux"
+	"github.com/rs/zerolog/log"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// UpdateUserSettingsReq changes only the settings that are sent
+type UpdateUserSettingsReq struct {
+	Discoverable    *bool                          `json:"discoverable"`
+	ContactRequests *string                        `json:"contact_requests" form:"omitnil,oneof=everyone group_members nobody"`
+	GroupRequests   *string                        `json:"group_requests"   form:"omitnil,oneof=everyone contacts group_members nobody"`
+	ReadReceipts    *bool                          `json:"read_receipts"`
+	Presence        *string                        `json:"presence"         form:"omitnil,oneof=everyone contacts group_members nobody"`
+	Notifications   *UpdateNotificationSettingsReq `json:"notifications"`
+}
+
+type UpdateNotificationSettingsReq struct {
+	GroupRequests *bool `json:"group_requests"`
+	NewContacts   *bool `json:"new_contacts"`
+}
+
+func GetUserSettings(w http.ResponseWriter, r *http.Request) {
+	log.Info().Msg("GET user settings")
+
+	user := db.ReadByUserId(mux.Vars(r)["user_id"])
+	if user.ID == bson.NilObjectID {
+		log.Error().Msg("User does not exist")
+		api_error.Write(w, r, http.StatusBadRequest, api_error.USER_NOT_FOUND, "User does not exist.")
+		return
+	}
+
+	json.NewEncoder(w).Encode(user.CurrentSettings())
+}
+
+func UpdateUserSettings(w http.ResponseWriter, r *http.Request) {
+	log.Info().Msg("PATCH user settings")
+
+	userID := mux.Vars(r)["user_id"]
+	user := db.ReadByUserId(userID)
+	if user.ID == bson.NilObjectID {
+		log.Error().Msg("User does not exist")
+		api_error.Write(w, r, http.StatusBadRequest, api_error.USER_NOT_FOUND, "User does not exist.")
+		return
+	}
+
+	req := UpdateUserSettingsReq{}
+	if !decodeRequest(w, r, &req) {
+		return
+	}
+
+	settings := user.CurrentSettings()
+	if req.Discoverable != nil {
+		settings.Discoverable = *req.Discoverable
+	}
+	if req.ContactRequests != nil {
+		settings.ContactRequests = *req.ContactRequests
+	}
+	if req.GroupRequests != nil {
+		settings.GroupRequests = *req.GroupRequests
+	}
+	if req.ReadReceipts != nil {
+		settings.ReadReceipts = *req.ReadReceipts
+	}
+	if req.Presence != nil {
+		settings.Presence = *req.Presence
+	}
+	if req.Notifications != nil {
+		if req.Notifications.GroupRequests != nil {
+			settings.Notifications.GroupRequests = *req.Notifications.GroupRequests
+		}
+		if req.Notifications.NewContacts != nil {
+			settings.Notifications.NewContacts = *req.Notifications.NewContacts
+		}
+	}
+
+	err := db.UpdateUserSettings(userID, settings)
+	if errors.Is(err, db.ErrUserNotFound) {
+		api_error.Write(w, r, http.StatusBadRequest, api_error.USER_NOT_FOUND, "User does not exist.")
+		return
+	}
+	if err != nil {
+		api_error.Write(w, r, http.StatusInternalServerError, api_error.INTERNAL, "Failed to update settings.")
+		return
+	}
+
+	json.NewEncoder(w).Encode(settings)
+}
@@ -0,0 +1,164 @@
+package resources_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"Rivall-Backend/api/resources"
+	db "Rivall-Backend/db"
+	"Rivall-Backend/util/test"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// updateSettings changes userID's settings through the API
+func updateSettings(t *testing.T, userID string, body string) {
+	t.Helper()
+
+	w := serve(resources.UpdateUserSettings, http.MethodPatch, body, userID, map[string]string{"user_id": userID})
+	test.Equal(t, w.Code, http.StatusOK)
+}
+
+func TestUndiscoverableUserCanNotBeLookedUp(t *testing.T) {
+	setupHandlers(t)
+	sam := createUser(t, "sam@example.com").ID.Hex()
+
+	w := serve(resources.GetContact, http.MethodGet, "", "", map[string]string{"user_id": sam})
+	test.Equal(t, w.Code, http.StatusOK)
+
+	updateSettings(t, sam, `{"discoverable":false}`)
+	w = serve(resources.GetContact, http.MethodGet, "", "", map[string]string{"user_id": sam})
+	test.Equal(t, w.Code, http.StatusBadRequest)
+}
+
+func TestContactRequestsSetting(t *testing.T) {
+	setupHandlers(t)
+	sam := createUser(t, "sam@example.com").ID.Hex()
+	alex := createUser(t, "alex@example.com").ID.Hex()
+	kim := createUser(t, "kim@example.com").ID.Hex()
+
+	addContact := func(userID string, contactID string) int {
+		body := `{"contact_id":"` + contactID + `"}`
+		return serve(resources.PostUserContact, http.MethodPost, body, userID, map[string]string{"user_id": userID}).Code
+	}
+
+	updateSettings(t, alex, `{"contact_requests":"nobody"}`)
+	test.Equal(t, addContact(sam, alex), http.StatusForbidden)
+	test.Equal(t, len(db.ReadByUserId(sam).Contacts), 0)
+
+	// group_members lets in who alex shares a group with, and nobody else
+	updateSettings(t, alex, `{"contact_requests":"group_members"}`)
+	groupID, err := db.CreateGroup("Climbers", alex)
+	test.NoError(t, err)
+	test.NoError(t, db.AddUserToGroup(groupID, kim))
+	test.Equal(t, addContact(sam, alex), http.StatusForbidden)
+	test.Equal(t, addContact(kim, alex), http.StatusCreated)
+}
+
+func TestPostUserContactReturnsContact(t *testing.T) {
+	setupHandlers(t)
+	sam := createUser(t, "sam@example.com").ID.Hex()
+	alex := createUser(t, "alex@example.com").ID.Hex()
+
+	body := `{"contact_id":"` + alex + `"}`
+	w := serve(resources.PostUserContact, http.MethodPost, body, sam, map[string]string{"user_id": sam})
+	test.Equal(t, w.Code, http.StatusCreated)
+
+	contact := resources.ContactUserRes{}
+	test.NoError(t, json.NewDecoder(w.Body).Decode(&contact))
+	test.Equal(t, contact.ID, alex)
+	test.Equal(t, contact.Email, "alex@example.com")
+}
+
+func TestGroupRequestsSetting(t *testing.T) {
+	setupHandlers(t)
+	sam := createUser(t, "sam@example.com").ID.Hex()
+	alex := createUser(t, "alex@example.com").ID.Hex()
+
+	createGroup := func() int {
+		body := `{"group_name":"Climbers","message":"join us","user_ids":["` + alex + `"]}`
+		return serve(resources.WriteNewMessageGroup, http.MethodPost, body, sam, map[string]string{"user_id": sam}).Code
+	}
+
+	updateSettings(t, alex, `{"group_requests":"nobody"}`)
+	test.Equal(t, createGroup(), http.StatusForbidden)
+	test.Equal(t, len(db.ReadByUserId(alex).GroupRequests), 0)
+
+	updateSettings(t, alex, `{"group_requests":"contacts"}`)
+	test.Equal(t, createGroup(), http.StatusForbidden)
+	test.NoError(t, db.CreateContact(alex, sam))
+	test.Equal(t, createGroup(), http.StatusCreated)
+	test.Equal(t, len(db.ReadByUserId(alex).GroupRequests), 1)
+}
+
+func TestPresenceSetting(t *testing.T) {
+	setupHandlers(t)
+	sam := createUser(t, "sam@example.com").ID.Hex()
+	alex := createUser(t, "alex@example.com").ID.Hex()
+	kim := createUser(t, "kim@example.com").ID.Hex()
+	groupID, err := db.CreateGroup("Climbers", sam)
+	test.NoError(t, err)
+	test.NoError(t, db.AddUserToGroup(groupID, alex))
+	test.NoError(t, db.AddUserToGroup(groupID, kim))
+	test.NoError(t, db.CreateContact(alex, sam))
+
+	// by default only contacts see whether alex is online
+	if groupMembers(t, sam, groupID)[alex].Online == nil {
+		t.Fatal("Expected alex's contacts to see their presence")
+	}
+	if groupMembers(t, kim, groupID)[alex].Online != nil {
+		t.Fatal("Expected alex's presence to be hidden from other members")
+	}
+
+	updateSettings(t, alex, `{"presence":"nobody"}`)
+	if groupMembers(t, sam, groupID)[alex].Online != nil {
+		t.Fatal("Expected alex's presence to be hidden from everyone")
+	}
+	// members always see their own
+	if groupMembers(t, alex, groupID)[alex].Online == nil {
+		t.Fatal("Expected alex to see their own presence")
+	}
+
+	updateSettings(t, alex, `{"presence":"group_members"}`)
+	if groupMembers(t, kim, groupID)[alex].Online == nil {
+		t.Fatal("Expected alex's presence to be shown to group members")
+	}
+}
+
+func TestReadReceiptsSetting(t *testing.T) {
+	setupHandlers(t)
+	sam := createUser(t, "sam@example.com")
+	alex := createUser(t, "alex@example.com")
+	kim := createUser(t, "kim@example.com")
+	groupID, err := db.CreateGroup("Climbers", sam.ID.Hex())
+	test.NoError(t, err)
+	test.NoError(t, db.AddUserToGroup(groupID, alex.ID.Hex()))
+	test.NoError(t, db.AddUserToGroup(groupID, kim.ID.Hex()))
+	test.NoError(t, db.InsertGroupMessage(groupID, db.Message{
+		ID:          bson.NewObjectID(),
+		UserID:      sam.ID,
+		MessageData: "hello",
+		SeenBy:      []bson.ObjectID{sam.ID, alex.ID, kim.ID},
+	}))
+
+	seenBy := func(viewerID string) []bson.ObjectID {
+		vars := map[string]string{"user_id": viewerID, "group_id": groupID}
+		w := serve(resources.GetGroup, http.MethodGet, "", viewerID, vars)
+		test.Equal(t, w.Code, http.StatusOK)
+		res := resources.GroupWithMessagesRes{}
+		test.NoError(t, json.NewDecoder(w.Body).Decode(&res))
+		test.Equal(t, len(res.Messages), 1)
+		return res.Messages[0].SeenBy
+	}
+
+	test.Equal(t, len(seenBy(kim.ID.Hex())), 3)
+
+	// the sender and the viewer stay, alex is left out for everyone else
+	updateSettings(t, alex.ID.Hex(), `{"read_receipts":false}`)
+	seen := seenBy(kim.ID.Hex())
+	test.Equal(t, len(seen), 2)
+	test.Equal(t, seen[0], sam.ID)
+	test.Equal(t, seen[1], kim.ID)
+	test.Equal(t, len(seenBy(alex.ID.Hex())), 3)
+}
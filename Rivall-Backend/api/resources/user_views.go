@@ -0,0 +1,131 @@
+package resources
+
+import (
+	"time"
+
+	db "Rivall-Backend/db"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// A user is seen three ways. UserRes and SelfUserRes are for the user themselves,
+// ContactUserRes for their contacts, and PublicUserRes for anyone else, fellow group
+// members included. Handlers never encode a db.User, it holds password hashes and secrets.
+
+// PublicUserRes is what anyone can see of a user
+type PublicUserRes struct {
+	ID          string `json:"_id"`
+	FirstName   string `json:"first_name"`
+	LastName    string `json:"last_name"`
+	AvatarImage string `json:"avatar_image"`
+	Bio         string `json:"bio"`
+}
+
+// ContactUserRes is what people who know the user see, it adds their email
+type ContactUserRes struct {
+	PublicUserRes
+	Email string `json:"email"`
+}
+
+// UserRes is the user's own account, as returned on login
+type UserRes struct {
+	ID            string `json:"_id"`
+	FirstName     string `json:"first_name"`
+	LastName      string `json:"last_name"`
+	Email         string `json:"email"`
+	EmailVerified bool   `json:"email_verified"`
+	AvatarImage   string `json:"avatar_image"`
+	Bio           string `json:"bio"`
+}
+
+// SelfUserRes is everything the user can see of their own account
+type SelfUserRes struct {
+	UserRes
+	PendingEmail        string            `json:"pending_email,omitempty"`
+	TwoFactorEnabled    bool              `json:"two_factor_enabled"`
+	Identities          []IdentityRes     `json:"identities"`
+	DeletionScheduledAt *time.Time        `json:"deletion_scheduled_at,omitempty"`
+	GroupIDs            []string          `json:"group_ids"`
+	Contacts            []ContactRes      `json:"contacts"`
+	GroupRequests       []db.GroupRequest `json:"group_requests"`
+}
+
+type IdentityRes struct {
+	Provider string    `json:"provider"`
+	Email    string    `json:"email"`
+	LinkedAt time.Time `json:"linked_at"`
+}
+
+type ContactRes struct {
+	ContactUserRes
+	DirectMessageID string `json:"direct_message_id"`
+}
+
+func newPublicUserRes(user db.User) PublicUserRes {
+	return PublicUserRes{
+		ID:          user.ID.Hex(),
+		FirstName:   user.FirstName,
+		LastName:    user.LastName,
+		AvatarImage: user.AvatarImage,
+		Bio:         user.Bio,
+	}
+}
+
+func newContactUserRes(user db.User) ContactUserRes {
+	return ContactUserRes{
+		PublicUserRes: newPublicUserRes(user),
+		Email:         user.Email,
+	}
+}
+
+func newUserRes(user db.User) UserRes {
+	return UserRes{
+		ID:            user.ID.Hex(),
+		FirstName:     user.FirstName,
+		LastName:      user.LastName,
+		Email:         user.Email,
+		EmailVerified: user.EmailVerified,
+		AvatarImage:   user.AvatarImage,
+		Bio:           user.Bio,
+	}
+}
+
+func newSelfUserRes(user db.User) SelfUserRes {
+	res := SelfUserRes{
+		UserRes:             newUserRes(user),
+		PendingEmail:        user.PendingEmail,
+		TwoFactorEnabled:    user.TwoFactor.Enabled,
+		Identities:          []IdentityRes{},
+		DeletionScheduledAt: user.DeletionScheduledAt,
+		GroupIDs:            []string{},
+		Contacts:            []ContactRes{},
+		GroupRequests:       []db.GroupRequest{},
+	}
+
+	for _, identity := range user.Identities {
+		res.Identities = append(res.Identities, IdentityRes{
+			Provider: identity.Provider,
+			Email:    identity.Email,
+			LinkedAt: identity.LinkedAt,
+		})
+	}
+	for _, groupID := range user.GroupIDs {
+		res.GroupIDs = append(res.GroupIDs, groupID.Hex())
+	}
+	for _, contact := range user.Contacts {
+		other := db.ReadByUserId(contact.ContactID.Hex())
+		if other.ID == bson.NilObjectID {
+			continue
+		}
+		res.Contacts = append(res.Contacts, ContactRes{
+			ContactUserRes:  newContactUserRes(other),
+			DirectMessageID: contact.DirectMessageID.Hex(),
+		})
+	}
+	for _, request := range user.GroupRequests {
+		if request.Status == db.GROUP_REQUEST_PENDING {
+			res.GroupRequests = append(res.GroupRequests, request)
+		}
+	}
+	return res
+}
@@ -2,9 +2,12 @@ package resources
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
+	"strings"
 
 	db "Rivall-Backend/db"
+	"Rivall-Backend/util/api_error"
 
 	"github.com/gorilla/mux"
 	"github.com/rs/zerolog/log"
@@ -20,14 +23,71 @@ func GetUser(w http.ResponseWriter, r *http.Request) {
 	log.Debug().Msgf("User ID: %s", userID)
 
 	// check user exists
-	user := db.ReadByUserIdWithPopulatedFields(userID)
+	user := db.ReadByUserId(userID)
 	if user.ID == bson.NilObjectID {
 		log.Error().Msg("User does not exist")
-		w.WriteHeader(http.StatusBadRequest)
-		w.Write([]byte("User does not exist."))
+		api_error.Write(w, r, http.StatusBadRequest, api_error.USER_NOT_FOUND, "User does not exist.")
 		return
 	}
 
-	// return user
-	json.NewEncoder(w).Encode(user)
+	// return the user's own view of their account
+	json.NewEncoder(w).Encode(newSelfUserRes(user))
+}
+
+// UpdateUserReq changes only the fields that are sent, an empty avatar_image removes the avatar
+type UpdateUserReq struct {
+	FirstName   *string `json:"first_name"   form:"omitnil,min=1,max=50"`
+	LastName    *string `json:"last_name"    form:"omitnil,min=1,max=50"`
+	Bio         *string `json:"bio"          form:"omitnil,max=280"`
+	AvatarImage *string `json:"avatar_image" form:"omitnil,max=2048,eq=|https_url"`
+}
+
+func (req *UpdateUserReq) normalize() {
+	for _, field := range []*string{req.FirstName, req.LastName, req.Bio, req.AvatarImage} {
+		if field != nil {
+			*field = strings.TrimSpace(*field)
+		}
+	}
+}
+
+func UpdateUser(w http.ResponseWriter, r *http.Request) {
+	log.Info().Msg("PATCH user")
+
+	userID := mux.Vars(r)["user_id"]
+	user := db.ReadByUserId(userID)
+	if user.ID == bson.NilObjectID {
+		log.Error().Msg("User does not exist")
+		api_error.Write(w, r, http.StatusBadRequest, api_error.USER_NOT_FOUND, "User does not exist.")
+		return
+	}
+
+	req := UpdateUserReq{}
+	if !decodeRequest(w, r, &req) {
+		return
+	}
+
+	if req.FirstName != nil {
+		user.FirstName = *req.FirstName
+	}
+	if req.LastName != nil {
+		user.LastName = *req.LastName
+	}
+	if req.Bio != nil {
+		user.Bio = *req.Bio
+	}
+	if req.AvatarImage != nil {
+		user.AvatarImage = *req.AvatarImage
+	}
+
+	err := db.UpdateUserProfile(userID, user.FirstName, user.LastName, user.Bio, user.AvatarImage)
+	if errors.Is(err, db.ErrUserNotFound) {
+		api_error.Write(w, r, http.StatusBadRequest, api_error.USER_NOT_FOUND, "User does not exist.")
+		return
+	}
+	if err != nil {
+		api_error.Write(w, r, http.StatusInternalServerError, api_error.INTERNAL, "Failed to update user.")
+		return
+	}
+
+	json.NewEncoder(w).Encode(newSelfUserRes(user))
 }
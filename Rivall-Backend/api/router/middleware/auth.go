@@ -7,6 +7,7 @@ import (
 	"time"
 
 	"Rivall-Backend/globals"
+	"Rivall-Backend/util/api_error"
 
 	"github.com/gorilla/mux"
 	"github.com/rs/zerolog/log"
@@ -22,8 +23,7 @@ func AuthMiddleware(next http.Handler) http.Handler {
 		// get token from header
 		tokenString := r.Header.Get("Authorization")
 		if tokenString == "" {
-			w.WriteHeader(http.StatusUnauthorized)
-			w.Write([]byte("Missing Authorization header"))
+			api_error.Write(w, r, http.StatusUnauthorized, api_error.UNAUTHORIZED, "Missing Authorization header")
 			return
 		}
 
@@ -33,31 +33,27 @@ func AuthMiddleware(next http.Handler) http.Handler {
 		// get token claims
 		claims, ok := globals.SessionManager.ValidateJWTToken(tokenString)
 		if !ok {
-			w.WriteHeader(http.StatusUnauthorized)
-			w.Write([]byte("Invalid token"))
+			api_error.Write(w, r, http.StatusUnauthorized, api_error.INVALID_TOKEN, "Invalid token")
 			return
 		}
 
 		// get user_id from claims
 		userID, ok := claims["user_id"].(string)
 		if !ok {
-			w.WriteHeader(http.StatusUnauthorized)
-			w.Write([]byte("Invalid token: user_id"))
+			api_error.Write(w, r, http.StatusUnauthorized, api_error.INVALID_TOKEN, "Invalid token: user_id")
 			return
 		}
 
 		// check if session exists
 		session, ok := globals.SessionManager.GetSession(tokenString)
 		if !ok {
-			w.WriteHeader(http.StatusUnauthorized)
-			w.Write([]byte("Invalid token: token not found"))
+			api_error.Write(w, r, http.StatusUnauthorized, api_error.INVALID_TOKEN, "Invalid token: token not found")
 			return
 		}
 
 		// check if session is valid
 		if session.TokenExpiresAt.Before(time.Now()) {
-			w.WriteHeader(http.StatusUnauthorized)
-			w.Write([]byte("Token expired"))
+			api_error.Write(w, r, http.StatusUnauthorized, api_error.INVALID_TOKEN, "Token expired")
 			return
 		}
 
@@ -69,13 +65,15 @@ func AuthMiddleware(next http.Handler) http.Handler {
 		// if user_id in url, check if it matches the token user_id
 		if vars["user_id"] != "" {
 			if vars["user_id"] != userID {
-				w.WriteHeader(http.StatusUnauthorized)
-				w.Write([]byte("Unauthorized user"))
+				api_error.Write(w, r, http.StatusUnauthorized, api_error.UNAUTHORIZED, "Unauthorized user")
 				return
 			}
 		}
 
+		globals.SessionManager.TouchDeviceSession(session.FamilyID)
+
 		ctx := context.WithValue(r.Context(), "user_id", userID)
+		ctx = context.WithValue(ctx, "session_id", session.FamilyID)
 		log.Debug().Str("user_id", userID).Msg("Authenticated user")
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
@@ -32,6 +32,8 @@ func RequestLogging(next http.Handler) http.Handler {
 			s = colorstring.Color("[yellow]POST")
 		case http.MethodPut:
 			s = colorstring.Color("[blue]PUT")
+		case http.MethodPatch:
+			s = colorstring.Color("[cyan]PATCH")
 		case http.MethodDelete:
 			s = colorstring.Color("[red]DELETE")
 		default:
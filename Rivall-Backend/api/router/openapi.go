@@ -0,0 +1,537 @@
+package router
+
+import (
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"reflect"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/gorilla/mux"
+	"github.com/rs/zerolog/log"
+
+	"Rivall-Backend/api/resources"
+	"Rivall-Backend/api/websocket"
+	"Rivall-Backend/db"
+	"Rivall-Backend/util/api_error"
+	"Rivall-Backend/util/asyncapi"
+	"Rivall-Backend/util/keyring"
+	"Rivall-Backend/util/openapi"
+)
+
+//go:generate curl -fsSL -o docs/redoc.standalone.js https://cdn.redoc.ly/redoc/v2.1.5/bundles/redoc.standalone.js
+
+// docsFiles holds the documentation page and the Redoc bundle it renders with, served by
+// this API rather than a CDN. Change the version above and run go generate to update Redoc.
+//
+//go:embed docs
+var docsFiles embed.FS
+
+var info = openapi.Info{
+	Title:   "Rivall API",
+	Version: "1",
+	Description: "REST API of the Rivall app. Private routes take an access token from login in the " +
+		"`Authorization: Bearer` header. Every error answers with the error envelope, its `code` is " +
+		"listed in `util/api_error`.",
+}
+
+func okWith(body any) []openapi.RouteResponse {
+	return []openapi.RouteResponse{{Status: http.StatusOK, Body: body}}
+}
+
+func statusWith(status int, body any) []openapi.RouteResponse {
+	return []openapi.RouteResponse{{Status: status, Body: body}}
+}
+
+var noContent = []openapi.RouteResponse{{Status: http.StatusNoContent}}
+
+var pagination = []openapi.Parameter{
+	{Name: "limit", Description: fmt.Sprintf("Up to %d templates, %d by default", resources.MAX_TEMPLATE_PAGE_SIZE, resources.DEFAULT_TEMPLATE_PAGE_SIZE), Schema: &openapi.Schema{Type: "integer"}},
+	{Name: "offset", Description: "Templates to skip", Schema: &openapi.Schema{Type: "integer"}},
+}
+
+// routeDocs describes every route New registers, keyed by openapi.Key. Add new routes here,
+// the router tests fail on routes without a description
+var routeDocs = map[string]openapi.Route{
+	"GET /health": {
+		Tag: "health", Summary: "Check the API is up", Public: true,
+		Responses: okWith(resources.HealthRes{}),
+	},
+	"GET /.well-known/jwks.json": {
+		Tag: "auth", Summary: "Public keys access and refresh tokens are verified with", Public: true,
+		Responses: okWith(keyring.JWKS{}),
+	},
+	"GET /api/v1/openapi.json": {
+		Tag: "docs", Summary: "This OpenAPI document", Public: true,
+		Responses: okWith(map[string]any{}),
+	},
+	"GET /api/v1/asyncapi.json": {
+		Tag: "docs", Summary: "The AsyncAPI document of the websocket events", Public: true,
+		Responses: okWith(asyncapi.Document{}),
+	},
+	"GET /api/v1/docs": {
+		Tag: "docs", Summary: "Browsable documentation of the API", Public: true,
+		Responses: []openapi.RouteResponse{{Status: http.StatusOK, Body: "", ContentType: "text/html"}},
+	},
+	"GET /api/v1/docs/redoc.standalone.js": {
+		Tag: "docs", Summary: "The Redoc bundle the documentation page renders with", Public: true,
+		Responses: []openapi.RouteResponse{{Status: http.StatusOK, Body: "", ContentType: "text/javascript"}},
+	},
+
+	// Auth
+	"POST /api/v1/auth/register": {
+		Tag: "auth", Summary: "Register a new user", Public: true,
+		Description: "The account starts unverified and a verification code is emailed to it.",
+		Request:     resources.RegisterUserReq{},
+		Responses:   []openapi.RouteResponse{{Status: http.StatusCreated}},
+	},
+	"POST /api/v1/auth/login": {
+		Tag: "auth", Summary: "Log in with an email and password", Public: true,
+		Description: "Users with two-factor authentication get a challenge to answer at `/auth/login/2fa` instead of sessions. Accounts past their deletion grace period answer 410.",
+		Request:     resources.LoginUserReq{},
+		Responses:   []openapi.RouteResponse{{Status: http.StatusAccepted, OneOf: []any{resources.LoginUserRes{}, resources.TwoFactorChallengeRes{}}}},
+	},
+	"POST /api/v1/auth/login/2fa": {
+		Tag: "auth", Summary: "Answer a two-factor challenge", Public: true,
+		Request:   resources.LoginTwoFactorReq{},
+		Responses: statusWith(http.StatusAccepted, resources.LoginUserRes{}),
+	},
+	"GET /api/v1/auth/oidc/{provider}": {
+		Tag: "auth", Summary: "Start signing in with an OpenID Connect provider", Public: true,
+		Responses: okWith(resources.OIDCAuthorizationRes{}),
+	},
+	"POST /api/v1/auth/oidc/{provider}/callback": {
+		Tag: "auth", Summary: "Finish signing in with an OpenID Connect provider", Public: true,
+		Request:   resources.OIDCCallbackReq{},
+		Responses: []openapi.RouteResponse{{Status: http.StatusAccepted, OneOf: []any{resources.LoginUserRes{}, resources.TwoFactorChallengeRes{}}}},
+	},
+	"POST /api/v1/auth/verify-email": {
+		Tag: "auth", Summary: "Verify an email address with the code sent to it", Public: true,
+		Request:   resources.VerifyEmailReq{},
+		Responses: noContent,
+	},
+	"POST /api/v1/auth/verify-email/resend": {
+		Tag: "auth", Summary: "Send a new verification code", Public: true,
+		Request:   resources.ResendVerificationReq{},
+		Responses: []openapi.RouteResponse{{Status: http.StatusAccepted}},
+	},
+	"POST /api/v1/auth/recovery/send-code": {
+		Tag: "auth", Summary: "Send an account recovery code", Public: true,
+		Request:   resources.RecoveryCodeReq{},
+		Responses: []openapi.RouteResponse{{Status: http.StatusCreated}},
+	},
+	"POST /api/v1/auth/recovery/validate-code": {
+		Tag: "auth", Summary: "Log in with an account recovery code", Public: true,
+		Description: "The login also returns a `password_reset_token` to set a new password with.",
+		Request:     resources.RecoveryCodeValidationReq{},
+		Responses:   []openapi.RouteResponse{{Status: http.StatusAccepted, OneOf: []any{resources.LoginUserRes{}, resources.TwoFactorChallengeRes{}}}},
+	},
+	"PUT /api/v1/auth/recovery/{user_id}/reset-password": {
+		Tag: "auth", Summary: "Change a user's password",
+		Description: "Needs the `current_password` or the `reset_token` from account recovery. Users without a password send the `email_code` from `/confirmation-code` instead.",
+		Request:     resources.UpdatePasswordReq{},
+		Responses:   []openapi.RouteResponse{{Status: http.StatusCreated}},
+	},
+	"POST /api/v1/auth/{user_id}/refresh": {
+		Tag: "auth", Summary: "Trade a refresh token for new tokens",
+		Request:   resources.RenewAccessTokenReq{},
+		Responses: statusWith(http.StatusCreated, resources.RenewAccessTokenRes{}),
+	},
+	"DELETE /api/v1/auth/{user_id}/logout": {
+		Tag: "auth", Summary: "Log out",
+		Description: "Ends the login the access token belongs to, its refresh token stops working too.",
+		Responses:   []openapi.RouteResponse{{Status: http.StatusOK}},
+	},
+
+	// Users
+	"GET /api/v1/contacts/{user_id}": {
+		Tag: "users", Summary: "Look up a discoverable user's public profile", Public: true,
+		Responses: okWith(resources.PublicUserRes{}),
+	},
+	"GET /api/v1/users/{user_id}": {
+		Tag: "users", Summary: "Retrieve the user's own account",
+		Responses: okWith(resources.SelfUserRes{}),
+	},
+	"PATCH /api/v1/users/{user_id}": {
+		Tag: "users", Summary: "Update the user's profile, only the fields sent are changed",
+		Request:   resources.UpdateUserReq{},
+		Responses: okWith(resources.SelfUserRes{}),
+	},
+	"DELETE /api/v1/users/{user_id}": {
+		Tag: "users", Summary: "Delete the user's account after a grace period",
+		Description: "Needs the `password`, or the `email_code` from `/confirmation-code` for users without one.",
+		Request:     resources.DeleteUserReq{},
+		Responses:   statusWith(http.StatusAccepted, resources.DeleteUserRes{}),
+	},
+	"POST /api/v1/users/{user_id}/confirmation-code": {
+		Tag: "users", Summary: "Mail a code confirming a sensitive change",
+		Description: "For users without a password, like those created by an OpenID Connect provider. The code stands in for the password when changing it, turning off two-factor authentication or deleting the account.",
+		Responses:   []openapi.RouteResponse{{Status: http.StatusAccepted}},
+	},
+	"DELETE /api/v1/users/{user_id}/deletion": {
+		Tag: "users", Summary: "Cancel a scheduled account deletion",
+		Responses: noContent,
+	},
+	"GET /api/v1/users/{user_id}/settings": {
+		Tag: "users", Summary: "Retrieve the user's privacy and notification settings",
+		Responses: okWith(db.UserSettings{}),
+	},
+	"PATCH /api/v1/users/{user_id}/settings": {
+		Tag: "users", Summary: "Change the user's settings, only the fields sent are changed",
+		Request:   resources.UpdateUserSettingsReq{},
+		Responses: okWith(db.UserSettings{}),
+	},
+	"POST /api/v1/users/{user_id}/exports": {
+		Tag: "users", Summary: "Request a copy of everything held about the user",
+		Description: "Answers 409 while the user has an export waiting or running.",
+		Responses:   statusWith(http.StatusAccepted, db.DataExport{}),
+	},
+	"GET /api/v1/users/{user_id}/exports/{export_id}": {
+		Tag: "users", Summary: "Check on a data export",
+		Responses: okWith(db.DataExport{}),
+	},
+	"GET /api/v1/users/{user_id}/exports/{export_id}/download": {
+		Tag: "users", Summary: "Download a ready data export",
+		Responses: []openapi.RouteResponse{{Status: http.StatusOK, Description: "A zip of JSON files", ContentType: "application/zip"}},
+	},
+	"PUT /api/v1/users/{user_id}/email": {
+		Tag: "users", Summary: "Ask to change the user's email",
+		Description: "Needs the `password`, or the `email_code` from `/confirmation-code` for users without one, and with two-factor authentication a `code`.",
+		Request:     resources.ChangeEmailReq{},
+		Responses:   []openapi.RouteResponse{{Status: http.StatusAccepted}},
+	},
+	"POST /api/v1/users/{user_id}/email/verify": {
+		Tag: "users", Summary: "Confirm an email change with the code sent to the new address",
+		Request:   resources.ConfirmEmailChangeReq{},
+		Responses: noContent,
+	},
+
+	// Two-factor authentication and linked providers
+	"POST /api/v1/users/{user_id}/2fa/enroll": {
+		Tag: "2fa", Summary: "Start two-factor enrollment",
+		Responses: statusWith(http.StatusCreated, resources.TwoFactorEnrollRes{}),
+	},
+	"POST /api/v1/users/{user_id}/2fa/confirm": {
+		Tag: "2fa", Summary: "Turn two-factor authentication on with a first code",
+		Description: "Needs the `password`, or the `email_code` from `/confirmation-code` for users without one, and a `code` from the authenticator.",
+		Request:     resources.ConfirmTwoFactorReq{},
+		Responses:   statusWith(http.StatusCreated, resources.TwoFactorRecoveryCodesRes{}),
+	},
+	"DELETE /api/v1/users/{user_id}/2fa": {
+		Tag: "2fa", Summary: "Turn two-factor authentication off",
+		Description: "Needs the `password`, or the `email_code` from `/confirmation-code` for users without one, and a `code`.",
+		Request:     resources.DisableTwoFactorReq{},
+		Responses:   noContent,
+	},
+	"POST /api/v1/users/{user_id}/oidc/{provider}": {
+		Tag: "auth", Summary: "Start linking an OpenID Connect provider account",
+		Responses: okWith(resources.OIDCAuthorizationRes{}),
+	},
+	"POST /api/v1/users/{user_id}/oidc/{provider}/callback": {
+		Tag: "auth", Summary: "Finish linking an OpenID Connect provider account",
+		Request:   resources.OIDCCallbackReq{},
+		Responses: noContent,
+	},
+	"DELETE /api/v1/users/{user_id}/oidc/{provider}": {
+		Tag: "auth", Summary: "Unlink an OpenID Connect provider",
+		Responses: noContent,
+	},
+
+	// Sessions
+	"GET /api/v1/users/{user_id}/sessions": {
+		Tag: "sessions", Summary: "List the devices the user is logged in on",
+		Responses: okWith([]resources.DeviceSessionRes{}),
+	},
+	"DELETE /api/v1/users/{user_id}/sessions": {
+		Tag: "sessions", Summary: "Log out every other device",
+		Responses: okWith(resources.RevokeSessionsRes{}),
+	},
+	"DELETE /api/v1/users/{user_id}/sessions/{session_id}": {
+		Tag: "sessions", Summary: "Log out one device",
+		Responses: noContent,
+	},
+
+	// Contacts
+	"POST /api/v1/users/{user_id}/contacts": {
+		Tag: "contacts", Summary: "Add a contact",
+		Request:   resources.NewContactReq{},
+		Responses: statusWith(http.StatusCreated, resources.ContactUserRes{}),
+	},
+	"GET /api/v1/users/{user_id}/contacts/{chat_id}/chat": {
+		Tag: "contacts", Summary: "Retrieve the direct messages with a contact",
+		Responses: okWith(resources.ChatRes{}),
+	},
+
+	// Groups
+	"POST /api/v1/users/{user_id}/groups": {
+		Tag: "groups", Summary: "Create a group and send requests to the listed users",
+		Request:   resources.NewGroupReq{},
+		Responses: statusWith(http.StatusCreated, resources.NewGroupRes{}),
+	},
+	"GET /api/v1/users/{user_id}/groups": {
+		Tag: "groups", Summary: "List the user's groups",
+		Responses: okWith([]resources.GroupRes{}),
+	},
+	"GET /api/v1/users/{user_id}/groups/requests": {
+		Tag: "groups", Summary: "List the user's pending group requests",
+		Responses: okWith([]db.GroupRequest{}),
+	},
+	"GET /api/v1/users/{user_id}/groups/{group_id}": {
+		Tag: "groups", Summary: "Retrieve a group and a page of its messages",
+		Description: "Pages count back from the newest message, `has_more_messages` is set while older ones are left.",
+		Query: []openapi.Parameter{
+			{Name: "limit", Description: fmt.Sprintf("Up to %d messages, %d by default", resources.MAX_MESSAGE_PAGE_SIZE, resources.DEFAULT_MESSAGE_PAGE_SIZE), Schema: &openapi.Schema{Type: "integer"}},
+			{Name: "offset", Description: "Newest messages to skip", Schema: &openapi.Schema{Type: "integer"}},
+		},
+		Responses: okWith(resources.GroupWithMessagesRes{}),
+	},
+	"PATCH /api/v1/users/{user_id}/groups/{group_id}": {
+		Tag: "groups", Summary: "Rename a group (admins only)",
+		Request:   resources.UpdateGroupReq{},
+		Responses: okWith(resources.GroupRes{}),
+	},
+	"DELETE /api/v1/users/{user_id}/groups/{group_id}": {
+		Tag: "groups", Summary: "Delete a group (owner only)",
+		Responses: noContent,
+	},
+	"GET /api/v1/users/{user_id}/groups/{group_id}/members": {
+		Tag: "groups", Summary: "List a group's members",
+		Responses: okWith([]resources.GroupMemberRes{}),
+	},
+	"DELETE /api/v1/users/{user_id}/groups/{group_id}/members/{member_id}": {
+		Tag: "groups", Summary: "Remove a member from a group",
+		Responses: noContent,
+	},
+	"PUT /api/v1/users/{user_id}/groups/{group_id}/members/{member_id}/role": {
+		Tag: "groups", Summary: "Promote or demote a member (owner only)",
+		Request:   resources.UpdateGroupMemberRoleReq{},
+		Responses: noContent,
+	},
+	"PUT /api/v1/users/{user_id}/groups/{group_id}/owner": {
+		Tag: "groups", Summary: "Transfer group ownership (owner only)",
+		Request:   resources.TransferGroupOwnershipReq{},
+		Responses: noContent,
+	},
+	"POST /api/v1/users/{user_id}/groups/{group_id}/leave": {
+		Tag: "groups", Summary: "Leave a group",
+		Responses: noContent,
+	},
+	"GET /api/v1/users/{user_id}/groups/{group_id}/requests": {
+		Tag: "groups", Summary: "List a group's pending requests (admins only)",
+		Responses: okWith([]db.GroupRequest{}),
+	},
+	"POST /api/v1/users/{user_id}/groups/{group_id}/accept": {
+		Tag: "groups", Summary: "Accept a group request",
+		Responses: []openapi.RouteResponse{{Status: http.StatusOK}},
+	},
+	"POST /api/v1/users/{user_id}/groups/{group_id}/reject": {
+		Tag: "groups", Summary: "Reject a group request",
+		Responses: []openapi.RouteResponse{{Status: http.StatusOK}},
+	},
+
+	// Group invites
+	"POST /api/v1/users/{user_id}/groups/{group_id}/invites": {
+		Tag: "invites", Summary: "Create an invite link (admins only)",
+		Request:   resources.NewGroupInviteReq{},
+		Responses: statusWith(http.StatusCreated, db.GroupInvite{}),
+	},
+	"GET /api/v1/users/{user_id}/groups/{group_id}/invites": {
+		Tag: "invites", Summary: "List a group's active invites (admins only)",
+		Responses: okWith([]db.GroupInvite{}),
+	},
+	"DELETE /api/v1/users/{user_id}/groups/{group_id}/invites/{invite_id}": {
+		Tag: "invites", Summary: "Revoke an invite (admins only)",
+		Responses: noContent,
+	},
+	"GET /api/v1/users/{user_id}/groups/{group_id}/join-requests": {
+		Tag: "invites", Summary: "List the users waiting for approval (admins only)",
+		Responses: okWith([]db.GroupJoinRequest{}),
+	},
+	"POST /api/v1/users/{user_id}/groups/{group_id}/join-requests/{member_id}/approve": {
+		Tag: "invites", Summary: "Approve a join request (admins only)",
+		Description: "Counts as a use of the invite the request was made with, an invite that was used up, expired or revoked meanwhile answers 410.",
+		Responses:   noContent,
+	},
+	"POST /api/v1/users/{user_id}/groups/{group_id}/join-requests/{member_id}/reject": {
+		Tag: "invites", Summary: "Reject a join request (admins only)",
+		Responses: noContent,
+	},
+	"POST /api/v1/users/{user_id}/invites/{code}/join": {
+		Tag: "invites", Summary: "Join a group with an invite code",
+		Responses: []openapi.RouteResponse{
+			{Status: http.StatusOK, Description: "Joined the group", Body: resources.JoinGroupInviteRes{}},
+			{Status: http.StatusAccepted, Description: "Waiting for an admin to approve", Body: resources.JoinGroupInviteRes{}},
+		},
+	},
+	"GET /api/v1/invites/{code}": {
+		Tag: "invites", Summary: "Preview the group an invite code joins",
+		Responses: okWith(resources.GroupInvitePreviewRes{}),
+	},
+
+	// Challenge templates
+	"POST /api/v1/users/{user_id}/challenges/{challenge_id}/template": {
+		Tag: "challenges", Summary: "Publish a completed, well rated challenge as a template",
+		Description: "A title or description that names the group, the challenge's own included, answers 422 until it is reworded.",
+		Request:     resources.PublishChallengeTemplateReq{}, OptionalRequest: true,
+		Responses: statusWith(http.StatusCreated, db.ChallengeTemplate{}),
+	},
+	"GET /api/v1/users/{user_id}/groups/{group_id}/challenge-templates/recommended": {
+		Tag: "challenges", Summary: "Recommend challenge templates for a group",
+		Query:     pagination[:1],
+		Responses: okWith([]resources.ScoredChallengeTemplateRes{}),
+	},
+	"GET /api/v1/challenge-templates": {
+		Tag: "challenges", Summary: "Search challenge templates",
+		Query: append([]openapi.Parameter{
+			{Name: "q", Description: "Text to search titles and descriptions for"},
+			{Name: "metric_type", Schema: &openapi.Schema{Type: "string", Enum: db.MetricTypes}},
+		}, pagination...),
+		Responses: okWith([]db.ChallengeTemplate{}),
+	},
+	"GET /api/v1/challenge-templates/{template_id}": {
+		Tag: "challenges", Summary: "Retrieve a challenge template",
+		Responses: okWith(db.ChallengeTemplate{}),
+	},
+
+	// Websocket
+	"GET /api/v1/ws/connect/{user_id}": {
+		Tag: "websocket", Summary: "Open the websocket", Public: true,
+		Description: "Upgrades to a websocket, the access token is sent as the `Authorization` query parameter. " +
+			"The events are described in the AsyncAPI document at `/api/v1/asyncapi.json`.",
+		Query:     []openapi.Parameter{{Name: "Authorization", Description: "An access token", Required: true}},
+		Responses: []openapi.RouteResponse{{Status: http.StatusSwitchingProtocols}},
+	},
+}
+
+// OpenAPI describes the routes of r. It errors on routes missing from routeDocs and on
+// descriptions of routes r doesn't serve, the document still holds the described ones
+func OpenAPI(r *mux.Router) (openapi.Document, error) {
+	g := openapi.New(info, api_error.Response{})
+
+	served := make(map[string]bool)
+	var errs []error
+	err := r.Walk(func(route *mux.Route, _ *mux.Router, _ []*mux.Route) error {
+		handler := route.GetHandler()
+		if handler == nil {
+			return nil
+		}
+		path, err := route.GetPathTemplate()
+		if err != nil {
+			return err
+		}
+		methods, err := route.GetMethods()
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s has no methods", path))
+			return nil
+		}
+
+		for _, method := range methods {
+			key := openapi.Key(method, path)
+			served[key] = true
+			doc, ok := routeDocs[key]
+			if !ok {
+				errs = append(errs, fmt.Errorf("%s is not described", key))
+				continue
+			}
+			g.Add(method, path, handlerName(handler), doc)
+		}
+		return nil
+	})
+	if err != nil {
+		return g.Document(), err
+	}
+
+	keys := make([]string, 0, len(routeDocs))
+	for key := range routeDocs {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	for _, key := range keys {
+		if !served[key] {
+			errs = append(errs, fmt.Errorf("%s is described but not served", key))
+		}
+	}
+
+	return g.Document(), errors.Join(errs...)
+}
+
+// handlerName is the name of the function serving a route, used as its operation id
+func handlerName(handler http.Handler) string {
+	name := runtime.FuncForPC(reflect.ValueOf(handler).Pointer()).Name()
+	name = name[strings.LastIndex(name, ".")+1:]
+	return strings.TrimSuffix(name, "-fm")
+}
+
+// serveOpenAPI answers with the document of r, built on the first request once every route
+// is registered
+func serveOpenAPI(r *mux.Router) http.HandlerFunc {
+	var once sync.Once
+	var body []byte
+	return func(w http.ResponseWriter, req *http.Request) {
+		once.Do(func() {
+			doc, err := OpenAPI(r)
+			if err != nil {
+				log.Warn().Err(err).Msg("OpenAPI document is incomplete")
+			}
+			body, err = json.Marshal(doc)
+			if err != nil {
+				log.Error().Err(err).Msg("Failed to marshal OpenAPI document")
+			}
+		})
+		if body == nil {
+			api_error.Write(w, req, http.StatusInternalServerError, api_error.INTERNAL, "Failed to build the OpenAPI document.")
+			return
+		}
+		w.Write(body)
+	}
+}
+
+var (
+	asyncAPIOnce sync.Once
+	asyncAPIBody []byte
+)
+
+// serveAsyncAPI answers with the document of the websocket events, which only depends on
+// the event registry
+func serveAsyncAPI(w http.ResponseWriter, r *http.Request) {
+	asyncAPIOnce.Do(func() {
+		var err error
+		asyncAPIBody, err = json.Marshal(websocket.AsyncAPI())
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to marshal AsyncAPI document")
+		}
+	})
+	if asyncAPIBody == nil {
+		api_error.Write(w, r, http.StatusInternalServerError, api_error.INTERNAL, "Failed to build the AsyncAPI document.")
+		return
+	}
+	w.Write(asyncAPIBody)
+}
+
+func serveDocs(w http.ResponseWriter, r *http.Request) {
+	page, err := fs.ReadFile(docsFiles, "docs/docs.html")
+	if err != nil {
+		api_error.Write(w, r, http.StatusInternalServerError, api_error.INTERNAL, "Failed to read the documentation page")
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write(page)
+}
+
+func serveDocsScript(w http.ResponseWriter, r *http.Request) {
+	script, err := fs.ReadFile(docsFiles, "docs/redoc.standalone.js")
+	if err != nil {
+		log.Error().Err(err).Msg("Redoc bundle is not vendored, run go generate ./api/router")
+		api_error.Write(w, r, http.StatusNotFound, api_error.NOT_FOUND, "Documentation bundle not found")
+		return
+	}
+	w.Header().Set("Content-Type", "text/javascript; charset=utf-8")
+	w.Header().Set("Cache-Control", "public, max-age=86400")
+	w.Write(script)
+}
@@ -18,28 +18,88 @@ func New() *mux.Router {
 	// Add health routes
 	r.HandleFunc("/health", resources.Read).Methods(http.MethodGet)
 
+	// Add the public keys tokens are verified with
+	r.HandleFunc("/.well-known/jwks.json", resources.GetJWKS).Methods(http.MethodGet)
+
 	// Add v1 routes
 	publicRouter := r.PathPrefix("/api/v1").Subrouter()
 	publicRouter.HandleFunc("/auth/register", resources.RegisterNewUser).Methods(http.MethodPost)
 	publicRouter.HandleFunc("/auth/login", resources.LoginUser).Methods(http.MethodPost)
+	publicRouter.HandleFunc("/auth/login/2fa", resources.LoginTwoFactor).Methods(http.MethodPost)
+	publicRouter.HandleFunc("/auth/oidc/{provider}", resources.StartOIDCLogin).Methods(http.MethodGet)
+	publicRouter.HandleFunc("/auth/oidc/{provider}/callback", resources.OIDCLoginCallback).Methods(http.MethodPost)
+	publicRouter.HandleFunc("/auth/verify-email", resources.VerifyEmail).Methods(http.MethodPost)
+	publicRouter.HandleFunc("/auth/verify-email/resend", resources.ResendVerificationEmail).Methods(http.MethodPost)
 	publicRouter.HandleFunc("/auth/recovery/send-code", resources.SendAccountRecoveryEmail).Methods(http.MethodPost)
 	publicRouter.HandleFunc("/auth/recovery/validate-code", resources.ValidateAccountRecoveryCode).Methods(http.MethodPost)
 	publicRouter.HandleFunc("/contacts/{user_id}", resources.GetContact).Methods(http.MethodGet)
 
+	// Add the API documentation, every route needs a description in routeDocs
+	publicRouter.HandleFunc("/openapi.json", serveOpenAPI(r)).Methods(http.MethodGet)
+	publicRouter.HandleFunc("/asyncapi.json", serveAsyncAPI).Methods(http.MethodGet)
+	publicRouter.HandleFunc("/docs", serveDocs).Methods(http.MethodGet)
+	publicRouter.HandleFunc("/docs/redoc.standalone.js", serveDocsScript).Methods(http.MethodGet)
+
 	privateRouter := r.PathPrefix("/api/v1").Subrouter()
 	privateRouter.Use(middleware.AuthMiddleware)
 
 	privateRouter.HandleFunc("/users/{user_id}", resources.GetUser).Methods(http.MethodGet)
+	privateRouter.HandleFunc("/users/{user_id}", resources.UpdateUser).Methods(http.MethodPatch)
+	privateRouter.HandleFunc("/users/{user_id}", resources.DeleteUser).Methods(http.MethodDelete)
+	privateRouter.HandleFunc("/users/{user_id}/settings", resources.GetUserSettings).Methods(http.MethodGet)
+	privateRouter.HandleFunc("/users/{user_id}/settings", resources.UpdateUserSettings).Methods(http.MethodPatch)
+	privateRouter.HandleFunc("/users/{user_id}/deletion", resources.CancelUserDeletion).Methods(http.MethodDelete)
+	privateRouter.HandleFunc("/users/{user_id}/confirmation-code", resources.SendConfirmationCode).Methods(http.MethodPost)
+	privateRouter.HandleFunc("/users/{user_id}/exports", resources.RequestDataExport).Methods(http.MethodPost)
+	privateRouter.HandleFunc("/users/{user_id}/exports/{export_id}", resources.GetDataExport).Methods(http.MethodGet)
+	privateRouter.HandleFunc("/users/{user_id}/exports/{export_id}/download", resources.DownloadDataExport).Methods(http.MethodGet)
 	privateRouter.HandleFunc("/auth/recovery/{user_id}/reset-password", resources.UpdateUserPassword).Methods(http.MethodPut)
 	privateRouter.HandleFunc("/auth/{user_id}/refresh", resources.RenewAccessToken).Methods(http.MethodPost)
 	privateRouter.HandleFunc("/auth/{user_id}/logout", resources.LogoutUser).Methods(http.MethodDelete)
 	privateRouter.HandleFunc("/users/{user_id}", resources.GetUser).Methods(http.MethodGet)
+	privateRouter.HandleFunc("/users/{user_id}/2fa/enroll", resources.EnrollTwoFactor).Methods(http.MethodPost)
+	privateRouter.HandleFunc("/users/{user_id}/2fa/confirm", resources.ConfirmTwoFactor).Methods(http.MethodPost)
+	privateRouter.HandleFunc("/users/{user_id}/2fa", resources.DisableTwoFactor).Methods(http.MethodDelete)
+	privateRouter.HandleFunc("/users/{user_id}/oidc/{provider}", resources.StartOIDCLink).Methods(http.MethodPost)
+	privateRouter.HandleFunc("/users/{user_id}/oidc/{provider}/callback", resources.LinkOIDCCallback).Methods(http.MethodPost)
+	privateRouter.HandleFunc("/users/{user_id}/oidc/{provider}", resources.UnlinkOIDC).Methods(http.MethodDelete)
+	privateRouter.HandleFunc("/users/{user_id}/email", resources.ChangeUserEmail).Methods(http.MethodPut)
+	privateRouter.HandleFunc("/users/{user_id}/email/verify", resources.ConfirmUserEmailChange).Methods(http.MethodPost)
 	privateRouter.HandleFunc("/users/{user_id}/contacts", resources.PostUserContact).Methods(http.MethodPost)
 	privateRouter.HandleFunc("/users/{user_id}/contacts/{chat_id}/chat", resources.GetChat).Methods(http.MethodGet)
+	privateRouter.HandleFunc("/users/{user_id}/sessions", resources.GetUserSessions).Methods(http.MethodGet)
+	privateRouter.HandleFunc("/users/{user_id}/sessions", resources.RevokeOtherUserSessions).Methods(http.MethodDelete)
+	privateRouter.HandleFunc("/users/{user_id}/sessions/{session_id}", resources.RevokeUserSession).Methods(http.MethodDelete)
+	privateRouter.HandleFunc("/users/{user_id}/groups", resources.WriteNewMessageGroup).Methods(http.MethodPost)
+	privateRouter.HandleFunc("/users/{user_id}/groups", resources.GetUserGroups).Methods(http.MethodGet)
+	privateRouter.HandleFunc("/users/{user_id}/groups/requests", resources.GetUserGroupRequests).Methods(http.MethodGet)
+	privateRouter.HandleFunc("/users/{user_id}/groups/{group_id}", resources.GetGroup).Methods(http.MethodGet)
+	privateRouter.HandleFunc("/users/{user_id}/groups/{group_id}", resources.UpdateGroup).Methods(http.MethodPatch)
+	privateRouter.HandleFunc("/users/{user_id}/groups/{group_id}", resources.DeleteGroup).Methods(http.MethodDelete)
+	privateRouter.HandleFunc("/users/{user_id}/groups/{group_id}/members", resources.GetGroupMembers).Methods(http.MethodGet)
+	privateRouter.HandleFunc("/users/{user_id}/groups/{group_id}/members/{member_id}", resources.KickGroupMember).Methods(http.MethodDelete)
+	privateRouter.HandleFunc("/users/{user_id}/groups/{group_id}/members/{member_id}/role", resources.UpdateGroupMemberRole).Methods(http.MethodPut)
+	privateRouter.HandleFunc("/users/{user_id}/groups/{group_id}/owner", resources.TransferGroupOwnership).Methods(http.MethodPut)
+	privateRouter.HandleFunc("/users/{user_id}/groups/{group_id}/leave", resources.LeaveGroup).Methods(http.MethodPost)
+	privateRouter.HandleFunc("/users/{user_id}/groups/{group_id}/requests", resources.GetGroupPendingRequests).Methods(http.MethodGet)
+	privateRouter.HandleFunc("/users/{user_id}/groups/{group_id}/accept", resources.AcceptGroupRequest).Methods(http.MethodPost)
+	privateRouter.HandleFunc("/users/{user_id}/groups/{group_id}/reject", resources.RejectGroupRequest).Methods(http.MethodPost)
+	privateRouter.HandleFunc("/users/{user_id}/groups/{group_id}/invites", resources.CreateGroupInvite).Methods(http.MethodPost)
+	privateRouter.HandleFunc("/users/{user_id}/groups/{group_id}/invites", resources.GetGroupInvites).Methods(http.MethodGet)
+	privateRouter.HandleFunc("/users/{user_id}/groups/{group_id}/invites/{invite_id}", resources.RevokeGroupInvite).Methods(http.MethodDelete)
+	privateRouter.HandleFunc("/users/{user_id}/groups/{group_id}/join-requests", resources.GetGroupJoinRequests).Methods(http.MethodGet)
+	privateRouter.HandleFunc("/users/{user_id}/groups/{group_id}/join-requests/{member_id}/approve", resources.ApproveGroupJoinRequest).Methods(http.MethodPost)
+	privateRouter.HandleFunc("/users/{user_id}/groups/{group_id}/join-requests/{member_id}/reject", resources.RejectGroupJoinRequest).Methods(http.MethodPost)
+	privateRouter.HandleFunc("/users/{user_id}/invites/{code}/join", resources.JoinGroupWithInvite).Methods(http.MethodPost)
+	privateRouter.HandleFunc("/invites/{code}", resources.GetGroupInvitePreview).Methods(http.MethodGet)
+	privateRouter.HandleFunc("/users/{user_id}/challenges/{challenge_id}/template", resources.PublishChallengeTemplate).Methods(http.MethodPost)
+	privateRouter.HandleFunc("/users/{user_id}/groups/{group_id}/challenge-templates/recommended", resources.GetRecommendedChallengeTemplates).Methods(http.MethodGet)
+	privateRouter.HandleFunc("/challenge-templates", resources.GetChallengeTemplates).Methods(http.MethodGet)
+	privateRouter.HandleFunc("/challenge-templates/{template_id}", resources.GetChallengeTemplate).Methods(http.MethodGet)
 	// privateRouter.HandleFunc("/contacts/{user_id}", resources.GetContact).Methods(http.MethodGet)
 
 	privateWSRouter := r.PathPrefix("/api/v1/ws").Subrouter()
-	privateWSRouter.HandleFunc("/connect/{user_id}", websocket.WSManager.ServeWS)
+	privateWSRouter.HandleFunc("/connect/{user_id}", websocket.WSManager.ServeWS).Methods(http.MethodGet)
 
 	// Middlewares
 	r.Use(middleware.RequestID)
@@ -0,0 +1,90 @@
+package router_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"Rivall-Backend/api/resources"
+	"Rivall-Backend/api/router"
+	"Rivall-Backend/util/test"
+)
+
+func TestEveryRouteIsDescribed(t *testing.T) {
+	// a new route needs a description in routeDocs, a removed one needs its description gone
+	doc, err := router.OpenAPI(router.New())
+	if err != nil {
+		t.Fatalf("OpenAPI document is out of date with the router:\n%v", err)
+	}
+
+	for path, item := range doc.Paths {
+		for method, op := range item {
+			if op.Summary == "" {
+				t.Errorf("%s %s has no summary", method, path)
+			}
+			if len(op.Responses) < 2 {
+				t.Errorf("%s %s describes no successful response", method, path)
+			}
+		}
+	}
+}
+
+func TestServeHealth(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/health", nil)
+	w := httptest.NewRecorder()
+	router.New().ServeHTTP(w, req)
+
+	test.Equal(t, w.Code, http.StatusOK)
+	res := resources.HealthRes{}
+	test.NoError(t, json.NewDecoder(w.Body).Decode(&res))
+	test.Equal(t, res.Status, "healthy")
+}
+
+func TestServeOpenAPI(t *testing.T) {
+	r := router.New()
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/openapi.json", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	test.Equal(t, w.Code, http.StatusOK)
+	var doc struct {
+		OpenAPI string         `json:"openapi"`
+		Paths   map[string]any `json:"paths"`
+	}
+	test.NoError(t, json.NewDecoder(w.Body).Decode(&doc))
+	test.Equal(t, doc.OpenAPI, "3.0.3")
+	if _, ok := doc.Paths["/api/v1/users/{user_id}/groups/{group_id}"]; !ok {
+		t.Fatal("Expected the group route in the document")
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/api/v1/docs", nil)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	test.Equal(t, w.Code, http.StatusOK)
+	test.Equal(t, w.Header().Get("Content-Type"), "text/html; charset=utf-8")
+	// the page only loads scripts served by the API
+	if !strings.Contains(w.Body.String(), `<script src="/api/v1/docs/redoc.standalone.js">`) || strings.Contains(w.Body.String(), "https://") {
+		t.Fatal("Expected the docs page to load the vendored Redoc bundle")
+	}
+}
+
+func TestServeAsyncAPI(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/asyncapi.json", nil)
+	w := httptest.NewRecorder()
+	router.New().ServeHTTP(w, req)
+
+	test.Equal(t, w.Code, http.StatusOK)
+	var doc struct {
+		AsyncAPI string         `json:"asyncapi"`
+		Channels map[string]any `json:"channels"`
+	}
+	test.NoError(t, json.NewDecoder(w.Body).Decode(&doc))
+	test.Equal(t, doc.AsyncAPI, "2.6.0")
+	if _, ok := doc.Channels["/api/v1/ws/connect/{user_id}"]; !ok {
+		t.Fatal("Expected the websocket channel in the document")
+	}
+}
@@ -0,0 +1,85 @@
+package websocket
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	db "Rivall-Backend/db"
+	"Rivall-Backend/globals"
+
+	"github.com/rs/zerolog/log"
+)
+
+// ACCOUNT_DELETION_INTERVAL is how often accounts past their grace period are looked for
+const ACCOUNT_DELETION_INTERVAL = time.Minute * 10
+
+// DeleteAccount removes a user for good. Their groups pass on as if they left, their
+// messages stay behind anonymized and nothing else keeps pointing at them. The user is
+// deleted last, so a run that fails part way is finished by the next one.
+func DeleteAccount(m *Manager, userID string) error {
+	// log them out everywhere first so nothing changes while they are taken apart
+	if err := globals.SessionManager.RevokeUserSessions(userID); err != nil {
+		log.Error().Err(err).Msg("failed to revoke sessions of deleted user")
+		return err
+	}
+	m.RemoveClientByUserID(userID)
+
+	groups, err := db.ReadGroupsByUserId(userID)
+	if err != nil {
+		return err
+	}
+	for _, group := range groups {
+		err := leaveGroup(m, userID, group.ID.Hex(), MemberLeftReasonDeleted)
+		if err != nil && !errors.Is(err, ErrNotGroupMember) && !errors.Is(err, ErrGroupDoesNotExist) {
+			log.Error().Err(err).Msg("failed to remove deleted user from group")
+			return err
+		}
+	}
+
+	if err := db.RemoveUserReferences(userID); err != nil {
+		return err
+	}
+	if err := db.AnonymizeUser(userID); err != nil {
+		return err
+	}
+	if err := db.DeleteUserDataExports(userID); err != nil {
+		return err
+	}
+	if err := db.DeleteUser(userID); err != nil {
+		return err
+	}
+	// and again, a refresh that raced the cascade must not outlive the user
+	if err := globals.SessionManager.RevokeUserSessions(userID); err != nil {
+		log.Error().Err(err).Msg("failed to revoke sessions of deleted user")
+		return err
+	}
+
+	if err := db.CreateAuditEntry(db.AUDIT_ACCOUNT_DELETED, userID, nil); err != nil {
+		log.Error().Err(err).Msg("failed to audit account deletion")
+	}
+	log.Info().Msgf("Deleted account %s", userID)
+	return nil
+}
+
+// RunAccountDeletions deletes accounts whose grace period is over until ctx is done
+func (m *Manager) RunAccountDeletions(ctx context.Context) {
+	ticker := time.NewTicker(ACCOUNT_DELETION_INTERVAL)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			userIDs, err := db.ReadUserIdsDueForDeletion(time.Now())
+			if err != nil {
+				continue
+			}
+			for _, userID := range userIDs {
+				if err := DeleteAccount(m, userID); err != nil {
+					log.Error().Err(err).Msgf("failed to delete account %s", userID)
+				}
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
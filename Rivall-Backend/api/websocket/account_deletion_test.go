@@ -0,0 +1,80 @@
+package websocket_test
+
+import (
+	"testing"
+
+	"Rivall-Backend/api/websocket"
+	db "Rivall-Backend/db"
+	"Rivall-Backend/globals"
+	"Rivall-Backend/util/session_manager"
+	"Rivall-Backend/util/test"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+func TestDeleteAccount(t *testing.T) {
+	m := setupManager(t)
+
+	sam := createUser(t, "sam@example.com")
+	alex := createUser(t, "alex@example.com")
+	kim := createUser(t, "kim@example.com")
+	samID, _ := bson.ObjectIDFromHex(sam)
+	alexID, _ := bson.ObjectIDFromHex(alex)
+
+	// sam owns a group with kim as its admin, and one nobody else is in
+	shared, err := db.CreateGroup("Climbers", sam)
+	test.NoError(t, err)
+	test.NoError(t, db.AddUserToGroup(shared, alex))
+	test.NoError(t, db.AddUserToGroup(shared, kim))
+	test.NoError(t, db.SetGroupMemberRole(shared, kim, db.GROUP_ROLE_ADMIN))
+	alone, err := db.CreateGroup("Solo", sam)
+	test.NoError(t, err)
+	test.NoError(t, db.InsertGroupMessage(shared, db.Message{
+		ID:          bson.NewObjectID(),
+		UserID:      samID,
+		MessageData: "hello",
+		SeenBy:      []bson.ObjectID{samID, alexID},
+	}))
+
+	// and is pointed at by a contact, a group request and a join request
+	test.NoError(t, db.CreateContact(sam, alex))
+	_, err = db.CreateGroupRequest(sam, alex, shared, "Climbers", "join us")
+	test.NoError(t, err)
+	other, err := db.CreateGroup("Runners", alex)
+	test.NoError(t, err)
+	test.NoError(t, db.AddGroupJoinRequest(other, sam, bson.NewObjectID().Hex()))
+
+	access, refresh, err := globals.SessionManager.NewSessionFamily(sam, session_manager.Device{Name: "Phone"})
+	test.NoError(t, err)
+	// a session no device lists, like one left by a login that didn't finish
+	stray, err := globals.SessionManager.NewAccessSession(sam, "family")
+	test.NoError(t, err)
+
+	test.NoError(t, websocket.DeleteAccount(m, sam))
+
+	test.Equal(t, db.UserExists(sam), false)
+
+	// the longest serving admin inherits the group, the empty group is gone
+	group := db.ReadByGroupId(shared)
+	test.Equal(t, group.Role(kim), db.GROUP_ROLE_OWNER)
+	test.Equal(t, group.Role(sam), "")
+	test.Equal(t, group.Role(alex), db.GROUP_ROLE_MEMBER)
+	test.Equal(t, db.GroupExists(alone), false)
+
+	// the message stays, but no longer says who wrote or read it
+	test.Equal(t, len(group.Messages), 1)
+	test.Equal(t, group.Messages[0].UserID, db.DELETED_USER_ID)
+	test.Equal(t, len(group.Messages[0].SeenBy), 1)
+	test.Equal(t, group.Messages[0].SeenBy[0], alexID)
+	test.Equal(t, group.LastMessage.UserID, db.DELETED_USER_ID)
+
+	user := db.ReadByUserId(alex)
+	test.Equal(t, len(user.Contacts), 0)
+	test.Equal(t, len(user.GroupRequests), 0)
+	test.Equal(t, len(db.ReadByGroupId(other).JoinRequests), 0)
+
+	for _, token := range []string{access.Token, refresh.Token, stray.Token} {
+		_, ok := globals.SessionManager.GetSession(token)
+		test.Equal(t, ok, false)
+	}
+}
@@ -0,0 +1,51 @@
+package websocket
+
+import (
+	"Rivall-Backend/util/asyncapi"
+	"Rivall-Backend/util/openapi"
+)
+
+const CHANNEL = "/api/v1/ws/connect/{user_id}"
+
+var info = openapi.Info{
+	Title:   "Rivall websocket",
+	Version: "1",
+	Description: "Events exchanged over the websocket. Every message is an event envelope whose `type` " +
+		"decides the shape of its `payload`. Failed events are answered with an `error` event.",
+}
+
+// AsyncAPI describes every event of the registry, the messages clients send are published on
+// the channel and the ones the server sends are subscribed to
+func AsyncAPI() asyncapi.Document {
+	g := asyncapi.New(info, CHANNEL, asyncapi.Channel{
+		Description: "Opened with an access token as the `Authorization` query parameter.",
+		Parameters: map[string]asyncapi.Parameter{
+			"user_id": {Description: "The user of the access token", Schema: &openapi.Schema{Type: "string"}},
+		},
+	})
+
+	for _, def := range Events() {
+		message := asyncapi.Message{Name: def.Type, Summary: def.Summary, Payload: envelope(def.Type, g.Schema(def.Payload))}
+		if def.Direction == DIRECTION_INBOUND {
+			g.Publish(message)
+		} else {
+			g.Subscribe(message)
+		}
+	}
+	return g.Document()
+}
+
+// envelope is the schema of an Event of the given type
+func envelope(eventType string, payload *openapi.Schema) *openapi.Schema {
+	return &openapi.Schema{
+		Type:     "object",
+		Required: []string{"type", "payload"},
+		Properties: map[string]*openapi.Schema{
+			"type":              {Type: "string", Enum: []string{eventType}},
+			"payload":           payload,
+			"group_id":          {Type: "string", Description: "The group the event is about"},
+			"direct_message_id": {Type: "string", Description: "The direct message the event is about"},
+			"user_id":           {Type: "string", Description: "The user who caused the event, set by the server"},
+		},
+	}
+}
@@ -0,0 +1,55 @@
+package websocket_test
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+
+	"Rivall-Backend/api/websocket"
+	db "Rivall-Backend/db"
+	"Rivall-Backend/util/message_types"
+	"Rivall-Backend/util/test"
+)
+
+func TestChallengeCardCountsTemplateUse(t *testing.T) {
+	m := setupManager(t)
+	sam := createUser(t, "sam@example.com")
+	groupID, err := db.CreateGroup("Climbers", sam)
+	test.NoError(t, err)
+	otherID, err := db.CreateGroup("Runners", sam)
+	test.NoError(t, err)
+
+	challenge := db.Challenge{Title: "100 push-ups", MetricType: db.MetricTypeCount, Goal: 100, DurationDays: 30}
+	templateID, err := db.CreateChallengeTemplate(db.NewChallengeTemplate(challenge, "", ""))
+	test.NoError(t, err)
+
+	client := websocket.NewClient(nil, m, sam, "")
+	propose := func(groupID string, templateID string) error {
+		payload := fmt.Sprintf(`{"template_id":%q,"title":"100 push-ups","metric_type":"count","goal":100,"duration_days":30}`, templateID)
+		return websocket.SendGroupMessageHandler(websocket.SendGroupMessageEvent{
+			MessageType: message_types.ChallengeCard,
+			Payload:     json.RawMessage(payload),
+		}, websocket.Event{GroupID: groupID, UserID: sam}, client)
+	}
+	timesUsed := func() int {
+		template, err := db.ReadChallengeTemplateById(templateID)
+		test.NoError(t, err)
+		return template.TimesUsed
+	}
+
+	test.NoError(t, propose(groupID, templateID))
+	test.Equal(t, timesUsed(), 1)
+
+	// proposing it again in the same group doesn't make it more popular
+	test.NoError(t, propose(groupID, templateID))
+	test.Equal(t, timesUsed(), 1)
+
+	test.NoError(t, propose(otherID, templateID))
+	test.Equal(t, timesUsed(), 2)
+
+	err = propose(groupID, "000000000000000000000000")
+	if !errors.Is(err, db.ErrChallengeTemplateNotFound) {
+		t.Fatalf(`Expected:"%v", Got:"%v"`, db.ErrChallengeTemplateNotFound, err)
+	}
+}
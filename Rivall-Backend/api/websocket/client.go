@@ -17,6 +17,8 @@ type Client struct {
 	Egress     chan Event
 	chatroom   string
 	userID     string
+	// sessionID is the device session the connection was opened with
+	sessionID string
 }
 
 var (
@@ -28,12 +30,13 @@ var (
 	pingInterval = (pongWait * 9) / 10
 )
 
-func NewClient(conn *websocket.Conn, manager *Manager, userID string) *Client {
+func NewClient(conn *websocket.Conn, manager *Manager, userID string, sessionID string) *Client {
 	return &Client{
 		connection: conn,
 		manager:    manager,
 		Egress:     make(chan Event),
 		userID:     userID,
+		sessionID:  sessionID,
 	}
 }
 
@@ -71,12 +74,12 @@ func (c *Client) readMessages() {
 		// Marshal incoming data into a Event struct
 		var request Event
 		if err := json.Unmarshal(payload, &request); err != nil {
-			log.Err(err).Msg("error unmarshaling message")
-			// break // Breaking the connection here might be harsh xD
+			c.sendError("", err)
+			continue
 		}
-		// Route the Event
+		// Route the Event, failures are sent back as an error event
 		if err := c.manager.routeEvent(request, c); err != nil {
-			log.Err(err).Msg("error routing event")
+			c.sendError(request.Type, err)
 		}
 	}
 }
@@ -0,0 +1,86 @@
+package websocket
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+
+	db "Rivall-Backend/db"
+	"Rivall-Backend/util/api_error"
+	"Rivall-Backend/util/message_types"
+	"Rivall-Backend/util/validator"
+
+	"github.com/rs/xid"
+	"github.com/rs/zerolog/log"
+)
+
+// ErrorEvent is the payload of an error event, it has the same fields as a REST error
+type ErrorEvent struct {
+	api_error.Error
+	// EventType is the type of the event that failed
+	EventType string `json:"event_type"`
+}
+
+// errorCodes gives the errors of event handlers their code, the rest are internal errors
+var errorCodes = []struct {
+	err  error
+	code string
+}{
+	{ErrEventNotSupported, api_error.UNSUPPORTED_EVENT},
+	{ErrUserDoesNotExist, api_error.USER_NOT_FOUND},
+	{ErrGroupDoesNotExist, api_error.GROUP_NOT_FOUND},
+	{ErrNotGroupMember, api_error.NOT_GROUP_MEMBER},
+	{ErrNotInDirectMessage, api_error.FORBIDDEN},
+	{ErrGroupPermission, api_error.FORBIDDEN},
+	{ErrGroupRequestsNotAllowed, api_error.FORBIDDEN},
+	{db.ErrEmailNotVerified, api_error.EMAIL_NOT_VERIFIED},
+	{ErrDirectMessageDoesNotExist, api_error.NOT_FOUND},
+	{ErrNoPendingGroupRequest, api_error.NOT_FOUND},
+	{ErrChallengeDoesNotExist, api_error.NOT_FOUND},
+	{ErrCardDoesNotExist, api_error.NOT_FOUND},
+	{db.ErrChallengeTemplateNotFound, api_error.NOT_FOUND},
+	{ErrAlreadyGroupMember, api_error.CONFLICT},
+	{ErrCannotInviteSelf, api_error.BAD_REQUEST},
+	{ErrChallengeNotInGroup, api_error.BAD_REQUEST},
+	{ErrNotChallengeCard, api_error.BAD_REQUEST},
+	{message_types.ErrUnknownMessageType, api_error.BAD_REQUEST},
+	{message_types.ErrNotClientSendable, api_error.BAD_REQUEST},
+	{message_types.ErrInvalidPayload, api_error.BAD_REQUEST},
+}
+
+// toAPIError describes a failed event the way a REST error would be
+func toAPIError(err error) api_error.Error {
+	if fields := validator.ToFieldErrors(err); fields != nil {
+		return api_error.Error{Code: api_error.VALIDATION_FAILED, Message: "The event has invalid fields.", Fields: fields}
+	}
+
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &syntaxErr) || errors.As(err, &typeErr) {
+		return api_error.Error{Code: api_error.INVALID_JSON, Message: "Failed to decode event, invalid JSON."}
+	}
+
+	for _, known := range errorCodes {
+		if errors.Is(err, known.err) {
+			message := known.err.Error()
+			return api_error.Error{Code: known.code, Message: strings.ToUpper(message[:1]) + message[1:] + "."}
+		}
+	}
+	return api_error.Error{Code: api_error.INTERNAL, Message: "Failed to handle event."}
+}
+
+// sendError tells the client an event failed, the request ID is logged with the error
+func (c *Client) sendError(eventType string, err error) {
+	requestID := xid.New().String()
+	log.Error().Err(err).Str("request_id", requestID).Msgf("error handling %s event", eventType)
+
+	payload := ErrorEvent{Error: toAPIError(err), EventType: eventType}
+	payload.RequestID = requestID
+	event, err := NewEvent(EventError, payload)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to marshal error event")
+		return
+	}
+	event.UserID = c.userID
+	c.Send(event)
+}
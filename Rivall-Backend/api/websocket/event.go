@@ -17,7 +17,7 @@ type Event struct {
 }
 
 // EventHandler is a function signature that is used to affect messages on the socket and triggered
-// depending on the type
+// depending on the type, the registry wraps typed handlers into one
 type EventHandler func(event Event, c *Client) error
 
 const (
@@ -28,7 +28,7 @@ const (
 	EventRejectGroupRequest = "reject_group_request"
 	EventSendGroupMessage   = "send_group_message"
 
-	// Don't forget to add new action events to the setupEventHandlers func in manager.go
+	// Don't forget to add new events and their payloads to the registry in registry.go
 
 	// Listen Events
 	EventNewMessage           = "new_message"
@@ -36,4 +36,11 @@ const (
 	EventGroupRequestAccepted = "group_request_accepted"
 	EventGroupRequestRejected = "group_request_rejected"
 	EventNewGroupMessage      = "new_group_message"
+	EventMemberJoined         = "member_joined"
+	EventMemberLeft           = "member_left"
+	EventRoleChanged          = "role_changed"
+	EventNewJoinRequest       = "new_join_request"
+	EventJoinRequestAnswered  = "join_request_answered"
+	// EventError answers an action event that failed, its payload is an ErrorEvent
+	EventError = "error"
 )
@@ -0,0 +1,162 @@
+package websocket
+
+import (
+	"errors"
+	"time"
+
+	db "Rivall-Backend/db"
+
+	"github.com/rs/zerolog/log"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+var (
+	ErrAlreadyGroupMember   = errors.New("user is already in the group")
+	ErrNoPendingJoinRequest = errors.New("user has not asked to join the group")
+)
+
+type NewJoinRequestEvent struct {
+	GroupID  string `json:"group_id"`
+	UserID   string `json:"user_id"`
+	InviteID string `json:"invite_id"`
+}
+
+type JoinRequestAnsweredEvent struct {
+	GroupID  string `json:"group_id"`
+	UserID   string `json:"user_id"`
+	Approved bool   `json:"approved"`
+	ActorID  string `json:"actor_id"`
+}
+
+func groupManagerIDs(group db.Group) []bson.ObjectID {
+	return append([]bson.ObjectID{group.AdminID}, group.AdminIDs...)
+}
+
+func sendEvent(m *Manager, userID string, eventType string, groupID string, actorID string, payload any) error {
+	outgoingEvent, err := NewEvent(eventType, payload)
+	if err != nil {
+		log.Error().Err(err).Msgf("failed to marshal %s event", eventType)
+		return err
+	}
+	outgoingEvent.GroupID = groupID
+	outgoingEvent.UserID = actorID
+
+	m.SendToUser(userID, outgoingEvent)
+	return nil
+}
+
+// JoinGroupWithInvite joins userID to the group behind an invite code. Invites that require
+// approval queue the user for the group admins instead, reported by joined being false.
+func JoinGroupWithInvite(m *Manager, userID string, code string) (invite db.GroupInvite, joined bool, err error) {
+	if !db.ReadByUserId(userID).EmailVerified {
+		return invite, false, db.ErrEmailNotVerified
+	}
+
+	invite, err = db.ReadGroupInviteByCode(code)
+	if err != nil {
+		return invite, false, err
+	}
+	if !invite.Usable(time.Now()) {
+		return invite, false, db.ErrInviteNotUsable
+	}
+
+	groupID := invite.GroupID.Hex()
+	group := db.ReadByGroupId(groupID)
+	if group.ID == bson.NilObjectID {
+		return invite, false, ErrGroupDoesNotExist
+	}
+	if group.Role(userID) != "" {
+		return invite, false, ErrAlreadyGroupMember
+	}
+	for _, request := range group.JoinRequests {
+		if request.UserID.Hex() == userID {
+			return invite, false, db.ErrJoinRequestFound
+		}
+	}
+
+	// a request only counts as a use of the invite once it is approved
+	if invite.RequiresApproval {
+		if err := db.AddGroupJoinRequest(groupID, userID, invite.ID.Hex()); err != nil {
+			return invite, false, err
+		}
+
+		payload := NewJoinRequestEvent{GroupID: groupID, UserID: userID, InviteID: invite.ID.Hex()}
+		for _, managerID := range groupManagerIDs(group) {
+			if err := sendEvent(m, managerID.Hex(), EventNewJoinRequest, groupID, userID, payload); err != nil {
+				return invite, false, err
+			}
+		}
+		return invite, false, nil
+	}
+
+	// count the use before joining so a used up invite can't be raced past its limit
+	invite, err = db.UseGroupInvite(code)
+	if err != nil {
+		return invite, false, err
+	}
+
+	if err := db.AddUserToGroup(groupID, userID); err != nil {
+		log.Error().Err(err).Msg("failed to join group with invite")
+		return invite, false, err
+	}
+	return invite, true, announceMemberJoined(m, groupID, userID)
+}
+
+// AnswerJoinRequest approves or rejects the join request targetID made through an invite.
+// Approving counts a use of the invite, failing if it was used up or revoked meanwhile.
+func AnswerJoinRequest(m *Manager, actorID string, groupID string, targetID string, approve bool) error {
+	group, actorRole, err := readGroupMember(groupID, actorID)
+	if err != nil {
+		return err
+	}
+	if !db.CanManageGroup(actorRole) {
+		return ErrGroupPermission
+	}
+
+	var request *db.GroupJoinRequest
+	for i := range group.JoinRequests {
+		if group.JoinRequests[i].UserID.Hex() == targetID {
+			request = &group.JoinRequests[i]
+		}
+	}
+	if request == nil {
+		return ErrNoPendingJoinRequest
+	}
+
+	if approve {
+		if _, err := db.UseGroupInviteById(request.InviteID.Hex()); err != nil {
+			return err
+		}
+	}
+
+	removed, err := db.RemoveGroupJoinRequest(groupID, targetID)
+	if err == nil && !removed {
+		// another admin answered first
+		err = ErrNoPendingJoinRequest
+	}
+	if err != nil {
+		if approve {
+			if err := db.ReleaseGroupInviteUse(request.InviteID.Hex()); err != nil {
+				log.Error().Err(err).Msg("failed to release invite use")
+			}
+		}
+		return err
+	}
+
+	if approve {
+		if err := db.AddUserToGroup(groupID, targetID); err != nil {
+			log.Error().Err(err).Msg("failed to add approved user to group")
+			return err
+		}
+		if err := announceMemberJoined(m, groupID, targetID); err != nil {
+			return err
+		}
+	}
+
+	return sendEvent(m, targetID, EventJoinRequestAnswered, groupID, actorID, JoinRequestAnsweredEvent{
+		GroupID:  groupID,
+		UserID:   targetID,
+		Approved: approve,
+		ActorID:  actorID,
+	})
+}
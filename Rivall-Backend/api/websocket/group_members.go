@@ -0,0 +1,250 @@
+package websocket
+
+import (
+	"errors"
+
+	db "Rivall-Backend/db"
+
+	"github.com/rs/zerolog/log"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+var (
+	ErrNotGroupMember    = errors.New("user is not in the group")
+	ErrGroupPermission   = errors.New("user does not have permission for this group action")
+	ErrInvalidGroupRole  = errors.New("invalid group role")
+	ErrUseLeaveGroup     = errors.New("members leave a group instead of removing themselves")
+	ErrAlreadyGroupOwner = errors.New("user already owns the group")
+)
+
+const (
+	MemberLeftReasonLeft    = "left"
+	MemberLeftReasonKicked  = "kicked"
+	MemberLeftReasonDeleted = "deleted"
+)
+
+type MemberJoinedEvent struct {
+	GroupID string `json:"group_id"`
+	UserID  string `json:"user_id"`
+	Role    string `json:"role"`
+}
+
+type MemberLeftEvent struct {
+	GroupID string `json:"group_id"`
+	UserID  string `json:"user_id"`
+	Reason  string `json:"reason"`
+	ActorID string `json:"actor_id"`
+}
+
+type RoleChangedEvent struct {
+	GroupID      string `json:"group_id"`
+	UserID       string `json:"user_id"`
+	Role         string `json:"role"`
+	PreviousRole string `json:"previous_role"`
+	ActorID      string `json:"actor_id"`
+}
+
+// BroadcastToGroup pushes an event to every listed user that is online
+func (m *Manager) BroadcastToGroup(memberIDs []bson.ObjectID, event Event) {
+	for _, memberID := range memberIDs {
+		m.SendToUser(memberID.Hex(), event)
+	}
+}
+
+func broadcastGroupEvent(m *Manager, group db.Group, actorID string, eventType string, payload any) error {
+	outgoingEvent, err := NewEvent(eventType, payload)
+	if err != nil {
+		log.Error().Err(err).Msgf("failed to marshal %s event", eventType)
+		return err
+	}
+	outgoingEvent.GroupID = group.ID.Hex()
+	outgoingEvent.UserID = actorID
+
+	m.BroadcastToGroup(group.GroupMembers, outgoingEvent)
+	return nil
+}
+
+func readGroupMember(groupID string, userID string) (db.Group, string, error) {
+	group := db.ReadByGroupId(groupID)
+	if group.ID == bson.NilObjectID {
+		return group, "", ErrGroupDoesNotExist
+	}
+	role := group.Role(userID)
+	if role == "" {
+		return group, "", ErrNotGroupMember
+	}
+	return group, role, nil
+}
+
+// announceMemberJoined tells the group a member joined, called once they are in the group
+func announceMemberJoined(m *Manager, groupID string, userID string) error {
+	group, role, err := readGroupMember(groupID, userID)
+	if err != nil {
+		return err
+	}
+
+	return broadcastGroupEvent(m, group, userID, EventMemberJoined, MemberJoinedEvent{
+		GroupID: groupID,
+		UserID:  userID,
+		Role:    role,
+	})
+}
+
+// KickGroupMember removes targetID from the group on behalf of actorID
+func KickGroupMember(m *Manager, actorID string, groupID string, targetID string) error {
+	if actorID == targetID {
+		return ErrUseLeaveGroup
+	}
+
+	group, actorRole, err := readGroupMember(groupID, actorID)
+	if err != nil {
+		return err
+	}
+	targetRole := group.Role(targetID)
+	if targetRole == "" {
+		return ErrNotGroupMember
+	}
+	if !db.CanKickGroupMember(actorRole, targetRole) {
+		return ErrGroupPermission
+	}
+
+	if err := db.RemoveUserFromGroup(groupID, targetID); err != nil {
+		log.Error().Err(err).Msg("failed to remove group member")
+		return err
+	}
+
+	// the kicked member is still in group.GroupMembers so they hear about it too
+	return broadcastGroupEvent(m, group, actorID, EventMemberLeft, MemberLeftEvent{
+		GroupID: groupID,
+		UserID:  targetID,
+		Reason:  MemberLeftReasonKicked,
+		ActorID: actorID,
+	})
+}
+
+// LeaveGroup removes userID from the group. When the owner leaves ownership passes to the
+// longest serving admin, or member, and an empty group is deleted.
+func LeaveGroup(m *Manager, userID string, groupID string) error {
+	return leaveGroup(m, userID, groupID, MemberLeftReasonLeft)
+}
+
+func leaveGroup(m *Manager, userID string, groupID string, reason string) error {
+	group, role, err := readGroupMember(groupID, userID)
+	if err != nil {
+		return err
+	}
+
+	if role == db.GROUP_ROLE_OWNER {
+		nextOwner, ok := db.NextGroupOwner(group, userID)
+		if !ok {
+			log.Info().Msgf("Last member left group %s, deleting it", groupID)
+			return db.DeleteGroup(groupID)
+		}
+
+		previousRole := group.Role(nextOwner.Hex())
+		if err := db.TransferGroupOwnership(groupID, userID, nextOwner.Hex()); err != nil {
+			log.Error().Err(err).Msg("failed to pass on group ownership")
+			return err
+		}
+
+		err = broadcastGroupEvent(m, group, userID, EventRoleChanged, RoleChangedEvent{
+			GroupID:      groupID,
+			UserID:       nextOwner.Hex(),
+			Role:         db.GROUP_ROLE_OWNER,
+			PreviousRole: previousRole,
+			ActorID:      userID,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	if err := db.RemoveUserFromGroup(groupID, userID); err != nil {
+		log.Error().Err(err).Msg("failed to leave group")
+		return err
+	}
+
+	return broadcastGroupEvent(m, group, userID, EventMemberLeft, MemberLeftEvent{
+		GroupID: groupID,
+		UserID:  userID,
+		Reason:  reason,
+		ActorID: userID,
+	})
+}
+
+// ChangeGroupRole promotes targetID to admin or demotes them to member on behalf of actorID
+func ChangeGroupRole(m *Manager, actorID string, groupID string, targetID string, role string) error {
+	if role != db.GROUP_ROLE_ADMIN && role != db.GROUP_ROLE_MEMBER {
+		return ErrInvalidGroupRole
+	}
+
+	group, actorRole, err := readGroupMember(groupID, actorID)
+	if err != nil {
+		return err
+	}
+	targetRole := group.Role(targetID)
+	if targetRole == "" {
+		return ErrNotGroupMember
+	}
+	if !db.CanChangeGroupRole(actorRole, targetRole, role) {
+		return ErrGroupPermission
+	}
+	if targetRole == role {
+		return nil
+	}
+
+	if err := db.SetGroupMemberRole(groupID, targetID, role); err != nil {
+		log.Error().Err(err).Msg("failed to change group role")
+		return err
+	}
+
+	return broadcastGroupEvent(m, group, actorID, EventRoleChanged, RoleChangedEvent{
+		GroupID:      groupID,
+		UserID:       targetID,
+		Role:         role,
+		PreviousRole: targetRole,
+		ActorID:      actorID,
+	})
+}
+
+// TransferGroupOwnership makes targetID the owner, the current owner becomes an admin
+func TransferGroupOwnership(m *Manager, actorID string, groupID string, targetID string) error {
+	group, actorRole, err := readGroupMember(groupID, actorID)
+	if err != nil {
+		return err
+	}
+	if actorRole != db.GROUP_ROLE_OWNER {
+		return ErrGroupPermission
+	}
+	if actorID == targetID {
+		return ErrAlreadyGroupOwner
+	}
+	targetRole := group.Role(targetID)
+	if targetRole == "" {
+		return ErrNotGroupMember
+	}
+
+	if err := db.TransferGroupOwnership(groupID, actorID, targetID); err != nil {
+		log.Error().Err(err).Msg("failed to transfer group ownership")
+		return err
+	}
+
+	err = broadcastGroupEvent(m, group, actorID, EventRoleChanged, RoleChangedEvent{
+		GroupID:      groupID,
+		UserID:       targetID,
+		Role:         db.GROUP_ROLE_OWNER,
+		PreviousRole: targetRole,
+		ActorID:      actorID,
+	})
+	if err != nil {
+		return err
+	}
+
+	return broadcastGroupEvent(m, group, actorID, EventRoleChanged, RoleChangedEvent{
+		GroupID:      groupID,
+		UserID:       actorID,
+		Role:         db.GROUP_ROLE_ADMIN,
+		PreviousRole: db.GROUP_ROLE_OWNER,
+		ActorID:      actorID,
+	})
+}
@@ -1,18 +1,20 @@
 package websocket
 
 import (
-	"encoding/json"
+	"errors"
 	"time"
 
 	db "Rivall-Backend/db"
+	"Rivall-Backend/globals"
 
 	"github.com/rs/zerolog/log"
+	"go.mongodb.org/mongo-driver/v2/bson"
 )
 
 type CreateGroupPayload struct {
-	GroupName string   `json:"group_name"`
-	UserIDs   []string `json:"user_ids"`
-	Message   string   `json:"message"`
+	GroupName string   `json:"group_name" form:"required,max=50"`
+	UserIDs   []string `json:"user_ids"   form:"max=50,unique,dive,len=24,hexadecimal"`
+	Message   string   `json:"message"    form:"max=280"`
 }
 
 type JoinGroupRequest struct {
@@ -26,72 +28,99 @@ type JoinGroupRequest struct {
 	Status        int8      `json:"status"`
 }
 
-func CreateGroupHandler(event Event, c *Client) error {
-	// Marshal Payload into wanted format
-	var chatevent CreateGroupPayload
-	if err := json.Unmarshal(event.Payload, &chatevent); err != nil {
-		log.Error().Err(err).Msg("bad payload in request")
-		return err
-	}
+var (
+	ErrCannotInviteSelf = errors.New("users can not send a group request to themselves")
+	ErrUserDoesNotExist = errors.New("user does not exist")
+	// ErrGroupRequestsNotAllowed is returned when a user's settings don't let the sender request them
+	ErrGroupRequestsNotAllowed = errors.New("user does not accept group requests from the sender")
+)
 
+func CreateGroupHandler(chatevent CreateGroupPayload, event Event, c *Client) error {
 	// Get Admin UserID, Admin is the user creating the group
 	AdminUserID := event.UserID
 
-	// Confirm Users are legitimate
+	_, _, err := CreateGroupWithRequests(c.Manager(), AdminUserID, chatevent)
+	return err
+}
+
+// CreateGroupWithRequests creates a group administered by adminUserID and sends a join request to
+// every requested user, pushing a new_group_request event to the ones that are online.
+// Both the create_group event and the REST groups API create groups through here.
+func CreateGroupWithRequests(m *Manager, adminUserID string, chatevent CreateGroupPayload) (string, []JoinGroupRequest, error) {
+	if err := globals.Validator.Struct(chatevent); err != nil {
+		log.Error().Err(err).Msg("invalid create group payload")
+		return "", nil, err
+	}
+
+	// Only users who verified their email can send group requests
+	admin := db.ReadByUserId(adminUserID)
+	if !admin.EmailVerified {
+		return "", nil, db.ErrEmailNotVerified
+	}
+
+	// Confirm Users are legitimate and accept requests from the admin
+	recipients := make(map[string]db.User)
 	for _, userID := range chatevent.UserIDs {
-		if exists := db.UserExists(userID); !exists {
+		if userID == adminUserID {
+			return "", nil, ErrCannotInviteSelf
+		}
+		recipient := db.ReadByUserId(userID)
+		if recipient.ID == bson.NilObjectID {
 			log.Error().Msg("user does not exist")
-			return nil
+			return "", nil, ErrUserDoesNotExist
+		}
+		if !recipient.Allows(recipient.CurrentSettings().GroupRequests, admin) {
+			return "", nil, ErrGroupRequestsNotAllowed
 		}
+		recipients[userID] = recipient
 	}
 
 	// Create New Group in Database
-	groupID, err := db.CreateGroup(chatevent.GroupName, AdminUserID)
+	groupID, err := db.CreateGroup(chatevent.GroupName, adminUserID)
 	if err != nil {
 		log.Error().Err(err).Msg("failed to create group")
-		return err
+		return "", nil, err
 	}
 
 	log.Info().Msgf(`Created group with ID: %v`, groupID)
 
 	// Add a Request to all users requested to be added to the group
+	requests := []JoinGroupRequest{}
 	for _, UserID := range chatevent.UserIDs {
-		id, err := db.CreateGroupRequest(AdminUserID, UserID, groupID, chatevent.GroupName, chatevent.Message)
+		id, err := db.CreateGroupRequest(adminUserID, UserID, groupID, chatevent.GroupName, chatevent.Message)
 		if err != nil {
 			log.Error().Err(err).Msg("failed to send group request")
-			return err
+			return groupID, requests, err
 		}
 
 		// Prepare an Outgoing Message to others
 		var broadMessage JoinGroupRequest
 		broadMessage.ID = id
-		broadMessage.SendUserID = AdminUserID
+		broadMessage.SendUserID = adminUserID
 		broadMessage.RecieveUserID = UserID
 		broadMessage.GroupID = groupID
 		broadMessage.GroupName = chatevent.GroupName
 		broadMessage.Message = chatevent.Message
 		broadMessage.Timestamp = time.Now()
 		broadMessage.Status = 0
+		requests = append(requests, broadMessage)
 
-		data, err := json.Marshal(broadMessage)
+		// Place payload into an Event
+		outgoingEvent, err := NewEvent(EventNewGroupRequest, broadMessage)
 		if err != nil {
 			log.Error().Err(err).Msg("failed to marshal broadcast message")
-			return err
+			return groupID, requests, err
 		}
-
-		// Place payload into an Event
-		var outgoingEvent Event
-		outgoingEvent.Payload = data
-		outgoingEvent.Type = EventNewGroupRequest
 		outgoingEvent.GroupID = groupID
-		outgoingEvent.UserID = AdminUserID
-		outgoingEvent.DirectMessageID = event.DirectMessageID
+		outgoingEvent.UserID = adminUserID
 
-		// Broadcast to all other Clients in the group if the users are online
-		if _, ok := c.Manager().Clients()[UserID]; !ok {
-			continue
+		// Send to the user if they are online, otherwise email them if they want to hear about it
+		if !m.SendToUser(UserID, outgoingEvent) {
+			recipient := recipients[UserID]
+			m.NotifyOffline(recipient, recipient.CurrentSettings().Notifications.GroupRequests,
+				"You were asked to join "+chatevent.GroupName,
+				admin.FirstName+" "+admin.LastName+" asked you to join the group "+chatevent.GroupName+" on Rivall.")
 		}
-		c.Manager().Clients()[UserID].Egress <- outgoingEvent
 	}
-	return nil
+	return groupID, requests, nil
 }
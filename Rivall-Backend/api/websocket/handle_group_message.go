@@ -2,43 +2,64 @@ package websocket
 
 import (
 	"encoding/json"
+	"errors"
 	"time"
 
 	"github.com/rs/zerolog/log"
 	"go.mongodb.org/mongo-driver/v2/bson"
 
 	"Rivall-Backend/db"
+	"Rivall-Backend/util/message_types"
+)
+
+var (
+	ErrCardDoesNotExist      = errors.New("voted on card does not exist in this group")
+	ErrNotChallengeCard      = errors.New("votes can only be cast on challenge cards")
+	ErrChallengeDoesNotExist = errors.New("challenge does not exist")
+	ErrChallengeNotInGroup   = errors.New("challenge does not belong to this group")
 )
 
 type SendGroupMessageEvent struct {
-	MessageData string `json:"message_data"`
-	ReceiverID  string `json:"receiver_id"`
-	Timestamp   string `json:"timestamp"`
-	MessageType string `json:"message_type"`
+	MessageData string          `json:"message_data"`
+	ReceiverID  string          `json:"receiver_id"`
+	Timestamp   string          `json:"timestamp"`
+	MessageType string          `json:"message_type"`
+	Payload     json.RawMessage `json:"payload,omitempty"`
 }
 
 type NewGroupMessageEvent struct {
 	SendGroupMessageEvent
+	ID     string   `json:"_id"`
 	Sent   string   `json:"sent"`
 	SeenBy []string `json:"seen_by"`
 }
 
-func SendGroupMessageHandler(event Event, c *Client) error {
-	// Marshal Payload into wanted format
-	var chatevent SendGroupMessageEvent
-	if err := json.Unmarshal(event.Payload, &chatevent); err != nil {
-		log.Error().Err(err).Msg("bad payload in request")
-		return nil
-	}
-
+func SendGroupMessageHandler(chatevent SendGroupMessageEvent, event Event, c *Client) error {
 	// Validate Event
 	if exists := db.GroupExists(event.GroupID); !exists {
 		log.Error().Msg("group does not exist")
-		return nil
+		return ErrGroupDoesNotExist
 	}
 	if exists := db.UserInGroup(event.GroupID, event.UserID); !exists {
 		log.Error().Msgf("Sender user not in group: %s", event.UserID)
-		return nil
+		return ErrNotGroupMember
+	}
+
+	// Validate the structured payload against the message type
+	chatevent.MessageType = message_types.Normalize(chatevent.MessageType)
+	payload, err := message_types.DecodeClientPayload(chatevent.MessageType, chatevent.Payload)
+	if err != nil {
+		log.Error().Err(err).Msgf("rejected %s message", chatevent.MessageType)
+		return err
+	}
+	if err := validateGroupMessagePayload(event.GroupID, payload); err != nil {
+		log.Error().Err(err).Msgf("rejected %s message", chatevent.MessageType)
+		return err
+	}
+	payloadDoc, err := message_types.ToDocument(payload)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to convert message payload")
+		return err
 	}
 
 	// Save message to Group in Database
@@ -54,31 +75,48 @@ func SendGroupMessageHandler(event Event, c *Client) error {
 		MessageData: chatevent.MessageData,
 		Timestamp:   chatevent.Timestamp,
 		MessageType: chatevent.MessageType,
+		Payload:     payloadDoc,
 		SeenBy:      []bson.ObjectID{bsonUserID},
 	}
+	// a group taking up a template counts once towards how popular it is
+	usedTemplateID := ""
+	if card, ok := payload.(*message_types.ChallengeCardPayload); ok && card.TemplateID != "" {
+		if !db.GroupUsedChallengeTemplate(event.GroupID, card.TemplateID) {
+			usedTemplateID = card.TemplateID
+		}
+	}
 	if err := db.InsertGroupMessage(event.GroupID, message); err != nil {
 		log.Error().Err(err).Msg("failed to insert message")
 		return err
 	}
+	if usedTemplateID != "" {
+		if err := db.CountChallengeTemplateUse(usedTemplateID); err != nil {
+			log.Error().Err(err).Msg("failed to count challenge template use")
+		}
+	}
 
 	// Prepare an Outgoing Message to others
 	var broadMessage NewGroupMessageEvent
 	broadMessage.SendGroupMessageEvent = chatevent
+	broadMessage.Payload = nil
+	if payload != nil {
+		broadMessage.Payload, err = json.Marshal(payload)
+		if err != nil {
+			log.Error().Err(err).Msg("failed to marshal message payload")
+			return err
+		}
+	}
+	broadMessage.ID = message.ID.Hex()
 	broadMessage.Sent = time.Now().Format(time.RFC3339)
 	broadMessage.SeenBy = []string{event.UserID}
-	broadMessageData, err := json.Marshal(broadMessage)
-
-	var outgoingEvent Event
-	outgoingEvent.Payload = broadMessageData
-	outgoingEvent.Type = EventNewGroupMessage
-	outgoingEvent.GroupID = event.GroupID
-	outgoingEvent.UserID = event.UserID
-	outgoingEvent.DirectMessageID = event.DirectMessageID
-
+	outgoingEvent, err := NewEvent(EventNewGroupMessage, broadMessage)
 	if err != nil {
 		log.Error().Err(err).Msg("failed to marshal message")
 		return err
 	}
+	outgoingEvent.GroupID = event.GroupID
+	outgoingEvent.UserID = event.UserID
+	outgoingEvent.DirectMessageID = event.DirectMessageID
 
 	// Get all Group Members, Send them the message
 	groupMembers, err := db.GetGroupMembers(event.GroupID)
@@ -97,3 +135,40 @@ func SendGroupMessageHandler(event Event, c *Client) error {
 	}
 	return nil
 }
+
+func validateGroupMessagePayload(groupID string, payload any) error {
+	// Check the references inside a payload point at this group
+	switch p := payload.(type) {
+	case *message_types.ChallengeCardPayload:
+		if p.TemplateID != "" {
+			if _, err := db.ReadChallengeTemplateById(p.TemplateID); err != nil {
+				return db.ErrChallengeTemplateNotFound
+			}
+		}
+		if p.ChallengeID != "" {
+			return challengeInGroup(p.ChallengeID, groupID)
+		}
+	case *message_types.VotePayload:
+		card, err := db.ReadGroupMessage(groupID, p.CardMessageID)
+		if err != nil {
+			return ErrCardDoesNotExist
+		}
+		if card.MessageType != message_types.ChallengeCard {
+			return ErrNotChallengeCard
+		}
+	case *message_types.ProgressUpdatePayload:
+		return challengeInGroup(p.ChallengeID, groupID)
+	}
+	return nil
+}
+
+func challengeInGroup(challengeID string, groupID string) error {
+	challenge, err := db.ReadChallengeById(challengeID)
+	if err != nil {
+		return ErrChallengeDoesNotExist
+	}
+	if challenge.GroupID.Hex() != groupID {
+		return ErrChallengeNotInGroup
+	}
+	return nil
+}
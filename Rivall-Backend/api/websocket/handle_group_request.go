@@ -2,139 +2,93 @@ package websocket
 
 import (
 	db "Rivall-Backend/db"
-	"encoding/json"
+	"errors"
 
 	"github.com/rs/zerolog/log"
 )
 
-type AcceptGroupRequestEvent struct {
-	GroupID string `json:"group_id"`
-	UserID  string `json:"user_id"`
+var (
+	ErrGroupDoesNotExist     = errors.New("group does not exist")
+	ErrNoPendingGroupRequest = errors.New("user was not requested to join group")
+)
+
+// AnswerGroupRequestPayload answers a group request. The group is the one of the event, the
+// payload's group is used by clients that leave it out of the event
+type AnswerGroupRequestPayload struct {
+	GroupID string `json:"group_id" form:"omitempty,len=24,hexadecimal"`
 }
 
-type AcceptGroupRequestPayload struct {
+// GroupRequestAnsweredEvent tells the group admin a user answered their request
+type GroupRequestAnsweredEvent struct {
 	GroupID string `json:"group_id"`
 	UserID  string `json:"user_id"`
 }
 
-func AcceptGroupRequestHandler(event Event, c *Client) error {
-	// Marshal Payload into wanted format
-	var chatevent AcceptGroupRequestPayload
-	if err := json.Unmarshal(event.Payload, &chatevent); err != nil {
-		log.Error().Err(err).Msg("bad payload in request")
-		return err
-	}
-
-	// Validate Event
-	if exists := db.GroupExists(event.GroupID); !exists {
-		log.Error().Msg("group does not exist")
-		return nil
-	}
-	if exists := db.UserWasRequestedToJoinGroup(event.GroupID, event.UserID); !exists {
-		log.Error().Msgf("User was not requested to join group: %s", event.UserID)
-		return nil
-	}
-
-	// Accept Group Request in Database
-	if err := db.AcceptGroupRequest(chatevent.GroupID, chatevent.UserID); err != nil {
-		log.Error().Err(err).Msg("failed to accept group request")
-		return err
-	}
-
-	var acceptEvent AcceptGroupRequestEvent
-	acceptEvent.GroupID = chatevent.GroupID
-	acceptEvent.UserID = chatevent.UserID
-
-	data, err := json.Marshal(acceptEvent)
-	if err != nil {
-		log.Error().Err(err).Msg("failed to marshal accept group request event")
-		return err
-	}
-
-	var outgoingEvent Event
-	outgoingEvent.Payload = data
-	outgoingEvent.Type = EventAcceptGroupRequest
-	outgoingEvent.UserID = chatevent.UserID
-	outgoingEvent.GroupID = chatevent.GroupID
-	outgoingEvent.DirectMessageID = ""
-
-	// Send event to admin user
-	AdminID, err := db.GetGroupAdminID(chatevent.GroupID)
-	if err != nil {
-		log.Error().Err(err).Msg("failed to get group admin")
-		return err
-	}
-
-	if _, ok := c.Manager().Clients()[AdminID]; !ok {
-		return nil
-	}
-	c.Manager().Clients()[AdminID].Egress <- outgoingEvent
-	return nil
+func AcceptGroupRequestHandler(payload AnswerGroupRequestPayload, event Event, c *Client) error {
+	// unknown groups and missing requests come back to the client as error events
+	return AnswerGroupRequest(c.Manager(), event.UserID, payload.groupID(event), true)
 }
 
-type RejectGroupRequestEvent struct {
-	GroupID string `json:"group_id"`
-	UserID  string `json:"user_id"`
+func RejectGroupRequestHandler(payload AnswerGroupRequestPayload, event Event, c *Client) error {
+	// unknown groups and missing requests come back to the client as error events
+	return AnswerGroupRequest(c.Manager(), event.UserID, payload.groupID(event), false)
 }
 
-type RejectGroupRequestPayload struct {
-	GroupID string `json:"group_id"`
-	UserID  string `json:"user_id"`
-}
-
-func RejectGroupRequestHandler(event Event, c *Client) error {
-	// Marshal Payload into wanted format
-	var chatevent RejectGroupRequestPayload
-	if err := json.Unmarshal(event.Payload, &chatevent); err != nil {
-		log.Error().Err(err).Msg("bad payload in request")
-		return err
+func (p AnswerGroupRequestPayload) groupID(event Event) string {
+	if event.GroupID != "" {
+		return event.GroupID
 	}
+	return p.GroupID
+}
 
+// AnswerGroupRequest accepts or rejects the pending request userID holds for groupID and
+// lets the group admin know. Both the websocket events and the REST groups API answer requests through here.
+func AnswerGroupRequest(m *Manager, userID string, groupID string, accept bool) error {
 	// Validate Event
-	if exists := db.GroupExists(event.GroupID); !exists {
+	if exists := db.GroupExists(groupID); !exists {
 		log.Error().Msg("group does not exist")
-		return nil
+		return ErrGroupDoesNotExist
 	}
-	if exists := db.UserWasRequestedToJoinGroup(event.GroupID, event.UserID); !exists {
-		log.Error().Msgf("User was not requested to join group: %s", event.UserID)
-		return nil
+	if exists := db.UserWasRequestedToJoinGroup(groupID, userID); !exists {
+		log.Error().Msgf("User was not requested to join group: %s", userID)
+		return ErrNoPendingGroupRequest
 	}
 
-	// Reject Group Request in Database
-	if err := db.RejectGroupRequest(chatevent.GroupID, chatevent.UserID); err != nil {
-		log.Error().Err(err).Msg("failed to reject group request")
-		return err
+	eventType := EventGroupRequestRejected
+	if accept {
+		// Accept Group Request in Database
+		if err := db.AcceptGroupRequest(userID, groupID); err != nil {
+			log.Error().Err(err).Msg("failed to accept group request")
+			return err
+		}
+		eventType = EventGroupRequestAccepted
+	} else {
+		// Reject Group Request in Database
+		if err := db.RejectGroupRequest(userID, groupID); err != nil {
+			log.Error().Err(err).Msg("failed to reject group request")
+			return err
+		}
 	}
 
-	var rejectEvent RejectGroupRequestEvent
-	rejectEvent.GroupID = chatevent.GroupID
-	rejectEvent.UserID = chatevent.UserID
-
-	data, err := json.Marshal(rejectEvent)
+	outgoingEvent, err := NewEvent(eventType, GroupRequestAnsweredEvent{GroupID: groupID, UserID: userID})
 	if err != nil {
-		log.Error().Err(err).Msg("failed to marshal reject group request event")
+		log.Error().Err(err).Msgf("failed to marshal %s event", eventType)
 		return err
 	}
-
-	var outgoingEvent Event
-	outgoingEvent.Payload = data
-	outgoingEvent.Type = EventRejectGroupRequest
-	outgoingEvent.UserID = chatevent.UserID
-	outgoingEvent.GroupID = chatevent.GroupID
-	outgoingEvent.DirectMessageID = ""
+	outgoingEvent.UserID = userID
+	outgoingEvent.GroupID = groupID
 
 	// Send event to admin user
-	AdminID, err := db.GetGroupAdminID(chatevent.GroupID)
+	AdminID, err := db.GetGroupAdminID(groupID)
 	if err != nil {
 		log.Error().Err(err).Msg("failed to get group admin")
 		return err
 	}
 
-	if _, ok := c.Manager().Clients()[AdminID]; !ok {
-		return nil
-	}
-	c.Manager().Clients()[AdminID].Egress <- outgoingEvent
+	m.SendToUser(AdminID, outgoingEvent)
 
+	if accept {
+		return announceMemberJoined(m, groupID, userID)
+	}
 	return nil
-
 }
@@ -1,7 +1,7 @@
 package websocket
 
 import (
-	"encoding/json"
+	"errors"
 	"time"
 
 	"github.com/rs/zerolog/log"
@@ -12,11 +12,16 @@ import (
 
 type SendMessageEvent struct {
 	MessageData string `json:"message_data"`
-	ReceiverID  string `json:"receiver_id"`
+	ReceiverID  string `json:"receiver_id"  form:"required,len=24,hexadecimal"`
 	Timestamp   string `json:"timestamp"`
 	MessageType string `json:"message_type"`
 }
 
+var (
+	ErrDirectMessageDoesNotExist = errors.New("direct message does not exist")
+	ErrNotInDirectMessage        = errors.New("user is not in the direct message")
+)
+
 // NewMessageEvent is returned when responding to send_message
 type NewMessageEvent struct {
 	SendMessageEvent
@@ -24,26 +29,19 @@ type NewMessageEvent struct {
 	SeenBy []string  `json:"seen_by"`
 }
 
-func SendMessageHandler(event Event, c *Client) error {
-	// Marshal Payload into wanted format
-	var chatevent SendMessageEvent
-	if err := json.Unmarshal(event.Payload, &chatevent); err != nil {
-		log.Error().Err(err).Msg("bad payload in request")
-		return err
-	}
-
+func SendMessageHandler(chatevent SendMessageEvent, event Event, c *Client) error {
 	// Validate Event
 	if exists := db.DirectMessageExists(event.DirectMessageID); !exists {
 		log.Error().Msg("direct message does not exist")
-		return nil
+		return ErrDirectMessageDoesNotExist
 	}
 	if exists := db.UserInDirectMessage(event.DirectMessageID, event.UserID); !exists {
 		log.Error().Msgf("Sender user not in direct message: %s", event.UserID)
-		return nil
+		return ErrNotInDirectMessage
 	}
 	if exists := db.UserInDirectMessage(event.DirectMessageID, chatevent.ReceiverID); !exists {
 		log.Error().Msgf("Receiver user not in direct message: %s", chatevent.ReceiverID)
-		return nil
+		return ErrNotInDirectMessage
 	}
 
 	// Save message to Group in Database
@@ -75,16 +73,12 @@ func SendMessageHandler(event Event, c *Client) error {
 	broadMessage.MessageType = chatevent.MessageType
 	broadMessage.SeenBy = []string{event.UserID}
 
-	data, err := json.Marshal(broadMessage)
+	// Place payload into an Event
+	outgoingEvent, err := NewEvent(EventNewMessage, broadMessage)
 	if err != nil {
 		log.Error().Err(err).Msg("failed to marshal broadcast message")
 		return err
 	}
-
-	// Place payload into an Event
-	var outgoingEvent Event
-	outgoingEvent.Payload = data
-	outgoingEvent.Type = EventNewMessage
 	outgoingEvent.GroupID = event.GroupID
 	outgoingEvent.UserID = event.UserID
 	outgoingEvent.DirectMessageID = event.DirectMessageID
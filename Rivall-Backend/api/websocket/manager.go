@@ -12,6 +12,7 @@ import (
 	"github.com/rs/zerolog/log"
 
 	"Rivall-Backend/globals"
+	"Rivall-Backend/util/api_error"
 )
 
 var (
@@ -60,8 +61,6 @@ type Manager struct {
 	// Using a syncMutex here to be able to lock state before editing clients
 	// Could also use Channels to block
 	sync.RWMutex
-	// handlers are functions that are used to handle Events
-	handlers map[string]EventHandler
 }
 
 func (m *Manager) Clients() ClientMap {
@@ -71,25 +70,19 @@ func (m *Manager) Clients() ClientMap {
 func NewManager(ctx context.Context) *Manager {
 	log.Info().Msg("Creating new Websocket Manager")
 	m := &Manager{
-		clients:  make(ClientMap),
-		handlers: make(map[string]EventHandler),
+		clients: make(ClientMap),
 	}
-	m.setupEventHandlers()
 	log.Info().Msg("Websocket Manager Created")
 	return m
 }
 
-func (m *Manager) setupEventHandlers() {
-	m.handlers[EventSendMessage] = SendMessageHandler
-	m.handlers[EventCreateGroup] = CreateGroupHandler
-	m.handlers[EventAcceptGroupRequest] = AcceptGroupRequestHandler
-	m.handlers[EventRejectGroupRequest] = RejectGroupRequestHandler
-	m.handlers[EventSendGroupMessage] = SendGroupMessageHandler
-}
-
 func (m *Manager) routeEvent(event Event, c *Client) error {
-	if handler, ok := m.handlers[event.Type]; ok {
-		if err := handler(event, c); err != nil {
+	// Events always act as the authenticated user of the connection
+	event.UserID = c.userID
+
+	// only events clients send have a handler in the registry
+	if def, ok := registry[event.Type]; ok && def.handler != nil {
+		if err := def.handler(event, c); err != nil {
 			return err
 		}
 		return nil
@@ -105,49 +98,40 @@ func (m *Manager) ServeWS(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	userID := vars["user_id"]
 	if userID == "" {
-		w.WriteHeader(http.StatusUnauthorized)
-		return
-	}
-
-	if userID == "" {
-		w.WriteHeader(http.StatusUnauthorized)
+		api_error.Write(w, r, http.StatusUnauthorized, api_error.UNAUTHORIZED, "User ID must be provided")
 		return
 	}
 
 	tokenString := r.URL.Query().Get("Authorization")
 	if tokenString == "" {
-		w.WriteHeader(http.StatusUnauthorized)
+		api_error.Write(w, r, http.StatusUnauthorized, api_error.UNAUTHORIZED, "Missing Authorization token")
 		return
 	}
 
 	log.Debug().Msgf("Verifying Token: %s", tokenString)
 	claims, ok := globals.SessionManager.ValidateJWTToken(tokenString)
 	if !ok {
-		w.WriteHeader(http.StatusUnauthorized)
-		w.Write([]byte("Invalid token"))
+		api_error.Write(w, r, http.StatusUnauthorized, api_error.INVALID_TOKEN, "Invalid token")
 		return
 	}
 
 	// get user_id from claims
 	userID, ok = claims["user_id"].(string)
 	if !ok {
-		w.WriteHeader(http.StatusUnauthorized)
-		w.Write([]byte("Invalid token: user_id"))
+		api_error.Write(w, r, http.StatusUnauthorized, api_error.INVALID_TOKEN, "Invalid token: user_id")
 		return
 	}
 
 	// check if session exists
 	session, ok := globals.SessionManager.GetSession(tokenString)
 	if !ok {
-		w.WriteHeader(http.StatusUnauthorized)
-		w.Write([]byte("Invalid token: token not found"))
+		api_error.Write(w, r, http.StatusUnauthorized, api_error.INVALID_TOKEN, "Invalid token: token not found")
 		return
 	}
 
 	// check if session is valid
 	if session.TokenExpiresAt.Before(time.Now()) {
-		w.WriteHeader(http.StatusUnauthorized)
-		w.Write([]byte("Token expired"))
+		api_error.Write(w, r, http.StatusUnauthorized, api_error.INVALID_TOKEN, "Token expired")
 		return
 	}
 
@@ -158,7 +142,7 @@ func (m *Manager) ServeWS(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	client := NewClient(conn, m, userID)
+	client := NewClient(conn, m, userID, session.FamilyID)
 	m.addClient(client)
 	log.Debug().Msg("Client Added to Manager")
 
@@ -187,6 +171,19 @@ func (m *Manager) removeClient(client *Client) {
 	}
 }
 
+// SendToUser pushes an event to a user if they are connected, reporting whether they were
+func (m *Manager) SendToUser(userID string, event Event) bool {
+	m.RLock()
+	client, ok := m.clients[userID]
+	m.RUnlock()
+
+	if !ok {
+		return false
+	}
+	client.Egress <- event
+	return true
+}
+
 // remove client by user id
 func (m *Manager) RemoveClientByUserID(userID string) {
 	m.Lock()
@@ -201,4 +198,16 @@ func (m *Manager) RemoveClientByUserID(userID string) {
 	}
 }
 
+// RemoveClientBySessionID closes the user's connection if it was opened with the given device session
+func (m *Manager) RemoveClientBySessionID(userID string, sessionID string) {
+	m.Lock()
+	defer m.Unlock()
+
+	if client, ok := m.clients[userID]; ok && client.sessionID == sessionID {
+		client.connection.Close()
+		delete(m.clients, userID)
+		log.Debug().Msg("Client Removed")
+	}
+}
+
 var WSManager = NewManager(context.Background())
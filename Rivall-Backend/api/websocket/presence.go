@@ -0,0 +1,44 @@
+package websocket
+
+import (
+	db "Rivall-Backend/db"
+	"Rivall-Backend/globals"
+	"Rivall-Backend/util/mailer"
+
+	"github.com/rs/zerolog/log"
+)
+
+// IsOnline reports whether the user has a websocket connection open
+func (m *Manager) IsOnline(userID string) bool {
+	m.RLock()
+	defer m.RUnlock()
+
+	_, ok := m.clients[userID]
+	return ok
+}
+
+// PresenceFor tells viewer whether user is online, ok is false when the user's presence
+// setting hides it from them
+func (m *Manager) PresenceFor(user db.User, viewer db.User) (online bool, ok bool) {
+	if !user.Allows(user.CurrentSettings().Presence, viewer) {
+		return false, false
+	}
+	return m.IsOnline(user.ID.Hex()), true
+}
+
+// NotifyOffline emails a user who is not connected, when they enabled the notification.
+// Users who are online hear about it through their websocket instead.
+func (m *Manager) NotifyOffline(user db.User, enabled bool, title string, body string) {
+	if !enabled || m.IsOnline(user.ID.Hex()) {
+		return
+	}
+
+	err := globals.Mailer.Send(user.Email, mailer.TEMPLATE_NOTIFICATION, mailer.NotificationData{
+		FirstName: user.FirstName,
+		Title:     title,
+		Body:      body,
+	})
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to queue notification")
+	}
+}
@@ -0,0 +1,145 @@
+package websocket
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+
+	"Rivall-Backend/globals"
+
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// DIRECTION_INBOUND events are sent by clients and handled by the server
+	DIRECTION_INBOUND = "inbound"
+	// DIRECTION_OUTBOUND events are sent by the server
+	DIRECTION_OUTBOUND = "outbound"
+)
+
+var (
+	ErrNotOutboundEvent     = errors.New("event type is not sent by the server")
+	ErrEventPayloadMismatch = errors.New("payload does not match the event type")
+)
+
+// EventDefinition describes an event type and the payload it carries
+type EventDefinition struct {
+	Type      string
+	Direction string
+	Summary   string
+	// Payload is a zero value of the payload struct
+	Payload any
+	// handler decodes and handles inbound events
+	handler EventHandler
+}
+
+// inbound defines an event clients send, its payload is decoded into P and validated before
+// handle is called
+func inbound[P any](eventType string, summary string, handle func(payload P, event Event, c *Client) error) EventDefinition {
+	var zero P
+	return EventDefinition{
+		Type:      eventType,
+		Direction: DIRECTION_INBOUND,
+		Summary:   summary,
+		Payload:   zero,
+		handler: func(event Event, c *Client) error {
+			var payload P
+			if err := decodePayload(event.Payload, &payload); err != nil {
+				log.Error().Err(err).Msgf("bad payload in %s event", event.Type)
+				return err
+			}
+			return handle(payload, event, c)
+		},
+	}
+}
+
+// outbound defines an event the server sends with a payload of type P
+func outbound[P any](eventType string, summary string) EventDefinition {
+	var zero P
+	return EventDefinition{Type: eventType, Direction: DIRECTION_OUTBOUND, Summary: summary, Payload: zero}
+}
+
+// registry holds every event of the protocol, new events are added here
+var registry = map[string]EventDefinition{}
+
+func init() {
+	// filled in init, the handlers send events through NewEvent which reads the registry
+	for _, def := range []EventDefinition{
+		// Action Events
+		inbound(EventSendMessage, "Send a direct message", SendMessageHandler),
+		inbound(EventCreateGroup, "Create a group and request users to join it", CreateGroupHandler),
+		inbound(EventAcceptGroupRequest, "Accept a request to join the group of the event", AcceptGroupRequestHandler),
+		inbound(EventRejectGroupRequest, "Reject a request to join the group of the event", RejectGroupRequestHandler),
+		inbound(EventSendGroupMessage, "Send a message to the group of the event", SendGroupMessageHandler),
+
+		// Listen Events
+		outbound[NewMessageEvent](EventNewMessage, "A direct message was sent to the user"),
+		outbound[JoinGroupRequest](EventNewGroupRequest, "The user was requested to join a group"),
+		outbound[GroupRequestAnsweredEvent](EventGroupRequestAccepted, "A user accepted a request to join a group the user administers"),
+		outbound[GroupRequestAnsweredEvent](EventGroupRequestRejected, "A user rejected a request to join a group the user administers"),
+		outbound[NewGroupMessageEvent](EventNewGroupMessage, "A message was sent to a group of the user"),
+		outbound[MemberJoinedEvent](EventMemberJoined, "A member joined a group of the user"),
+		outbound[MemberLeftEvent](EventMemberLeft, "A member left or was removed from a group of the user"),
+		outbound[RoleChangedEvent](EventRoleChanged, "The role of a member changed in a group of the user"),
+		outbound[NewJoinRequestEvent](EventNewJoinRequest, "A user asked to join a group the user manages"),
+		outbound[JoinRequestAnsweredEvent](EventJoinRequestAnswered, "A request of the user to join a group was answered"),
+		outbound[ErrorEvent](EventError, "An event the user sent failed"),
+	} {
+		registry[def.Type] = def
+	}
+}
+
+// Events lists every event of the protocol sorted by type
+func Events() []EventDefinition {
+	events := make([]EventDefinition, 0, len(registry))
+	for _, def := range registry {
+		events = append(events, def)
+	}
+	slices.SortFunc(events, func(a, b EventDefinition) int {
+		return strings.Compare(a.Type, b.Type)
+	})
+	return events
+}
+
+// LookupEvent returns the definition of an event type
+func LookupEvent(eventType string) (EventDefinition, bool) {
+	def, ok := registry[eventType]
+	return def, ok
+}
+
+// NewEvent encodes the payload of an event the server sends, the payload has to be of the
+// type registered for the event
+func NewEvent(eventType string, payload any) (Event, error) {
+	def, ok := registry[eventType]
+	if !ok {
+		return Event{}, fmt.Errorf("%w: %q", ErrEventNotSupported, eventType)
+	}
+	if def.Direction != DIRECTION_OUTBOUND {
+		return Event{}, fmt.Errorf("%w: %q", ErrNotOutboundEvent, eventType)
+	}
+	if reflect.TypeOf(payload) != reflect.TypeOf(def.Payload) {
+		return Event{}, fmt.Errorf("%w: %q takes %T, got %T", ErrEventPayloadMismatch, eventType, def.Payload, payload)
+	}
+
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return Event{}, err
+	}
+	return Event{Type: eventType, Payload: data}, nil
+}
+
+// decodePayload reads an inbound payload into v and validates it, a missing payload decodes
+// as the zero value so that events whose fields are all optional can leave it out
+func decodePayload(payload json.RawMessage, v any) error {
+	trimmed := bytes.TrimSpace(payload)
+	if len(trimmed) != 0 && !bytes.Equal(trimmed, []byte("null")) {
+		if err := json.Unmarshal(trimmed, v); err != nil {
+			return err
+		}
+	}
+	return globals.Validator.Struct(v)
+}
@@ -0,0 +1,103 @@
+package websocket_test
+
+import (
+	"encoding/json"
+	"errors"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"strconv"
+	"strings"
+	"testing"
+
+	"Rivall-Backend/api/websocket"
+	"Rivall-Backend/util/test"
+)
+
+// eventTypes reads the values of the Event constants declared in event.go
+func eventTypes(t *testing.T) []string {
+	file, err := parser.ParseFile(token.NewFileSet(), "event.go", nil, 0)
+	test.NoError(t, err)
+
+	var types []string
+	ast.Inspect(file, func(node ast.Node) bool {
+		spec, ok := node.(*ast.ValueSpec)
+		if !ok {
+			return true
+		}
+		for i, name := range spec.Names {
+			if !strings.HasPrefix(name.Name, "Event") || i >= len(spec.Values) {
+				continue
+			}
+			lit, ok := spec.Values[i].(*ast.BasicLit)
+			if !ok || lit.Kind != token.STRING {
+				continue
+			}
+			value, err := strconv.Unquote(lit.Value)
+			test.NoError(t, err)
+			types = append(types, value)
+		}
+		return true
+	})
+	return types
+}
+
+func TestEveryEventIsRegistered(t *testing.T) {
+	t.Parallel()
+
+	types := eventTypes(t)
+	if len(types) == 0 {
+		t.Fatal("Expected event constants in event.go")
+	}
+	for _, eventType := range types {
+		def, ok := websocket.LookupEvent(eventType)
+		if !ok {
+			t.Errorf("%s is not in the event registry", eventType)
+			continue
+		}
+		if def.Payload == nil || def.Summary == "" {
+			t.Errorf("%s has no payload or summary", eventType)
+		}
+	}
+	test.Equal(t, len(websocket.Events()), len(types))
+}
+
+func TestNewEvent(t *testing.T) {
+	t.Parallel()
+
+	event, err := websocket.NewEvent(websocket.EventGroupRequestAccepted, websocket.GroupRequestAnsweredEvent{GroupID: "g", UserID: "u"})
+	test.NoError(t, err)
+	test.Equal(t, event.Type, websocket.EventGroupRequestAccepted)
+	var payload websocket.GroupRequestAnsweredEvent
+	test.NoError(t, json.Unmarshal(event.Payload, &payload))
+	test.Equal(t, payload.UserID, "u")
+
+	_, err = websocket.NewEvent(websocket.EventAcceptGroupRequest, websocket.AnswerGroupRequestPayload{})
+	test.Equal(t, errors.Is(err, websocket.ErrNotOutboundEvent), true)
+
+	_, err = websocket.NewEvent(websocket.EventMemberJoined, websocket.MemberLeftEvent{})
+	test.Equal(t, errors.Is(err, websocket.ErrEventPayloadMismatch), true)
+
+	_, err = websocket.NewEvent("unknown", websocket.MemberLeftEvent{})
+	test.Equal(t, errors.Is(err, websocket.ErrEventNotSupported), true)
+}
+
+func TestAsyncAPI(t *testing.T) {
+	t.Parallel()
+
+	doc := websocket.AsyncAPI()
+	test.Equal(t, len(doc.Components.Messages), len(websocket.Events()))
+
+	channel := doc.Channels[websocket.CHANNEL]
+	test.Equal(t, channel.Publish.Message.OneOf[0].Ref, "#/components/messages/"+websocket.EventAcceptGroupRequest)
+
+	message := doc.Components.Messages[websocket.EventCreateGroup]
+	test.Equal(t, message.Payload.Properties["type"].Enum[0], websocket.EventCreateGroup)
+	test.Equal(t, message.Payload.Properties["payload"].Ref, "#/components/schemas/CreateGroupPayload")
+	test.Equal(t, doc.Components.Schemas["CreateGroupPayload"].Required[0], "group_name")
+
+	errorEvent := doc.Components.Schemas["ErrorEvent"]
+	if _, ok := errorEvent.Properties["code"]; !ok {
+		t.Error("Expected the error event to have the fields of a REST error")
+	}
+}
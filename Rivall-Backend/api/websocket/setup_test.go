@@ -0,0 +1,43 @@
+package websocket_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"Rivall-Backend/api/websocket"
+	db "Rivall-Backend/db"
+	"Rivall-Backend/globals"
+	"Rivall-Backend/util/keyring"
+	"Rivall-Backend/util/password_hasher"
+	"Rivall-Backend/util/session_manager"
+	"Rivall-Backend/util/test"
+
+	"github.com/rs/zerolog/log"
+)
+
+// setupManager points the globals event handlers use at a throwaway database and in memory
+// sessions, and returns a manager without clients
+func setupManager(t *testing.T) *websocket.Manager {
+	test.Mongo(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	keys, err := keyring.New(ctx, keyring.ALGORITHM_EDDSA, time.Hour, time.Hour, keyring.NewMemoryStore())
+	test.NoError(t, err)
+
+	globals.Logger = &log.Logger
+	globals.SessionManager = session_manager.NewSessionsManager(keys, "rivall-test", "rivall-test", session_manager.NewMemoryStore(ctx))
+	globals.PasswordHasher = password_hasher.New(password_hasher.Bcrypt{Cost: 4})
+	return websocket.NewManager(ctx)
+}
+
+// createUser stores a verified user and returns their ID
+func createUser(t *testing.T, email string) string {
+	t.Helper()
+
+	test.NoError(t, db.CreateUser(db.User{FirstName: "Sam", LastName: "Doe", Email: email, Password: "Correct-Horse-Battery-9"}))
+	test.NoError(t, db.VerifyUserEmail(email))
+	return db.ReadByUserEmail(email).ID.Hex()
+}
@@ -2,8 +2,13 @@ package config
 
 import (
 	"log"
+	"os"
+	"strings"
 	"time"
 
+	"Rivall-Backend/util/otp"
+	"Rivall-Backend/util/password_hasher"
+
 	"github.com/joeshaw/envdecode"
 	"github.com/joho/godotenv"
 )
@@ -16,8 +21,11 @@ func readDotEnvFile() {
 }
 
 type Conf struct {
-	Server ConfServer
-	DB     ConfDB
+	Server   ConfServer
+	DB       ConfDB
+	Mail     ConfMail
+	Password ConfPassword
+	OIDC     ConfOIDC
 }
 
 type ConfServer struct {
@@ -27,7 +35,20 @@ type ConfServer struct {
 	TimeoutWrite time.Duration `env:"SERVER_TIMEOUT_WRITE,required"`
 	TimeoutIdle  time.Duration `env:"SERVER_TIMEOUT_IDLE,required"`
 	Debug        bool          `env:"SERVER_DEBUG,required"`
-	JWTSecretKey string        `env:"JWT_SECRET_KEY,required"`
+	SessionStore string        `env:"SESSION_STORE,default=mongo"`
+	// TrustedProxies lists the proxy addresses or CIDR ranges, separated by semicolons, whose
+	// X-Forwarded-For header is believed
+	TrustedProxies []string `env:"TRUSTED_PROXIES"`
+
+	JWTAlgorithm   string        `env:"JWT_ALGORITHM,default=EdDSA"`
+	JWTIssuer      string        `env:"JWT_ISSUER,default=rivall"`
+	JWTAudience    string        `env:"JWT_AUDIENCE,default=rivall-api"`
+	JWTKeyRotation time.Duration `env:"JWT_KEY_ROTATION,default=720h"`
+	JWTKeyGrace    time.Duration `env:"JWT_KEY_GRACE,default=48h"`
+
+	// OTPSecret keys the hashes of mailed codes, replicas must share it and keep it across
+	// restarts for codes to stay valid
+	OTPSecret string `env:"OTP_SECRET,required"`
 }
 
 type ConfDB struct {
@@ -35,6 +56,63 @@ type ConfDB struct {
 	Debug    bool   `env:"DB_DEBUG"`
 }
 
+type ConfMail struct {
+	Backend      string        `env:"MAIL_BACKEND,default=smtp"`
+	File         string        `env:"MAIL_FILE,default=mail.log"`
+	From         string        `env:"MAIL_FROM,default=Rivall <no-reply@rivall.app>"`
+	SMTPHost     string        `env:"SMTP_HOST"`
+	SMTPPort     int           `env:"SMTP_PORT,default=587"`
+	SMTPUsername string        `env:"SMTP_USERNAME"`
+	SMTPPassword string        `env:"SMTP_PASSWORD"`
+	SMTPTLS      string        `env:"SMTP_TLS,default=mandatory"`
+	MaxAttempts  int           `env:"MAIL_MAX_ATTEMPTS,default=5"`
+	RetryDelay   time.Duration `env:"MAIL_RETRY_DELAY,default=30s"`
+}
+
+type ConfPassword struct {
+	MinLength  int `env:"PASSWORD_MIN_LENGTH,default=10"`
+	MaxLength  int `env:"PASSWORD_MAX_LENGTH,default=72"`
+	MinClasses int `env:"PASSWORD_MIN_CLASSES,default=3"`
+
+	Hasher     string `env:"PASSWORD_HASHER,default=argon2id"`
+	BcryptCost int    `env:"BCRYPT_COST,default=12"`
+	// Argon2Memory is in KiB
+	Argon2Memory      uint32 `env:"ARGON2_MEMORY,default=65536"`
+	Argon2Iterations  uint32 `env:"ARGON2_ITERATIONS,default=3"`
+	Argon2Parallelism uint8  `env:"ARGON2_PARALLELISM,default=2"`
+}
+
+type ConfOIDC struct {
+	// Names lists the providers, separated by semicolons, each configured by
+	// OIDC_<NAME>_ISSUER, OIDC_<NAME>_CLIENT_ID, OIDC_<NAME>_CLIENT_SECRET and
+	// OIDC_<NAME>_REDIRECT_URL
+	Names     []string `env:"OIDC_PROVIDERS"`
+	Providers []ConfOIDCProvider
+}
+
+type ConfOIDCProvider struct {
+	Name         string
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+}
+
+func readOIDCProvider(name string) ConfOIDCProvider {
+	prefix := "OIDC_" + strings.ToUpper(name) + "_"
+	provider := ConfOIDCProvider{
+		Name:         strings.ToLower(name),
+		Issuer:       os.Getenv(prefix + "ISSUER"),
+		ClientID:     os.Getenv(prefix + "CLIENT_ID"),
+		ClientSecret: os.Getenv(prefix + "CLIENT_SECRET"),
+		RedirectURL:  os.Getenv(prefix + "REDIRECT_URL"),
+	}
+	if provider.Issuer == "" || provider.ClientID == "" || provider.RedirectURL == "" {
+		log.Fatalf("%sISSUER, %sCLIENT_ID and %sREDIRECT_URL are required", prefix, prefix, prefix)
+	}
+	return provider
+}
+
 func New() *Conf {
 	readDotEnvFile()
 	var c Conf
@@ -42,12 +120,69 @@ func New() *Conf {
 		log.Fatalf("Failed to decode: %s", err)
 	}
 
-	if len(c.Server.JWTSecretKey) == 0 {
-		log.Fatalf("JWT_SECRET_KEY is required")
+	if c.Server.JWTAlgorithm != "EdDSA" && c.Server.JWTAlgorithm != "RS256" {
+		log.Fatalf("JWT_ALGORITHM must be EdDSA or RS256")
+	}
+
+	if c.Server.JWTKeyRotation <= 0 {
+		log.Fatalf("JWT_KEY_ROTATION must be positive")
+	}
+
+	switch c.Mail.Backend {
+	case "smtp":
+		if c.Mail.SMTPHost == "" {
+			log.Fatalf("SMTP_HOST is required when MAIL_BACKEND is smtp")
+		}
+	case "console", "file":
+	default:
+		log.Fatalf("MAIL_BACKEND must be smtp, console or file")
+	}
+
+	if c.Mail.MaxAttempts < 1 {
+		log.Fatalf("MAIL_MAX_ATTEMPTS must be at least 1")
+	}
+
+	if c.Password.MinLength < 8 {
+		log.Fatalf("PASSWORD_MIN_LENGTH must be at least 8")
+	}
+
+	if c.Password.Hasher != "argon2id" && c.Password.Hasher != "bcrypt" {
+		log.Fatalf("PASSWORD_HASHER must be argon2id or bcrypt")
+	}
+
+	if c.Password.BcryptCost < 10 || c.Password.BcryptCost > 31 {
+		log.Fatalf("BCRYPT_COST must be between 10 and 31")
+	}
+
+	if c.Password.Argon2Memory < 19*1024 || c.Password.Argon2Iterations < 1 || c.Password.Argon2Parallelism < 1 {
+		log.Fatalf("ARGON2_MEMORY must be at least 19456 and ARGON2_ITERATIONS and ARGON2_PARALLELISM at least 1")
+	}
+
+	// bcrypt only uses the first 72 bytes of a password
+	maxLength := 1024
+	if c.Password.Hasher == "bcrypt" {
+		maxLength = password_hasher.BCRYPT_MAX_BYTES
+	}
+	if c.Password.MaxLength < c.Password.MinLength || c.Password.MaxLength > maxLength {
+		log.Fatalf("PASSWORD_MAX_LENGTH must be between PASSWORD_MIN_LENGTH and %d with %s", maxLength, c.Password.Hasher)
+	}
+
+	if c.Password.MinClasses < 1 || c.Password.MinClasses > 4 {
+		log.Fatalf("PASSWORD_MIN_CLASSES must be between 1 and 4")
+	}
+
+	if len(c.Server.OTPSecret) < otp.MIN_SECRET_LENGTH {
+		log.Fatalf("OTP_SECRET must be at least %d characters", otp.MIN_SECRET_LENGTH)
+	}
+
+	if c.Server.SessionStore != "mongo" && c.Server.SessionStore != "memory" {
+		log.Fatalf("SESSION_STORE must be mongo or memory")
 	}
 
-	if len(c.Server.JWTSecretKey) < 32 {
-		log.Fatalf("JWT_SECRET_KEY must be at least 32 characters")
+	for _, name := range c.OIDC.Names {
+		if name = strings.TrimSpace(name); name != "" {
+			c.OIDC.Providers = append(c.OIDC.Providers, readOIDCProvider(name))
+		}
 	}
 
 	return &c
@@ -0,0 +1,201 @@
+package db
+
+import (
+	"Rivall-Backend/globals"
+	"context"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// DELETED_USER_ID takes the place of a deleted user on what they leave behind, like
+// their messages, it never matches a user
+var DELETED_USER_ID = bson.NilObjectID
+
+const AUDIT_ACCOUNT_DELETED = "account_deleted"
+
+// ScheduleUserDeletion marks a user to be deleted at deleteAt, keeping an earlier date
+// that is already scheduled
+func ScheduleUserDeletion(id string, deleteAt time.Time) (time.Time, error) {
+	collection := globals.MongoClient.Database(Database).Collection("Users")
+
+	i, err := bson.ObjectIDFromHex(id)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	filter := bson.M{"_id": i, "deletion_scheduled_at": bson.M{"$exists": false}}
+	update := bson.M{"$set": bson.M{"deletion_scheduled_at": deleteAt}}
+	if _, err := collection.UpdateOne(context.TODO(), filter, update); err != nil {
+		log.Error().Err(err).Msg("Failed to schedule user deletion")
+		return time.Time{}, err
+	}
+
+	user := ReadByUserId(id)
+	if user.DeletionScheduledAt == nil {
+		return time.Time{}, ErrUserNotFound
+	}
+	return *user.DeletionScheduledAt, nil
+}
+
+// CancelUserDeletion reports false if no deletion was scheduled
+func CancelUserDeletion(id string) (bool, error) {
+	collection := globals.MongoClient.Database(Database).Collection("Users")
+
+	i, err := bson.ObjectIDFromHex(id)
+	if err != nil {
+		return false, err
+	}
+
+	filter := bson.M{"_id": i, "deletion_scheduled_at": bson.M{"$exists": true}}
+	update := bson.M{"$unset": bson.M{"deletion_scheduled_at": ""}}
+	result, err := collection.UpdateOne(context.TODO(), filter, update)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to cancel user deletion")
+		return false, err
+	}
+	return result.ModifiedCount == 1, nil
+}
+
+// ReadUserIdsDueForDeletion lists the users whose grace period is over
+func ReadUserIdsDueForDeletion(now time.Time) ([]string, error) {
+	collection := globals.MongoClient.Database(Database).Collection("Users")
+
+	opts := options.Find().SetProjection(bson.M{"_id": 1})
+	cursor, err := collection.Find(context.TODO(), bson.M{"deletion_scheduled_at": bson.M{"$lte": now}}, opts)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to read users due for deletion")
+		return nil, err
+	}
+
+	var users []User
+	if err := cursor.All(context.TODO(), &users); err != nil {
+		log.Error().Err(err).Msg("Failed to decode users due for deletion")
+		return nil, err
+	}
+
+	ids := make([]string, len(users))
+	for i, user := range users {
+		ids[i] = user.ID.Hex()
+	}
+	return ids, nil
+}
+
+// RemoveUserReferences drops the contacts, group requests and join requests other users
+// and groups hold for a user
+func RemoveUserReferences(id string) error {
+	i, err := bson.ObjectIDFromHex(id)
+	if err != nil {
+		return err
+	}
+
+	users := globals.MongoClient.Database(Database).Collection("Users")
+	_, err = users.UpdateMany(
+		context.TODO(),
+		bson.M{"$or": bson.A{
+			bson.M{"contacts.contact_id": i},
+			bson.M{"group_requests.send_user_id": i},
+		}},
+		bson.M{"$pull": bson.M{
+			"contacts":       bson.M{"contact_id": i},
+			"group_requests": bson.M{"send_user_id": i},
+		}},
+	)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to remove user from contacts")
+		return err
+	}
+
+	groups := globals.MongoClient.Database(Database).Collection("Groups")
+	_, err = groups.UpdateMany(context.TODO(), bson.M{"join_requests.user_id": i}, bson.M{"$pull": bson.M{"join_requests": bson.M{"user_id": i}}})
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to remove user join requests")
+		return err
+	}
+
+	return nil
+}
+
+// anonymizeMessages replaces the user as the author and reader of the messages of a
+// DirectMessages or Groups collection
+func anonymizeMessages(collectionName string, i bson.ObjectID) error {
+	collection := globals.MongoClient.Database(Database).Collection(collectionName)
+	ctx := context.TODO()
+
+	opts := options.UpdateMany().SetArrayFilters([]interface{}{bson.M{"message.user_id": i}})
+	_, err := collection.UpdateMany(ctx,
+		bson.M{"messages.user_id": i},
+		bson.M{"$set": bson.M{"messages.$[message].user_id": DELETED_USER_ID}},
+		opts,
+	)
+	if err != nil {
+		return err
+	}
+
+	_, err = collection.UpdateMany(ctx, bson.M{"last_message.user_id": i}, bson.M{"$set": bson.M{"last_message.user_id": DELETED_USER_ID}})
+	if err != nil {
+		return err
+	}
+
+	_, err = collection.UpdateMany(ctx,
+		bson.M{"messages.seen_by": i},
+		bson.M{"$pull": bson.M{"messages.$[].seen_by": i, "last_message.seen_by": i}},
+	)
+	return err
+}
+
+// AnonymizeUser keeps what the user wrote and did for the people who shared it, without
+// tying any of it to them
+func AnonymizeUser(id string) error {
+	i, err := bson.ObjectIDFromHex(id)
+	if err != nil {
+		return err
+	}
+	ctx := context.TODO()
+
+	for _, collectionName := range []string{"DirectMessages", "Groups"} {
+		if err := anonymizeMessages(collectionName, i); err != nil {
+			log.Error().Err(err).Msgf("Failed to anonymize messages in %s", collectionName)
+			return err
+		}
+	}
+
+	directMessages := globals.MongoClient.Database(Database).Collection("DirectMessages")
+	for _, field := range []string{"user_a_id", "user_b_id"} {
+		_, err := directMessages.UpdateMany(ctx, bson.M{field: i}, bson.M{"$set": bson.M{field: DELETED_USER_ID}})
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to anonymize direct message lines")
+			return err
+		}
+	}
+
+	challenges := globals.MongoClient.Database(Database).Collection("Challenges")
+	_, err = challenges.UpdateMany(ctx, bson.M{"creator_id": i}, bson.M{"$set": bson.M{"creator_id": DELETED_USER_ID}})
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to anonymize challenges")
+		return err
+	}
+	for _, field := range []string{"participants", "ratings"} {
+		opts := options.UpdateMany().SetArrayFilters([]interface{}{bson.M{"entry.user_id": i}})
+		_, err := challenges.UpdateMany(ctx,
+			bson.M{field + ".user_id": i},
+			bson.M{"$set": bson.M{field + ".$[entry].user_id": DELETED_USER_ID}},
+			opts,
+		)
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to anonymize challenge participants")
+			return err
+		}
+	}
+
+	invites := globals.MongoClient.Database(Database).Collection("GroupInvites")
+	_, err = invites.UpdateMany(ctx, bson.M{"created_by": i}, bson.M{"$set": bson.M{"created_by": DELETED_USER_ID}})
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to anonymize group invites")
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,41 @@
+package db
+
+import (
+	"Rivall-Backend/globals"
+	"context"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+const (
+	AUDIT_LOCKOUT = "lockout"
+)
+
+// AuditEntry records a security relevant event for later review
+type AuditEntry struct {
+	ID        bson.ObjectID `json:"_id"        bson:"_id"`
+	Event     string        `json:"event"      bson:"event"`
+	Subject   string        `json:"subject"    bson:"subject"`
+	Details   bson.M        `json:"details"    bson:"details"`
+	CreatedAt time.Time     `json:"created_at" bson:"created_at"`
+}
+
+func CreateAuditEntry(event string, subject string, details bson.M) error {
+	collection := globals.MongoClient.Database(Database).Collection("AuditLog")
+
+	entry := AuditEntry{
+		ID:        bson.NewObjectID(),
+		Event:     event,
+		Subject:   subject,
+		Details:   details,
+		CreatedAt: time.Now(),
+	}
+
+	_, err := collection.InsertOne(context.TODO(), entry)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to write audit entry")
+	}
+	return err
+}
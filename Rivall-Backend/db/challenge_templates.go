@@ -0,0 +1,240 @@
+package db
+
+import (
+	"Rivall-Backend/globals"
+	"context"
+	"errors"
+	"regexp"
+	"strings"
+	"time"
+	"unicode"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// ChallengeTemplate is an anonymized copy of a successful challenge that other groups can reuse.
+// It never stores the source group, its members or their progress, only aggregate results.
+type ChallengeTemplate struct {
+	ID                bson.ObjectID `json:"_id"               bson:"_id"`
+	SourceChallengeID bson.ObjectID `json:"-"                 bson:"source_challenge_id"`
+	Title             string        `json:"title"             bson:"title"`
+	Description       string        `json:"description"       bson:"description"`
+	MetricType        string        `json:"metric_type"       bson:"metric_type"`
+	MetricUnit        string        `json:"metric_unit"       bson:"metric_unit"`
+	Goal              float64       `json:"goal"              bson:"goal"`
+	DurationDays      int           `json:"duration_days"     bson:"duration_days"`
+	AverageRating     float64       `json:"average_rating"    bson:"average_rating"`
+	RatingCount       int           `json:"rating_count"      bson:"rating_count"`
+	ParticipantCount  int           `json:"participant_count" bson:"participant_count"`
+	CompletionRate    float64       `json:"completion_rate"   bson:"completion_rate"`
+	TimesUsed         int           `json:"times_used"        bson:"times_used"`
+	PublishedAt       time.Time     `json:"published_at"      bson:"published_at"`
+}
+
+var (
+	ErrChallengeTemplateNotFound = errors.New("challenge template does not exist")
+	ErrChallengeTemplateExists   = errors.New("challenge has already been published")
+)
+
+// Only templates rated at least this well by enough participants may be published
+const MIN_TEMPLATE_RATING = 4.0
+const MIN_TEMPLATE_RATING_COUNT = 3
+
+// Upper bound on templates considered when ranking recommendations
+const MAX_RECOMMENDATION_CANDIDATES = 500
+
+func NewChallengeTemplate(challenge Challenge, title string, description string) ChallengeTemplate {
+	// Build an anonymized template from a completed challenge
+	if title == "" {
+		title = challenge.Title
+	}
+	if description == "" {
+		description = challenge.Description
+	}
+
+	return ChallengeTemplate{
+		ID:                bson.NewObjectID(),
+		SourceChallengeID: challenge.ID,
+		Title:             title,
+		Description:       description,
+		MetricType:        challenge.MetricType,
+		MetricUnit:        challenge.MetricUnit,
+		Goal:              challenge.Goal,
+		DurationDays:      challenge.DurationDays,
+		AverageRating:     challenge.AverageRating(),
+		RatingCount:       len(challenge.Ratings),
+		ParticipantCount:  len(challenge.Participants),
+		CompletionRate:    challenge.CompletionRate(),
+		TimesUsed:         0,
+		PublishedAt:       time.Now(),
+	}
+}
+
+func CreateChallengeTemplate(template ChallengeTemplate) (string, error) {
+	collection := globals.MongoClient.Database(Database).Collection("ChallengeTemplates")
+
+	// the unique index made by indexChallengeTemplateSources stops a challenge being
+	// published twice by requests racing each other
+	result, err := collection.InsertOne(context.Background(), template)
+	if mongo.IsDuplicateKeyError(err) {
+		return "", ErrChallengeTemplateExists
+	}
+	if err != nil {
+		globals.Logger.Error().Err(err).Msg("Failed to create challenge template")
+		return "", err
+	}
+
+	if oid, ok := result.InsertedID.(bson.ObjectID); ok {
+		globals.Logger.Info().Msgf("Created challenge template with ID: %v", oid.Hex())
+		return oid.Hex(), nil
+	}
+
+	return "", nil
+}
+
+// indexChallengeTemplateSources lets each challenge be published only once
+func indexChallengeTemplateSources(ctx context.Context) error {
+	collection := globals.MongoClient.Database(Database).Collection("ChallengeTemplates")
+
+	_, err := collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "source_challenge_id", Value: 1}},
+		Options: options.Index().SetName("source_challenge_id").SetUnique(true),
+	})
+	return err
+}
+
+func ReadChallengeTemplateById(templateID string) (ChallengeTemplate, error) {
+	var template ChallengeTemplate
+
+	id, err := bson.ObjectIDFromHex(templateID)
+	if err != nil {
+		globals.Logger.Error().Err(err).Msg("Failed to convert challenge template ID")
+		return template, err
+	}
+
+	collection := globals.MongoClient.Database(Database).Collection("ChallengeTemplates")
+
+	err = collection.FindOne(context.Background(), bson.M{"_id": id}).Decode(&template)
+	if err != nil {
+		globals.Logger.Error().Err(err).Msg("Failed to read challenge template")
+		return ChallengeTemplate{}, err
+	}
+
+	return template, nil
+}
+
+// NamesGroup reports if text mentions groupName as whole words, ignoring case and
+// punctuation, published templates must not point back at the group that ran them
+func NamesGroup(text string, groupName string) bool {
+	words := func(s string) string {
+		fields := strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+		})
+		return " " + strings.Join(fields, " ") + " "
+	}
+	name := words(groupName)
+	if strings.TrimSpace(name) == "" {
+		return false
+	}
+	return strings.Contains(words(text), name)
+}
+
+func CountChallengeTemplateUse(templateID string) error {
+	// Count a group taking up a template, this ranks popular templates higher
+	id, err := bson.ObjectIDFromHex(templateID)
+	if err != nil {
+		return ErrChallengeTemplateNotFound
+	}
+
+	collection := globals.MongoClient.Database(Database).Collection("ChallengeTemplates")
+	result, err := collection.UpdateOne(context.Background(), bson.M{"_id": id}, bson.M{"$inc": bson.M{"times_used": 1}})
+	if err != nil {
+		globals.Logger.Error().Err(err).Msg("Failed to count challenge template use")
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrChallengeTemplateNotFound
+	}
+
+	return nil
+}
+
+func GroupUsedChallengeTemplate(groupID string, templateID string) bool {
+	// Check if a group already proposed a challenge from a template, each group counts once
+	id, err := bson.ObjectIDFromHex(groupID)
+	if err != nil {
+		globals.Logger.Error().Err(err).Msg("Failed to convert group ID")
+		return false
+	}
+
+	collection := globals.MongoClient.Database(Database).Collection("Groups")
+	count, err := collection.CountDocuments(context.Background(), bson.M{"_id": id, "messages.payload.template_id": templateID})
+	if err != nil {
+		globals.Logger.Error().Err(err).Msg("Failed to check group template use")
+		return false
+	}
+
+	return count > 0
+}
+
+func ChallengeTemplateExistsForChallenge(challengeID string) bool {
+	// Check if a challenge has already been published as a template
+	id, err := bson.ObjectIDFromHex(challengeID)
+	if err != nil {
+		globals.Logger.Error().Err(err).Msg("Failed to convert challenge ID")
+		return false
+	}
+
+	collection := globals.MongoClient.Database(Database).Collection("ChallengeTemplates")
+
+	count, err := collection.CountDocuments(context.Background(), bson.M{"source_challenge_id": id})
+	if err != nil {
+		globals.Logger.Error().Err(err).Msg("Failed to check challenge template")
+		return false
+	}
+
+	return count > 0
+}
+
+func SearchChallengeTemplates(query string, metricType string, skip int64, limit int64) ([]ChallengeTemplate, error) {
+	// Search templates by title or description, best rated first
+	collection := globals.MongoClient.Database(Database).Collection("ChallengeTemplates")
+
+	filter := bson.M{}
+	if query != "" {
+		pattern := bson.Regex{Pattern: regexp.QuoteMeta(query), Options: "i"}
+		filter["$or"] = bson.A{
+			bson.M{"title": pattern},
+			bson.M{"description": pattern},
+		}
+	}
+	if metricType != "" {
+		filter["metric_type"] = metricType
+	}
+
+	opts := options.Find().
+		SetSort(bson.D{{Key: "average_rating", Value: -1}, {Key: "times_used", Value: -1}}).
+		SetSkip(skip).
+		SetLimit(limit)
+
+	cursor, err := collection.Find(context.Background(), filter, opts)
+	if err != nil {
+		globals.Logger.Error().Err(err).Msg("Failed to search challenge templates")
+		return nil, err
+	}
+
+	templates := []ChallengeTemplate{}
+	if err := cursor.All(context.Background(), &templates); err != nil {
+		globals.Logger.Error().Err(err).Msg("Failed to decode challenge templates")
+		return nil, err
+	}
+
+	return templates, nil
+}
+
+func ReadRecommendationCandidates() ([]ChallengeTemplate, error) {
+	// Read the best rated templates, the recommender ranks these per group
+	return SearchChallengeTemplates("", "", 0, MAX_RECOMMENDATION_CANDIDATES)
+}
@@ -0,0 +1,51 @@
+package db_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"Rivall-Backend/db"
+	"Rivall-Backend/globals"
+	"Rivall-Backend/util/test"
+
+	"github.com/rs/zerolog/log"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+func TestNamesGroup(t *testing.T) {
+	tests := []struct {
+		text, groupName string
+		expected        bool
+	}{
+		{"The Climbers push-up month", "Climbers", true},
+		{"the climbers' push-up month", "CLIMBERS", true},
+		{"Push-ups with Office Runners!", "office-runners", true},
+		{"Rock climbers unite", "Climbers United", false},
+		{"Run every day", "Run Club", false},
+		{"Running every day", "Run", false},
+		{"Anything", "", false},
+		{"", "Climbers", false},
+	}
+
+	for _, tt := range tests {
+		if got := db.NamesGroup(tt.text, tt.groupName); got != tt.expected {
+			t.Errorf("NamesGroup(%q, %q) = %v, want %v", tt.text, tt.groupName, got, tt.expected)
+		}
+	}
+}
+
+func TestChallengeTemplatePublishedOnce(t *testing.T) {
+	test.Mongo(t)
+	globals.Logger = &log.Logger
+	test.NoError(t, db.Migrate(context.Background()))
+
+	// requests racing past the handler's check still can't publish a challenge twice
+	challenge := db.Challenge{ID: bson.NewObjectID(), Title: "Push-up month", Status: db.ChallengeStatusCompleted}
+	_, err := db.CreateChallengeTemplate(db.NewChallengeTemplate(challenge, "", ""))
+	test.NoError(t, err)
+	_, err = db.CreateChallengeTemplate(db.NewChallengeTemplate(challenge, "", ""))
+	if !errors.Is(err, db.ErrChallengeTemplateExists) {
+		t.Fatalf("Expected ErrChallengeTemplateExists, got %v", err)
+	}
+}
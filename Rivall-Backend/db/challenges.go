@@ -0,0 +1,159 @@
+package db
+
+import (
+	"Rivall-Backend/globals"
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+const (
+	ChallengeStatusVoting    = "voting"
+	ChallengeStatusActive    = "active"
+	ChallengeStatusCompleted = "completed"
+	ChallengeStatusCancelled = "cancelled"
+)
+
+const (
+	MetricTypeCount    = "count"
+	MetricTypeDistance = "distance"
+	MetricTypeDuration = "duration"
+	MetricTypeWeight   = "weight"
+	MetricTypeCheckIn  = "check_in"
+)
+
+var MetricTypes = []string{
+	MetricTypeCount,
+	MetricTypeDistance,
+	MetricTypeDuration,
+	MetricTypeWeight,
+	MetricTypeCheckIn,
+}
+
+type Challenge struct {
+	ID           bson.ObjectID          `json:"_id"           bson:"_id"`
+	GroupID      bson.ObjectID          `json:"group_id"      bson:"group_id"`
+	CreatorID    bson.ObjectID          `json:"creator_id"    bson:"creator_id"`
+	TemplateID   bson.ObjectID          `json:"template_id"   bson:"template_id"`
+	Title        string                 `json:"title"         bson:"title"`
+	Description  string                 `json:"description"   bson:"description"`
+	MetricType   string                 `json:"metric_type"   bson:"metric_type"`
+	MetricUnit   string                 `json:"metric_unit"   bson:"metric_unit"`
+	Goal         float64                `json:"goal"          bson:"goal"`
+	DurationDays int                    `json:"duration_days" bson:"duration_days"`
+	Status       string                 `json:"status"        bson:"status"`
+	Participants []ChallengeParticipant `json:"participants"  bson:"participants"`
+	Ratings      []ChallengeRating      `json:"ratings"       bson:"ratings"`
+	StartDate    time.Time              `json:"start_date"    bson:"start_date"`
+	EndDate      time.Time              `json:"end_date"      bson:"end_date"`
+	CreatedAt    time.Time              `json:"created_at"    bson:"created_at"`
+}
+
+type ChallengeParticipant struct {
+	UserID    bson.ObjectID `json:"user_id"   bson:"user_id"`
+	Progress  float64       `json:"progress"  bson:"progress"`
+	Completed bool          `json:"completed" bson:"completed"`
+}
+
+// ChallengeRating is the post-challenge rating a participant gives the challenge itself (1-5)
+type ChallengeRating struct {
+	UserID bson.ObjectID `json:"user_id" bson:"user_id"`
+	Score  int           `json:"score"   bson:"score"`
+}
+
+func (c Challenge) AverageRating() float64 {
+	if len(c.Ratings) == 0 {
+		return 0
+	}
+	total := 0
+	for _, rating := range c.Ratings {
+		total += rating.Score
+	}
+	return float64(total) / float64(len(c.Ratings))
+}
+
+func (c Challenge) CompletionRate() float64 {
+	if len(c.Participants) == 0 {
+		return 0
+	}
+	completed := 0
+	for _, participant := range c.Participants {
+		if participant.Completed {
+			completed++
+		}
+	}
+	return float64(completed) / float64(len(c.Participants))
+}
+
+func ReadChallengeById(challengeID string) (Challenge, error) {
+	// Read a challenge by its ID
+	var challenge Challenge
+
+	id, err := bson.ObjectIDFromHex(challengeID)
+	if err != nil {
+		globals.Logger.Error().Err(err).Msg("Failed to convert challenge ID")
+		return challenge, err
+	}
+
+	collection := globals.MongoClient.Database(Database).Collection("Challenges")
+
+	err = collection.FindOne(context.Background(), bson.M{"_id": id}).Decode(&challenge)
+	if err != nil {
+		globals.Logger.Error().Err(err).Msg("Failed to read challenge")
+		return Challenge{}, err
+	}
+
+	return challenge, nil
+}
+
+func ReadChallengesByGroupId(groupID string) ([]Challenge, error) {
+	// Read every challenge a group has run
+	id, err := bson.ObjectIDFromHex(groupID)
+	if err != nil {
+		globals.Logger.Error().Err(err).Msg("Failed to convert group ID")
+		return nil, err
+	}
+
+	collection := globals.MongoClient.Database(Database).Collection("Challenges")
+
+	cursor, err := collection.Find(context.Background(), bson.M{"group_id": id})
+	if err != nil {
+		globals.Logger.Error().Err(err).Msg("Failed to read group challenges")
+		return nil, err
+	}
+
+	challenges := []Challenge{}
+	if err := cursor.All(context.Background(), &challenges); err != nil {
+		globals.Logger.Error().Err(err).Msg("Failed to decode group challenges")
+		return nil, err
+	}
+
+	return challenges, nil
+}
+
+func ReadChallengesByUserId(userID string) ([]Challenge, error) {
+	// Read every challenge a user created or took part in
+	id, err := bson.ObjectIDFromHex(userID)
+	if err != nil {
+		globals.Logger.Error().Err(err).Msg("Failed to convert user ID")
+		return nil, err
+	}
+
+	collection := globals.MongoClient.Database(Database).Collection("Challenges")
+
+	filter := bson.M{"$or": bson.A{bson.M{"creator_id": id}, bson.M{"participants.user_id": id}}}
+	cursor, err := collection.Find(context.Background(), filter)
+	if err != nil {
+		globals.Logger.Error().Err(err).Msg("Failed to read user challenges")
+		return nil, err
+	}
+
+	challenges := []Challenge{}
+	if err := cursor.All(context.Background(), &challenges); err != nil {
+		globals.Logger.Error().Err(err).Msg("Failed to decode user challenges")
+		return nil, err
+	}
+
+	return challenges, nil
+}
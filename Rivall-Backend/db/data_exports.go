@@ -0,0 +1,285 @@
+package db
+
+import (
+	"Rivall-Backend/globals"
+	"context"
+	"errors"
+	"io"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+const (
+	DATA_EXPORT_PENDING = "pending"
+	DATA_EXPORT_RUNNING = "running"
+	DATA_EXPORT_READY   = "ready"
+	DATA_EXPORT_FAILED  = "failed"
+)
+
+var (
+	ErrDataExportNotFound   = errors.New("data export not found")
+	ErrDataExportInProgress = errors.New("a data export is already in progress")
+)
+
+// DataExport is a user's request for a copy of their data, the archive is kept in the
+// DataExports GridFS bucket once it is ready
+type DataExport struct {
+	ID          bson.ObjectID `json:"_id"                    bson:"_id"`
+	UserID      bson.ObjectID `json:"user_id"                bson:"user_id"`
+	Status      string        `json:"status"                 bson:"status"`
+	RequestedAt time.Time     `json:"requested_at"           bson:"requested_at"`
+	StartedAt   *time.Time    `json:"started_at,omitempty"   bson:"started_at,omitempty"`
+	CompletedAt *time.Time    `json:"completed_at,omitempty" bson:"completed_at,omitempty"`
+	ExpiresAt   *time.Time    `json:"expires_at,omitempty"   bson:"expires_at,omitempty"`
+	ArchiveID   bson.ObjectID `json:"-"                      bson:"archive_id,omitempty"`
+	Size        int64         `json:"size"                   bson:"size"`
+	Error       string        `json:"error,omitempty"        bson:"error,omitempty"`
+}
+
+func dataExportArchives() *mongo.GridFSBucket {
+	return globals.MongoClient.Database(Database).GridFSBucket(options.GridFSBucket().SetName("DataExports"))
+}
+
+// CreateDataExport queues an export, a user has at most one waiting or running. The
+// unique index made by indexUnfinishedDataExports enforces that, so two requests at once
+// can't both queue one.
+func CreateDataExport(userID string) (DataExport, error) {
+	collection := globals.MongoClient.Database(Database).Collection("DataExports")
+
+	i, err := bson.ObjectIDFromHex(userID)
+	if err != nil {
+		return DataExport{}, err
+	}
+
+	export := DataExport{
+		ID:          bson.NewObjectID(),
+		UserID:      i,
+		Status:      DATA_EXPORT_PENDING,
+		RequestedAt: time.Now(),
+	}
+	_, err = collection.InsertOne(context.TODO(), export)
+	if mongo.IsDuplicateKeyError(err) {
+		return DataExport{}, ErrDataExportInProgress
+	}
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to create data export")
+		return DataExport{}, err
+	}
+	return export, nil
+}
+
+// indexUnfinishedDataExports lets each user have only one waiting or running export
+func indexUnfinishedDataExports(ctx context.Context) error {
+	collection := globals.MongoClient.Database(Database).Collection("DataExports")
+
+	unfinished := bson.M{"status": bson.M{"$in": bson.A{DATA_EXPORT_PENDING, DATA_EXPORT_RUNNING}}}
+	_, err := collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "user_id", Value: 1}},
+		Options: options.Index().SetName("unfinished_user_id").SetUnique(true).SetPartialFilterExpression(unfinished),
+	})
+	return err
+}
+
+func ReadDataExport(userID string, exportID string) (DataExport, error) {
+	collection := globals.MongoClient.Database(Database).Collection("DataExports")
+
+	i, err := bson.ObjectIDFromHex(userID)
+	if err != nil {
+		return DataExport{}, ErrDataExportNotFound
+	}
+	e, err := bson.ObjectIDFromHex(exportID)
+	if err != nil {
+		return DataExport{}, ErrDataExportNotFound
+	}
+
+	var export DataExport
+	err = collection.FindOne(context.TODO(), bson.M{"_id": e, "user_id": i}).Decode(&export)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return DataExport{}, ErrDataExportNotFound
+	}
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to read data export")
+		return DataExport{}, err
+	}
+	return export, nil
+}
+
+// ClaimDataExport hands the oldest waiting export to one worker. Exports running for
+// longer than staleAfter are handed out again, their worker is taken to have died.
+func ClaimDataExport(now time.Time, staleAfter time.Duration) (DataExport, bool, error) {
+	collection := globals.MongoClient.Database(Database).Collection("DataExports")
+
+	filter := bson.M{"$or": bson.A{
+		bson.M{"status": DATA_EXPORT_PENDING},
+		bson.M{"status": DATA_EXPORT_RUNNING, "started_at": bson.M{"$lt": now.Add(-staleAfter)}},
+	}}
+	update := bson.M{"$set": bson.M{"status": DATA_EXPORT_RUNNING, "started_at": now}}
+	opts := options.FindOneAndUpdate().SetSort(bson.M{"requested_at": 1}).SetReturnDocument(options.After)
+
+	var export DataExport
+	err := collection.FindOneAndUpdate(context.TODO(), filter, update, opts).Decode(&export)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return DataExport{}, false, nil
+	}
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to claim data export")
+		return DataExport{}, false, err
+	}
+	return export, true, nil
+}
+
+// CompleteDataExport stores the archive of a running export, which can be downloaded
+// until expiresAt
+func CompleteDataExport(export DataExport, archive io.Reader, expiresAt time.Time) error {
+	collection := globals.MongoClient.Database(Database).Collection("DataExports")
+	ctx := context.TODO()
+
+	archives := dataExportArchives()
+	archiveID, err := archives.UploadFromStream(ctx, export.ID.Hex()+".zip", archive)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to store data export archive")
+		return err
+	}
+
+	var file struct {
+		Length int64 `bson:"length"`
+	}
+	if err := archives.GetFilesCollection().FindOne(ctx, bson.M{"_id": archiveID}).Decode(&file); err != nil {
+		log.Error().Err(err).Msg("Failed to read data export archive size")
+	}
+
+	now := time.Now()
+	update := bson.M{"$set": bson.M{
+		"status":       DATA_EXPORT_READY,
+		"archive_id":   archiveID,
+		"size":         file.Length,
+		"completed_at": now,
+		"expires_at":   expiresAt,
+	}}
+	if _, err := collection.UpdateOne(ctx, bson.M{"_id": export.ID}, update); err != nil {
+		log.Error().Err(err).Msg("Failed to complete data export")
+		archives.Delete(ctx, archiveID)
+		return err
+	}
+	return nil
+}
+
+func FailDataExport(export DataExport, reason string) error {
+	collection := globals.MongoClient.Database(Database).Collection("DataExports")
+
+	update := bson.M{"$set": bson.M{"status": DATA_EXPORT_FAILED, "error": reason, "completed_at": time.Now()}}
+	_, err := collection.UpdateOne(context.TODO(), bson.M{"_id": export.ID}, update)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to fail data export")
+	}
+	return err
+}
+
+func OpenDataExportArchive(export DataExport) (*mongo.GridFSDownloadStream, error) {
+	return dataExportArchives().OpenDownloadStream(context.TODO(), export.ArchiveID)
+}
+
+// deleteDataExports removes the exports matching filter along with their archives
+func deleteDataExports(filter bson.M) error {
+	collection := globals.MongoClient.Database(Database).Collection("DataExports")
+	ctx := context.TODO()
+
+	cursor, err := collection.Find(ctx, filter)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to read data exports")
+		return err
+	}
+	var exports []DataExport
+	if err := cursor.All(ctx, &exports); err != nil {
+		log.Error().Err(err).Msg("Failed to decode data exports")
+		return err
+	}
+
+	archives := dataExportArchives()
+	for _, export := range exports {
+		if export.ArchiveID != bson.NilObjectID {
+			if err := archives.Delete(ctx, export.ArchiveID); err != nil && !errors.Is(err, mongo.ErrFileNotFound) {
+				log.Error().Err(err).Msg("Failed to delete data export archive")
+				return err
+			}
+		}
+		if _, err := collection.DeleteOne(ctx, bson.M{"_id": export.ID}); err != nil {
+			log.Error().Err(err).Msg("Failed to delete data export")
+			return err
+		}
+	}
+	return nil
+}
+
+// DeleteExpiredDataExports removes exports that can no longer be downloaded
+func DeleteExpiredDataExports(now time.Time) error {
+	return deleteDataExports(bson.M{"$or": bson.A{
+		bson.M{"expires_at": bson.M{"$lt": now}},
+		bson.M{"status": DATA_EXPORT_FAILED, "completed_at": bson.M{"$lt": now.Add(-time.Hour * 24)}},
+	}})
+}
+
+func DeleteUserDataExports(userID string) error {
+	i, err := bson.ObjectIDFromHex(userID)
+	if err != nil {
+		return err
+	}
+	return deleteDataExports(bson.M{"user_id": i})
+}
+
+// SentMessages are the messages a user sent in one direct message line or group
+type SentMessages struct {
+	ID        bson.ObjectID `bson:"_id"`
+	UserAID   bson.ObjectID `bson:"user_a_id"`
+	UserBID   bson.ObjectID `bson:"user_b_id"`
+	GroupName string        `bson:"group_name"`
+	Messages  []Message     `bson:"messages"`
+}
+
+// readSentMessages finds the messages userID sent in the DirectMessages or Groups collection
+func readSentMessages(collectionName string, userID string) ([]SentMessages, error) {
+	collection := globals.MongoClient.Database(Database).Collection(collectionName)
+
+	i, err := bson.ObjectIDFromHex(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	pipeline := bson.A{
+		bson.M{"$match": bson.M{"messages.user_id": i}},
+		bson.M{"$project": bson.M{
+			"user_a_id":  1,
+			"user_b_id":  1,
+			"group_name": 1,
+			"messages": bson.M{"$filter": bson.M{
+				"input": "$messages",
+				"as":    "message",
+				"cond":  bson.M{"$eq": bson.A{"$$message.user_id", i}},
+			}},
+		}},
+	}
+	cursor, err := collection.Aggregate(context.TODO(), pipeline)
+	if err != nil {
+		log.Error().Err(err).Msgf("Failed to read sent messages from %s", collectionName)
+		return nil, err
+	}
+
+	sent := []SentMessages{}
+	if err := cursor.All(context.TODO(), &sent); err != nil {
+		log.Error().Err(err).Msgf("Failed to decode sent messages from %s", collectionName)
+		return nil, err
+	}
+	return sent, nil
+}
+
+func ReadSentDirectMessages(userID string) ([]SentMessages, error) {
+	return readSentMessages("DirectMessages", userID)
+}
+
+func ReadSentGroupMessages(userID string) ([]SentMessages, error) {
+	return readSentMessages("Groups", userID)
+}
@@ -1,3 +1,4 @@
 package db
 
-const Database string = "Rivall-DB"
+// Database is the database every collection lives in, tests point it at a throwaway one
+var Database = "Rivall-DB"
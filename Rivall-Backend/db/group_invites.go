@@ -0,0 +1,306 @@
+package db
+
+import (
+	"Rivall-Backend/globals"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+var (
+	ErrInviteNotFound   = errors.New("invite does not exist")
+	ErrInviteNotUsable  = errors.New("invite is expired, revoked or used up")
+	ErrJoinRequestFound = errors.New("user already asked to join the group")
+)
+
+// GroupInvite is a shareable link into a group. A zero MaxUses allows unlimited uses
+// and a nil ExpiresAt never expires.
+type GroupInvite struct {
+	ID               bson.ObjectID `json:"_id"               bson:"_id"`
+	GroupID          bson.ObjectID `json:"group_id"          bson:"group_id"`
+	Code             string        `json:"code"              bson:"code"`
+	CreatedBy        bson.ObjectID `json:"created_by"        bson:"created_by"`
+	CreatedAt        time.Time     `json:"created_at"        bson:"created_at"`
+	ExpiresAt        *time.Time    `json:"expires_at"        bson:"expires_at"`
+	MaxUses          int           `json:"max_uses"          bson:"max_uses"`
+	Uses             int           `json:"uses"              bson:"uses"`
+	RequiresApproval bool          `json:"requires_approval" bson:"requires_approval"`
+	Revoked          bool          `json:"revoked"           bson:"revoked"`
+}
+
+// GroupJoinRequest is a user waiting for an admin to approve them joining through an invite
+type GroupJoinRequest struct {
+	UserID      bson.ObjectID `json:"user_id"      bson:"user_id"`
+	InviteID    bson.ObjectID `json:"invite_id"    bson:"invite_id"`
+	RequestedAt time.Time     `json:"requested_at" bson:"requested_at"`
+}
+
+const INVITE_CODE_BYTES = 16
+
+func (i GroupInvite) Usable(now time.Time) bool {
+	if i.Revoked {
+		return false
+	}
+	if i.ExpiresAt != nil && !i.ExpiresAt.After(now) {
+		return false
+	}
+	if i.MaxUses > 0 && i.Uses >= i.MaxUses {
+		return false
+	}
+	return true
+}
+
+func newInviteCode() (string, error) {
+	b := make([]byte, INVITE_CODE_BYTES)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func CreateGroupInvite(groupID string, createdBy string, expiresAt *time.Time, maxUses int, requiresApproval bool) (GroupInvite, error) {
+	bsonGroupID, err := bson.ObjectIDFromHex(groupID)
+	if err != nil {
+		globals.Logger.Error().Err(err).Msg("Failed to convert group ID")
+		return GroupInvite{}, err
+	}
+	bsonCreatedBy, err := bson.ObjectIDFromHex(createdBy)
+	if err != nil {
+		globals.Logger.Error().Err(err).Msg("Failed to convert user ID")
+		return GroupInvite{}, err
+	}
+
+	code, err := newInviteCode()
+	if err != nil {
+		globals.Logger.Error().Err(err).Msg("Failed to generate invite code")
+		return GroupInvite{}, err
+	}
+
+	invite := GroupInvite{
+		ID:               bson.NewObjectID(),
+		GroupID:          bsonGroupID,
+		Code:             code,
+		CreatedBy:        bsonCreatedBy,
+		CreatedAt:        time.Now(),
+		ExpiresAt:        expiresAt,
+		MaxUses:          maxUses,
+		Uses:             0,
+		RequiresApproval: requiresApproval,
+		Revoked:          false,
+	}
+
+	collection := globals.MongoClient.Database(Database).Collection("GroupInvites")
+	if _, err := collection.InsertOne(context.Background(), invite); err != nil {
+		globals.Logger.Error().Err(err).Msg("Failed to create group invite")
+		return GroupInvite{}, err
+	}
+
+	return invite, nil
+}
+
+func ReadGroupInviteByCode(code string) (GroupInvite, error) {
+	var invite GroupInvite
+
+	collection := globals.MongoClient.Database(Database).Collection("GroupInvites")
+	err := collection.FindOne(context.Background(), bson.M{"code": code}).Decode(&invite)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return invite, ErrInviteNotFound
+	}
+	if err != nil {
+		globals.Logger.Error().Err(err).Msg("Failed to read group invite")
+		return invite, err
+	}
+
+	return invite, nil
+}
+
+func ReadActiveGroupInvitesByGroupId(groupID string) ([]GroupInvite, error) {
+	// Read the invites of a group that can still be used
+	bsonGroupID, err := bson.ObjectIDFromHex(groupID)
+	if err != nil {
+		globals.Logger.Error().Err(err).Msg("Failed to convert group ID")
+		return nil, err
+	}
+
+	collection := globals.MongoClient.Database(Database).Collection("GroupInvites")
+	opts := options.Find().SetSort(bson.M{"created_at": -1})
+	cursor, err := collection.Find(context.Background(), bson.M{"group_id": bsonGroupID, "revoked": false}, opts)
+	if err != nil {
+		globals.Logger.Error().Err(err).Msg("Failed to read group invites")
+		return nil, err
+	}
+
+	var invites []GroupInvite
+	if err := cursor.All(context.Background(), &invites); err != nil {
+		globals.Logger.Error().Err(err).Msg("Failed to decode group invites")
+		return nil, err
+	}
+
+	now := time.Now()
+	active := []GroupInvite{}
+	for _, invite := range invites {
+		if invite.Usable(now) {
+			active = append(active, invite)
+		}
+	}
+
+	return active, nil
+}
+
+func RevokeGroupInvite(groupID string, inviteID string) error {
+	bsonGroupID, err := bson.ObjectIDFromHex(groupID)
+	if err != nil {
+		globals.Logger.Error().Err(err).Msg("Failed to convert group ID")
+		return err
+	}
+	bsonInviteID, err := bson.ObjectIDFromHex(inviteID)
+	if err != nil {
+		return ErrInviteNotFound
+	}
+
+	collection := globals.MongoClient.Database(Database).Collection("GroupInvites")
+	result, err := collection.UpdateOne(
+		context.Background(),
+		bson.M{"_id": bsonInviteID, "group_id": bsonGroupID},
+		bson.M{"$set": bson.M{"revoked": true}},
+	)
+	if err != nil {
+		globals.Logger.Error().Err(err).Msg("Failed to revoke group invite")
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrInviteNotFound
+	}
+
+	return nil
+}
+
+func UseGroupInvite(code string) (GroupInvite, error) {
+	// Count a use of an invite, failing if it is no longer usable
+	return useGroupInvite(bson.M{"code": code})
+}
+
+func UseGroupInviteById(inviteID string) (GroupInvite, error) {
+	// Count a use of an invite when a join request made through it is approved
+	i, err := bson.ObjectIDFromHex(inviteID)
+	if err != nil {
+		return GroupInvite{}, ErrInviteNotFound
+	}
+	return useGroupInvite(bson.M{"_id": i})
+}
+
+// useGroupInvite counts a use of the invite matching filter, only while it is usable so
+// joins racing for the last use can't both have it
+func useGroupInvite(filter bson.M) (GroupInvite, error) {
+	now := time.Now()
+	filter["revoked"] = false
+	filter["$and"] = bson.A{
+		bson.M{"$or": bson.A{
+			bson.M{"expires_at": nil},
+			bson.M{"expires_at": bson.M{"$gt": now}},
+		}},
+		bson.M{"$or": bson.A{
+			bson.M{"max_uses": 0},
+			bson.M{"$expr": bson.M{"$lt": bson.A{"$uses", "$max_uses"}}},
+		}},
+	}
+
+	collection := globals.MongoClient.Database(Database).Collection("GroupInvites")
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+
+	var invite GroupInvite
+	err := collection.FindOneAndUpdate(context.Background(), filter, bson.M{"$inc": bson.M{"uses": 1}}, opts).Decode(&invite)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return invite, ErrInviteNotUsable
+	}
+	if err != nil {
+		globals.Logger.Error().Err(err).Msg("Failed to use group invite")
+		return invite, err
+	}
+
+	return invite, nil
+}
+
+func ReleaseGroupInviteUse(inviteID string) error {
+	// Give back a use counted for a join that didn't happen
+	i, err := bson.ObjectIDFromHex(inviteID)
+	if err != nil {
+		return err
+	}
+
+	collection := globals.MongoClient.Database(Database).Collection("GroupInvites")
+	_, err = collection.UpdateOne(context.Background(), bson.M{"_id": i, "uses": bson.M{"$gt": 0}}, bson.M{"$inc": bson.M{"uses": -1}})
+	if err != nil {
+		globals.Logger.Error().Err(err).Msg("Failed to release group invite use")
+	}
+	return err
+}
+
+func AddGroupJoinRequest(groupID string, userID string, inviteID string) error {
+	// Queue a user for approval by the group admins
+	bsonGroupID, err := bson.ObjectIDFromHex(groupID)
+	if err != nil {
+		return err
+	}
+	bsonUserID, err := bson.ObjectIDFromHex(userID)
+	if err != nil {
+		return err
+	}
+	bsonInviteID, err := bson.ObjectIDFromHex(inviteID)
+	if err != nil {
+		return err
+	}
+
+	request := GroupJoinRequest{
+		UserID:      bsonUserID,
+		InviteID:    bsonInviteID,
+		RequestedAt: time.Now(),
+	}
+
+	collection := globals.MongoClient.Database(Database).Collection("Groups")
+	result, err := collection.UpdateOne(
+		context.Background(),
+		bson.M{"_id": bsonGroupID, "join_requests.user_id": bson.M{"$ne": bsonUserID}},
+		bson.M{"$push": bson.M{"join_requests": request}},
+	)
+	if err != nil {
+		globals.Logger.Error().Err(err).Msg("Failed to add group join request")
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrJoinRequestFound
+	}
+
+	return nil
+}
+
+func RemoveGroupJoinRequest(groupID string, userID string) (bool, error) {
+	// Remove a user's join request, reporting if there was one
+	bsonGroupID, err := bson.ObjectIDFromHex(groupID)
+	if err != nil {
+		return false, err
+	}
+	bsonUserID, err := bson.ObjectIDFromHex(userID)
+	if err != nil {
+		return false, err
+	}
+
+	collection := globals.MongoClient.Database(Database).Collection("Groups")
+	result, err := collection.UpdateOne(
+		context.Background(),
+		bson.M{"_id": bsonGroupID, "join_requests.user_id": bsonUserID},
+		bson.M{"$pull": bson.M{"join_requests": bson.M{"user_id": bsonUserID}}},
+	)
+	if err != nil {
+		globals.Logger.Error().Err(err).Msg("Failed to remove group join request")
+		return false, err
+	}
+
+	return result.ModifiedCount > 0, nil
+}
@@ -0,0 +1,33 @@
+package db_test
+
+import (
+	"testing"
+	"time"
+
+	"Rivall-Backend/db"
+)
+
+func TestGroupInviteUsable(t *testing.T) {
+	now := time.Now()
+	past := now.Add(-time.Hour)
+	future := now.Add(time.Hour)
+
+	tests := []struct {
+		name     string
+		invite   db.GroupInvite
+		expected bool
+	}{
+		{"unlimited", db.GroupInvite{}, true},
+		{"revoked", db.GroupInvite{Revoked: true}, false},
+		{"expired", db.GroupInvite{ExpiresAt: &past}, false},
+		{"not expired", db.GroupInvite{ExpiresAt: &future}, true},
+		{"uses left", db.GroupInvite{MaxUses: 3, Uses: 2}, true},
+		{"used up", db.GroupInvite{MaxUses: 3, Uses: 3}, false},
+	}
+
+	for _, tt := range tests {
+		if got := tt.invite.Usable(now); got != tt.expected {
+			t.Errorf("%s: Usable() = %v, want %v", tt.name, got, tt.expected)
+		}
+	}
+}
@@ -0,0 +1,214 @@
+package db
+
+import (
+	"Rivall-Backend/globals"
+	"context"
+	"errors"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+const (
+	GROUP_ROLE_OWNER  = "owner"
+	GROUP_ROLE_ADMIN  = "admin"
+	GROUP_ROLE_MEMBER = "member"
+)
+
+var ErrGroupChanged = errors.New("group changed while updating it")
+
+func groupRoleRank(role string) int {
+	switch role {
+	case GROUP_ROLE_OWNER:
+		return 3
+	case GROUP_ROLE_ADMIN:
+		return 2
+	case GROUP_ROLE_MEMBER:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// Role returns the role of a user in the group, or an empty string if they are not a member
+func (g Group) Role(userID string) string {
+	if g.AdminID.Hex() == userID {
+		return GROUP_ROLE_OWNER
+	}
+
+	isMember := false
+	for _, member := range g.GroupMembers {
+		if member.Hex() == userID {
+			isMember = true
+			break
+		}
+	}
+	if !isMember {
+		return ""
+	}
+
+	for _, admin := range g.AdminIDs {
+		if admin.Hex() == userID {
+			return GROUP_ROLE_ADMIN
+		}
+	}
+	return GROUP_ROLE_MEMBER
+}
+
+// CanManageGroup reports if a role may rename the group and see its pending requests
+func CanManageGroup(role string) bool {
+	return groupRoleRank(role) >= groupRoleRank(GROUP_ROLE_ADMIN)
+}
+
+// CanKickGroupMember reports if actorRole may remove a member holding targetRole.
+// Admins can remove members, the owner can remove anyone else.
+func CanKickGroupMember(actorRole string, targetRole string) bool {
+	if !CanManageGroup(actorRole) || targetRole == "" {
+		return false
+	}
+	return groupRoleRank(actorRole) > groupRoleRank(targetRole)
+}
+
+// CanChangeGroupRole reports if actorRole may set a member holding targetRole to newRole.
+// Only the owner promotes and demotes, ownership moves through a transfer instead.
+func CanChangeGroupRole(actorRole string, targetRole string, newRole string) bool {
+	if actorRole != GROUP_ROLE_OWNER {
+		return false
+	}
+	if targetRole != GROUP_ROLE_ADMIN && targetRole != GROUP_ROLE_MEMBER {
+		return false
+	}
+	return newRole == GROUP_ROLE_ADMIN || newRole == GROUP_ROLE_MEMBER
+}
+
+// NextGroupOwner picks who inherits the group when leavingUserID, the owner, leaves.
+// The longest serving admin is preferred, then the longest serving member.
+func NextGroupOwner(g Group, leavingUserID string) (bson.ObjectID, bool) {
+	for _, member := range g.GroupMembers {
+		if member.Hex() != leavingUserID && g.Role(member.Hex()) == GROUP_ROLE_ADMIN {
+			return member, true
+		}
+	}
+	for _, member := range g.GroupMembers {
+		if member.Hex() != leavingUserID {
+			return member, true
+		}
+	}
+	return bson.NilObjectID, false
+}
+
+func RemoveUserFromGroup(groupID string, userID string) error {
+	// Remove a member, including any admin rights they held
+	bsonGroupID, err := bson.ObjectIDFromHex(groupID)
+	if err != nil {
+		globals.Logger.Error().Err(err).Msg("Failed to convert group ID")
+		return err
+	}
+	bsonUserID, err := bson.ObjectIDFromHex(userID)
+	if err != nil {
+		globals.Logger.Error().Err(err).Msg("Failed to convert user ID")
+		return err
+	}
+
+	collection := globals.MongoClient.Database(Database).Collection("Groups")
+	_, err = collection.UpdateOne(
+		context.Background(),
+		bson.M{"_id": bsonGroupID},
+		bson.M{"$pull": bson.M{"users": bsonUserID, "admin_ids": bsonUserID}},
+	)
+	if err != nil {
+		globals.Logger.Error().Err(err).Msg("Failed to remove user from group")
+		return err
+	}
+
+	users := globals.MongoClient.Database(Database).Collection("Users")
+	_, err = users.UpdateOne(context.Background(), bson.M{"_id": bsonUserID}, bson.M{"$pull": bson.M{"group_ids": bsonGroupID}})
+	if err != nil {
+		globals.Logger.Error().Err(err).Msg("Failed to remove group from user")
+		return err
+	}
+
+	return nil
+}
+
+func SetGroupMemberRole(groupID string, userID string, role string) error {
+	// Promote a member to admin or demote an admin to member
+	bsonGroupID, err := bson.ObjectIDFromHex(groupID)
+	if err != nil {
+		globals.Logger.Error().Err(err).Msg("Failed to convert group ID")
+		return err
+	}
+	bsonUserID, err := bson.ObjectIDFromHex(userID)
+	if err != nil {
+		globals.Logger.Error().Err(err).Msg("Failed to convert user ID")
+		return err
+	}
+
+	var update bson.M
+	switch role {
+	case GROUP_ROLE_ADMIN:
+		update = bson.M{"$addToSet": bson.M{"admin_ids": bsonUserID}}
+	case GROUP_ROLE_MEMBER:
+		update = bson.M{"$pull": bson.M{"admin_ids": bsonUserID}}
+	default:
+		return errors.New("invalid group role")
+	}
+
+	collection := globals.MongoClient.Database(Database).Collection("Groups")
+	result, err := collection.UpdateOne(context.Background(), bson.M{"_id": bsonGroupID, "users": bsonUserID}, update)
+	if err != nil {
+		globals.Logger.Error().Err(err).Msg("Failed to set group member role")
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrGroupChanged
+	}
+
+	return nil
+}
+
+func TransferGroupOwnership(groupID string, fromUserID string, toUserID string) error {
+	// Hand the group to another member, the previous owner stays on as an admin
+	bsonGroupID, err := bson.ObjectIDFromHex(groupID)
+	if err != nil {
+		globals.Logger.Error().Err(err).Msg("Failed to convert group ID")
+		return err
+	}
+	bsonFromUserID, err := bson.ObjectIDFromHex(fromUserID)
+	if err != nil {
+		globals.Logger.Error().Err(err).Msg("Failed to convert user ID")
+		return err
+	}
+	bsonToUserID, err := bson.ObjectIDFromHex(toUserID)
+	if err != nil {
+		globals.Logger.Error().Err(err).Msg("Failed to convert user ID")
+		return err
+	}
+
+	collection := globals.MongoClient.Database(Database).Collection("Groups")
+
+	// only transfer if the group still belongs to fromUserID and toUserID is still a member
+	filter := bson.M{"_id": bsonGroupID, "admin_id": bsonFromUserID, "users": bsonToUserID}
+	result, err := collection.UpdateOne(context.Background(), filter, bson.M{
+		"$set":  bson.M{"admin_id": bsonToUserID},
+		"$pull": bson.M{"admin_ids": bsonToUserID},
+	})
+	if err != nil {
+		globals.Logger.Error().Err(err).Msg("Failed to transfer group ownership")
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrGroupChanged
+	}
+
+	_, err = collection.UpdateOne(
+		context.Background(),
+		bson.M{"_id": bsonGroupID, "users": bsonFromUserID},
+		bson.M{"$addToSet": bson.M{"admin_ids": bsonFromUserID}},
+	)
+	if err != nil {
+		globals.Logger.Error().Err(err).Msg("Failed to keep previous owner as admin")
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,93 @@
+package db_test
+
+import (
+	"testing"
+
+	"Rivall-Backend/db"
+	"Rivall-Backend/util/test"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+var (
+	owner  = bson.NewObjectID()
+	admin  = bson.NewObjectID()
+	member = bson.NewObjectID()
+	other  = bson.NewObjectID()
+)
+
+func testGroup() db.Group {
+	return db.Group{
+		ID:           bson.NewObjectID(),
+		AdminID:      owner,
+		AdminIDs:     []bson.ObjectID{admin},
+		GroupMembers: []bson.ObjectID{owner, member, admin},
+	}
+}
+
+func TestRole(t *testing.T) {
+	g := testGroup()
+	test.Equal(t, g.Role(owner.Hex()), db.GROUP_ROLE_OWNER)
+	test.Equal(t, g.Role(admin.Hex()), db.GROUP_ROLE_ADMIN)
+	test.Equal(t, g.Role(member.Hex()), db.GROUP_ROLE_MEMBER)
+	test.Equal(t, g.Role(other.Hex()), "")
+}
+
+func TestCanKickGroupMember(t *testing.T) {
+	tests := []struct {
+		actor, target string
+		expected      bool
+	}{
+		{db.GROUP_ROLE_OWNER, db.GROUP_ROLE_ADMIN, true},
+		{db.GROUP_ROLE_OWNER, db.GROUP_ROLE_MEMBER, true},
+		{db.GROUP_ROLE_ADMIN, db.GROUP_ROLE_MEMBER, true},
+		{db.GROUP_ROLE_ADMIN, db.GROUP_ROLE_ADMIN, false},
+		{db.GROUP_ROLE_ADMIN, db.GROUP_ROLE_OWNER, false},
+		{db.GROUP_ROLE_MEMBER, db.GROUP_ROLE_MEMBER, false},
+		{db.GROUP_ROLE_OWNER, "", false},
+	}
+
+	for _, tt := range tests {
+		if got := db.CanKickGroupMember(tt.actor, tt.target); got != tt.expected {
+			t.Errorf("CanKickGroupMember(%q, %q) = %v, want %v", tt.actor, tt.target, got, tt.expected)
+		}
+	}
+}
+
+func TestCanChangeGroupRole(t *testing.T) {
+	tests := []struct {
+		actor, target, role string
+		expected            bool
+	}{
+		{db.GROUP_ROLE_OWNER, db.GROUP_ROLE_MEMBER, db.GROUP_ROLE_ADMIN, true},
+		{db.GROUP_ROLE_OWNER, db.GROUP_ROLE_ADMIN, db.GROUP_ROLE_MEMBER, true},
+		{db.GROUP_ROLE_OWNER, db.GROUP_ROLE_MEMBER, db.GROUP_ROLE_OWNER, false},
+		{db.GROUP_ROLE_ADMIN, db.GROUP_ROLE_MEMBER, db.GROUP_ROLE_ADMIN, false},
+		{db.GROUP_ROLE_OWNER, db.GROUP_ROLE_OWNER, db.GROUP_ROLE_MEMBER, false},
+	}
+
+	for _, tt := range tests {
+		if got := db.CanChangeGroupRole(tt.actor, tt.target, tt.role); got != tt.expected {
+			t.Errorf("CanChangeGroupRole(%q, %q, %q) = %v, want %v", tt.actor, tt.target, tt.role, got, tt.expected)
+		}
+	}
+}
+
+func TestNextGroupOwner(t *testing.T) {
+	// admins inherit before members that joined earlier
+	next, ok := db.NextGroupOwner(testGroup(), owner.Hex())
+	test.Equal(t, ok, true)
+	test.Equal(t, next, admin)
+
+	// without admins the longest serving member inherits
+	g := testGroup()
+	g.AdminIDs = nil
+	next, ok = db.NextGroupOwner(g, owner.Hex())
+	test.Equal(t, ok, true)
+	test.Equal(t, next, member)
+
+	// nobody is left
+	g.GroupMembers = []bson.ObjectID{owner}
+	_, ok = db.NextGroupOwner(g, owner.Hex())
+	test.Equal(t, ok, false)
+}
@@ -3,20 +3,26 @@ package db
 import (
 	"Rivall-Backend/globals"
 	"context"
+	"time"
 
 	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
 )
 
 const collectionName string = "Groups"
 
+// Group is a group chat. AdminID is the group owner, AdminIDs are the members the owner promoted to admin.
 type Group struct {
-	ID           bson.ObjectID   `json:"_id"           bson:"_id"`
-	AdminID      bson.ObjectID   `json:"admin_id"      bson:"admin_id"`
-	GroupMembers []bson.ObjectID `json:"users"         bson:"users"`
-	LastMessage  Message         `json:"last_message"  bson:"last_message"`
-	Messages     []Message       `json:"messages"      bson:"messages"`
-	GroupName    string          `json:"group_name"    bson:"group_name"`
-	CreatedAt    bson.Timestamp  `json:"created_at"    bson:"created_at"`
+	ID           bson.ObjectID      `json:"_id"           bson:"_id"`
+	AdminID      bson.ObjectID      `json:"admin_id"      bson:"admin_id"`
+	AdminIDs     []bson.ObjectID    `json:"admin_ids"     bson:"admin_ids"`
+	GroupMembers []bson.ObjectID    `json:"users"         bson:"users"`
+	LastMessage  Message            `json:"last_message"  bson:"last_message"`
+	Messages     []Message          `json:"messages"      bson:"messages"`
+	GroupName    string             `json:"group_name"    bson:"group_name"`
+	CreatedAt    bson.Timestamp     `json:"created_at"    bson:"created_at"`
+	JoinRequests []GroupJoinRequest `json:"join_requests" bson:"join_requests"`
 }
 
 func ReadByGroupId(groupID string) Group {
@@ -55,11 +61,13 @@ func CreateGroup(groupName string, adminUserID string) (string, error) {
 	group := Group{
 		ID:           GroupID,
 		AdminID:      bsonAdminUserID,
+		AdminIDs:     []bson.ObjectID{},
 		GroupName:    groupName,
-		GroupMembers: []bson.ObjectID{},
+		GroupMembers: []bson.ObjectID{bsonAdminUserID},
 		LastMessage:  Message{},
 		Messages:     []Message{},
-		CreatedAt:    bson.Timestamp{},
+		CreatedAt:    bson.Timestamp{T: uint32(time.Now().Unix())},
+		JoinRequests: []GroupJoinRequest{},
 	}
 
 	collection := globals.MongoClient.Database(Database).Collection("Groups")
@@ -73,6 +81,14 @@ func CreateGroup(groupName string, adminUserID string) (string, error) {
 	// Ensure result.InsertedID is a string
 	if oid, ok := result.InsertedID.(bson.ObjectID); ok {
 		globals.Logger.Info().Msgf("Created group with ID: %v", oid.Hex())
+
+		// Keep the admin's group list in sync
+		users := globals.MongoClient.Database(Database).Collection("Users")
+		_, err = users.UpdateOne(context.Background(), bson.M{"_id": bsonAdminUserID}, bson.M{"$addToSet": bson.M{"group_ids": oid}})
+		if err != nil {
+			globals.Logger.Error().Err(err).Msg("Failed to add group to admin user")
+			return oid.Hex(), err
+		}
 		return oid.Hex(), nil
 	}
 	globals.Logger.Error().Msg("Failed to convert inserted ID to string")
@@ -108,10 +124,16 @@ func AddUserToGroup(groupID string, userID string) error {
 		return err
 	}
 
+	bsonUserID, err := bson.ObjectIDFromHex(userID)
+	if err != nil {
+		globals.Logger.Error().Err(err).Msg("Failed to convert user ID")
+		return err
+	}
+
 	collection := globals.MongoClient.Database(Database).Collection("Groups")
 
-	filter := bson.D{{"_id", bsonGroupID}}
-	update := bson.D{{"$push", bson.D{{"user_ids", userID}}}}
+	filter := bson.M{"_id": bsonGroupID}
+	update := bson.M{"$addToSet": bson.M{"users": bsonUserID}}
 
 	_, err = collection.UpdateOne(context.Background(), filter, update)
 	if err != nil {
@@ -119,6 +141,14 @@ func AddUserToGroup(groupID string, userID string) error {
 		return err
 	}
 
+	// Keep the user's group list in sync
+	users := globals.MongoClient.Database(Database).Collection("Users")
+	_, err = users.UpdateOne(context.Background(), bson.M{"_id": bsonUserID}, bson.M{"$addToSet": bson.M{"group_ids": bsonGroupID}})
+	if err != nil {
+		globals.Logger.Error().Err(err).Msg("Failed to add group to user")
+		return err
+	}
+
 	return nil
 }
 
@@ -188,8 +218,8 @@ func UserInGroup(groupID string, userID string) bool {
 }
 
 func UserWasRequestedToJoinGroup(groupID string, userID string) bool {
-	// Check if a user was requested to join a group
-	collection := globals.MongoClient.Database(Database).Collection("Groups")
+	// Check if a user has a pending request to join a group
+	collection := globals.MongoClient.Database(Database).Collection("Users")
 
 	bsonGroupID, err := bson.ObjectIDFromHex(groupID)
 	if err != nil {
@@ -197,20 +227,26 @@ func UserWasRequestedToJoinGroup(groupID string, userID string) bool {
 		return false
 	}
 
-	var result Group
-	err = collection.FindOne(context.Background(), bson.M{"_id": bsonGroupID}).Decode(&result)
+	bsonUserID, err := bson.ObjectIDFromHex(userID)
 	if err != nil {
+		globals.Logger.Error().Err(err).Msg("failed to convert user ID")
 		return false
 	}
 
-	// Check if the user ID is in the group members
-	for _, member := range result.GroupMembers {
-		if member.Hex() == userID {
-			return true
-		}
+	filter := bson.M{
+		"_id": bsonUserID,
+		"group_requests": bson.M{"$elemMatch": bson.M{
+			"group_id": bsonGroupID,
+			"status":   GROUP_REQUEST_PENDING,
+		}},
+	}
+	count, err := collection.CountDocuments(context.Background(), filter)
+	if err != nil {
+		globals.Logger.Error().Err(err).Msg("failed to check group request")
+		return false
 	}
 
-	return false
+	return count > 0
 }
 
 func GetGroupMembers(groupID string) ([]string, error) {
@@ -236,3 +272,171 @@ func GetGroupMembers(groupID string) ([]string, error) {
 
 	return members, nil
 }
+
+func ReadGroupMessage(groupID string, messageID string) (Message, error) {
+	// Read a single message from a group chat
+	collection := globals.MongoClient.Database(Database).Collection("Groups")
+
+	bsonGroupID, err := bson.ObjectIDFromHex(groupID)
+	if err != nil {
+		globals.Logger.Error().Err(err).Msg("failed to convert group ID")
+		return Message{}, err
+	}
+	bsonMessageID, err := bson.ObjectIDFromHex(messageID)
+	if err != nil {
+		globals.Logger.Error().Err(err).Msg("failed to convert message ID")
+		return Message{}, err
+	}
+
+	opts := options.FindOne().SetProjection(bson.M{"messages": bson.M{"$elemMatch": bson.M{"_id": bsonMessageID}}})
+
+	var result Group
+	err = collection.FindOne(context.Background(), bson.M{"_id": bsonGroupID}, opts).Decode(&result)
+	if err != nil {
+		return Message{}, err
+	}
+	if len(result.Messages) == 0 {
+		return Message{}, mongo.ErrNoDocuments
+	}
+
+	return result.Messages[0], nil
+}
+
+func ReadGroupsByUserId(userID string) ([]Group, error) {
+	// Read every group a user is a member of, without the message history
+	collection := globals.MongoClient.Database(Database).Collection("Groups")
+
+	bsonUserID, err := bson.ObjectIDFromHex(userID)
+	if err != nil {
+		globals.Logger.Error().Err(err).Msg("failed to convert user ID")
+		return nil, err
+	}
+
+	opts := options.Find().
+		SetProjection(bson.M{"messages": 0}).
+		SetSort(bson.M{"group_name": 1})
+
+	cursor, err := collection.Find(context.Background(), bson.M{"users": bsonUserID}, opts)
+	if err != nil {
+		globals.Logger.Error().Err(err).Msg("failed to read user groups")
+		return nil, err
+	}
+
+	groups := []Group{}
+	if err := cursor.All(context.Background(), &groups); err != nil {
+		globals.Logger.Error().Err(err).Msg("failed to decode user groups")
+		return nil, err
+	}
+
+	return groups, nil
+}
+
+func RenameGroup(groupID string, groupName string) error {
+	// Rename a group, including the name copied onto its pending requests
+	bsonGroupID, err := bson.ObjectIDFromHex(groupID)
+	if err != nil {
+		globals.Logger.Error().Err(err).Msg("failed to convert group ID")
+		return err
+	}
+
+	collection := globals.MongoClient.Database(Database).Collection("Groups")
+	_, err = collection.UpdateOne(context.Background(), bson.M{"_id": bsonGroupID}, bson.M{"$set": bson.M{"group_name": groupName}})
+	if err != nil {
+		globals.Logger.Error().Err(err).Msg("failed to rename group")
+		return err
+	}
+
+	users := globals.MongoClient.Database(Database).Collection("Users")
+	opts := options.UpdateMany().SetArrayFilters([]interface{}{bson.M{"request.group_id": bsonGroupID}})
+	_, err = users.UpdateMany(
+		context.Background(),
+		bson.M{"group_requests.group_id": bsonGroupID},
+		bson.M{"$set": bson.M{"group_requests.$[request].group_name": groupName}},
+		opts,
+	)
+	if err != nil {
+		globals.Logger.Error().Err(err).Msg("failed to rename group requests")
+		return err
+	}
+
+	return nil
+}
+
+func DeleteGroup(groupID string) error {
+	// Delete a group and every reference users hold to it
+	bsonGroupID, err := bson.ObjectIDFromHex(groupID)
+	if err != nil {
+		globals.Logger.Error().Err(err).Msg("failed to convert group ID")
+		return err
+	}
+
+	collection := globals.MongoClient.Database(Database).Collection("Groups")
+	_, err = collection.DeleteOne(context.Background(), bson.M{"_id": bsonGroupID})
+	if err != nil {
+		globals.Logger.Error().Err(err).Msg("failed to delete group")
+		return err
+	}
+
+	users := globals.MongoClient.Database(Database).Collection("Users")
+	_, err = users.UpdateMany(
+		context.Background(),
+		bson.M{"$or": bson.A{
+			bson.M{"group_ids": bsonGroupID},
+			bson.M{"group_requests.group_id": bsonGroupID},
+		}},
+		bson.M{"$pull": bson.M{
+			"group_ids":      bsonGroupID,
+			"group_requests": bson.M{"group_id": bsonGroupID},
+		}},
+	)
+	if err != nil {
+		globals.Logger.Error().Err(err).Msg("failed to remove group from users")
+		return err
+	}
+
+	// the group's invite codes must not resolve once it is gone
+	invites := globals.MongoClient.Database(Database).Collection("GroupInvites")
+	_, err = invites.DeleteMany(context.Background(), bson.M{"group_id": bsonGroupID})
+	if err != nil {
+		globals.Logger.Error().Err(err).Msg("failed to delete group invites")
+		return err
+	}
+
+	return nil
+}
+
+func ReadPendingGroupRequestsByGroupId(groupID string) ([]GroupRequest, error) {
+	// Read the requests a group has sent that have not been answered yet
+	bsonGroupID, err := bson.ObjectIDFromHex(groupID)
+	if err != nil {
+		globals.Logger.Error().Err(err).Msg("failed to convert group ID")
+		return nil, err
+	}
+
+	pending := bson.M{"group_id": bsonGroupID, "status": GROUP_REQUEST_PENDING}
+
+	users := globals.MongoClient.Database(Database).Collection("Users")
+	opts := options.Find().SetProjection(bson.M{"group_requests": 1})
+	cursor, err := users.Find(context.Background(), bson.M{"group_requests": bson.M{"$elemMatch": pending}}, opts)
+	if err != nil {
+		globals.Logger.Error().Err(err).Msg("failed to read group requests")
+		return nil, err
+	}
+
+	var results []User
+	if err := cursor.All(context.Background(), &results); err != nil {
+		globals.Logger.Error().Err(err).Msg("failed to decode group requests")
+		return nil, err
+	}
+
+	requests := []GroupRequest{}
+	for _, user := range results {
+		for _, request := range user.GroupRequests {
+			if request.GroupID == bsonGroupID && request.Status == GROUP_REQUEST_PENDING {
+				requests = append(requests, request)
+			}
+		}
+	}
+
+	return requests, nil
+}
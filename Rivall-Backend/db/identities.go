@@ -0,0 +1,103 @@
+package db
+
+import (
+	"Rivall-Backend/globals"
+	"context"
+	"errors"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// Identity is an account with an OpenID Connect provider that logs in as a user
+type Identity struct {
+	Provider string `json:"provider"  bson:"provider"`
+	// Subject is the provider's ID for the account, unlike the email it never changes
+	Subject  string    `json:"subject"   bson:"subject"`
+	Email    string    `json:"email"     bson:"email"`
+	LinkedAt time.Time `json:"linked_at" bson:"linked_at"`
+}
+
+var (
+	ErrIdentityTaken    = errors.New("identity is linked to another user")
+	ErrProviderLinked   = errors.New("a provider account is already linked")
+	ErrIdentityNotFound = errors.New("identity not found")
+)
+
+func ReadByIdentity(provider string, subject string) User {
+	var result User
+	filter := bson.M{"identities": bson.M{"$elemMatch": bson.M{"provider": provider, "subject": subject}}}
+
+	collection := globals.MongoClient.Database(Database).Collection("Users")
+	err := collection.FindOne(context.TODO(), filter).Decode(&result)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to read user")
+	}
+	return result
+}
+
+// CreateUserWithIdentity creates a user who logs in with a provider, the provider has
+// verified the email and there is no password until the user sets one
+func CreateUserWithIdentity(user User, identity Identity) (User, error) {
+	if ReadByUserEmail(user.Email).ID != bson.NilObjectID {
+		return User{}, ErrEmailTaken
+	}
+
+	user.Password = ""
+	user.EmailVerified = true
+	user.Identities = []Identity{identity}
+	return insertUser(user)
+}
+
+// LinkIdentity adds an identity to a user, who can have one account per provider
+func LinkIdentity(id string, identity Identity) error {
+	collection := globals.MongoClient.Database(Database).Collection("Users")
+
+	i, err := bson.ObjectIDFromHex(id)
+	if err != nil {
+		return err
+	}
+
+	if owner := ReadByIdentity(identity.Provider, identity.Subject); owner.ID != bson.NilObjectID {
+		if owner.ID == i {
+			return nil
+		}
+		return ErrIdentityTaken
+	}
+
+	filter := bson.M{"_id": i, "identities.provider": bson.M{"$ne": identity.Provider}}
+	update := bson.M{"$push": bson.M{"identities": identity}}
+
+	result, err := collection.UpdateOne(context.TODO(), filter, update)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to link identity")
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrProviderLinked
+	}
+	return nil
+}
+
+func UnlinkIdentity(id string, provider string) error {
+	collection := globals.MongoClient.Database(Database).Collection("Users")
+
+	i, err := bson.ObjectIDFromHex(id)
+	if err != nil {
+		return err
+	}
+
+	filter := bson.M{"_id": i, "identities.provider": provider}
+	update := bson.M{"$pull": bson.M{"identities": bson.M{"provider": provider}}}
+
+	result, err := collection.UpdateOne(context.TODO(), filter, update)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to unlink identity")
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrIdentityNotFound
+	}
+	return nil
+}
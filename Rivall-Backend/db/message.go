@@ -8,5 +8,6 @@ type Message struct {
 	MessageData string          `json:"message_data"  bson:"message_data"`
 	Timestamp   string          `json:"timestamp"     bson:"timestamp"`
 	MessageType string          `json:"message_type"  bson:"message_type"`
+	Payload     bson.M          `json:"payload,omitempty" bson:"payload,omitempty"` // structured data of non text messages, see util/message_types
 	SeenBy      []bson.ObjectID `json:"seen_by"     bson:"seen_by"`
 }
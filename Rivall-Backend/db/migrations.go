@@ -0,0 +1,52 @@
+package db
+
+import (
+	"context"
+
+	"Rivall-Backend/globals"
+
+	"github.com/rs/zerolog/log"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+type migration struct {
+	name string
+	run  func(ctx context.Context) error
+}
+
+// migrations bring documents written by older versions up to date and create the indexes
+// the code relies on, each one must be safe to run again on every start
+var migrations = []migration{
+	{name: "backfill email_verified", run: backfillEmailVerified},
+	{name: "index unfinished data exports", run: indexUnfinishedDataExports},
+	{name: "index challenge template sources", run: indexChallengeTemplateSources},
+}
+
+// Migrate runs every migration in order, stopping at the first that fails
+func Migrate(ctx context.Context) error {
+	for _, m := range migrations {
+		if err := m.run(ctx); err != nil {
+			log.Error().Err(err).Msgf("Migration %q failed", m.name)
+			return err
+		}
+	}
+	return nil
+}
+
+// backfillEmailVerified marks accounts from before email verification as verified, they
+// were never asked to prove their address and would otherwise be locked out of features
+// that need one
+func backfillEmailVerified(ctx context.Context) error {
+	collection := globals.MongoClient.Database(Database).Collection("Users")
+
+	filter := bson.M{"email_verified": bson.M{"$exists": false}}
+	update := bson.M{"$set": bson.M{"email_verified": true}}
+	result, err := collection.UpdateMany(ctx, filter, update)
+	if err != nil {
+		return err
+	}
+	if result.ModifiedCount > 0 {
+		log.Info().Msgf("Marked %d existing users as verified", result.ModifiedCount)
+	}
+	return nil
+}
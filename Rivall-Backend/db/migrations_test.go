@@ -0,0 +1,32 @@
+package db_test
+
+import (
+	"context"
+	"testing"
+
+	"Rivall-Backend/db"
+	"Rivall-Backend/util/test"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+func TestMigrateBackfillsEmailVerified(t *testing.T) {
+	database := test.Mongo(t)
+	ctx := context.Background()
+
+	// an account from before verification, and one that hasn't verified yet
+	old := bson.NewObjectID()
+	fresh := bson.NewObjectID()
+	_, err := database.Collection("Users").InsertMany(ctx, []any{
+		bson.M{"_id": old, "email": "old@example.com"},
+		bson.M{"_id": fresh, "email": "fresh@example.com", "email_verified": false},
+	})
+	test.NoError(t, err)
+
+	test.NoError(t, db.Migrate(ctx))
+	// running it again changes nothing
+	test.NoError(t, db.Migrate(ctx))
+
+	test.Equal(t, db.ReadByUserId(old.Hex()).EmailVerified, true)
+	test.Equal(t, db.ReadByUserId(fresh.Hex()).EmailVerified, false)
+}
@@ -0,0 +1,76 @@
+package db
+
+import (
+	"Rivall-Backend/globals"
+	"context"
+
+	"github.com/rs/zerolog/log"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// TwoFactor holds a user's TOTP settings, none of it is ever sent to clients
+type TwoFactor struct {
+	Enabled bool   `bson:"enabled"`
+	Secret  string `bson:"secret,omitempty"`
+	// PendingSecret waits for a first code before it replaces Secret
+	PendingSecret string `bson:"pending_secret,omitempty"`
+	// RecoveryCodes holds hashes of the unused recovery codes
+	RecoveryCodes []string `bson:"recovery_codes,omitempty"`
+	// LastUsedStep is the newest TOTP period a code was accepted for, codes can't be replayed
+	LastUsedStep int64 `bson:"last_used_step"`
+}
+
+func updateTwoFactor(id string, filter bson.M, update bson.M) (bool, error) {
+	collection := globals.MongoClient.Database(Database).Collection("Users")
+
+	i, err := bson.ObjectIDFromHex(id)
+	if err != nil {
+		return false, err
+	}
+	filter["_id"] = i
+
+	result, err := collection.UpdateOne(context.TODO(), filter, update)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to update two factor settings")
+		return false, err
+	}
+	return result.ModifiedCount == 1, nil
+}
+
+func SetTwoFactorPendingSecret(id string, secret string) error {
+	_, err := updateTwoFactor(id, bson.M{}, bson.M{"$set": bson.M{"two_factor.pending_secret": secret}})
+	return err
+}
+
+// EnableTwoFactor swaps in the confirmed pending secret, reporting false if it was replaced meanwhile
+func EnableTwoFactor(id string, secret string, step int64, recoveryCodeHashes []string) (bool, error) {
+	filter := bson.M{"two_factor.pending_secret": secret}
+	update := bson.M{
+		"$set": bson.M{"two_factor": TwoFactor{
+			Enabled:       true,
+			Secret:        secret,
+			RecoveryCodes: recoveryCodeHashes,
+			LastUsedStep:  step,
+		}},
+	}
+	return updateTwoFactor(id, filter, update)
+}
+
+func DisableTwoFactor(id string) error {
+	_, err := updateTwoFactor(id, bson.M{}, bson.M{"$set": bson.M{"two_factor": TwoFactor{}}})
+	return err
+}
+
+// UseTwoFactorStep records a TOTP period as used, reporting false if it or a later one already was
+func UseTwoFactorStep(id string, step int64) (bool, error) {
+	filter := bson.M{"two_factor.last_used_step": bson.M{"$lt": step}}
+	update := bson.M{"$set": bson.M{"two_factor.last_used_step": step}}
+	return updateTwoFactor(id, filter, update)
+}
+
+// UseTwoFactorRecoveryCode removes a recovery code, reporting false if it was not there
+func UseTwoFactorRecoveryCode(id string, hash string) (bool, error) {
+	filter := bson.M{"two_factor.recovery_codes": hash}
+	update := bson.M{"$pull": bson.M{"two_factor.recovery_codes": hash}}
+	return updateTwoFactor(id, filter, update)
+}
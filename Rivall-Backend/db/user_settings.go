@@ -0,0 +1,142 @@
+package db
+
+import (
+	"Rivall-Backend/globals"
+	"context"
+
+	"github.com/rs/zerolog/log"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// Who a privacy setting lets through
+const (
+	PRIVACY_EVERYONE = "everyone"
+	// PRIVACY_CONTACTS lets through the user's contacts
+	PRIVACY_CONTACTS = "contacts"
+	// PRIVACY_GROUP_MEMBERS lets through anyone who shares a group with the user
+	PRIVACY_GROUP_MEMBERS = "group_members"
+	PRIVACY_NOBODY        = "nobody"
+)
+
+type UserSettings struct {
+	// Discoverable users can be looked up by people who don't know them yet
+	Discoverable    bool   `json:"discoverable"     bson:"discoverable"`
+	ContactRequests string `json:"contact_requests" bson:"contact_requests"`
+	GroupRequests   string `json:"group_requests"   bson:"group_requests"`
+	// ReadReceipts shares when the user has seen a message
+	ReadReceipts  bool                 `json:"read_receipts"  bson:"read_receipts"`
+	Presence      string               `json:"presence"       bson:"presence"`
+	Notifications NotificationSettings `json:"notifications"  bson:"notifications"`
+}
+
+// NotificationSettings choose what the user is emailed about while they are offline
+type NotificationSettings struct {
+	GroupRequests bool `json:"group_requests" bson:"group_requests"`
+	NewContacts   bool `json:"new_contacts"   bson:"new_contacts"`
+}
+
+// DefaultUserSettings are the settings of a user who never changed them
+func DefaultUserSettings() UserSettings {
+	return UserSettings{
+		Discoverable:    true,
+		ContactRequests: PRIVACY_EVERYONE,
+		GroupRequests:   PRIVACY_EVERYONE,
+		ReadReceipts:    true,
+		Presence:        PRIVACY_CONTACTS,
+		Notifications: NotificationSettings{
+			GroupRequests: true,
+			NewContacts:   true,
+		},
+	}
+}
+
+// CurrentSettings are the user's settings, or the defaults if they never saved any
+func (u User) CurrentSettings() UserSettings {
+	if u.Settings == nil {
+		return DefaultUserSettings()
+	}
+	return *u.Settings
+}
+
+func UpdateUserSettings(userID string, settings UserSettings) error {
+	collection := globals.MongoClient.Database(Database).Collection("Users")
+
+	i, err := bson.ObjectIDFromHex(userID)
+	if err != nil {
+		return ErrUserNotFound
+	}
+
+	result, err := collection.UpdateOne(context.Background(), bson.M{"_id": i}, bson.M{"$set": bson.M{"settings": settings}})
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to update user settings")
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrUserNotFound
+	}
+	return nil
+}
+
+// HasContact reports whether contactID is one of the user's contacts
+func (u User) HasContact(contactID bson.ObjectID) bool {
+	for _, contact := range u.Contacts {
+		if contact.ContactID == contactID {
+			return true
+		}
+	}
+	return false
+}
+
+// SharesGroup reports whether the users are members of a common group
+func (u User) SharesGroup(other User) bool {
+	for _, groupID := range u.GroupIDs {
+		for _, otherGroupID := range other.GroupIDs {
+			if groupID == otherGroupID {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Allows reports whether a privacy setting of the user lets other through
+func (u User) Allows(setting string, other User) bool {
+	if u.ID == other.ID {
+		return true
+	}
+	switch setting {
+	case PRIVACY_EVERYONE:
+		return true
+	case PRIVACY_CONTACTS:
+		return u.HasContact(other.ID)
+	case PRIVACY_GROUP_MEMBERS:
+		return u.HasContact(other.ID) || u.SharesGroup(other)
+	default:
+		return false
+	}
+}
+
+// HideReadReceipts removes from the messages' seen_by the users who don't share read
+// receipts. Senders and the viewer stay, they know they've seen the message.
+func HideReadReceipts(messages []Message, viewerID string) []Message {
+	sharing := make(map[bson.ObjectID]bool)
+	for i, message := range messages {
+		seenBy := []bson.ObjectID{}
+		for _, userID := range message.SeenBy {
+			if userID.Hex() == viewerID || userID == message.UserID {
+				seenBy = append(seenBy, userID)
+				continue
+			}
+			shares, ok := sharing[userID]
+			if !ok {
+				shares = ReadByUserId(userID.Hex()).CurrentSettings().ReadReceipts
+				sharing[userID] = shares
+			}
+			if shares {
+				seenBy = append(seenBy, userID)
+			}
+		}
+		messages[i].SeenBy = seenBy
+	}
+	return messages
+}
@@ -0,0 +1,45 @@
+package db_test
+
+import (
+	"testing"
+
+	"Rivall-Backend/db"
+	"Rivall-Backend/util/test"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+func TestCurrentSettings(t *testing.T) {
+	test.Equal(t, db.User{}.CurrentSettings(), db.DefaultUserSettings())
+
+	settings := db.DefaultUserSettings()
+	settings.Discoverable = false
+	test.Equal(t, db.User{Settings: &settings}.CurrentSettings(), settings)
+}
+
+func TestAllows(t *testing.T) {
+	group := bson.NewObjectID()
+	user := db.User{ID: bson.NewObjectID(), GroupIDs: []bson.ObjectID{group}}
+	contact := db.User{ID: bson.NewObjectID()}
+	groupMember := db.User{ID: bson.NewObjectID(), GroupIDs: []bson.ObjectID{bson.NewObjectID(), group}}
+	stranger := db.User{ID: bson.NewObjectID()}
+	user.Contacts = []db.Contact{{ID: bson.NewObjectID(), ContactID: contact.ID}}
+
+	tests := []struct {
+		setting  string
+		other    db.User
+		expected bool
+	}{
+		{db.PRIVACY_EVERYONE, stranger, true},
+		{db.PRIVACY_CONTACTS, contact, true},
+		{db.PRIVACY_CONTACTS, groupMember, false},
+		{db.PRIVACY_GROUP_MEMBERS, groupMember, true},
+		{db.PRIVACY_GROUP_MEMBERS, contact, true},
+		{db.PRIVACY_GROUP_MEMBERS, stranger, false},
+		{db.PRIVACY_NOBODY, contact, false},
+		{db.PRIVACY_NOBODY, user, true},
+	}
+	for _, tt := range tests {
+		test.Equal(t, user.Allows(tt.setting, tt.other), tt.expected)
+	}
+}
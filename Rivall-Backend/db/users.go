@@ -4,22 +4,35 @@ import (
 	"Rivall-Backend/globals"
 	"context"
 	"errors"
+	"time"
 
 	"github.com/rs/zerolog/log"
 	"go.mongodb.org/mongo-driver/v2/bson"
-	"golang.org/x/crypto/bcrypt"
 )
 
 type User struct {
-	ID           bson.ObjectID   `json:"_id"           bson:"_id"`
-	FirstName    string          `json:"first_name"    bson:"first_name"`
-	LastName     string          `json:"last_name"     bson:"last_name"`
-	Email        string          `json:"email"         bson:"email"`
-	Password     string          `json:"password" bson:"password"`
-	AvatarImage  string          `json:"avatar_image"  bson:"avatar_image"`
-	GroupIDs     []bson.ObjectID `bson:"group_ids"`
-	OTP          string          `json:"otp"`
-	RefreshToken string          `json:"refresh_token" bson:"refresh_token"`
+	ID        bson.ObjectID `json:"_id"           bson:"_id"`
+	FirstName string        `json:"first_name"    bson:"first_name"`
+	LastName  string        `json:"last_name"     bson:"last_name"`
+	Email     string        `json:"email"         bson:"email"`
+	// EmailVerified is set once the user proves they own Email, PendingEmail waits on the same
+	// proof before it replaces Email
+	EmailVerified bool            `json:"email_verified" bson:"email_verified"`
+	PendingEmail  string          `json:"pending_email"  bson:"pending_email,omitempty"`
+	TwoFactor     TwoFactor       `json:"-"              bson:"two_factor"`
+	Password      string          `json:"-" bson:"password"`
+	AvatarImage   string          `json:"avatar_image"  bson:"avatar_image"`
+	GroupIDs      []bson.ObjectID `bson:"group_ids"`
+	OTP           string          `json:"-"`
+	RefreshToken  string          `json:"-" bson:"refresh_token"`
+	// Identities are the OpenID Connect accounts the user can log in with
+	Identities []Identity `json:"identities" bson:"identities"`
+	// DeletionScheduledAt is when the user asked to be deleted, it can be cancelled until then
+	DeletionScheduledAt *time.Time `json:"deletion_scheduled_at,omitempty" bson:"deletion_scheduled_at,omitempty"`
+	// Bio is a short introduction shown on the user's profile
+	Bio string `json:"bio" bson:"bio"`
+	// Settings is nil until the user changes them, read them with CurrentSettings
+	Settings *UserSettings `json:"settings,omitempty" bson:"settings,omitempty"`
 	// Contacts are not stored on the Database, they are fetched from the contact_ids
 	GroupRequests     []GroupRequest     `json:"group_requests" bson:"group_requests"`
 	Contacts          []Contact          `json:"contacts" bson:"contacts"`
@@ -37,6 +50,12 @@ type GroupRequest struct {
 	Status        int8           `json:"status" bson:"status"`
 }
 
+var (
+	ErrUserNotFound     = errors.New("user not found")
+	ErrEmailTaken       = errors.New("email is already in use")
+	ErrEmailNotVerified = errors.New("email address is not verified")
+)
+
 func ReadByUserIdWithPopulatedFields(id string) User {
 	var result User
 	i, _ := bson.ObjectIDFromHex(id)
@@ -149,67 +168,99 @@ func ReadByUserEmail(email string) User {
 	return result
 }
 
-func HashUserPassword(user User) User {
-	// Convert the plain pwd string to a byte slice
-	pwd := []byte(user.Password)
-
-	// Use GenerateFromPassword to hash & salt pwd.
-	// MinCost is just an integer constant provided by the bcrypt
-	// package along with DefaultCost & MaxCost.
-	// The cost can be any value you want provided it isn't lower
-	// than the MinCost (4)
-	hash, err := bcrypt.GenerateFromPassword(pwd, 12)
+// HashUserPassword replaces the user's plain password with a hash from the default hasher
+func HashUserPassword(user User) (User, error) {
+	hash, err := globals.PasswordHasher.Hash(user.Password)
 	if err != nil {
 		log.Error().Err(err).Msg("Failed to hash password")
+		return user, err
 	}
-	// GenerateFromPassword returns a byte slice so we need to
-	// convert the bytes to a string and return it
-	user.Password = string(hash)
+	user.Password = hash
 
-	return user
+	return user, nil
 }
 
 func ComparePasswords(hashedPwd string, plainPwd string) bool {
-	byteHash := []byte(hashedPwd)
-	bytePlain := []byte(plainPwd)
+	ok, _, err := globals.PasswordHasher.Verify(hashedPwd, plainPwd)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to compare passwords")
+		return false
+	}
+	return ok
+}
 
-	err := bcrypt.CompareHashAndPassword(byteHash, bytePlain)
+// CheckUserPassword compares plainPwd with the user's password. A match stored with an
+// outdated algorithm or cost is rehashed in place while the plain password is at hand.
+func CheckUserPassword(user User, plainPwd string) bool {
+	ok, rehash, err := globals.PasswordHasher.Verify(user.Password, plainPwd)
 	if err != nil {
 		log.Error().Err(err).Msg("Failed to compare passwords")
 		return false
 	}
+	if !ok || !rehash {
+		return ok
+	}
+
+	hash, err := globals.PasswordHasher.Hash(plainPwd)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to rehash password")
+		return true
+	}
+
+	// only replace the hash that was checked, a password change in between wins
+	collection := globals.MongoClient.Database(Database).Collection("Users")
+	filter := bson.M{"_id": user.ID, "password": user.Password}
+	update := bson.M{"$set": bson.M{"password": hash}}
+	if _, err := collection.UpdateOne(context.TODO(), filter, update); err != nil {
+		log.Error().Err(err).Msg("Failed to store rehashed password")
+	}
 	return true
 }
 
 func CreateUser(user User) error {
+	// hash password, a user is never stored without one
+	user, err := HashUserPassword(user)
+	if err != nil {
+		return err
+	}
+	user.EmailVerified = false
+	user.Identities = []Identity{}
+
+	_, err = insertUser(user)
+	return err
+}
+
+// insertUser stores a new user with a fresh ID and nothing carried over from the request
+func insertUser(user User) (User, error) {
 	collection := globals.MongoClient.Database(Database).Collection("Users")
 
-	// hash password
-	user = HashUserPassword(user)
 	user.ID = bson.NewObjectID()
 	user.RefreshToken = ""
+	user.PendingEmail = ""
+	user.TwoFactor = TwoFactor{}
 
 	// set default empty arrays
 	user.Contacts = []Contact{}
 	user.GroupIDs = []bson.ObjectID{}
 	user.GroupRequests = []GroupRequest{}
 
-	// remove contacts from user object
-
 	inserted, err := collection.InsertOne(context.TODO(), user)
 	if err != nil {
 		log.Error().Err(err).Msg("Failed to insert user")
+		return User{}, err
 	}
 	log.Info().Msgf("Inserted user with ID %v", inserted.InsertedID)
-	return err
+	return user, nil
 }
 
 func UpdateUserPassword(id string, password string) error {
 	collection := globals.MongoClient.Database(Database).Collection("Users")
 
 	// hash password
-	user := User{Password: password}
-	user = HashUserPassword(user)
+	user, err := HashUserPassword(User{Password: password})
+	if err != nil {
+		return err
+	}
 
 	i, err := bson.ObjectIDFromHex(id)
 	if err != nil {
@@ -225,6 +276,101 @@ func UpdateUserPassword(id string, password string) error {
 	return err
 }
 
+// UpdateUserProfile saves the details other users see of a user
+func UpdateUserProfile(id string, firstName string, lastName string, bio string, avatarImage string) error {
+	collection := globals.MongoClient.Database(Database).Collection("Users")
+
+	i, err := bson.ObjectIDFromHex(id)
+	if err != nil {
+		return ErrUserNotFound
+	}
+	update := bson.M{"$set": bson.M{
+		"first_name":   firstName,
+		"last_name":    lastName,
+		"bio":          bio,
+		"avatar_image": avatarImage,
+	}}
+
+	result, err := collection.UpdateOne(context.TODO(), bson.M{"_id": i}, update)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to update user profile")
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrUserNotFound
+	}
+	return nil
+}
+
+func VerifyUserEmail(email string) error {
+	collection := globals.MongoClient.Database(Database).Collection("Users")
+
+	filter := bson.M{"email": email}
+	update := bson.M{"$set": bson.M{"email_verified": true}}
+
+	result, err := collection.UpdateOne(context.TODO(), filter, update)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to verify user email")
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrUserNotFound
+	}
+	return nil
+}
+
+func SetUserPendingEmail(id string, email string) error {
+	collection := globals.MongoClient.Database(Database).Collection("Users")
+
+	i, err := bson.ObjectIDFromHex(id)
+	if err != nil {
+		return err
+	}
+	filter := bson.M{"_id": i}
+	update := bson.M{"$set": bson.M{"pending_email": email}}
+
+	result, err := collection.UpdateOne(context.TODO(), filter, update)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to set user pending email")
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrUserNotFound
+	}
+	return nil
+}
+
+// SwapUserPendingEmail replaces a user's email with their verified pending email
+func SwapUserPendingEmail(id string, email string) error {
+	collection := globals.MongoClient.Database(Database).Collection("Users")
+
+	i, err := bson.ObjectIDFromHex(id)
+	if err != nil {
+		return err
+	}
+
+	// someone may have registered the address since the change was asked for
+	if ReadByUserEmail(email).ID != bson.NilObjectID {
+		return ErrEmailTaken
+	}
+
+	filter := bson.M{"_id": i, "pending_email": email}
+	update := bson.M{
+		"$set":   bson.M{"email": email, "email_verified": true},
+		"$unset": bson.M{"pending_email": ""},
+	}
+
+	result, err := collection.UpdateOne(context.TODO(), filter, update)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to swap user email")
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrUserNotFound
+	}
+	return nil
+}
+
 func DeleteUser(id string) error {
 	collection := globals.MongoClient.Database(Database).Collection("Users")
 
@@ -238,13 +384,19 @@ func DeleteUser(id string) error {
 	return err
 }
 
+const (
+	GROUP_REQUEST_PENDING  int8 = 0
+	GROUP_REQUEST_ACCEPTED int8 = 1
+	GROUP_REQUEST_REJECTED int8 = 2
+)
+
 func enumRequestStatus(status int8) string {
 	switch status {
-	case 0:
+	case GROUP_REQUEST_PENDING:
 		return "Pending"
-	case 1:
+	case GROUP_REQUEST_ACCEPTED:
 		return "Accepted"
-	case 2:
+	case GROUP_REQUEST_REJECTED:
 		return "Rejected"
 	default:
 		return "Unknown"
@@ -287,7 +439,7 @@ func CreateGroupRequest(
 		GroupName:     groupName,
 		Message:       message,
 		Timestamp:     bson.Timestamp{},
-		Status:        0,
+		Status:        GROUP_REQUEST_PENDING,
 	}
 
 	updateResult, err := collection.UpdateOne(
@@ -309,18 +461,11 @@ func CreateGroupRequest(
 }
 
 func AcceptGroupRequest(userID string, groupID string) error {
-	collection := globals.MongoClient.Database(Database).Collection("Users")
-
-	i, _ := bson.ObjectIDFromHex(groupID)
-	j, _ := bson.ObjectIDFromHex(userID)
-
 	// Update Request Status
-	filter := bson.D{{"group_id", i}, {"receive_user_id", j}}
-	update := bson.D{{"$set", bson.D{{"status", 1}}}}
-
-	_, err := collection.UpdateOne(context.TODO(), filter, update)
+	err := setGroupRequestStatus(userID, groupID, GROUP_REQUEST_ACCEPTED)
 	if err != nil {
 		log.Error().Err(err).Msg("Failed to accept message group request")
+		return err
 	}
 
 	// Add user to group
@@ -333,16 +478,8 @@ func AcceptGroupRequest(userID string, groupID string) error {
 }
 
 func RejectGroupRequest(userID string, groupID string) error {
-	collection := globals.MongoClient.Database(Database).Collection("Users")
-
-	i, _ := bson.ObjectIDFromHex(groupID)
-	j, _ := bson.ObjectIDFromHex(userID)
-
 	// Update Request Status
-	filter := bson.D{{"group_id", i}, {"receive_user_id", j}}
-	update := bson.D{{"$set", bson.D{{"status", 2}}}}
-
-	_, err := collection.UpdateOne(context.TODO(), filter, update)
+	err := setGroupRequestStatus(userID, groupID, GROUP_REQUEST_REJECTED)
 	if err != nil {
 		log.Error().Err(err).Msg("Failed to reject message group request")
 	}
@@ -350,6 +487,38 @@ func RejectGroupRequest(userID string, groupID string) error {
 	return err
 }
 
+func setGroupRequestStatus(userID string, groupID string, status int8) error {
+	// Answer the pending request a user holds for a group
+	collection := globals.MongoClient.Database(Database).Collection("Users")
+
+	i, err := bson.ObjectIDFromHex(groupID)
+	if err != nil {
+		return err
+	}
+	j, err := bson.ObjectIDFromHex(userID)
+	if err != nil {
+		return err
+	}
+
+	filter := bson.M{
+		"_id": j,
+		"group_requests": bson.M{"$elemMatch": bson.M{
+			"group_id": i,
+			"status":   GROUP_REQUEST_PENDING,
+		}},
+	}
+	update := bson.M{"$set": bson.M{"group_requests.$.status": status}}
+
+	result, err := collection.UpdateOne(context.TODO(), filter, update)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return errors.New("no pending group request found")
+	}
+	return nil
+}
+
 func UserExists(id string) bool {
 	i, _ := bson.ObjectIDFromHex(id)
 	filter := bson.D{{"_id", i}}
@@ -1,7 +1,13 @@
 package globals
 
 import (
-	"Rivall-Backend/util/password_recovery"
+	"Rivall-Backend/util/client_ip"
+	"Rivall-Backend/util/keyring"
+	"Rivall-Backend/util/mailer"
+	"Rivall-Backend/util/oidc"
+	"Rivall-Backend/util/otp"
+	"Rivall-Backend/util/password_hasher"
+	"Rivall-Backend/util/rate_limiter"
 	"Rivall-Backend/util/session_manager"
 
 	"github.com/go-playground/validator/v10"
@@ -12,6 +18,14 @@ import (
 var Logger *zerolog.Logger
 var Validator *validator.Validate
 var MongoClient *mongo.Client
-var JWTSecretKey string
+var Keyring *keyring.Keyring
+var Mailer *mailer.Mailer
 var SessionManager *session_manager.Sessions
-var PasswordRecoveryMap *password_recovery.RecoveryRetentionMap
+var OTP *otp.Service
+var PasswordHasher *password_hasher.Hashers
+var OIDCProviders map[string]*oidc.Provider
+var ClientIP *client_ip.Resolver
+var LoginLimiter *rate_limiter.Limiter
+var RecoveryLimiter *rate_limiter.Limiter
+var VerificationLimiter *rate_limiter.Limiter
+var RegistrationLimiter *rate_limiter.Limiter
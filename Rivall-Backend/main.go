@@ -9,14 +9,23 @@ import (
 	"syscall"
 	"time"
 
+	"Rivall-Backend/api/resources"
 	"Rivall-Backend/api/router"
+	"Rivall-Backend/api/websocket"
 	"Rivall-Backend/config"
+	"Rivall-Backend/db"
 	"Rivall-Backend/globals"
+	"Rivall-Backend/util/client_ip"
+	"Rivall-Backend/util/keyring"
 	"Rivall-Backend/util/logger"
-	"Rivall-Backend/util/password_recovery"
+	"Rivall-Backend/util/mailer"
+	"Rivall-Backend/util/oidc"
+	"Rivall-Backend/util/otp"
+	"Rivall-Backend/util/password_hasher"
+	"Rivall-Backend/util/rate_limiter"
 	"Rivall-Backend/util/session_manager"
+	"Rivall-Backend/util/validator"
 
-	"github.com/go-playground/validator/v10"
 	"github.com/rs/zerolog/log"
 	"go.mongodb.org/mongo-driver/v2/bson"
 	"go.mongodb.org/mongo-driver/v2/mongo"
@@ -54,37 +63,200 @@ func DisconnectMongoDB(MongoClient *mongo.Client) {
 	log.Info().Msg("MongoDB disconnected")
 }
 
-// func sendEmail() {
-// 	message := mail.NewMsg()
-// 	if err := message.From("rivall@gmail.com"); err != nil {
-// 		log.Error().Err(err).Msg("Failed to set sender")
-// 		return
-// 	}
-// 	if err := message.To("nathaniel.jacob.reeves@gmail.com"); err != nil {
-// 		log.Error().Err(err).Msg("Failed to set recipient")
-// 		return
-// 	}
-// 	message.Subject("Test Email")
-// 	message.SetBodyString(mail.TypeTextPlain, "This is a test email")
-// 	client, err := mail.NewClient("smtp.example.com", mail.WithSMTPAuth(mail.SMTPAuthPlain),
-// 		mail.WithUsername("my_username"), mail.WithPassword("extremely_secret_pass"))
-// 	if err != nil {
-// 		log.Error().Err(err).Msg("Failed to create mail client")
-// 	}
-// 	if err := client.DialAndSend(message); err != nil {
-// 		log.Error().Err(err).Msg("Failed to send mail")
-// 	}
-// }
+func NewMailer(ctx context.Context, c *config.Conf) *mailer.Mailer {
+	// Send mail over SMTP in production, write it out locally in development
+	var backend mailer.Backend
+	switch c.Mail.Backend {
+	case "smtp":
+		smtp, err := mailer.NewSMTPBackend(c.Mail.SMTPHost, c.Mail.SMTPPort, c.Mail.SMTPUsername, c.Mail.SMTPPassword, c.Mail.SMTPTLS, c.Mail.From)
+		if err != nil {
+			log.Fatal().Err(err).Msg("Failed to create mail client")
+		}
+		backend = smtp
+	case "file":
+		f, err := os.OpenFile(c.Mail.File, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+		if err != nil {
+			log.Fatal().Err(err).Msg("Failed to open mail file")
+		}
+		backend = mailer.NewWriterBackend(f)
+	default:
+		backend = mailer.NewWriterBackend(os.Stdout)
+	}
 
-func main() {
+	m := mailer.New(backend, c.Mail.MaxAttempts, c.Mail.RetryDelay)
+	go m.Run(ctx)
+	return m
+}
+
+func NewSessionStore(ctx context.Context, c *config.Conf) session_manager.Store {
+	// Keep sessions in MongoDB unless running a single development instance
+	if c.Server.SessionStore == "memory" {
+		log.Warn().Msg("Using in memory session store, sessions are lost on restart")
+		return session_manager.NewMemoryStore(ctx)
+	}
+
+	store, err := session_manager.NewMongoStore(ctx, globals.MongoClient.Database(db.Database))
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to initialize session store")
+	}
+	return store
+}
+
+func NewOTP(ctx context.Context, c *config.Conf) *otp.Service {
+	// Codes and attempts are shared the same way as sessions, a code mailed by one replica
+	// has to work on the others and guesses count on all of them
+	var store otp.Store
+	if c.Server.SessionStore == "memory" {
+		store = otp.NewMemoryStore(ctx)
+	} else {
+		var err error
+		store, err = otp.NewMongoStore(ctx, globals.MongoClient.Database(db.Database))
+		if err != nil {
+			log.Fatal().Err(err).Msg("Failed to initialize one-time code store")
+		}
+	}
+
+	service, err := otp.New(otp.DefaultConfig(), store, []byte(c.Server.OTPSecret))
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to initialize one-time codes")
+	}
+	return service
+}
+
+func NewKeyring(ctx context.Context, c *config.Conf) *keyring.Keyring {
+	// Replaced keys must verify for as long as the tokens they signed can live
+	if c.Server.JWTKeyGrace < session_manager.REFRESH_TOKEN_TIMEOUT {
+		log.Fatal().Msgf("JWT_KEY_GRACE must be at least %v", session_manager.REFRESH_TOKEN_TIMEOUT)
+	}
+
+	// Keys are shared the same way as sessions, tokens have to verify on every replica
+	var store keyring.Store
+	if c.Server.SessionStore == "memory" {
+		store = keyring.NewMemoryStore()
+	} else {
+		store = keyring.NewMongoStore(globals.MongoClient.Database(db.Database).Collection("SigningKeys"))
+	}
+
+	keys, err := keyring.New(ctx, c.Server.JWTAlgorithm, c.Server.JWTKeyRotation, c.Server.JWTKeyGrace, store)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to initialize signing keys")
+	}
+	go keys.Run(ctx)
+
+	return keys
+}
+
+// NewPasswordHasher hashes new passwords with PASSWORD_HASHER and keeps the other hasher
+// to verify older hashes until they are upgraded on login
+func NewPasswordHasher(c *config.Conf) *password_hasher.Hashers {
+	bcrypt := password_hasher.Bcrypt{Cost: c.Password.BcryptCost}
+	argon2id := password_hasher.DefaultArgon2id()
+	argon2id.Memory = c.Password.Argon2Memory
+	argon2id.Iterations = c.Password.Argon2Iterations
+	argon2id.Parallelism = c.Password.Argon2Parallelism
+
+	if c.Password.Hasher == "bcrypt" {
+		return password_hasher.New(bcrypt, argon2id)
+	}
+	return password_hasher.New(argon2id, bcrypt)
+}
+
+// maxPasswordBytes is the limit of the hasher new passwords are hashed with
+func maxPasswordBytes(c *config.Conf) int {
+	if c.Password.Hasher == "bcrypt" {
+		return password_hasher.BCRYPT_MAX_BYTES
+	}
+	return 0
+}
+
+// NewOIDCProviders discovers the configured OpenID Connect providers, keyed by name
+func NewOIDCProviders(ctx context.Context, c *config.Conf) map[string]*oidc.Provider {
+	providers := make(map[string]*oidc.Provider)
+	for _, provider := range c.OIDC.Providers {
+		discovered, err := oidc.Discover(ctx, oidc.Config{
+			Name:         provider.Name,
+			Issuer:       provider.Issuer,
+			ClientID:     provider.ClientID,
+			ClientSecret: provider.ClientSecret,
+			RedirectURL:  provider.RedirectURL,
+		}, nil)
+		if err != nil {
+			log.Fatal().Err(err).Msgf("Failed to discover OIDC provider %s", provider.Name)
+		}
+		providers[provider.Name] = discovered
+	}
+	return providers
+}
+
+// NewClientIP reads client addresses from X-Forwarded-For only behind TRUSTED_PROXIES
+func NewClientIP(c *config.Conf) *client_ip.Resolver {
+	resolver, err := client_ip.New(c.Server.TrustedProxies)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to parse TRUSTED_PROXIES")
+	}
+	return resolver
+}
 
-	// Send Email
-	// sendEmail()
+func NewRateLimiters(ctx context.Context, c *config.Conf) (*rate_limiter.Limiter, *rate_limiter.Limiter, *rate_limiter.Limiter, *rate_limiter.Limiter) {
+	// Lockouts are shared the same way as sessions, so replicas can't be rotated through
+	var store rate_limiter.Store
+	if c.Server.SessionStore == "memory" {
+		store = rate_limiter.NewMemoryStore(ctx)
+	} else {
+		var err error
+		store, err = rate_limiter.NewMongoStore(ctx, globals.MongoClient.Database(db.Database).Collection("RateLimits"))
+		if err != nil {
+			log.Fatal().Err(err).Msg("Failed to initialize rate limit store")
+		}
+	}
+
+	audit := func(lockout rate_limiter.Lockout) {
+		log.Warn().Msgf("%s locked out %s %s after %d failures", lockout.Limiter, lockout.Kind, lockout.Value, lockout.Failures)
+		db.CreateAuditEntry(db.AUDIT_LOCKOUT, lockout.Value, bson.M{
+			"limiter":      lockout.Limiter,
+			"kind":         lockout.Kind,
+			"failures":     lockout.Failures,
+			"locked_until": lockout.LockedUntil,
+		})
+	}
+
+	login := rate_limiter.New(store, "login",
+		rate_limiter.Policy{Threshold: 20, BaseDelay: time.Minute, MaxDelay: time.Hour, Window: time.Hour},
+		rate_limiter.Policy{Threshold: 5, BaseDelay: time.Second * 30, MaxDelay: time.Minute * 30, Window: time.Hour},
+		audit,
+	)
+	recovery := rate_limiter.New(store, "recovery",
+		rate_limiter.Policy{Threshold: 10, BaseDelay: time.Minute, MaxDelay: time.Hour, Window: time.Hour},
+		rate_limiter.Policy{Threshold: 5, BaseDelay: time.Minute, MaxDelay: time.Hour, Window: time.Hour},
+		audit,
+	)
+	verification := rate_limiter.New(store, "verification",
+		rate_limiter.Policy{Threshold: 10, BaseDelay: time.Minute, MaxDelay: time.Hour, Window: time.Hour},
+		rate_limiter.Policy{Threshold: 5, BaseDelay: time.Minute, MaxDelay: time.Hour, Window: time.Hour},
+		audit,
+	)
+	// every registration counts, not just failed ones
+	registration := rate_limiter.New(store, "registration",
+		rate_limiter.Policy{Threshold: 10, BaseDelay: time.Minute * 10, MaxDelay: time.Hour * 24, Window: time.Hour * 24},
+		rate_limiter.Policy{},
+		audit,
+	)
+
+	return login, recovery, verification, registration
+}
+
+func main() {
 
 	// Initialize logger, validator, and config
 	c := config.New()
 	logLevel := c.Server.Debug
 	log := logger.New(logLevel, c)
+	validator.SetPasswordPolicy(validator.PasswordPolicy{
+		MinLength:  c.Password.MinLength,
+		MaxLength:  c.Password.MaxLength,
+		MaxBytes:   maxPasswordBytes(c),
+		MinClasses: c.Password.MinClasses,
+	})
 	val := validator.New()
 	log.Info().Msg("Building Rivall Backend API...")
 
@@ -95,9 +267,19 @@ func main() {
 	globals.Logger = log
 	globals.Validator = val
 	globals.MongoClient = ConnectMongoDB(ctx, c)
-	globals.JWTSecretKey = c.Server.JWTSecretKey
-	globals.SessionManager = session_manager.NewSessionsManager(ctx, c.Server.JWTSecretKey)
-	globals.PasswordRecoveryMap = password_recovery.NewRecoveryRetentionMap(ctx)
+	if err := db.Migrate(ctx); err != nil {
+		log.Fatal().Err(err).Msg("Failed to migrate database")
+	}
+	globals.Keyring = NewKeyring(ctx, c)
+	globals.SessionManager = session_manager.NewSessionsManager(globals.Keyring, c.Server.JWTIssuer, c.Server.JWTAudience, NewSessionStore(ctx, c))
+	globals.OTP = NewOTP(ctx, c)
+	globals.PasswordHasher = NewPasswordHasher(c)
+	globals.OIDCProviders = NewOIDCProviders(ctx, c)
+	globals.ClientIP = NewClientIP(c)
+	globals.LoginLimiter, globals.RecoveryLimiter, globals.VerificationLimiter, globals.RegistrationLimiter = NewRateLimiters(ctx, c)
+	globals.Mailer = NewMailer(ctx, c)
+	go websocket.WSManager.RunAccountDeletions(ctx)
+	go resources.RunDataExports(ctx)
 
 	// Initialize router
 	r := router.New()
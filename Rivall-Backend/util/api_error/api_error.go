@@ -0,0 +1,99 @@
+// Package api_error is the error body shared by every REST response and websocket error event
+package api_error
+
+import (
+	"encoding/json"
+	"net/http"
+
+	ctxUtil "Rivall-Backend/util/ctx"
+	"Rivall-Backend/util/validator"
+)
+
+// Codes are stable, clients branch on them rather than on messages
+const (
+	BAD_REQUEST         = "bad_request"
+	INVALID_JSON        = "invalid_json"
+	VALIDATION_FAILED   = "validation_failed"
+	UNAUTHORIZED        = "unauthorized"
+	INVALID_TOKEN       = "invalid_token"
+	INVALID_CREDENTIALS = "invalid_credentials"
+	INVALID_CODE        = "invalid_code"
+	FORBIDDEN           = "forbidden"
+	EMAIL_NOT_VERIFIED  = "email_not_verified"
+	NOT_FOUND           = "not_found"
+	USER_NOT_FOUND      = "user_not_found"
+	GROUP_NOT_FOUND     = "group_not_found"
+	NOT_GROUP_MEMBER    = "not_group_member"
+	CONFLICT            = "conflict"
+	EMAIL_TAKEN         = "email_taken"
+	GONE                = "gone"
+	TOO_LARGE           = "too_large"
+	RATE_LIMITED        = "rate_limited"
+	UNSUPPORTED_EVENT   = "unsupported_event"
+	INTERNAL            = "internal_error"
+)
+
+type Error struct {
+	Code    string                 `json:"code"`
+	Message string                 `json:"message"`
+	Fields  []validator.FieldError `json:"fields,omitempty"`
+	// RequestID matches the X-Request-ID of the request in the logs
+	RequestID string `json:"request_id,omitempty"`
+}
+
+// Response is the body of every REST error
+type Response struct {
+	Error Error `json:"error"`
+}
+
+// CodeForStatus is the code of an error that has nothing more specific to say than its status
+func CodeForStatus(status int) string {
+	switch status {
+	case http.StatusBadRequest:
+		return BAD_REQUEST
+	case http.StatusUnauthorized:
+		return UNAUTHORIZED
+	case http.StatusForbidden:
+		return FORBIDDEN
+	case http.StatusNotFound:
+		return NOT_FOUND
+	case http.StatusConflict:
+		return CONFLICT
+	case http.StatusGone:
+		return GONE
+	case http.StatusRequestEntityTooLarge:
+		return TOO_LARGE
+	case http.StatusUnprocessableEntity:
+		return VALIDATION_FAILED
+	case http.StatusTooManyRequests:
+		return RATE_LIMITED
+	default:
+		return INTERNAL
+	}
+}
+
+// WriteError writes e with the request's ID
+func WriteError(w http.ResponseWriter, r *http.Request, status int, e Error) {
+	e.RequestID = ctxUtil.RequestID(r.Context())
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(Response{Error: e})
+}
+
+func Write(w http.ResponseWriter, r *http.Request, status int, code string, message string) {
+	WriteError(w, r, status, Error{Code: code, Message: message})
+}
+
+// WriteValidation writes the field errors of a failed validation as a 422
+func WriteValidation(w http.ResponseWriter, r *http.Request, err error) {
+	fields := validator.ToFieldErrors(err)
+	if fields == nil {
+		Write(w, r, http.StatusBadRequest, BAD_REQUEST, "Invalid request.")
+		return
+	}
+	WriteError(w, r, http.StatusUnprocessableEntity, Error{
+		Code:    VALIDATION_FAILED,
+		Message: "The request has invalid fields.",
+		Fields:  fields,
+	})
+}
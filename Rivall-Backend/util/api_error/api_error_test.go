@@ -0,0 +1,69 @@
+package api_error_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"Rivall-Backend/util/api_error"
+	ctxUtil "Rivall-Backend/util/ctx"
+	"Rivall-Backend/util/test"
+	"Rivall-Backend/util/validator"
+)
+
+func decode(t *testing.T, w *httptest.ResponseRecorder) api_error.Error {
+	var res api_error.Response
+	test.NoError(t, json.NewDecoder(w.Body).Decode(&res))
+	return res.Error
+}
+
+func TestWrite(t *testing.T) {
+	t.Parallel()
+
+	r, _ := http.NewRequest(http.MethodGet, "/", nil)
+	r = r.WithContext(ctxUtil.SetRequestID(r.Context(), "9m4e2mr0ui3e8a215n4g"))
+	w := httptest.NewRecorder()
+	api_error.Write(w, r, http.StatusNotFound, api_error.USER_NOT_FOUND, "User does not exist.")
+
+	test.Equal(t, w.Code, http.StatusNotFound)
+	test.Equal(t, w.Header().Get("Content-Type"), "application/json")
+	res := decode(t, w)
+	test.Equal(t, res.Code, api_error.USER_NOT_FOUND)
+	test.Equal(t, res.Message, "User does not exist.")
+	test.Equal(t, res.RequestID, "9m4e2mr0ui3e8a215n4g")
+	test.Equal(t, len(res.Fields), 0)
+}
+
+func TestWriteValidation(t *testing.T) {
+	t.Parallel()
+
+	req := struct {
+		Name  string `json:"name"  form:"required"`
+		Inner struct {
+			Bio string `json:"bio" form:"max=3"`
+		} `json:"inner"`
+	}{}
+	req.Inner.Bio = "too long"
+	err := validator.New().Struct(req)
+
+	r, _ := http.NewRequest(http.MethodPost, "/", nil)
+	w := httptest.NewRecorder()
+	api_error.WriteValidation(w, r, err)
+
+	test.Equal(t, w.Code, http.StatusUnprocessableEntity)
+	res := decode(t, w)
+	test.Equal(t, res.Code, api_error.VALIDATION_FAILED)
+	test.Equal(t, len(res.Fields), 2)
+	test.Equal(t, res.Fields[0].Field, "name")
+	test.Equal(t, res.Fields[0].Message, "name is a required field")
+	test.Equal(t, res.Fields[1].Field, "inner.bio")
+}
+
+func TestCodeForStatus(t *testing.T) {
+	t.Parallel()
+
+	test.Equal(t, api_error.CodeForStatus(http.StatusBadRequest), api_error.BAD_REQUEST)
+	test.Equal(t, api_error.CodeForStatus(http.StatusTooManyRequests), api_error.RATE_LIMITED)
+	test.Equal(t, api_error.CodeForStatus(http.StatusBadGateway), api_error.INTERNAL)
+}
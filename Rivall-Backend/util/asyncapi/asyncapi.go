@@ -0,0 +1,118 @@
+package asyncapi
+
+import (
+	"slices"
+
+	"Rivall-Backend/util/openapi"
+)
+
+const VERSION = "2.6.0"
+
+// Document is an AsyncAPI 2 document, only the parts the websocket uses are modelled
+type Document struct {
+	AsyncAPI           string             `json:"asyncapi"`
+	Info               openapi.Info       `json:"info"`
+	DefaultContentType string             `json:"defaultContentType"`
+	Channels           map[string]Channel `json:"channels"`
+	Components         Components         `json:"components"`
+}
+
+// Channel is where messages are exchanged. Publish lists the messages clients send and
+// Subscribe the ones they receive
+type Channel struct {
+	Description string               `json:"description,omitempty"`
+	Parameters  map[string]Parameter `json:"parameters,omitempty"`
+	Publish     *Operation           `json:"publish,omitempty"`
+	Subscribe   *Operation           `json:"subscribe,omitempty"`
+}
+
+type Parameter struct {
+	Description string          `json:"description,omitempty"`
+	Schema      *openapi.Schema `json:"schema"`
+}
+
+type Operation struct {
+	OperationID string  `json:"operationId"`
+	Summary     string  `json:"summary,omitempty"`
+	Message     Message `json:"message"`
+}
+
+// Message is a message definition, or a reference to one, or a choice between several
+type Message struct {
+	Ref     string          `json:"$ref,omitempty"`
+	OneOf   []Message       `json:"oneOf,omitempty"`
+	Name    string          `json:"name,omitempty"`
+	Summary string          `json:"summary,omitempty"`
+	Payload *openapi.Schema `json:"payload,omitempty"`
+}
+
+type Components struct {
+	Schemas  map[string]*openapi.Schema `json:"schemas"`
+	Messages map[string]Message         `json:"messages"`
+}
+
+// Generator builds a Document out of the messages of a single channel
+type Generator struct {
+	doc       Document
+	channel   string
+	schemas   *openapi.Schemas
+	publish   []string
+	subscribe []string
+}
+
+// New starts a document with one channel, its operations are filled in by Publish and Subscribe
+func New(info openapi.Info, channel string, description Channel) *Generator {
+	return &Generator{
+		doc: Document{
+			AsyncAPI:           VERSION,
+			Info:               info,
+			DefaultContentType: "application/json",
+			Channels:           map[string]Channel{channel: description},
+			Components:         Components{Messages: make(map[string]Message)},
+		},
+		channel: channel,
+		schemas: openapi.NewSchemas(),
+	}
+}
+
+// Schema returns the schema of v's type, named structs are added to the components
+func (g *Generator) Schema(v any) *openapi.Schema {
+	return g.schemas.Of(v)
+}
+
+// Publish adds a message clients send on the channel
+func (g *Generator) Publish(message Message) {
+	g.doc.Components.Messages[message.Name] = message
+	g.publish = append(g.publish, message.Name)
+}
+
+// Subscribe adds a message clients receive on the channel
+func (g *Generator) Subscribe(message Message) {
+	g.doc.Components.Messages[message.Name] = message
+	g.subscribe = append(g.subscribe, message.Name)
+}
+
+// Document returns the document described so far
+func (g *Generator) Document() Document {
+	doc := g.doc
+	doc.Components.Schemas = g.schemas.Components()
+
+	channel := doc.Channels[g.channel]
+	channel.Publish = operation("send", "Messages clients send", g.publish)
+	channel.Subscribe = operation("receive", "Messages clients receive", g.subscribe)
+	doc.Channels = map[string]Channel{g.channel: channel}
+	return doc
+}
+
+func operation(operationID string, summary string, names []string) *Operation {
+	if len(names) == 0 {
+		return nil
+	}
+	names = slices.Sorted(slices.Values(names))
+
+	op := &Operation{OperationID: operationID, Summary: summary}
+	for _, name := range names {
+		op.Message.OneOf = append(op.Message.OneOf, Message{Ref: "#/components/messages/" + name})
+	}
+	return op
+}
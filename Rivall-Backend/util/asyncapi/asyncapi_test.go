@@ -0,0 +1,38 @@
+package asyncapi_test
+
+import (
+	"testing"
+
+	"Rivall-Backend/util/asyncapi"
+	"Rivall-Backend/util/openapi"
+	"Rivall-Backend/util/test"
+)
+
+type sendReq struct {
+	Text string `json:"text" form:"required,max=280"`
+}
+
+type newRes struct {
+	Text string `json:"text"`
+}
+
+func TestDocument(t *testing.T) {
+	t.Parallel()
+
+	g := asyncapi.New(openapi.Info{Title: "Test", Version: "1"}, "/ws", asyncapi.Channel{Description: "Chat"})
+	g.Publish(asyncapi.Message{Name: "send", Payload: g.Schema(sendReq{})})
+	g.Subscribe(asyncapi.Message{Name: "new_b", Payload: g.Schema(newRes{})})
+	g.Subscribe(asyncapi.Message{Name: "new_a", Payload: g.Schema(newRes{})})
+	doc := g.Document()
+
+	channel := doc.Channels["/ws"]
+	test.Equal(t, channel.Description, "Chat")
+	test.Equal(t, len(channel.Publish.Message.OneOf), 1)
+	test.Equal(t, channel.Publish.Message.OneOf[0].Ref, "#/components/messages/send")
+	test.Equal(t, len(channel.Subscribe.Message.OneOf), 2)
+	test.Equal(t, channel.Subscribe.Message.OneOf[0].Ref, "#/components/messages/new_a")
+
+	test.Equal(t, doc.Components.Messages["send"].Payload.Ref, "#/components/schemas/sendReq")
+	test.Equal(t, doc.Components.Schemas["sendReq"].Required[0], "text")
+	test.Equal(t, *doc.Components.Schemas["sendReq"].Properties["text"].MaxLength, 280)
+}
@@ -0,0 +1,87 @@
+package client_ip
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+// Resolver finds the address of the client a request came from. X-Forwarded-For is only
+// read when the connection comes from a trusted proxy, anyone else could write any address
+// into it. A nil Resolver trusts no proxy.
+type Resolver struct {
+	trusted []netip.Prefix
+}
+
+// New trusts the proxies listed as addresses or CIDR ranges
+func New(trustedProxies []string) (*Resolver, error) {
+	r := &Resolver{}
+	for _, proxy := range trustedProxies {
+		proxy = strings.TrimSpace(proxy)
+		if proxy == "" {
+			continue
+		}
+		if !strings.Contains(proxy, "/") {
+			addr, err := netip.ParseAddr(proxy)
+			if err != nil {
+				return nil, fmt.Errorf("invalid trusted proxy %q: %w", proxy, err)
+			}
+			r.trusted = append(r.trusted, netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()))
+			continue
+		}
+		prefix, err := netip.ParsePrefix(proxy)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy %q: %w", proxy, err)
+		}
+		r.trusted = append(r.trusted, prefix.Masked())
+	}
+	return r, nil
+}
+
+func (r *Resolver) isTrusted(addr netip.Addr) bool {
+	if r == nil {
+		return false
+	}
+	addr = addr.Unmap()
+	for _, prefix := range r.trusted {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// IP returns the client address of req. Behind trusted proxies it is the right-most
+// forwarded address that isn't a trusted proxy, the addresses left of it were written by
+// the client and can't be believed.
+func (r *Resolver) IP(req *http.Request) string {
+	host, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		host = req.RemoteAddr
+	}
+	remote, err := netip.ParseAddr(host)
+	if err != nil || !r.isTrusted(remote) {
+		return host
+	}
+
+	var hops []string
+	for _, header := range req.Header.Values("X-Forwarded-For") {
+		hops = append(hops, strings.Split(header, ",")...)
+	}
+
+	client := remote
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop, err := netip.ParseAddr(strings.TrimSpace(hops[i]))
+		if err != nil {
+			// a trusted proxy never writes garbage, stop at the last address it vouched for
+			break
+		}
+		client = hop
+		if !r.isTrusted(hop) {
+			break
+		}
+	}
+	return client.Unmap().String()
+}
@@ -0,0 +1,58 @@
+package client_ip_test
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"Rivall-Backend/util/client_ip"
+	"Rivall-Backend/util/test"
+)
+
+func TestIP(t *testing.T) {
+	t.Parallel()
+
+	resolver, err := client_ip.New([]string{"10.0.0.0/8", "192.168.1.1"})
+	test.NoError(t, err)
+
+	tests := []struct {
+		name      string
+		remote    string
+		forwarded []string
+		ip        string
+	}{
+		{name: "no proxy", remote: "203.0.113.7:4000", ip: "203.0.113.7"},
+		{name: "spoofed without proxy", remote: "203.0.113.7:4000", forwarded: []string{"198.51.100.1"}, ip: "203.0.113.7"},
+		{name: "through a proxy", remote: "10.0.0.2:4000", forwarded: []string{"198.51.100.1"}, ip: "198.51.100.1"},
+		{name: "spoofed through a proxy", remote: "10.0.0.2:4000", forwarded: []string{"1.2.3.4, 198.51.100.1"}, ip: "198.51.100.1"},
+		{name: "through two proxies", remote: "10.0.0.2:4000", forwarded: []string{"1.2.3.4, 198.51.100.1", "192.168.1.1"}, ip: "198.51.100.1"},
+		{name: "garbage from the client", remote: "10.0.0.2:4000", forwarded: []string{"not an ip, 198.51.100.1"}, ip: "198.51.100.1"},
+		{name: "garbage from the proxy", remote: "10.0.0.2:4000", forwarded: []string{"198.51.100.1, not an ip"}, ip: "10.0.0.2"},
+		{name: "only proxies", remote: "10.0.0.2:4000", forwarded: []string{"10.0.0.3"}, ip: "10.0.0.3"},
+		{name: "proxy without header", remote: "10.0.0.2:4000", ip: "10.0.0.2"},
+		{name: "ipv6", remote: "[2001:db8::1]:4000", forwarded: []string{"198.51.100.1"}, ip: "2001:db8::1"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/", nil)
+			r.RemoteAddr = tc.remote
+			for _, header := range tc.forwarded {
+				r.Header.Add("X-Forwarded-For", header)
+			}
+			test.Equal(t, resolver.IP(r), tc.ip)
+		})
+	}
+}
+
+func TestNewRejectsInvalidProxies(t *testing.T) {
+	t.Parallel()
+
+	_, err := client_ip.New([]string{"10.0.0.0/33"})
+	if err == nil {
+		t.Fatal("Expected an invalid range to be rejected")
+	}
+	_, err = client_ip.New([]string{"proxy.internal"})
+	if err == nil {
+		t.Fatal("Expected a host name to be rejected")
+	}
+}
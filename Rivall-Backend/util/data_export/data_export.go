@@ -0,0 +1,98 @@
+package data_export
+
+import (
+	"archive/zip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"time"
+)
+
+// FORMAT_VERSION changes whenever the layout of an export changes
+const FORMAT_VERSION = 1
+
+const MANIFEST_NAME = "manifest.json"
+
+var ErrDuplicateFile = errors.New("export already has a file with this name")
+
+// File is one JSON document of an export
+type File struct {
+	Name        string
+	Description string
+	// Records counts the entries of Data when it is a list
+	Records int
+	Data    any
+}
+
+type ManifestEntry struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Records     int    `json:"records"`
+	Bytes       int    `json:"bytes"`
+	SHA256      string `json:"sha256"`
+}
+
+// Manifest lists what an export holds so its files can be checked and understood on their own
+type Manifest struct {
+	FormatVersion int             `json:"format_version"`
+	UserID        string          `json:"user_id"`
+	GeneratedAt   time.Time       `json:"generated_at"`
+	Files         []ManifestEntry `json:"files"`
+}
+
+// Write zips files as indented JSON, followed by a manifest describing them
+func Write(w io.Writer, userID string, generatedAt time.Time, files []File) (Manifest, error) {
+	manifest := Manifest{
+		FormatVersion: FORMAT_VERSION,
+		UserID:        userID,
+		GeneratedAt:   generatedAt.UTC(),
+		Files:         []ManifestEntry{},
+	}
+	archive := zip.NewWriter(w)
+
+	seen := map[string]bool{MANIFEST_NAME: true}
+	for _, file := range files {
+		if seen[file.Name] {
+			return Manifest{}, ErrDuplicateFile
+		}
+		seen[file.Name] = true
+
+		data, err := json.MarshalIndent(file.Data, "", "  ")
+		if err != nil {
+			return Manifest{}, err
+		}
+		if err := writeFile(archive, file.Name, generatedAt, data); err != nil {
+			return Manifest{}, err
+		}
+
+		sum := sha256.Sum256(data)
+		manifest.Files = append(manifest.Files, ManifestEntry{
+			Name:        file.Name,
+			Description: file.Description,
+			Records:     file.Records,
+			Bytes:       len(data),
+			SHA256:      hex.EncodeToString(sum[:]),
+		})
+	}
+
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return Manifest{}, err
+	}
+	if err := writeFile(archive, MANIFEST_NAME, generatedAt, data); err != nil {
+		return Manifest{}, err
+	}
+
+	return manifest, archive.Close()
+}
+
+func writeFile(archive *zip.Writer, name string, modified time.Time, data []byte) error {
+	f, err := archive.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: modified})
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(data)
+	return err
+}
@@ -0,0 +1,79 @@
+package data_export_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"testing"
+	"time"
+
+	"Rivall-Backend/util/data_export"
+	"Rivall-Backend/util/test"
+)
+
+func readZip(t *testing.T, data []byte) map[string][]byte {
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	test.NoError(t, err)
+
+	files := map[string][]byte{}
+	for _, f := range archive.File {
+		r, err := f.Open()
+		test.NoError(t, err)
+		content, err := io.ReadAll(r)
+		test.NoError(t, err)
+		r.Close()
+		files[f.Name] = content
+	}
+	return files
+}
+
+func TestWrite(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+	generatedAt := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	_, err := data_export.Write(&buf, "user", generatedAt, []data_export.File{
+		{Name: "profile.json", Description: "Profile", Data: map[string]string{"first_name": "Sam"}},
+		{Name: "contacts.json", Description: "Contacts", Records: 2, Data: []string{"a", "b"}},
+	})
+	test.NoError(t, err)
+
+	files := readZip(t, buf.Bytes())
+	test.Equal(t, len(files), 3)
+
+	var manifest data_export.Manifest
+	test.NoError(t, json.Unmarshal(files[data_export.MANIFEST_NAME], &manifest))
+	test.Equal(t, manifest.FormatVersion, data_export.FORMAT_VERSION)
+	test.Equal(t, manifest.UserID, "user")
+	test.Equal(t, manifest.GeneratedAt.Equal(generatedAt), true)
+	test.Equal(t, len(manifest.Files), 2)
+
+	// the manifest describes every file exactly
+	for _, entry := range manifest.Files {
+		content, ok := files[entry.Name]
+		test.Equal(t, ok, true)
+		sum := sha256.Sum256(content)
+		test.Equal(t, entry.SHA256, hex.EncodeToString(sum[:]))
+		test.Equal(t, entry.Bytes, len(content))
+	}
+	test.Equal(t, manifest.Files[1].Records, 2)
+
+	var contacts []string
+	test.NoError(t, json.Unmarshal(files["contacts.json"], &contacts))
+	test.Equal(t, len(contacts), 2)
+}
+
+func TestWriteDuplicateFile(t *testing.T) {
+	t.Parallel()
+
+	for _, name := range []string{"profile.json", data_export.MANIFEST_NAME} {
+		_, err := data_export.Write(io.Discard, "user", time.Now(), []data_export.File{
+			{Name: "profile.json", Data: nil},
+			{Name: name, Data: nil},
+		})
+		test.Equal(t, err, data_export.ErrDuplicateFile)
+	}
+}
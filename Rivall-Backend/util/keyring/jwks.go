@@ -0,0 +1,62 @@
+package keyring
+
+import (
+	"crypto/ed25519"
+	"crypto/rsa"
+	"encoding/base64"
+	"math/big"
+	"time"
+)
+
+// JWK is the public half of a key as described in RFC 7517
+type JWK struct {
+	KeyType   string `json:"kty"`
+	Use       string `json:"use"`
+	Algorithm string `json:"alg"`
+	KeyID     string `json:"kid"`
+	// RSA keys
+	N string `json:"n,omitempty"`
+	E string `json:"e,omitempty"`
+	// OKP keys
+	Curve string `json:"crv,omitempty"`
+	X     string `json:"x,omitempty"`
+}
+
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWKS lists the public keys tokens may currently be verified with
+func (k *Keyring) JWKS(now time.Time) JWKS {
+	k.RLock()
+	ids := make([]string, 0, len(k.keys))
+	for _, key := range k.keys {
+		ids = append(ids, key.ID)
+	}
+	k.RUnlock()
+
+	set := JWKS{Keys: []JWK{}}
+	for _, id := range ids {
+		key, ok := k.VerificationKey(id, now)
+		if !ok {
+			continue
+		}
+
+		jwk := JWK{Use: "sig", Algorithm: key.Algorithm, KeyID: key.ID}
+		switch public := key.Public().(type) {
+		case ed25519.PublicKey:
+			jwk.KeyType = "OKP"
+			jwk.Curve = "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(public)
+		case *rsa.PublicKey:
+			jwk.KeyType = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(public.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes())
+		default:
+			continue
+		}
+		set.Keys = append(set.Keys, jwk)
+	}
+
+	return set
+}
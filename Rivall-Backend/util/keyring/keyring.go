@@ -0,0 +1,288 @@
+package keyring
+
+import (
+	"context"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"log"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	ALGORITHM_EDDSA = "EdDSA"
+	ALGORITHM_RS256 = "RS256"
+
+	RSA_KEY_BITS = 2048
+
+	// REFRESH_INTERVAL is how often the keyring picks up keys other replicas rotated in
+	REFRESH_INTERVAL = time.Minute
+)
+
+var (
+	ErrUnsupportedAlgorithm = errors.New("unsupported signing algorithm")
+	ErrNoSigningKey         = errors.New("keyring has no signing key")
+)
+
+// Key is a signing key pair tagged with the kid tokens reference it by
+type Key struct {
+	ID        string
+	Algorithm string
+	CreatedAt time.Time
+	Private   crypto.Signer
+}
+
+func (k *Key) Public() crypto.PublicKey {
+	return k.Private.Public()
+}
+
+// StoredKey is a key as it is persisted, the private key is PKCS #8 PEM
+type StoredKey struct {
+	ID         string    `bson:"_id"`
+	Algorithm  string    `bson:"algorithm"`
+	PrivateKey string    `bson:"private_key"`
+	CreatedAt  time.Time `bson:"created_at"`
+}
+
+// Store persists keys so every replica signs and verifies with the same keyring
+type Store interface {
+	Load(ctx context.Context) ([]StoredKey, error)
+	Save(ctx context.Context, key StoredKey) error
+	Delete(ctx context.Context, id string) error
+}
+
+// Keyring signs with its newest key and keeps verifying with replaced keys for a
+// grace period, so tokens issued before a rotation stay valid until they expire.
+type Keyring struct {
+	algorithm   string
+	rotateEvery time.Duration
+	grace       time.Duration
+	store       Store
+
+	// keys are ordered oldest to newest, the newest one signs
+	keys []*Key
+
+	sync.RWMutex
+}
+
+func New(ctx context.Context, algorithm string, rotateEvery time.Duration, grace time.Duration, store Store) (*Keyring, error) {
+	if algorithm != ALGORITHM_EDDSA && algorithm != ALGORITHM_RS256 {
+		return nil, ErrUnsupportedAlgorithm
+	}
+
+	k := Keyring{
+		algorithm:   algorithm,
+		rotateEvery: rotateEvery,
+		grace:       grace,
+		store:       store,
+	}
+
+	if err := k.Refresh(ctx, time.Now()); err != nil {
+		return nil, err
+	}
+
+	return &k, nil
+}
+
+func (k *Keyring) Algorithm() string {
+	return k.algorithm
+}
+
+// SigningKey returns the key new tokens are signed with
+func (k *Keyring) SigningKey() (*Key, error) {
+	k.RLock()
+	defer k.RUnlock()
+
+	if len(k.keys) == 0 {
+		return nil, ErrNoSigningKey
+	}
+	return k.keys[len(k.keys)-1], nil
+}
+
+// VerificationKey returns the key with the given kid if tokens signed by it are still accepted
+func (k *Keyring) VerificationKey(id string, now time.Time) (*Key, bool) {
+	k.RLock()
+	defer k.RUnlock()
+
+	for i, key := range k.keys {
+		if key.ID != id {
+			continue
+		}
+		if key.Algorithm != k.algorithm {
+			return nil, false
+		}
+		if i < len(k.keys)-1 && !now.Before(k.keys[i+1].CreatedAt.Add(k.grace)) {
+			return nil, false
+		}
+		return key, true
+	}
+	return nil, false
+}
+
+// Refresh loads keys from the store, rotates in a new signing key when the current
+// one is due and drops keys that are past their grace period.
+func (k *Keyring) Refresh(ctx context.Context, now time.Time) error {
+	stored, err := k.store.Load(ctx)
+	if err != nil {
+		return err
+	}
+
+	keys := []*Key{}
+	for _, s := range stored {
+		key, err := decodeKey(s)
+		if err != nil {
+			log.Printf("skipping unreadable signing key %s: %v", s.ID, err)
+			continue
+		}
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].CreatedAt.Before(keys[j].CreatedAt)
+	})
+
+	// a change of algorithm retires every key of the old one
+	newest := len(keys) - 1
+	if newest < 0 || keys[newest].Algorithm != k.algorithm || !now.Before(keys[newest].CreatedAt.Add(k.rotateEvery)) {
+		key, err := k.generate(ctx, now)
+		if err != nil {
+			return err
+		}
+		keys = append(keys, key)
+	}
+
+	active := []*Key{}
+	for i, key := range keys {
+		if i < len(keys)-1 && !now.Before(keys[i+1].CreatedAt.Add(k.grace)) {
+			if err := k.store.Delete(ctx, key.ID); err != nil {
+				return err
+			}
+			continue
+		}
+		active = append(active, key)
+	}
+
+	k.Lock()
+	k.keys = active
+	k.Unlock()
+
+	return nil
+}
+
+// Rotate replaces the signing key now, the old key keeps verifying for the grace period
+func (k *Keyring) Rotate(ctx context.Context) error {
+	if _, err := k.generate(ctx, time.Now()); err != nil {
+		return err
+	}
+	return k.Refresh(ctx, time.Now())
+}
+
+// Run refreshes the keyring until ctx is done
+func (k *Keyring) Run(ctx context.Context) {
+	ticker := time.NewTicker(REFRESH_INTERVAL)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := k.Refresh(ctx, time.Now()); err != nil {
+				log.Printf("failed to refresh signing keys: %v", err)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (k *Keyring) generate(ctx context.Context, now time.Time) (*Key, error) {
+	var private crypto.Signer
+	var err error
+
+	switch k.algorithm {
+	case ALGORITHM_EDDSA:
+		_, private, err = ed25519.GenerateKey(rand.Reader)
+	case ALGORITHM_RS256:
+		private, err = rsa.GenerateKey(rand.Reader, RSA_KEY_BITS)
+	default:
+		return nil, ErrUnsupportedAlgorithm
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return nil, err
+	}
+
+	key := Key{
+		ID:        hex.EncodeToString(id),
+		Algorithm: k.algorithm,
+		CreatedAt: now,
+		Private:   private,
+	}
+
+	stored, err := encodeKey(&key)
+	if err != nil {
+		return nil, err
+	}
+	if err := k.store.Save(ctx, stored); err != nil {
+		return nil, err
+	}
+
+	return &key, nil
+}
+
+func encodeKey(key *Key) (StoredKey, error) {
+	der, err := x509.MarshalPKCS8PrivateKey(key.Private)
+	if err != nil {
+		return StoredKey{}, err
+	}
+
+	return StoredKey{
+		ID:         key.ID,
+		Algorithm:  key.Algorithm,
+		PrivateKey: string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})),
+		CreatedAt:  key.CreatedAt,
+	}, nil
+}
+
+func decodeKey(stored StoredKey) (*Key, error) {
+	block, _ := pem.Decode([]byte(stored.PrivateKey))
+	if block == nil {
+		return nil, errors.New("invalid private key PEM")
+	}
+
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+
+	var private crypto.Signer
+	switch p := parsed.(type) {
+	case ed25519.PrivateKey:
+		if stored.Algorithm != ALGORITHM_EDDSA {
+			return nil, ErrUnsupportedAlgorithm
+		}
+		private = p
+	case *rsa.PrivateKey:
+		if stored.Algorithm != ALGORITHM_RS256 {
+			return nil, ErrUnsupportedAlgorithm
+		}
+		private = p
+	default:
+		return nil, ErrUnsupportedAlgorithm
+	}
+
+	return &Key{
+		ID:        stored.ID,
+		Algorithm: stored.Algorithm,
+		CreatedAt: stored.CreatedAt,
+		Private:   private,
+	}, nil
+}
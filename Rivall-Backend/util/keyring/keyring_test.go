@@ -0,0 +1,107 @@
+package keyring_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"Rivall-Backend/util/keyring"
+	"Rivall-Backend/util/test"
+)
+
+const (
+	rotateEvery = time.Hour
+	grace       = time.Hour * 24
+)
+
+func TestRotationGracePeriod(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	store := keyring.NewMemoryStore()
+
+	keys, err := keyring.New(ctx, keyring.ALGORITHM_EDDSA, rotateEvery, grace, store)
+	test.NoError(t, err)
+	first, err := keys.SigningKey()
+	test.NoError(t, err)
+
+	// not due yet, the same key keeps signing
+	start := first.CreatedAt
+	test.NoError(t, keys.Refresh(ctx, start.Add(rotateEvery/2)))
+	current, err := keys.SigningKey()
+	test.NoError(t, err)
+	test.Equal(t, current.ID, first.ID)
+
+	// due, a new key signs and the old one still verifies
+	rotatedAt := start.Add(rotateEvery)
+	test.NoError(t, keys.Refresh(ctx, rotatedAt))
+	second, err := keys.SigningKey()
+	test.NoError(t, err)
+	if second.ID == first.ID {
+		t.Fatal("expected a new signing key")
+	}
+	_, ok := keys.VerificationKey(first.ID, rotatedAt.Add(grace/2))
+	test.Equal(t, ok, true)
+
+	// past the grace period the old key is gone
+	_, ok = keys.VerificationKey(first.ID, second.CreatedAt.Add(grace))
+	test.Equal(t, ok, false)
+
+	// and refreshing prunes it from the store
+	test.NoError(t, keys.Refresh(ctx, second.CreatedAt.Add(grace)))
+	stored, err := store.Load(ctx)
+	test.NoError(t, err)
+	for _, key := range stored {
+		if key.ID == first.ID {
+			t.Fatal("expected the replaced key to be pruned")
+		}
+	}
+}
+
+func TestReplicasShareKeys(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	store := keyring.NewMemoryStore()
+
+	a, err := keyring.New(ctx, keyring.ALGORITHM_EDDSA, rotateEvery, grace, store)
+	test.NoError(t, err)
+	b, err := keyring.New(ctx, keyring.ALGORITHM_EDDSA, rotateEvery, grace, store)
+	test.NoError(t, err)
+
+	key, err := a.SigningKey()
+	test.NoError(t, err)
+	other, err := b.SigningKey()
+	test.NoError(t, err)
+	test.Equal(t, other.ID, key.ID)
+}
+
+func TestJWKS(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	tests := []struct {
+		algorithm string
+		keyType   string
+	}{
+		{keyring.ALGORITHM_EDDSA, "OKP"},
+		{keyring.ALGORITHM_RS256, "RSA"},
+	}
+
+	for _, tc := range tests {
+		keys, err := keyring.New(ctx, tc.algorithm, rotateEvery, grace, keyring.NewMemoryStore())
+		test.NoError(t, err)
+		key, err := keys.SigningKey()
+		test.NoError(t, err)
+
+		set := keys.JWKS(time.Now())
+		test.Equal(t, len(set.Keys), 1)
+		test.Equal(t, set.Keys[0].KeyID, key.ID)
+		test.Equal(t, set.Keys[0].KeyType, tc.keyType)
+		test.Equal(t, set.Keys[0].Algorithm, tc.algorithm)
+	}
+}
+
+func TestUnsupportedAlgorithm(t *testing.T) {
+	t.Parallel()
+	_, err := keyring.New(context.Background(), "HS256", rotateEvery, grace, keyring.NewMemoryStore())
+	test.Equal(t, err, keyring.ErrUnsupportedAlgorithm)
+}
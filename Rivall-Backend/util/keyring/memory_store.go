@@ -0,0 +1,46 @@
+package keyring
+
+import (
+	"context"
+	"sync"
+)
+
+// MemoryStore keeps keys in process, tokens stop verifying on restart.
+// Use it for development and tests.
+type MemoryStore struct {
+	// keys is a map of kid to key
+	keys map[string]StoredKey
+
+	sync.RWMutex
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{keys: make(map[string]StoredKey)}
+}
+
+func (s *MemoryStore) Load(ctx context.Context) ([]StoredKey, error) {
+	s.RLock()
+	defer s.RUnlock()
+
+	keys := []StoredKey{}
+	for _, key := range s.keys {
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+func (s *MemoryStore) Save(ctx context.Context, key StoredKey) error {
+	s.Lock()
+	defer s.Unlock()
+
+	s.keys[key.ID] = key
+	return nil
+}
+
+func (s *MemoryStore) Delete(ctx context.Context, id string) error {
+	s.Lock()
+	defer s.Unlock()
+
+	delete(s.keys, id)
+	return nil
+}
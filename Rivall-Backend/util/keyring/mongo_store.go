@@ -0,0 +1,41 @@
+package keyring
+
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+// MongoStore shares keys between replicas through a MongoDB collection.
+// The collection holds private keys, restrict access to it accordingly.
+type MongoStore struct {
+	collection *mongo.Collection
+}
+
+func NewMongoStore(collection *mongo.Collection) *MongoStore {
+	return &MongoStore{collection: collection}
+}
+
+func (s *MongoStore) Load(ctx context.Context) ([]StoredKey, error) {
+	cursor, err := s.collection.Find(ctx, bson.M{})
+	if err != nil {
+		return nil, err
+	}
+
+	keys := []StoredKey{}
+	if err := cursor.All(ctx, &keys); err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+func (s *MongoStore) Save(ctx context.Context, key StoredKey) error {
+	_, err := s.collection.InsertOne(ctx, key)
+	return err
+}
+
+func (s *MongoStore) Delete(ctx context.Context, id string) error {
+	_, err := s.collection.DeleteOne(ctx, bson.M{"_id": id})
+	return err
+}
@@ -0,0 +1,43 @@
+package mailer
+
+import (
+	"context"
+	"sync"
+)
+
+// CaptureBackend keeps sent mail in memory so tests can assert on it
+type CaptureBackend struct {
+	messages []Message
+	sync.Mutex
+}
+
+func NewCaptureBackend() *CaptureBackend {
+	return &CaptureBackend{messages: []Message{}}
+}
+
+func (b *CaptureBackend) Send(ctx context.Context, message Message) error {
+	b.Lock()
+	defer b.Unlock()
+
+	b.messages = append(b.messages, message)
+	return nil
+}
+
+// Messages returns the mail sent so far
+func (b *CaptureBackend) Messages() []Message {
+	b.Lock()
+	defer b.Unlock()
+
+	return append([]Message{}, b.messages...)
+}
+
+// SentTo returns the mail sent to one address
+func (b *CaptureBackend) SentTo(to string) []Message {
+	sent := []Message{}
+	for _, message := range b.Messages() {
+		if message.To == to {
+			sent = append(sent, message)
+		}
+	}
+	return sent
+}
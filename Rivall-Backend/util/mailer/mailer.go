@@ -0,0 +1,162 @@
+package mailer
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+const QUEUE_SIZE = 256
+
+var ErrQueueFull = errors.New("mail queue is full")
+
+// Message is an email ready to hand to a backend
+type Message struct {
+	To      string
+	Subject string
+	Text    string
+	HTML    string
+}
+
+// Backend delivers a message, errors are retried by the Mailer
+type Backend interface {
+	Send(ctx context.Context, message Message) error
+}
+
+type job struct {
+	message  Message
+	attempts int
+}
+
+// Mailer renders templated mail and delivers it in the background, retrying
+// failed deliveries with exponential backoff.
+type Mailer struct {
+	backend     Backend
+	queue       chan *job
+	maxAttempts int
+	retryDelay  time.Duration
+
+	// pending counts queued messages, including ones waiting to be retried, idle is closed
+	// when it drops back to zero for Flush calls waiting on it
+	mu      sync.Mutex
+	pending int
+	idle    chan struct{}
+}
+
+func New(backend Backend, maxAttempts int, retryDelay time.Duration) *Mailer {
+	return &Mailer{
+		backend:     backend,
+		queue:       make(chan *job, QUEUE_SIZE),
+		maxAttempts: maxAttempts,
+		retryDelay:  retryDelay,
+	}
+}
+
+// Send renders a template for the recipient and queues it for delivery
+func (m *Mailer) Send(to string, template string, data any) error {
+	message, err := Render(template, data)
+	if err != nil {
+		return err
+	}
+	message.To = to
+	return m.Enqueue(message)
+}
+
+// Enqueue queues a message for delivery without blocking
+func (m *Mailer) Enqueue(message Message) error {
+	m.add()
+	select {
+	case m.queue <- &job{message: message}:
+		return nil
+	default:
+		m.done()
+		return ErrQueueFull
+	}
+}
+
+func (m *Mailer) add() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.pending++
+}
+
+func (m *Mailer) done() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.pending--
+	if m.pending == 0 && m.idle != nil {
+		close(m.idle)
+		m.idle = nil
+	}
+}
+
+// Run delivers queued mail until ctx is done
+func (m *Mailer) Run(ctx context.Context) {
+	for {
+		select {
+		case j := <-m.queue:
+			m.deliver(ctx, j)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Flush waits until every queued message was delivered or given up on
+func (m *Mailer) Flush(ctx context.Context) error {
+	m.mu.Lock()
+	if m.pending == 0 {
+		m.mu.Unlock()
+		return nil
+	}
+	if m.idle == nil {
+		m.idle = make(chan struct{})
+	}
+	idle := m.idle
+	m.mu.Unlock()
+
+	select {
+	case <-idle:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (m *Mailer) deliver(ctx context.Context, j *job) {
+	j.attempts++
+	err := m.backend.Send(ctx, j.message)
+	if err == nil {
+		m.done()
+		return
+	}
+
+	if j.attempts >= m.maxAttempts {
+		log.Error().Err(err).Int("attempts", j.attempts).Str("subject", j.message.Subject).Msg("Giving up on sending mail")
+		m.done()
+		return
+	}
+
+	// wait retryDelay, then twice as long after every further failure
+	delay := m.retryDelay << (j.attempts - 1)
+	log.Warn().Err(err).Int("attempts", j.attempts).Dur("retry_in", delay).Msg("Failed to send mail, retrying")
+
+	go func() {
+		timer := time.NewTimer(delay)
+		defer timer.Stop()
+
+		select {
+		case <-timer.C:
+			select {
+			case m.queue <- j:
+			case <-ctx.Done():
+				m.done()
+			}
+		case <-ctx.Done():
+			m.done()
+		}
+	}()
+}
@@ -0,0 +1,152 @@
+package mailer_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"Rivall-Backend/util/mailer"
+	"Rivall-Backend/util/test"
+)
+
+// flakyBackend fails its first failures sends, then captures
+type flakyBackend struct {
+	*mailer.CaptureBackend
+	failures int
+	attempts int
+	sync.Mutex
+}
+
+func (b *flakyBackend) Send(ctx context.Context, message mailer.Message) error {
+	b.Lock()
+	b.attempts++
+	fail := b.attempts <= b.failures
+	b.Unlock()
+
+	if fail {
+		return errors.New("connection refused")
+	}
+	return b.CaptureBackend.Send(ctx, message)
+}
+
+func runMailer(t *testing.T, backend mailer.Backend, maxAttempts int) *mailer.Mailer {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	m := mailer.New(backend, maxAttempts, time.Millisecond)
+	go m.Run(ctx)
+	return m
+}
+
+func flush(t *testing.T, m *mailer.Mailer) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+	test.NoError(t, m.Flush(ctx))
+}
+
+func TestRender(t *testing.T) {
+	t.Parallel()
+
+	message, err := mailer.Render(mailer.TEMPLATE_RECOVERY_CODE, mailer.RecoveryCodeData{
+		FirstName:        "<Sam>",
+		Code:             "A1B2C3",
+		ExpiresInMinutes: 10,
+	})
+	test.NoError(t, err)
+	test.Equal(t, message.Subject, "Your Rivall recovery code")
+	test.Equal(t, strings.Contains(message.Text, "A1B2C3"), true)
+	test.Equal(t, strings.Contains(message.HTML, "A1B2C3"), true)
+	// the HTML part escapes user supplied values
+	test.Equal(t, strings.Contains(message.HTML, "&lt;Sam&gt;"), true)
+
+	message, err = mailer.Render(mailer.TEMPLATE_EMAIL_VERIFICATION, mailer.EmailVerificationData{
+		FirstName:        "Sam",
+		Code:             "D4E5F6",
+		ExpiresInMinutes: 10,
+	})
+	test.NoError(t, err)
+	test.Equal(t, message.Subject, "Verify your Rivall email address")
+	test.Equal(t, strings.Contains(message.Text, "D4E5F6"), true)
+
+	message, err = mailer.Render(mailer.TEMPLATE_CONFIRMATION_CODE, mailer.ConfirmationCodeData{
+		FirstName:        "Sam",
+		Code:             "G7H8J9",
+		ExpiresInMinutes: 10,
+	})
+	test.NoError(t, err)
+	test.Equal(t, message.Subject, "Confirm a change to your Rivall account")
+	test.Equal(t, strings.Contains(message.HTML, "G7H8J9"), true)
+
+	_, err = mailer.Render("missing", nil)
+	test.Equal(t, err, mailer.ErrUnknownTemplate)
+}
+
+func TestSendCapturesMail(t *testing.T) {
+	t.Parallel()
+	backend := mailer.NewCaptureBackend()
+	m := runMailer(t, backend, 3)
+
+	err := m.Send("sam@example.com", mailer.TEMPLATE_NOTIFICATION, mailer.NotificationData{
+		FirstName: "Sam",
+		Title:     "New challenge",
+		Body:      "Your group started a challenge.",
+	})
+	test.NoError(t, err)
+	flush(t, m)
+
+	sent := backend.SentTo("sam@example.com")
+	test.Equal(t, len(sent), 1)
+	test.Equal(t, sent[0].Subject, "New challenge")
+}
+
+func TestSendRetries(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name      string
+		failures  int
+		delivered int
+	}{
+		{"recovers", 2, 1},
+		{"gives up", 5, 0},
+	}
+
+	for _, tc := range tests {
+		backend := &flakyBackend{CaptureBackend: mailer.NewCaptureBackend(), failures: tc.failures}
+		m := runMailer(t, backend, 3)
+
+		test.NoError(t, m.Enqueue(mailer.Message{To: "sam@example.com", Subject: "Hi", Text: "Hi"}))
+		flush(t, m)
+
+		if got := len(backend.Messages()); got != tc.delivered {
+			t.Errorf("%s: delivered %d, want %d", tc.name, got, tc.delivered)
+		}
+	}
+}
+
+func TestFlushWhileEnqueueing(t *testing.T) {
+	t.Parallel()
+	backend := mailer.NewCaptureBackend()
+	m := runMailer(t, backend, 1)
+
+	// mail keeps being queued while others wait for the queue to drain
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			test.NoError(t, m.Enqueue(mailer.Message{To: "sam@example.com", Subject: "Hi", Text: "Hi"}))
+		}()
+		go func() {
+			defer wg.Done()
+			flush(t, m)
+		}()
+	}
+	wg.Wait()
+	flush(t, m)
+
+	test.Equal(t, len(backend.SentTo("sam@example.com")), 20)
+}
@@ -0,0 +1,67 @@
+package mailer
+
+import (
+	"context"
+
+	"github.com/wneessen/go-mail"
+)
+
+const (
+	SMTP_TLS_MANDATORY     = "mandatory"
+	SMTP_TLS_OPPORTUNISTIC = "opportunistic"
+	SMTP_TLS_SSL           = "ssl"
+	SMTP_TLS_NONE          = "none"
+)
+
+// SMTPBackend delivers mail through an SMTP server
+type SMTPBackend struct {
+	client *mail.Client
+	from   string
+}
+
+func NewSMTPBackend(host string, port int, username string, password string, tlsMode string, from string) (*SMTPBackend, error) {
+	opts := []mail.Option{mail.WithPort(port)}
+
+	switch tlsMode {
+	case SMTP_TLS_SSL:
+		opts = append(opts, mail.WithSSL())
+	case SMTP_TLS_OPPORTUNISTIC:
+		opts = append(opts, mail.WithTLSPortPolicy(mail.TLSOpportunistic))
+	case SMTP_TLS_NONE:
+		opts = append(opts, mail.WithTLSPortPolicy(mail.NoTLS))
+	default:
+		opts = append(opts, mail.WithTLSPortPolicy(mail.TLSMandatory))
+	}
+
+	if username != "" {
+		opts = append(opts,
+			mail.WithSMTPAuth(mail.SMTPAuthPlain),
+			mail.WithUsername(username),
+			mail.WithPassword(password),
+		)
+	}
+
+	client, err := mail.NewClient(host, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	return &SMTPBackend{client: client, from: from}, nil
+}
+
+func (b *SMTPBackend) Send(ctx context.Context, message Message) error {
+	msg := mail.NewMsg()
+	if err := msg.From(b.from); err != nil {
+		return err
+	}
+	if err := msg.To(message.To); err != nil {
+		return err
+	}
+	msg.Subject(message.Subject)
+	msg.SetBodyString(mail.TypeTextPlain, message.Text)
+	if message.HTML != "" {
+		msg.AddAlternativeString(mail.TypeTextHTML, message.HTML)
+	}
+
+	return b.client.DialAndSendWithContext(ctx, msg)
+}
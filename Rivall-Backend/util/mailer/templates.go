@@ -0,0 +1,96 @@
+package mailer
+
+import (
+	"bytes"
+	"embed"
+	"errors"
+	htmltemplate "html/template"
+	texttemplate "text/template"
+)
+
+const (
+	TEMPLATE_RECOVERY_CODE      = "recovery_code"
+	TEMPLATE_EMAIL_VERIFICATION = "email_verification"
+	TEMPLATE_NOTIFICATION       = "notification"
+	TEMPLATE_CONFIRMATION_CODE  = "confirmation_code"
+)
+
+var ErrUnknownTemplate = errors.New("unknown mail template")
+
+// Every template has a <name>.txt defining "subject" and "body", and a <name>.html body
+//
+//go:embed templates
+var templateFiles embed.FS
+
+type mailTemplate struct {
+	text *texttemplate.Template
+	html *htmltemplate.Template
+}
+
+// templates are parsed one set per name so their "subject" and "body" don't collide
+var templates = map[string]mailTemplate{
+	TEMPLATE_RECOVERY_CODE:      mustParse(TEMPLATE_RECOVERY_CODE),
+	TEMPLATE_EMAIL_VERIFICATION: mustParse(TEMPLATE_EMAIL_VERIFICATION),
+	TEMPLATE_NOTIFICATION:       mustParse(TEMPLATE_NOTIFICATION),
+	TEMPLATE_CONFIRMATION_CODE:  mustParse(TEMPLATE_CONFIRMATION_CODE),
+}
+
+func mustParse(name string) mailTemplate {
+	return mailTemplate{
+		text: texttemplate.Must(texttemplate.ParseFS(templateFiles, "templates/"+name+".txt")),
+		html: htmltemplate.Must(htmltemplate.ParseFS(templateFiles, "templates/"+name+".html")),
+	}
+}
+
+// RecoveryCodeData fills the recovery_code template
+type RecoveryCodeData struct {
+	FirstName        string
+	Code             string
+	ExpiresInMinutes int
+}
+
+// EmailVerificationData fills the email_verification template
+type EmailVerificationData struct {
+	FirstName        string
+	Code             string
+	ExpiresInMinutes int
+}
+
+// ConfirmationCodeData fills the confirmation_code template
+type ConfirmationCodeData struct {
+	FirstName        string
+	Code             string
+	ExpiresInMinutes int
+}
+
+// NotificationData fills the notification template
+type NotificationData struct {
+	FirstName string
+	Title     string
+	Body      string
+}
+
+// Render fills the subject, plain-text and HTML parts of a template
+func Render(name string, data any) (Message, error) {
+	t, ok := templates[name]
+	if !ok {
+		return Message{}, ErrUnknownTemplate
+	}
+
+	var subject, textBody, htmlBody bytes.Buffer
+	if err := t.text.ExecuteTemplate(&subject, "subject", data); err != nil {
+		return Message{}, err
+	}
+	if err := t.text.ExecuteTemplate(&textBody, "body", data); err != nil {
+		return Message{}, err
+	}
+	if err := t.html.Execute(&htmlBody, data); err != nil {
+		return Message{}, err
+	}
+
+	return Message{
+		Subject: subject.String(),
+		Text:    textBody.String(),
+		HTML:    htmlBody.String(),
+	}, nil
+}
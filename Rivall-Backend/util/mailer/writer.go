@@ -0,0 +1,28 @@
+package mailer
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+)
+
+// WriterBackend writes the plain-text part of mail to a writer, such as the
+// console or a file, instead of sending it. Use it for development.
+type WriterBackend struct {
+	w io.Writer
+	sync.Mutex
+}
+
+func NewWriterBackend(w io.Writer) *WriterBackend {
+	return &WriterBackend{w: w}
+}
+
+func (b *WriterBackend) Send(ctx context.Context, message Message) error {
+	b.Lock()
+	defer b.Unlock()
+
+	_, err := fmt.Fprintf(b.w, "To: %s\nSubject: %s\n\n%s\n%s\n", message.To, message.Subject, message.Text, strings.Repeat("-", 72))
+	return err
+}
@@ -0,0 +1,155 @@
+package message_types
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"Rivall-Backend/util/validator"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+const (
+	Text           = "text"
+	ChallengeCard  = "challenge_card"
+	Vote           = "vote"
+	ProgressUpdate = "progress_update"
+	System         = "system"
+)
+
+var (
+	ErrUnknownMessageType = errors.New("unknown message type")
+	ErrNotClientSendable  = errors.New("message type can not be sent by clients")
+	ErrInvalidPayload     = errors.New("invalid message payload")
+)
+
+// ChallengeCardPayload proposes a challenge to the group, members vote on it with Vote messages
+type ChallengeCardPayload struct {
+	ChallengeID  string  `json:"challenge_id,omitempty"  form:"omitempty,len=24,hexadecimal"`
+	TemplateID   string  `json:"template_id,omitempty"   form:"omitempty,len=24,hexadecimal"`
+	Title        string  `json:"title"                   form:"required,max=100"`
+	Description  string  `json:"description"             form:"max=1000"`
+	MetricType   string  `json:"metric_type"             form:"required,oneof=count distance duration weight check_in"`
+	MetricUnit   string  `json:"metric_unit"             form:"max=20"`
+	Goal         float64 `json:"goal"                    form:"gt=0"`
+	DurationDays int     `json:"duration_days"           form:"required,min=1,max=365"`
+}
+
+// VotePayload is a member's vote on a challenge card posted earlier in the same chat
+type VotePayload struct {
+	CardMessageID string `json:"card_message_id" form:"required,len=24,hexadecimal"`
+	Vote          string `json:"vote"            form:"required,oneof=yes no"`
+}
+
+// ProgressUpdatePayload reports a member's progress on a running challenge
+type ProgressUpdatePayload struct {
+	ChallengeID string  `json:"challenge_id" form:"required,len=24,hexadecimal"`
+	Value       float64 `json:"value"        form:"gte=0"`
+	Note        string  `json:"note"         form:"max=280"`
+}
+
+// SystemPayload describes something the server did in a chat, e.g. a member joining
+type SystemPayload struct {
+	Event  string `json:"event"             form:"required"`
+	UserID string `json:"user_id,omitempty" form:"omitempty,len=24,hexadecimal"`
+	Text   string `json:"text"`
+}
+
+type definition struct {
+	// newPayload returns a pointer to the payload struct, nil when the type has no payload
+	newPayload func() any
+	// clientSendable is false for types only the server may create
+	clientSendable bool
+}
+
+var registry = map[string]definition{
+	Text:           {newPayload: nil, clientSendable: true},
+	ChallengeCard:  {newPayload: func() any { return &ChallengeCardPayload{} }, clientSendable: true},
+	Vote:           {newPayload: func() any { return &VotePayload{} }, clientSendable: true},
+	ProgressUpdate: {newPayload: func() any { return &ProgressUpdatePayload{} }, clientSendable: true},
+	System:         {newPayload: func() any { return &SystemPayload{} }, clientSendable: false},
+}
+
+var validate = validator.New()
+
+// Normalize returns the message type to store, treating a missing type as text for older clients
+func Normalize(messageType string) string {
+	if messageType == "" {
+		return Text
+	}
+	return messageType
+}
+
+func IsKnown(messageType string) bool {
+	_, ok := registry[Normalize(messageType)]
+	return ok
+}
+
+// DecodeClientPayload validates a payload sent by a client and returns its typed value
+func DecodeClientPayload(messageType string, payload json.RawMessage) (any, error) {
+	def, ok := registry[Normalize(messageType)]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownMessageType, messageType)
+	}
+	if !def.clientSendable {
+		return nil, fmt.Errorf("%w: %q", ErrNotClientSendable, messageType)
+	}
+	return decode(def, payload)
+}
+
+// DecodePayload validates a payload of any known type, including server only types
+func DecodePayload(messageType string, payload json.RawMessage) (any, error) {
+	def, ok := registry[Normalize(messageType)]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownMessageType, messageType)
+	}
+	return decode(def, payload)
+}
+
+func decode(def definition, payload json.RawMessage) (any, error) {
+	// Text messages keep everything in message_data
+	if def.newPayload == nil {
+		return nil, nil
+	}
+
+	if len(bytes.TrimSpace(payload)) == 0 || bytes.Equal(bytes.TrimSpace(payload), []byte("null")) {
+		return nil, fmt.Errorf("%w: payload is required", ErrInvalidPayload)
+	}
+
+	v := def.newPayload()
+	decoder := json.NewDecoder(bytes.NewReader(payload))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(v); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidPayload, err)
+	}
+
+	if err := validate.Struct(v); err != nil {
+		if errResp := validator.ToErrResponse(err); errResp != nil {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidPayload, strings.Join(errResp.Errors, ", "))
+		}
+		return nil, fmt.Errorf("%w: %v", ErrInvalidPayload, err)
+	}
+
+	return v, nil
+}
+
+// ToDocument converts a typed payload into the document stored alongside the message
+func ToDocument(payload any) (bson.M, error) {
+	if payload == nil {
+		return nil, nil
+	}
+
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	doc := bson.M{}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
@@ -0,0 +1,92 @@
+package message_types_test
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"Rivall-Backend/util/message_types"
+	"Rivall-Backend/util/test"
+)
+
+type testCase struct {
+	name        string
+	messageType string
+	payload     string
+	expected    error
+}
+
+var tests = []*testCase{
+	{
+		name:        `text without payload`,
+		messageType: "text",
+		expected:    nil,
+	},
+	{
+		name:        `missing type is text`,
+		messageType: "",
+		expected:    nil,
+	},
+	{
+		name:        `unknown type`,
+		messageType: "poll",
+		expected:    message_types.ErrUnknownMessageType,
+	},
+	{
+		name:        `system from client`,
+		messageType: "system",
+		payload:     `{"event":"member_joined"}`,
+		expected:    message_types.ErrNotClientSendable,
+	},
+	{
+		name:        `challenge card`,
+		messageType: "challenge_card",
+		payload:     `{"title":"10k steps","metric_type":"count","metric_unit":"steps","goal":10000,"duration_days":7}`,
+		expected:    nil,
+	},
+	{
+		name:        `challenge card without payload`,
+		messageType: "challenge_card",
+		expected:    message_types.ErrInvalidPayload,
+	},
+	{
+		name:        `challenge card with bad metric`,
+		messageType: "challenge_card",
+		payload:     `{"title":"10k steps","metric_type":"vibes","goal":10000,"duration_days":7}`,
+		expected:    message_types.ErrInvalidPayload,
+	},
+	{
+		name:        `vote with unknown field`,
+		messageType: "vote",
+		payload:     `{"card_message_id":"65b2f0c2e4b0a1a2b3c4d5e6","vote":"yes","weight":10}`,
+		expected:    message_types.ErrInvalidPayload,
+	},
+	{
+		name:        `progress update`,
+		messageType: "progress_update",
+		payload:     `{"challenge_id":"65b2f0c2e4b0a1a2b3c4d5e6","value":3.5}`,
+		expected:    nil,
+	},
+}
+
+func TestDecodeClientPayload(t *testing.T) {
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			_, err := message_types.DecodeClientPayload(tc.messageType, json.RawMessage(tc.payload))
+			if !errors.Is(err, tc.expected) {
+				t.Fatalf(`Expected:"%v", Got:"%v"`, tc.expected, err)
+			}
+		})
+	}
+}
+
+func TestToDocument(t *testing.T) {
+	payload, err := message_types.DecodeClientPayload("vote", json.RawMessage(`{"card_message_id":"65b2f0c2e4b0a1a2b3c4d5e6","vote":"no"}`))
+	test.NoError(t, err)
+
+	doc, err := message_types.ToDocument(payload)
+	test.NoError(t, err)
+	test.Equal(t, doc["vote"], any("no"))
+}
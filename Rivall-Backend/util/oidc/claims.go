@@ -0,0 +1,74 @@
+package oidc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Claims are what we take from a verified ID token
+type Claims struct {
+	jwt.RegisteredClaims
+	Nonce           string       `json:"nonce"`
+	AuthorizedParty string       `json:"azp"`
+	Email           string       `json:"email"`
+	EmailVerified   verifiedBool `json:"email_verified"`
+	GivenName       string       `json:"given_name"`
+	FamilyName      string       `json:"family_name"`
+}
+
+// verifiedBool reads email_verified, which some providers send as a string
+type verifiedBool bool
+
+func (b *verifiedBool) UnmarshalJSON(data []byte) error {
+	var value any
+	if err := json.Unmarshal(data, &value); err != nil {
+		return err
+	}
+	switch v := value.(type) {
+	case bool:
+		*b = verifiedBool(v)
+	case string:
+		*b = verifiedBool(strings.EqualFold(v, "true"))
+	default:
+		*b = false
+	}
+	return nil
+}
+
+// Verify checks an ID token's signature against the provider's keys, its issuer, audience,
+// expiry and that it answers the request that was sent nonce
+func (p *Provider) Verify(ctx context.Context, rawIDToken string, nonce string) (Claims, error) {
+	claims := Claims{}
+	_, err := jwt.ParseWithClaims(rawIDToken, &claims, func(token *jwt.Token) (any, error) {
+		kid, _ := token.Header["kid"].(string)
+		return p.keys.get(ctx, kid)
+	},
+		jwt.WithValidMethods([]string{"RS256", "ES256", "EdDSA"}),
+		jwt.WithIssuer(p.config.Issuer),
+		jwt.WithAudience(p.config.ClientID),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+		jwt.WithLeeway(TOKEN_LEEWAY),
+	)
+	if err != nil {
+		return Claims{}, fmt.Errorf("%w: %w", ErrInvalidToken, err)
+	}
+
+	// a token for several audiences has to name us as the party it was issued to
+	if (len(claims.Audience) > 1 || claims.AuthorizedParty != "") && claims.AuthorizedParty != p.config.ClientID {
+		return Claims{}, fmt.Errorf("%w: azp %q", ErrInvalidToken, claims.AuthorizedParty)
+	}
+	if claims.Subject == "" {
+		return Claims{}, fmt.Errorf("%w: no subject", ErrInvalidToken)
+	}
+	if claims.Nonce != nonce {
+		return Claims{}, ErrNonceMismatch
+	}
+
+	claims.Email = strings.ToLower(claims.Email)
+	return claims, nil
+}
@@ -0,0 +1,71 @@
+package oidc
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"math/big"
+)
+
+// jwk is a public key as described in RFC 7517, providers sign with RSA, EC or OKP keys
+type jwk struct {
+	KeyType string `json:"kty"`
+	Use     string `json:"use"`
+	KeyID   string `json:"kid"`
+	// RSA keys
+	N string `json:"n"`
+	E string `json:"e"`
+	// EC and OKP keys
+	Curve string `json:"crv"`
+	X     string `json:"x"`
+	Y     string `json:"y"`
+}
+
+type jwks struct {
+	Keys []jwk `json:"keys"`
+}
+
+// publicKeys decodes the signing keys, skipping encryption keys and ones we can't use
+func (set jwks) publicKeys() map[string]any {
+	keys := make(map[string]any)
+	for _, key := range set.Keys {
+		if key.Use != "" && key.Use != "sig" {
+			continue
+		}
+		if public := key.publicKey(); public != nil {
+			keys[key.KeyID] = public
+		}
+	}
+	return keys
+}
+
+func (key jwk) publicKey() any {
+	switch key.KeyType {
+	case "RSA":
+		n, errN := base64.RawURLEncoding.DecodeString(key.N)
+		e, errE := base64.RawURLEncoding.DecodeString(key.E)
+		if errN != nil || errE != nil || len(e) > 4 {
+			return nil
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+	case "EC":
+		if key.Curve != "P-256" {
+			return nil
+		}
+		x, errX := base64.RawURLEncoding.DecodeString(key.X)
+		y, errY := base64.RawURLEncoding.DecodeString(key.Y)
+		if errX != nil || errY != nil {
+			return nil
+		}
+		return &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+	case "OKP":
+		x, err := base64.RawURLEncoding.DecodeString(key.X)
+		if key.Curve != "Ed25519" || err != nil || len(x) != ed25519.PublicKeySize {
+			return nil
+		}
+		return ed25519.PublicKey(x)
+	}
+	return nil
+}
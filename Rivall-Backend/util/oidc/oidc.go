@@ -0,0 +1,237 @@
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TOKEN_LEEWAY allows for clock skew with the provider when checking exp and iat
+const TOKEN_LEEWAY = time.Second * 30
+
+// JWKS_REFRESH_INTERVAL limits how often an unknown kid makes us refetch the provider's keys
+const JWKS_REFRESH_INTERVAL = time.Minute
+
+var (
+	ErrDiscovery     = errors.New("failed to discover provider")
+	ErrExchange      = errors.New("failed to exchange authorization code")
+	ErrInvalidToken  = errors.New("invalid id token")
+	ErrNonceMismatch = errors.New("id token nonce does not match")
+)
+
+// Config describes a provider registered with us as a client
+type Config struct {
+	// Name is how the provider appears in our routes, like google
+	Name         string
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	// RedirectURL is where the provider sends users back with a code, the client app
+	// hands the code on to us
+	RedirectURL string
+	Scopes      []string
+}
+
+// discovery is the part of the provider's /.well-known/openid-configuration we use
+type discovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// Provider runs the authorization code flow with PKCE against one OpenID Connect provider
+type Provider struct {
+	config    Config
+	endpoints discovery
+	client    *http.Client
+
+	keys keySet
+}
+
+// Discover reads the provider's configuration from its issuer URL
+func Discover(ctx context.Context, config Config, client *http.Client) (*Provider, error) {
+	if client == nil {
+		client = &http.Client{Timeout: time.Second * 10}
+	}
+	if len(config.Scopes) == 0 {
+		config.Scopes = []string{"openid", "email", "profile"}
+	}
+
+	wellKnown := strings.TrimSuffix(config.Issuer, "/") + "/.well-known/openid-configuration"
+	var endpoints discovery
+	if err := getJSON(ctx, client, wellKnown, &endpoints); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrDiscovery, err)
+	}
+	// a provider may only speak for its own issuer
+	if endpoints.Issuer != config.Issuer {
+		return nil, fmt.Errorf("%w: issuer %q does not match %q", ErrDiscovery, endpoints.Issuer, config.Issuer)
+	}
+	if endpoints.AuthorizationEndpoint == "" || endpoints.TokenEndpoint == "" || endpoints.JWKSURI == "" {
+		return nil, fmt.Errorf("%w: missing endpoints", ErrDiscovery)
+	}
+
+	return &Provider{
+		config:    config,
+		endpoints: endpoints,
+		client:    client,
+		keys:      keySet{uri: endpoints.JWKSURI, client: client},
+	}, nil
+}
+
+func (p *Provider) Name() string {
+	return p.config.Name
+}
+
+// AuthCodeURL is where the user signs in with the provider, state and nonce tie the answer
+// to this request and challenge is the PKCE S256 challenge of the verifier kept for Exchange
+func (p *Provider) AuthCodeURL(state string, nonce string, challenge string) string {
+	query := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {p.config.ClientID},
+		"redirect_uri":          {p.config.RedirectURL},
+		"scope":                 {strings.Join(p.config.Scopes, " ")},
+		"state":                 {state},
+		"nonce":                 {nonce},
+		"code_challenge":        {challenge},
+		"code_challenge_method": {"S256"},
+	}
+
+	separator := "?"
+	if strings.Contains(p.endpoints.AuthorizationEndpoint, "?") {
+		separator = "&"
+	}
+	return p.endpoints.AuthorizationEndpoint + separator + query.Encode()
+}
+
+type tokenRes struct {
+	IDToken          string `json:"id_token"`
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+}
+
+// Exchange trades an authorization code and its PKCE verifier for the raw ID token
+func (p *Provider) Exchange(ctx context.Context, code string, verifier string) (string, error) {
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {p.config.RedirectURL},
+		"client_id":     {p.config.ClientID},
+		"code_verifier": {verifier},
+	}
+	if p.config.ClientSecret != "" {
+		form.Set("client_secret", p.config.ClientSecret)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.endpoints.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	res, err := p.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("%w: %w", ErrExchange, err)
+	}
+	defer res.Body.Close()
+
+	var token tokenRes
+	if err := json.NewDecoder(res.Body).Decode(&token); err != nil {
+		return "", fmt.Errorf("%w: %w", ErrExchange, err)
+	}
+	if res.StatusCode != http.StatusOK || token.Error != "" {
+		return "", fmt.Errorf("%w: %s %s", ErrExchange, token.Error, token.ErrorDescription)
+	}
+	if token.IDToken == "" {
+		return "", fmt.Errorf("%w: no id token", ErrExchange)
+	}
+	return token.IDToken, nil
+}
+
+// NewPKCE returns a code verifier and its S256 challenge
+func NewPKCE() (string, string, error) {
+	verifier, err := randomString(32)
+	if err != nil {
+		return "", "", err
+	}
+	sum := sha256.Sum256([]byte(verifier))
+	return verifier, base64.RawURLEncoding.EncodeToString(sum[:]), nil
+}
+
+// NewNonce returns a value for AuthCodeURL that Verify expects back in the ID token
+func NewNonce() (string, error) {
+	return randomString(16)
+}
+
+func randomString(size int) (string, error) {
+	b := make([]byte, size)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func getJSON(ctx context.Context, client *http.Client, url string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	res, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s: %s", url, res.Status)
+	}
+	return json.NewDecoder(res.Body).Decode(v)
+}
+
+// keySet caches the provider's signing keys by kid
+type keySet struct {
+	uri    string
+	client *http.Client
+
+	mu        sync.Mutex
+	keys      map[string]any
+	fetchedAt time.Time
+}
+
+func (s *keySet) get(ctx context.Context, kid string) (any, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if key, ok := s.keys[kid]; ok {
+		return key, nil
+	}
+	// an unknown kid usually means the provider rotated its keys
+	if time.Since(s.fetchedAt) < JWKS_REFRESH_INTERVAL {
+		return nil, fmt.Errorf("%w: unknown key %q", ErrInvalidToken, kid)
+	}
+
+	var set jwks
+	if err := getJSON(ctx, s.client, s.uri, &set); err != nil {
+		return nil, err
+	}
+	s.keys = set.publicKeys()
+	s.fetchedAt = time.Now()
+
+	key, ok := s.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("%w: unknown key %q", ErrInvalidToken, kid)
+	}
+	return key, nil
+}
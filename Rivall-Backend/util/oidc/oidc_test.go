@@ -0,0 +1,112 @@
+package oidc_test
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"testing"
+	"time"
+
+	"Rivall-Backend/util/oidc"
+	"Rivall-Backend/util/test"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const clientID = test.OIDC_CLIENT_ID
+
+func discover(t *testing.T, p *test.OIDCProvider) *oidc.Provider {
+	return p.Discover(t, "fake")
+}
+
+func TestAuthorizationCodeFlow(t *testing.T) {
+	t.Parallel()
+	fake := test.NewOIDCProvider(t)
+	provider := discover(t, fake)
+
+	verifier, challenge, err := oidc.NewPKCE()
+	test.NoError(t, err)
+	nonce, err := oidc.NewNonce()
+	test.NoError(t, err)
+
+	authURL, err := url.Parse(provider.AuthCodeURL("state-1", nonce, challenge))
+	test.NoError(t, err)
+	test.Equal(t, authURL.Path, "/authorize")
+	test.Equal(t, authURL.Query().Get("code_challenge"), challenge)
+	test.Equal(t, authURL.Query().Get("code_challenge_method"), "S256")
+	test.Equal(t, authURL.Query().Get("state"), "state-1")
+
+	code := fake.Grant(challenge, nonce, jwt.MapClaims{"email": "Sam@Example.com", "email_verified": "true"})
+	idToken, err := provider.Exchange(context.Background(), code, verifier)
+	test.NoError(t, err)
+
+	claims, err := provider.Verify(context.Background(), idToken, nonce)
+	test.NoError(t, err)
+	test.Equal(t, claims.Subject, "subject-1")
+	test.Equal(t, claims.Email, "sam@example.com")
+	test.Equal(t, bool(claims.EmailVerified), true)
+
+	// codes are single use
+	_, err = provider.Exchange(context.Background(), code, verifier)
+	if !errors.Is(err, oidc.ErrExchange) {
+		t.Fatalf(`Expected:"%v", Got:"%v"`, oidc.ErrExchange, err)
+	}
+}
+
+func TestExchangeRequiresVerifier(t *testing.T) {
+	t.Parallel()
+	fake := test.NewOIDCProvider(t)
+	provider := discover(t, fake)
+
+	_, challenge, err := oidc.NewPKCE()
+	test.NoError(t, err)
+	otherVerifier, _, err := oidc.NewPKCE()
+	test.NoError(t, err)
+
+	code := fake.Grant(challenge, "nonce", nil)
+	_, err = provider.Exchange(context.Background(), code, otherVerifier)
+	if !errors.Is(err, oidc.ErrExchange) {
+		t.Fatalf(`Expected:"%v", Got:"%v"`, oidc.ErrExchange, err)
+	}
+}
+
+func TestVerifyRejects(t *testing.T) {
+	t.Parallel()
+	fake := test.NewOIDCProvider(t)
+	provider := discover(t, fake)
+
+	tests := []struct {
+		name     string
+		nonce    string
+		claims   jwt.MapClaims
+		expected error
+	}{
+		{"wrong nonce", "other", nil, oidc.ErrNonceMismatch},
+		{"wrong audience", "nonce", jwt.MapClaims{"aud": "someone-else"}, oidc.ErrInvalidToken},
+		{"wrong issuer", "nonce", jwt.MapClaims{"iss": "https://evil.example"}, oidc.ErrInvalidToken},
+		{"expired", "nonce", jwt.MapClaims{"exp": time.Now().Add(-time.Hour).Unix()}, oidc.ErrInvalidToken},
+		{"other party", "nonce", jwt.MapClaims{"aud": []string{clientID, "other"}, "azp": "other"}, oidc.ErrInvalidToken},
+	}
+
+	for _, tc := range tests {
+		verifier, challenge, err := oidc.NewPKCE()
+		test.NoError(t, err)
+		idToken, err := provider.Exchange(context.Background(), fake.Grant(challenge, "nonce", tc.claims), verifier)
+		test.NoError(t, err)
+
+		_, err = provider.Verify(context.Background(), idToken, tc.nonce)
+		if !errors.Is(err, tc.expected) {
+			t.Fatalf(`%s: Expected:"%v", Got:"%v"`, tc.name, tc.expected, err)
+		}
+	}
+}
+
+func TestDiscoverChecksIssuer(t *testing.T) {
+	t.Parallel()
+	fake := test.NewOIDCProvider(t)
+
+	_, err := oidc.Discover(context.Background(), oidc.Config{Issuer: fake.URL + "/other", ClientID: clientID}, fake.Client())
+	if !errors.Is(err, oidc.ErrDiscovery) {
+		t.Fatalf(`Expected:"%v", Got:"%v"`, oidc.ErrDiscovery, err)
+	}
+}
@@ -0,0 +1,216 @@
+package openapi
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const VERSION = "3.0.3"
+
+// Document is an OpenAPI 3 document, only the parts the API uses are modelled
+type Document struct {
+	OpenAPI    string              `json:"openapi"`
+	Info       Info                `json:"info"`
+	Paths      map[string]PathItem `json:"paths"`
+	Components Components          `json:"components"`
+}
+
+type Info struct {
+	Title       string `json:"title"`
+	Version     string `json:"version"`
+	Description string `json:"description,omitempty"`
+}
+
+// PathItem holds the operations of a path keyed by lower case method
+type PathItem map[string]*Operation
+
+type Components struct {
+	Schemas         map[string]*Schema        `json:"schemas"`
+	SecuritySchemes map[string]SecurityScheme `json:"securitySchemes"`
+}
+
+type SecurityScheme struct {
+	Type         string `json:"type"`
+	Scheme       string `json:"scheme"`
+	BearerFormat string `json:"bearerFormat,omitempty"`
+}
+
+type Operation struct {
+	OperationID string                `json:"operationId,omitempty"`
+	Summary     string                `json:"summary"`
+	Description string                `json:"description,omitempty"`
+	Tags        []string              `json:"tags,omitempty"`
+	Parameters  []Parameter           `json:"parameters,omitempty"`
+	RequestBody *RequestBody          `json:"requestBody,omitempty"`
+	Responses   map[string]Response   `json:"responses"`
+	Security    []map[string][]string `json:"security"`
+}
+
+type Parameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required"`
+	Schema      *Schema `json:"schema"`
+}
+
+type RequestBody struct {
+	Required bool                 `json:"required"`
+	Content  map[string]MediaType `json:"content"`
+}
+
+type Response struct {
+	Description string               `json:"description"`
+	Content     map[string]MediaType `json:"content,omitempty"`
+}
+
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+// Route describes an operation the router serves
+type Route struct {
+	Summary     string
+	Description string
+	Tag         string
+	// Public routes don't need an access token
+	Public bool
+	Query  []Parameter
+	// Request is a zero value of the JSON body, nil when the route reads no body
+	Request any
+	// OptionalRequest lets the body be left out
+	OptionalRequest bool
+	Responses       []RouteResponse
+}
+
+// RouteResponse is a successful answer of a route, errors are described once for every route
+type RouteResponse struct {
+	Status      int
+	Description string
+	// Body is a zero value of the JSON body, nil when the response has none. Responses with
+	// a ContentType and no Body are binary files
+	Body any
+	// OneOf lists the bodies when the response can take more than one shape
+	OneOf       []any
+	ContentType string
+}
+
+var pathParam = regexp.MustCompile(`\{([^}:]+)(:[^}]*)?\}`)
+
+// Generator builds a Document out of routes and the types they read and write
+type Generator struct {
+	doc     Document
+	schemas *Schemas
+	errors  *Schema
+}
+
+// New starts a document whose error responses all have the shape of errorBody
+func New(info Info, errorBody any) *Generator {
+	g := &Generator{
+		doc: Document{
+			OpenAPI: VERSION,
+			Info:    info,
+			Paths:   make(map[string]PathItem),
+			Components: Components{
+				SecuritySchemes: map[string]SecurityScheme{
+					"bearer": {Type: "http", Scheme: "bearer", BearerFormat: "JWT"},
+				},
+			},
+		},
+		schemas: NewSchemas(),
+	}
+	g.errors = g.schemas.Of(errorBody)
+	return g
+}
+
+// Add describes the route serving method on path, path is a mux path template
+func (g *Generator) Add(method string, path string, operationID string, route Route) {
+	path = pathParam.ReplaceAllString(path, "{$1}")
+
+	op := &Operation{
+		OperationID: operationID,
+		Summary:     route.Summary,
+		Description: route.Description,
+		Responses:   make(map[string]Response),
+		Security:    []map[string][]string{{"bearer": {}}},
+	}
+	if route.Public {
+		op.Security = []map[string][]string{}
+	}
+	if route.Tag != "" {
+		op.Tags = []string{route.Tag}
+	}
+
+	for _, match := range pathParam.FindAllStringSubmatch(path, -1) {
+		op.Parameters = append(op.Parameters, Parameter{
+			Name:     match[1],
+			In:       "path",
+			Required: true,
+			Schema:   &Schema{Type: "string"},
+		})
+	}
+	for _, query := range route.Query {
+		query.In = "query"
+		if query.Schema == nil {
+			query.Schema = &Schema{Type: "string"}
+		}
+		op.Parameters = append(op.Parameters, query)
+	}
+
+	if route.Request != nil {
+		op.RequestBody = &RequestBody{
+			Required: !route.OptionalRequest,
+			Content:  map[string]MediaType{"application/json": {Schema: g.schemas.Of(route.Request)}},
+		}
+	}
+
+	for _, res := range route.Responses {
+		response := Response{Description: res.Description}
+		if response.Description == "" {
+			response.Description = http.StatusText(res.Status)
+		}
+		contentType := res.ContentType
+		if contentType == "" {
+			contentType = "application/json"
+		}
+		switch {
+		case len(res.OneOf) > 0:
+			schema := &Schema{}
+			for _, body := range res.OneOf {
+				schema.OneOf = append(schema.OneOf, g.schemas.Of(body))
+			}
+			response.Content = map[string]MediaType{contentType: {Schema: schema}}
+		case res.Body != nil:
+			response.Content = map[string]MediaType{contentType: {Schema: g.schemas.Of(res.Body)}}
+		case res.ContentType != "":
+			response.Content = map[string]MediaType{contentType: {Schema: &Schema{Type: "string", Format: "binary"}}}
+		}
+		op.Responses[strconv.Itoa(res.Status)] = response
+	}
+	op.Responses["default"] = Response{
+		Description: "Error",
+		Content:     map[string]MediaType{"application/json": {Schema: g.errors}},
+	}
+
+	item, ok := g.doc.Paths[path]
+	if !ok {
+		item = make(PathItem)
+		g.doc.Paths[path] = item
+	}
+	item[strings.ToLower(method)] = op
+}
+
+// Document returns the document described so far
+func (g *Generator) Document() Document {
+	doc := g.doc
+	doc.Components.Schemas = g.schemas.Components()
+	return doc
+}
+
+// Key names a route the way route descriptions are looked up, like "GET /api/v1/users/{user_id}"
+func Key(method string, path string) string {
+	return fmt.Sprintf("%s %s", method, pathParam.ReplaceAllString(path, "{$1}"))
+}
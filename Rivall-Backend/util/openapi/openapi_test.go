@@ -0,0 +1,89 @@
+package openapi_test
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"Rivall-Backend/util/openapi"
+	"Rivall-Backend/util/test"
+)
+
+type errorRes struct {
+	Message string `json:"message"`
+}
+
+type profile struct {
+	Name string `json:"name"`
+}
+
+type createReq struct {
+	Name     string   `json:"name"       form:"required,max=50"`
+	Role     string   `json:"role"       form:"omitempty,oneof=admin member"`
+	Friends  []string `json:"friend_ids" form:"max=5,unique,dive,len=24,hexadecimal"`
+	Bio      *string  `json:"bio"        form:"omitnil,max=280"`
+	Internal string   `json:"-"`
+}
+
+type createRes struct {
+	profile
+	CreatedAt time.Time `json:"created_at"`
+	Manager   *profile  `json:"manager"`
+	Settings  struct {
+		Public bool `json:"public"`
+	} `json:"settings"`
+}
+
+func TestAdd(t *testing.T) {
+	t.Parallel()
+
+	g := openapi.New(openapi.Info{Title: "Test", Version: "1"}, errorRes{})
+	g.Add(http.MethodPost, "/users/{user_id}/things/{thing_id:[0-9]+}", "CreateThing", openapi.Route{
+		Summary:   "Create a thing",
+		Request:   createReq{},
+		Responses: []openapi.RouteResponse{{Status: http.StatusCreated, Body: createRes{}}},
+	})
+	g.Add(http.MethodGet, "/health", "Read", openapi.Route{
+		Summary:   "Health",
+		Public:    true,
+		Responses: []openapi.RouteResponse{{Status: http.StatusOK, ContentType: "application/zip"}},
+	})
+	doc := g.Document()
+
+	op := doc.Paths["/users/{user_id}/things/{thing_id}"]["post"]
+	if op == nil {
+		t.Fatalf("Expected the post operation, got paths %v", doc.Paths)
+	}
+	test.Equal(t, len(op.Parameters), 2)
+	test.Equal(t, op.Parameters[1].Name, "thing_id")
+	test.Equal(t, len(op.Security), 1)
+	test.Equal(t, op.Responses["201"].Content["application/json"].Schema.Ref, "#/components/schemas/createRes")
+	test.Equal(t, op.Responses["default"].Content["application/json"].Schema.Ref, "#/components/schemas/errorRes")
+	test.Equal(t, len(doc.Paths["/health"]["get"].Security), 0)
+	test.Equal(t, doc.Paths["/health"]["get"].Responses["200"].Content["application/zip"].Schema.Format, "binary")
+
+	req := doc.Components.Schemas["createReq"]
+	test.Equal(t, len(req.Required), 1)
+	test.Equal(t, req.Required[0], "name")
+	test.Equal(t, *req.Properties["name"].MaxLength, 50)
+	test.Equal(t, len(req.Properties["role"].Enum), 2)
+	test.Equal(t, *req.Properties["friend_ids"].MaxItems, 5)
+	test.Equal(t, req.Properties["friend_ids"].UniqueItems, true)
+	test.Equal(t, *req.Properties["friend_ids"].Items.MinLength, 24)
+	test.Equal(t, req.Properties["friend_ids"].Items.Pattern, "^[0-9a-fA-F]+$")
+	test.Equal(t, req.Properties["bio"].Nullable, true)
+	test.Equal(t, len(req.Properties), 4)
+
+	res := doc.Components.Schemas["createRes"]
+	test.Equal(t, res.Properties["name"].Type, "string")
+	test.Equal(t, res.Properties["created_at"].Format, "date-time")
+	test.Equal(t, res.Properties["manager"].Nullable, true)
+	test.Equal(t, res.Properties["manager"].OneOf[0].Ref, "#/components/schemas/profile")
+	test.Equal(t, res.Properties["settings"].Properties["public"].Type, "boolean")
+}
+
+func TestKey(t *testing.T) {
+	t.Parallel()
+
+	test.Equal(t, openapi.Key(http.MethodGet, "/users/{user_id:[0-9a-f]+}"), "GET /users/{user_id}")
+}
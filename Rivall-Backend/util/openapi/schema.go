@@ -0,0 +1,250 @@
+package openapi
+
+import (
+	"encoding"
+	"encoding/json"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schema is an OpenAPI schema object
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Nullable             bool               `json:"nullable,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	OneOf                []*Schema          `json:"oneOf,omitempty"`
+	Enum                 []string           `json:"enum,omitempty"`
+	Pattern              string             `json:"pattern,omitempty"`
+	MinLength            *int               `json:"minLength,omitempty"`
+	MaxLength            *int               `json:"maxLength,omitempty"`
+	MinItems             *int               `json:"minItems,omitempty"`
+	MaxItems             *int               `json:"maxItems,omitempty"`
+	UniqueItems          bool               `json:"uniqueItems,omitempty"`
+	Minimum              *float64           `json:"minimum,omitempty"`
+	Maximum              *float64           `json:"maximum,omitempty"`
+}
+
+var (
+	timeType          = reflect.TypeOf(time.Time{})
+	rawMessageType    = reflect.TypeOf(json.RawMessage{})
+	jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+// Schemas turns Go types into schemas the way encoding/json writes them, named structs
+// become components that are referenced
+type Schemas struct {
+	components map[string]*Schema
+	names      map[reflect.Type]string
+}
+
+// NewSchemas starts an empty set of components
+func NewSchemas() *Schemas {
+	return &Schemas{
+		components: make(map[string]*Schema),
+		names:      make(map[reflect.Type]string),
+	}
+}
+
+// Components returns the named schemas referenced so far
+func (s *Schemas) Components() map[string]*Schema {
+	return s.components
+}
+
+// Of returns the schema of v's type
+func (s *Schemas) Of(v any) *Schema {
+	return s.schema(reflect.TypeOf(v))
+}
+
+func (s *Schemas) schema(t reflect.Type) *Schema {
+	if t.Kind() == reflect.Pointer {
+		return s.schema(t.Elem())
+	}
+
+	switch {
+	case t == timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	case t == rawMessageType:
+		return &Schema{}
+	case t.Implements(textMarshalerType):
+		return &Schema{Type: "string"}
+	case t.Implements(jsonMarshalerType):
+		return &Schema{}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &Schema{Type: "integer"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", Format: "byte"}
+		}
+		return &Schema{Type: "array", Items: s.schema(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: s.schema(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return s.object(t)
+		}
+		return s.ref(t)
+	default:
+		return &Schema{}
+	}
+}
+
+func (s *Schemas) ref(t reflect.Type) *Schema {
+	name, ok := s.names[t]
+	if !ok {
+		name = t.Name()
+		if _, taken := s.components[name]; taken {
+			// types from different packages can share a name
+			pkg := t.PkgPath()
+			name = pkg[strings.LastIndex(pkg, "/")+1:] + "." + name
+		}
+		s.names[t] = name
+		// claim the name before the fields, so types can refer to themselves
+		s.components[name] = &Schema{}
+		*s.components[name] = *s.object(t)
+	}
+	return &Schema{Ref: "#/components/schemas/" + name}
+}
+
+func (s *Schemas) object(t reflect.Type) *Schema {
+	schema := &Schema{Type: "object", Properties: make(map[string]*Schema)}
+	s.fields(schema, t)
+	return schema
+}
+
+func (s *Schemas) fields(schema *Schema, t reflect.Type) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+
+		// untagged embedded structs lift their fields, like encoding/json does
+		if field.Anonymous && name == "" {
+			embedded := field.Type
+			if embedded.Kind() == reflect.Pointer {
+				embedded = embedded.Elem()
+			}
+			if embedded.Kind() == reflect.Struct {
+				s.fields(schema, embedded)
+				continue
+			}
+		}
+		if !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		property := s.schema(field.Type)
+		if field.Type.Kind() == reflect.Pointer && !strings.Contains(opts, "omitempty") {
+			property = nullable(property)
+		}
+		if constrain(property, field.Type, field.Tag.Get("form")) {
+			schema.Required = append(schema.Required, name)
+		}
+		schema.Properties[name] = property
+	}
+}
+
+func nullable(schema *Schema) *Schema {
+	if schema.Ref != "" {
+		// siblings of $ref are ignored, so the reference has to be wrapped
+		return &Schema{OneOf: []*Schema{schema}, Nullable: true}
+	}
+	schema.Nullable = true
+	return schema
+}
+
+// constrain adds the validator's form tag rules to schema and reports whether the field
+// is required
+func constrain(schema *Schema, t reflect.Type, tag string) bool {
+	if tag == "" {
+		return false
+	}
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	required := false
+	for _, rule := range strings.Split(tag, ",") {
+		name, param, _ := strings.Cut(rule, "=")
+		switch name {
+		case "dive":
+			// the remaining rules apply to each item
+			if schema.Items != nil {
+				_, rest, _ := strings.Cut(tag, "dive,")
+				constrain(schema.Items, t.Elem(), rest)
+			}
+			return required
+		case "required":
+			required = true
+		case "email":
+			schema.Format = "email"
+		case "url":
+			schema.Format = "uri"
+		case "hexadecimal":
+			schema.Pattern = "^[0-9a-fA-F]+$"
+		case "unique":
+			schema.UniqueItems = true
+		case "oneof":
+			schema.Enum = strings.Fields(param)
+		case "min", "max", "len":
+			n, err := strconv.Atoi(param)
+			if err != nil {
+				continue
+			}
+			bound(schema, t, name, n)
+		}
+	}
+	return required
+}
+
+func bound(schema *Schema, t reflect.Type, rule string, n int) {
+	min, max := rule != "max", rule != "min"
+	switch t.Kind() {
+	case reflect.String:
+		if min {
+			schema.MinLength = &n
+		}
+		if max {
+			schema.MaxLength = &n
+		}
+	case reflect.Slice, reflect.Array, reflect.Map:
+		if min {
+			schema.MinItems = &n
+		}
+		if max {
+			schema.MaxItems = &n
+		}
+	default:
+		f := float64(n)
+		if min {
+			schema.Minimum = &f
+		}
+		if max {
+			schema.Maximum = &f
+		}
+	}
+}
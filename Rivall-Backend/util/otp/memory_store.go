@@ -0,0 +1,131 @@
+package otp
+
+import (
+	"context"
+	"crypto/hmac"
+	"sync"
+	"time"
+)
+
+// MemoryStore keeps codes and attempts in process, they are lost on restart and each
+// replica counts on its own. Use it for development and tests.
+type MemoryStore struct {
+	codes    map[string]Code
+	attempts map[string]Attempts
+
+	mu sync.Mutex
+}
+
+func NewMemoryStore(ctx context.Context) *MemoryStore {
+	s := MemoryStore{
+		codes:    make(map[string]Code),
+		attempts: make(map[string]Attempts),
+	}
+
+	go s.Retention(ctx)
+
+	return &s
+}
+
+func (s *MemoryStore) IssueCode(ctx context.Context, code Code, now time.Time, notBefore time.Time) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if active, ok := s.codes[code.Key]; ok && now.Before(active.ExpiresAt) && active.IssuedAt.After(notBefore) {
+		return false, nil
+	}
+	s.codes[code.Key] = code
+	return true, nil
+}
+
+func (s *MemoryStore) UseCode(ctx context.Context, key string, hash []byte, now time.Time) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	active, ok := s.codes[key]
+	if !ok || !now.Before(active.ExpiresAt) || !hmac.Equal(active.Hash, hash) {
+		return false, nil
+	}
+	delete(s.codes, key)
+	return true, nil
+}
+
+func (s *MemoryStore) DeleteCode(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.codes, key)
+	return nil
+}
+
+func (s *MemoryStore) GetAttempts(ctx context.Context, key string, now time.Time) (Attempts, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	a, ok := s.attempts[key]
+	if !ok || !now.Before(a.ExpiresAt) {
+		return Attempts{Key: key}, nil
+	}
+	return a, nil
+}
+
+func (s *MemoryStore) FailAttempt(ctx context.Context, key string, now time.Time, window time.Duration) (Attempts, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	a, ok := s.attempts[key]
+	if !ok || !now.Before(a.ExpiresAt) {
+		a = Attempts{Key: key, ExpiresAt: now.Add(window)}
+	}
+	a.Count++
+	s.attempts[key] = a
+	return a, nil
+}
+
+func (s *MemoryStore) LockAttempts(ctx context.Context, key string, lockedUntil time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	a := s.attempts[key]
+	a.Key = key
+	a.LockedUntil = lockedUntil
+	if lockedUntil.After(a.ExpiresAt) {
+		a.ExpiresAt = lockedUntil
+	}
+	s.attempts[key] = a
+	return nil
+}
+
+func (s *MemoryStore) DeleteAttempts(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.attempts, key)
+	return nil
+}
+
+// Retention drops expired codes and attempts until ctx is done
+func (s *MemoryStore) Retention(ctx context.Context) {
+	ticker := time.NewTicker(time.Second * 30)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			now := time.Now()
+			s.mu.Lock()
+			for key, c := range s.codes {
+				if !now.Before(c.ExpiresAt) {
+					delete(s.codes, key)
+				}
+			}
+			for key, a := range s.attempts {
+				if !now.Before(a.ExpiresAt) {
+					delete(s.attempts, key)
+				}
+			}
+			s.mu.Unlock()
+		case <-ctx.Done():
+			return
+		}
+	}
+}
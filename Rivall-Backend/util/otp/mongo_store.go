@@ -0,0 +1,104 @@
+package otp
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// MongoStore keeps codes and attempts in MongoDB, so codes outlive restarts and every
+// replica counts the same attempts. TTL indexes remove expired documents.
+type MongoStore struct {
+	codes    *mongo.Collection
+	attempts *mongo.Collection
+}
+
+func NewMongoStore(ctx context.Context, database *mongo.Database) (*MongoStore, error) {
+	s := MongoStore{
+		codes:    database.Collection("OneTimeCodes"),
+		attempts: database.Collection("OneTimeCodeAttempts"),
+	}
+
+	expireIndex := mongo.IndexModel{
+		Keys:    bson.D{{Key: "expires_at", Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(0),
+	}
+	if _, err := s.codes.Indexes().CreateOne(ctx, expireIndex); err != nil {
+		return nil, err
+	}
+	if _, err := s.attempts.Indexes().CreateOne(ctx, expireIndex); err != nil {
+		return nil, err
+	}
+
+	return &s, nil
+}
+
+func (s *MongoStore) IssueCode(ctx context.Context, code Code, now time.Time, notBefore time.Time) (bool, error) {
+	// a code that can't be replaced yet doesn't match, and the upsert then collides with it
+	filter := bson.M{"_id": code.Key, "$or": bson.A{
+		bson.M{"expires_at": bson.M{"$lte": now}},
+		bson.M{"issued_at": bson.M{"$lte": notBefore}},
+	}}
+	_, err := s.codes.ReplaceOne(ctx, filter, code, options.Replace().SetUpsert(true))
+	if mongo.IsDuplicateKeyError(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (s *MongoStore) UseCode(ctx context.Context, key string, hash []byte, now time.Time) (bool, error) {
+	// the TTL monitor only runs about once a minute
+	result, err := s.codes.DeleteOne(ctx, bson.M{"_id": key, "hash": hash, "expires_at": bson.M{"$gt": now}})
+	if err != nil {
+		return false, err
+	}
+	return result.DeletedCount == 1, nil
+}
+
+func (s *MongoStore) DeleteCode(ctx context.Context, key string) error {
+	_, err := s.codes.DeleteOne(ctx, bson.M{"_id": key})
+	return err
+}
+
+func (s *MongoStore) GetAttempts(ctx context.Context, key string, now time.Time) (Attempts, error) {
+	var a Attempts
+	err := s.attempts.FindOne(ctx, bson.M{"_id": key, "expires_at": bson.M{"$gt": now}}).Decode(&a)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return Attempts{Key: key}, nil
+	}
+	return a, err
+}
+
+func (s *MongoStore) FailAttempt(ctx context.Context, key string, now time.Time, window time.Duration) (Attempts, error) {
+	// start over from expired attempts the TTL monitor hasn't removed yet
+	if _, err := s.attempts.DeleteOne(ctx, bson.M{"_id": key, "expires_at": bson.M{"$lte": now}}); err != nil {
+		return Attempts{}, err
+	}
+
+	var a Attempts
+	update := bson.M{
+		"$inc":         bson.M{"count": 1},
+		"$setOnInsert": bson.M{"expires_at": now.Add(window)},
+	}
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+	err := s.attempts.FindOneAndUpdate(ctx, bson.M{"_id": key}, update, opts).Decode(&a)
+	return a, err
+}
+
+func (s *MongoStore) LockAttempts(ctx context.Context, key string, lockedUntil time.Time) error {
+	update := bson.M{
+		"$set": bson.M{"locked_until": lockedUntil},
+		"$max": bson.M{"expires_at": lockedUntil},
+	}
+	_, err := s.attempts.UpdateOne(ctx, bson.M{"_id": key}, update, options.UpdateOne().SetUpsert(true))
+	return err
+}
+
+func (s *MongoStore) DeleteAttempts(ctx context.Context, key string) error {
+	_, err := s.attempts.DeleteOne(ctx, bson.M{"_id": key})
+	return err
+}
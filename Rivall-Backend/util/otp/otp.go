@@ -0,0 +1,208 @@
+package otp
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+	"math/big"
+	"strings"
+	"time"
+)
+
+const (
+	PURPOSE_RECOVERY           = "recovery"
+	PURPOSE_EMAIL_VERIFICATION = "email_verification"
+	PURPOSE_LOGIN_CONFIRMATION = "login_confirmation"
+	PURPOSE_EMAIL_CHANGE       = "email_change"
+	PURPOSE_REAUTHENTICATION   = "reauthentication"
+
+	// CODE_ALPHABET leaves out characters that are easy to misread, like 0/O and 1/I
+	CODE_ALPHABET = "23456789ABCDEFGHJKLMNPQRSTUVWXYZ"
+	CODE_LENGTH   = 8
+)
+
+var (
+	ErrInvalidCode = errors.New("code is invalid or expired")
+	ErrLockedOut   = errors.New("too many failed attempts, try again later")
+	ErrTooSoon     = errors.New("a code was sent recently, try again later")
+)
+
+type Config struct {
+	// CodeTimeout is how long an issued code stays valid
+	CodeTimeout time.Duration
+	// ResendInterval is how long to wait before replacing an active code with a new one
+	ResendInterval time.Duration
+	// MaxEmailAttempts failed checks for one email and purpose within AttemptWindow lock it out
+	MaxEmailAttempts int
+	// MaxIPAttempts failed checks from one IP within AttemptWindow lock the IP out, across all emails
+	MaxIPAttempts int
+	AttemptWindow time.Duration
+	Lockout       time.Duration
+}
+
+func DefaultConfig() Config {
+	return Config{
+		CodeTimeout:      time.Minute * 10,
+		ResendInterval:   time.Minute,
+		MaxEmailAttempts: 5,
+		MaxIPAttempts:    20,
+		AttemptWindow:    time.Minute * 15,
+		Lockout:          time.Minute * 15,
+	}
+}
+
+// MIN_SECRET_LENGTH is the shortest key code hashes may be keyed with, in bytes
+const MIN_SECRET_LENGTH = 32
+
+var ErrSecretTooShort = errors.New("otp secret must be at least 32 bytes")
+
+// Service issues and checks one-time codes. Only a keyed hash of each code is kept and
+// every email has at most one active code per purpose.
+type Service struct {
+	config Config
+	store  Store
+	// secret keys the code hashes, every replica needs the same one for codes to outlive
+	// the process that issued them
+	secret []byte
+}
+
+func New(config Config, store Store, secret []byte) (*Service, error) {
+	if len(secret) < MIN_SECRET_LENGTH {
+		return nil, ErrSecretTooShort
+	}
+	return &Service{config: config, store: store, secret: secret}, nil
+}
+
+func (s *Service) CodeTimeout() time.Duration {
+	return s.config.CodeTimeout
+}
+
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// normalizeCode forgives case and the separators people type when copying a code
+func normalizeCode(c string) string {
+	c = strings.ToUpper(c)
+	c = strings.ReplaceAll(c, " ", "")
+	return strings.ReplaceAll(c, "-", "")
+}
+
+// codeKey is what a code is stored under, emailKey and ipKey what failed checks count against
+func codeKey(purpose string, email string) string {
+	return purpose + ":" + normalizeEmail(email)
+}
+
+func emailKey(purpose string, email string) string {
+	return "email:" + codeKey(purpose, email)
+}
+
+func ipKey(ip string) string {
+	return "ip:" + ip
+}
+
+func (s *Service) hash(key string, c string) []byte {
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write([]byte(key + "\x00" + c))
+	return mac.Sum(nil)
+}
+
+func newCode() (string, error) {
+	b := make([]byte, CODE_LENGTH)
+	max := big.NewInt(int64(len(CODE_ALPHABET)))
+	for i := range b {
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+		b[i] = CODE_ALPHABET[n.Int64()]
+	}
+	return string(b), nil
+}
+
+// Issue creates a code for email, replacing any active code for the same purpose once it
+// is ResendInterval old
+func (s *Service) Issue(purpose string, email string) (string, time.Time, error) {
+	ctx := context.Background()
+	key := codeKey(purpose, email)
+	now := time.Now()
+
+	attempts, err := s.store.GetAttempts(ctx, emailKey(purpose, email), now)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	if now.Before(attempts.LockedUntil) {
+		return "", time.Time{}, ErrLockedOut
+	}
+
+	c, err := newCode()
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	expiresAt := now.Add(s.config.CodeTimeout)
+	code := Code{Key: key, Hash: s.hash(key, c), IssuedAt: now, ExpiresAt: expiresAt}
+	issued, err := s.store.IssueCode(ctx, code, now, now.Add(-s.config.ResendInterval))
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	if !issued {
+		return "", time.Time{}, ErrTooSoon
+	}
+
+	return c, expiresAt, nil
+}
+
+// Verify checks a code for email, a correct code can only be used once. Failed checks
+// count against both the email and the IP they came from.
+func (s *Service) Verify(purpose string, email string, ip string, c string) error {
+	ctx := context.Background()
+	key := codeKey(purpose, email)
+	emailAttemptsKey, ipAttemptsKey := emailKey(purpose, email), ipKey(ip)
+	now := time.Now()
+
+	for _, attemptsKey := range []string{emailAttemptsKey, ipAttemptsKey} {
+		attempts, err := s.store.GetAttempts(ctx, attemptsKey, now)
+		if err != nil {
+			return err
+		}
+		if now.Before(attempts.LockedUntil) {
+			return ErrLockedOut
+		}
+	}
+
+	used, err := s.store.UseCode(ctx, key, s.hash(key, normalizeCode(c)), now)
+	if err != nil {
+		return err
+	}
+	if used {
+		return s.store.DeleteAttempts(ctx, emailAttemptsKey)
+	}
+
+	emailAttempts, err := s.store.FailAttempt(ctx, emailAttemptsKey, now, s.config.AttemptWindow)
+	if err != nil {
+		return err
+	}
+	ipAttempts, err := s.store.FailAttempt(ctx, ipAttemptsKey, now, s.config.AttemptWindow)
+	if err != nil {
+		return err
+	}
+	if emailAttempts.Count >= s.config.MaxEmailAttempts {
+		// a locked out code can't be guessed after the lockout either
+		if err := s.store.LockAttempts(ctx, emailAttemptsKey, now.Add(s.config.Lockout)); err != nil {
+			return err
+		}
+		if err := s.store.DeleteCode(ctx, key); err != nil {
+			return err
+		}
+	}
+	if ipAttempts.Count >= s.config.MaxIPAttempts {
+		if err := s.store.LockAttempts(ctx, ipAttemptsKey, now.Add(s.config.Lockout)); err != nil {
+			return err
+		}
+	}
+
+	return ErrInvalidCode
+}
@@ -0,0 +1,206 @@
+package otp_test
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"Rivall-Backend/util/otp"
+	"Rivall-Backend/util/test"
+)
+
+var secret = []byte(strings.Repeat("s", otp.MIN_SECRET_LENGTH))
+
+func newService(t *testing.T, config otp.Config) *otp.Service {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	s, err := otp.New(config, otp.NewMemoryStore(ctx), secret)
+	test.NoError(t, err)
+	return s
+}
+
+func TestVerify(t *testing.T) {
+	t.Parallel()
+	s := newService(t, otp.DefaultConfig())
+
+	code, _, err := s.Issue(otp.PURPOSE_RECOVERY, "Sam@Example.com")
+	test.NoError(t, err)
+	test.Equal(t, len(code), otp.CODE_LENGTH)
+
+	// codes only work for the purpose they were issued for
+	test.Equal(t, s.Verify(otp.PURPOSE_EMAIL_VERIFICATION, "sam@example.com", "10.0.0.1", code), otp.ErrInvalidCode)
+
+	test.NoError(t, s.Verify(otp.PURPOSE_RECOVERY, "sam@example.com", "10.0.0.1", strings.ToLower(code)))
+
+	// and only once
+	test.Equal(t, s.Verify(otp.PURPOSE_RECOVERY, "sam@example.com", "10.0.0.1", code), otp.ErrInvalidCode)
+}
+
+func TestSingleActiveCode(t *testing.T) {
+	t.Parallel()
+	config := otp.DefaultConfig()
+	config.ResendInterval = time.Millisecond * 20
+	s := newService(t, config)
+
+	first, _, err := s.Issue(otp.PURPOSE_RECOVERY, "sam@example.com")
+	test.NoError(t, err)
+
+	// a fresh code can't be replaced straight away
+	_, _, err = s.Issue(otp.PURPOSE_RECOVERY, "sam@example.com")
+	test.Equal(t, err, otp.ErrTooSoon)
+
+	time.Sleep(config.ResendInterval)
+	second, _, err := s.Issue(otp.PURPOSE_RECOVERY, "sam@example.com")
+	test.NoError(t, err)
+
+	if first != second {
+		test.Equal(t, s.Verify(otp.PURPOSE_RECOVERY, "sam@example.com", "10.0.0.1", first), otp.ErrInvalidCode)
+	}
+	test.NoError(t, s.Verify(otp.PURPOSE_RECOVERY, "sam@example.com", "10.0.0.1", second))
+}
+
+func TestExpiredCode(t *testing.T) {
+	t.Parallel()
+	config := otp.DefaultConfig()
+	config.CodeTimeout = time.Millisecond
+	s := newService(t, config)
+
+	code, _, err := s.Issue(otp.PURPOSE_RECOVERY, "sam@example.com")
+	test.NoError(t, err)
+	time.Sleep(time.Millisecond * 5)
+	test.Equal(t, s.Verify(otp.PURPOSE_RECOVERY, "sam@example.com", "10.0.0.1", code), otp.ErrInvalidCode)
+}
+
+func TestEmailLockout(t *testing.T) {
+	t.Parallel()
+	config := otp.DefaultConfig()
+	config.MaxEmailAttempts = 3
+	config.Lockout = time.Millisecond * 50
+	s := newService(t, config)
+
+	code, _, err := s.Issue(otp.PURPOSE_RECOVERY, "sam@example.com")
+	test.NoError(t, err)
+
+	for i := 0; i < config.MaxEmailAttempts; i++ {
+		test.Equal(t, s.Verify(otp.PURPOSE_RECOVERY, "sam@example.com", "10.0.0.1", "WRONG"), otp.ErrInvalidCode)
+	}
+
+	// locked out even with the right code, and no new code can be issued
+	test.Equal(t, s.Verify(otp.PURPOSE_RECOVERY, "sam@example.com", "10.0.0.2", code), otp.ErrLockedOut)
+	_, _, err = s.Issue(otp.PURPOSE_RECOVERY, "sam@example.com")
+	test.Equal(t, err, otp.ErrLockedOut)
+
+	// the lockout ends, the guessed at code does not come back
+	time.Sleep(config.Lockout)
+	test.Equal(t, s.Verify(otp.PURPOSE_RECOVERY, "sam@example.com", "10.0.0.2", code), otp.ErrInvalidCode)
+}
+
+func TestIPLockout(t *testing.T) {
+	t.Parallel()
+	config := otp.DefaultConfig()
+	config.MaxIPAttempts = 3
+	s := newService(t, config)
+
+	code, _, err := s.Issue(otp.PURPOSE_RECOVERY, "victim@example.com")
+	test.NoError(t, err)
+
+	// guessing across many emails from one IP
+	for _, email := range []string{"a@example.com", "b@example.com", "c@example.com"} {
+		test.Equal(t, s.Verify(otp.PURPOSE_RECOVERY, email, "10.0.0.1", "WRONG"), otp.ErrInvalidCode)
+	}
+
+	test.Equal(t, s.Verify(otp.PURPOSE_RECOVERY, "victim@example.com", "10.0.0.1", code), otp.ErrLockedOut)
+	test.NoError(t, s.Verify(otp.PURPOSE_RECOVERY, "victim@example.com", "10.0.0.2", code))
+}
+
+func TestConcurrentUse(t *testing.T) {
+	t.Parallel()
+	config := otp.DefaultConfig()
+	config.ResendInterval = 0
+	s := newService(t, config)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			code, _, err := s.Issue(otp.PURPOSE_LOGIN_CONFIRMATION, "sam@example.com")
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			s.Verify(otp.PURPOSE_LOGIN_CONFIRMATION, "sam@example.com", "10.0.0.1", code)
+		}()
+	}
+	wg.Wait()
+}
+
+func TestNewNeedsSecret(t *testing.T) {
+	t.Parallel()
+	_, err := otp.New(otp.DefaultConfig(), otp.NewMemoryStore(context.Background()), []byte("short"))
+	test.Equal(t, err, otp.ErrSecretTooShort)
+}
+
+// replicas share their store and secret, like a restarted process shares them with the
+// one before it
+func testReplicas(t *testing.T, store otp.Store) {
+	config := otp.DefaultConfig()
+	config.MaxEmailAttempts = 4
+	a, err := otp.New(config, store, secret)
+	test.NoError(t, err)
+	b, err := otp.New(config, store, secret)
+	test.NoError(t, err)
+
+	code, _, err := a.Issue(otp.PURPOSE_RECOVERY, "sam@example.com")
+	test.NoError(t, err)
+	_, _, err = b.Issue(otp.PURPOSE_RECOVERY, "sam@example.com")
+	test.Equal(t, err, otp.ErrTooSoon)
+	test.NoError(t, b.Verify(otp.PURPOSE_RECOVERY, "sam@example.com", "10.0.0.1", code))
+	test.Equal(t, a.Verify(otp.PURPOSE_RECOVERY, "sam@example.com", "10.0.0.1", code), otp.ErrInvalidCode)
+
+	// guesses spread over replicas add up
+	code, _, err = b.Issue(otp.PURPOSE_EMAIL_VERIFICATION, "sam@example.com")
+	test.NoError(t, err)
+	for i := 0; i < config.MaxEmailAttempts; i++ {
+		replica := []*otp.Service{a, b}[i%2]
+		test.Equal(t, replica.Verify(otp.PURPOSE_EMAIL_VERIFICATION, "sam@example.com", "10.0.0.1", "WRONG"), otp.ErrInvalidCode)
+	}
+	test.Equal(t, a.Verify(otp.PURPOSE_EMAIL_VERIFICATION, "sam@example.com", "10.0.0.1", code), otp.ErrLockedOut)
+	test.Equal(t, b.Verify(otp.PURPOSE_EMAIL_VERIFICATION, "sam@example.com", "10.0.0.1", code), otp.ErrLockedOut)
+
+	// a correct code is only accepted once however many replicas race to check it
+	code, _, err = a.Issue(otp.PURPOSE_LOGIN_CONFIRMATION, "sam@example.com")
+	test.NoError(t, err)
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	accepted := 0
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(s *otp.Service) {
+			defer wg.Done()
+			if s.Verify(otp.PURPOSE_LOGIN_CONFIRMATION, "sam@example.com", "10.0.0.2", code) == nil {
+				mu.Lock()
+				accepted++
+				mu.Unlock()
+			}
+		}([]*otp.Service{a, b}[i%2])
+	}
+	wg.Wait()
+	test.Equal(t, accepted, 1)
+}
+
+func TestReplicasShareMemoryStore(t *testing.T) {
+	t.Parallel()
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	testReplicas(t, otp.NewMemoryStore(ctx))
+}
+
+func TestReplicasShareMongoStore(t *testing.T) {
+	database := test.Mongo(t)
+	store, err := otp.NewMongoStore(context.Background(), database)
+	test.NoError(t, err)
+	testReplicas(t, store)
+}
@@ -0,0 +1,41 @@
+package otp
+
+import (
+	"context"
+	"time"
+)
+
+// Code is the keyed hash of the active code for one purpose and email
+type Code struct {
+	Key       string    `bson:"_id"`
+	Hash      []byte    `bson:"hash"`
+	IssuedAt  time.Time `bson:"issued_at"`
+	ExpiresAt time.Time `bson:"expires_at"`
+}
+
+// Attempts counts the failed checks of one email and purpose, or of one IP
+type Attempts struct {
+	Key         string    `bson:"_id"`
+	Count       int       `bson:"count"`
+	LockedUntil time.Time `bson:"locked_until"`
+	// ExpiresAt is when the failures are forgotten, the end of their window or lockout
+	ExpiresAt time.Time `bson:"expires_at"`
+}
+
+// Store keeps codes and attempts. Expired codes and attempts read as missing.
+type Store interface {
+	// IssueCode saves code unless its key has an unexpired code issued after notBefore,
+	// reporting false then
+	IssueCode(ctx context.Context, code Code, now time.Time, notBefore time.Time) (bool, error)
+	// UseCode deletes the key's code if it is unexpired and has hash, reporting whether it did.
+	// Only one of several concurrent uses succeeds.
+	UseCode(ctx context.Context, key string, hash []byte, now time.Time) (bool, error)
+	DeleteCode(ctx context.Context, key string) error
+
+	GetAttempts(ctx context.Context, key string, now time.Time) (Attempts, error)
+	// FailAttempt counts a failed check, a key without attempts starts a window ending window later
+	FailAttempt(ctx context.Context, key string, now time.Time, window time.Duration) (Attempts, error)
+	// LockAttempts locks a key out until lockedUntil
+	LockAttempts(ctx context.Context, key string, lockedUntil time.Time) error
+	DeleteAttempts(ctx context.Context, key string) error
+}
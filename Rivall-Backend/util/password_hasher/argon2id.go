@@ -0,0 +1,109 @@
+package password_hasher
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+)
+
+var ErrInvalidHash = errors.New("invalid argon2id hash")
+
+// Argon2id makes hashes in the PHC string format, $argon2id$v=19$m=65536,t=3,p=2$salt$key
+type Argon2id struct {
+	// Memory is in KiB
+	Memory      uint32
+	Iterations  uint32
+	Parallelism uint8
+	SaltLength  uint32
+	KeyLength   uint32
+}
+
+func DefaultArgon2id() Argon2id {
+	return Argon2id{
+		Memory:      64 * 1024,
+		Iterations:  3,
+		Parallelism: 2,
+		SaltLength:  16,
+		KeyLength:   32,
+	}
+}
+
+var encoding = base64.RawStdEncoding
+
+func (a Argon2id) Hash(password string) (string, error) {
+	salt := make([]byte, a.SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+
+	key := argon2.IDKey([]byte(password), salt, a.Iterations, a.Memory, a.Parallelism, a.KeyLength)
+
+	return fmt.Sprintf(
+		"$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, a.Memory, a.Iterations, a.Parallelism,
+		encoding.EncodeToString(salt), encoding.EncodeToString(key),
+	), nil
+}
+
+func (a Argon2id) Recognizes(hash string) bool {
+	return strings.HasPrefix(hash, "$argon2id$")
+}
+
+// decode reads the parameters, salt and key out of a hash
+func (a Argon2id) decode(hash string) (Argon2id, []byte, []byte, error) {
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 || parts[1] != "argon2id" {
+		return Argon2id{}, nil, nil, ErrInvalidHash
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return Argon2id{}, nil, nil, ErrInvalidHash
+	}
+
+	var params Argon2id
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.Memory, &params.Iterations, &params.Parallelism); err != nil {
+		return Argon2id{}, nil, nil, ErrInvalidHash
+	}
+
+	salt, err := encoding.DecodeString(parts[4])
+	if err != nil {
+		return Argon2id{}, nil, nil, ErrInvalidHash
+	}
+	key, err := encoding.DecodeString(parts[5])
+	if err != nil {
+		return Argon2id{}, nil, nil, ErrInvalidHash
+	}
+	params.SaltLength = uint32(len(salt))
+	params.KeyLength = uint32(len(key))
+
+	return params, salt, key, nil
+}
+
+func (a Argon2id) Verify(hash string, password string) (bool, error) {
+	params, salt, key, err := a.decode(hash)
+	if err != nil {
+		return false, err
+	}
+
+	// hash with the parameters the stored hash was made with, not the current ones
+	other := argon2.IDKey([]byte(password), salt, params.Iterations, params.Memory, params.Parallelism, params.KeyLength)
+	return subtle.ConstantTimeCompare(key, other) == 1, nil
+}
+
+func (a Argon2id) NeedsRehash(hash string) bool {
+	params, _, _, err := a.decode(hash)
+	if err != nil {
+		return true
+	}
+	return params.Memory < a.Memory ||
+		params.Iterations < a.Iterations ||
+		params.Parallelism < a.Parallelism ||
+		params.SaltLength < a.SaltLength ||
+		params.KeyLength < a.KeyLength
+}
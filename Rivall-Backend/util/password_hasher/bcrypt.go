@@ -0,0 +1,41 @@
+package password_hasher
+
+import (
+	"errors"
+	"strings"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// BCRYPT_MAX_BYTES is the longest password bcrypt hashes, it refuses longer ones
+const BCRYPT_MAX_BYTES = 72
+
+// Bcrypt makes hashes like $2a$12$..., it only uses the first 72 bytes of a password
+type Bcrypt struct {
+	Cost int
+}
+
+func (b Bcrypt) Hash(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), b.Cost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+func (b Bcrypt) Recognizes(hash string) bool {
+	return strings.HasPrefix(hash, "$2a$") || strings.HasPrefix(hash, "$2b$") || strings.HasPrefix(hash, "$2y$")
+}
+
+func (b Bcrypt) Verify(hash string, password string) (bool, error) {
+	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
+	if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (b Bcrypt) NeedsRehash(hash string) bool {
+	cost, err := bcrypt.Cost([]byte(hash))
+	return err != nil || cost < b.Cost
+}
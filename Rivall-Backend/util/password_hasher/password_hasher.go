@@ -0,0 +1,53 @@
+package password_hasher
+
+import (
+	"errors"
+)
+
+var ErrUnknownHash = errors.New("hash was not made by a known hasher")
+
+// Hasher hashes passwords into self-describing strings that carry their algorithm and
+// parameters, so a hash can be checked after the defaults change
+type Hasher interface {
+	Hash(password string) (string, error)
+	// Recognizes reports if hash was made by this hasher's algorithm
+	Recognizes(hash string) bool
+	// Verify checks password against a hash this hasher recognizes
+	Verify(hash string, password string) (bool, error)
+	// NeedsRehash reports if a recognized hash was made with weaker parameters than the hasher's
+	NeedsRehash(hash string) bool
+}
+
+// Hashers hashes new passwords with a default hasher and verifies hashes from any of them
+type Hashers struct {
+	preferred Hasher
+	hashers   []Hasher
+}
+
+func New(preferred Hasher, others ...Hasher) *Hashers {
+	return &Hashers{
+		preferred: preferred,
+		hashers:   append([]Hasher{preferred}, others...),
+	}
+}
+
+func (h *Hashers) Hash(password string) (string, error) {
+	return h.preferred.Hash(password)
+}
+
+// Verify checks password against hash. A matching hash made by another hasher or with
+// outdated parameters is reported with rehash, so it can be replaced with a fresh one.
+func (h *Hashers) Verify(hash string, password string) (ok bool, rehash bool, err error) {
+	for _, hasher := range h.hashers {
+		if !hasher.Recognizes(hash) {
+			continue
+		}
+
+		ok, err := hasher.Verify(hash, password)
+		if err != nil || !ok {
+			return false, false, err
+		}
+		return true, hasher != h.preferred || hasher.NeedsRehash(hash), nil
+	}
+	return false, false, ErrUnknownHash
+}
@@ -0,0 +1,106 @@
+package password_hasher_test
+
+import (
+	"strings"
+	"testing"
+
+	"Rivall-Backend/util/password_hasher"
+	"Rivall-Backend/util/test"
+)
+
+// fastArgon2id keeps tests quick, real deployments use DefaultArgon2id
+var fastArgon2id = password_hasher.Argon2id{Memory: 1024, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32}
+
+func TestHashers(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		hasher password_hasher.Hasher
+		prefix string
+	}{
+		{"bcrypt", password_hasher.Bcrypt{Cost: 4}, "$2a$04$"},
+		{"argon2id", fastArgon2id, "$argon2id$v=19$m=1024,t=1,p=1$"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			hashers := password_hasher.New(tt.hasher)
+
+			hash, err := hashers.Hash("correct horse")
+			test.NoError(t, err)
+			test.Equal(t, strings.HasPrefix(hash, tt.prefix), true)
+
+			ok, rehash, err := hashers.Verify(hash, "correct horse")
+			test.NoError(t, err)
+			test.Equal(t, ok, true)
+			test.Equal(t, rehash, false)
+
+			ok, _, err = hashers.Verify(hash, "wrong horse")
+			test.NoError(t, err)
+			test.Equal(t, ok, false)
+		})
+	}
+}
+
+func TestRehashOtherAlgorithm(t *testing.T) {
+	t.Parallel()
+
+	old := password_hasher.New(password_hasher.Bcrypt{Cost: 4})
+	hash, err := old.Hash("correct horse")
+	test.NoError(t, err)
+
+	// bcrypt hashes still verify after moving to argon2id, and ask to be replaced
+	hashers := password_hasher.New(fastArgon2id, password_hasher.Bcrypt{Cost: 4})
+	ok, rehash, err := hashers.Verify(hash, "correct horse")
+	test.NoError(t, err)
+	test.Equal(t, ok, true)
+	test.Equal(t, rehash, true)
+
+	// a wrong password never asks for a rehash
+	ok, rehash, err = hashers.Verify(hash, "wrong horse")
+	test.NoError(t, err)
+	test.Equal(t, ok, false)
+	test.Equal(t, rehash, false)
+}
+
+func TestRehashOutdatedParameters(t *testing.T) {
+	t.Parallel()
+
+	bcryptHash, err := password_hasher.Bcrypt{Cost: 4}.Hash("correct horse")
+	test.NoError(t, err)
+	test.Equal(t, password_hasher.Bcrypt{Cost: 5}.NeedsRehash(bcryptHash), true)
+	test.Equal(t, password_hasher.Bcrypt{Cost: 4}.NeedsRehash(bcryptHash), false)
+
+	argonHash, err := fastArgon2id.Hash("correct horse")
+	test.NoError(t, err)
+	stronger := fastArgon2id
+	stronger.Iterations = 2
+	test.Equal(t, stronger.NeedsRehash(argonHash), true)
+
+	// hashes keep verifying with the parameters they were made with
+	ok, err := stronger.Verify(argonHash, "correct horse")
+	test.NoError(t, err)
+	test.Equal(t, ok, true)
+}
+
+func TestUnknownHash(t *testing.T) {
+	t.Parallel()
+	hashers := password_hasher.New(fastArgon2id, password_hasher.Bcrypt{Cost: 4})
+
+	_, _, err := hashers.Verify("plaintext", "plaintext")
+	test.Equal(t, err, password_hasher.ErrUnknownHash)
+
+	_, _, err = hashers.Verify("$argon2id$v=19$garbage", "correct horse")
+	test.Equal(t, err, password_hasher.ErrInvalidHash)
+}
+
+func TestBcryptTooLong(t *testing.T) {
+	t.Parallel()
+
+	_, err := password_hasher.Bcrypt{Cost: 4}.Hash(strings.Repeat("a", 73))
+	if err == nil {
+		t.Fatal("expected bcrypt to refuse passwords over 72 bytes")
+	}
+}
@@ -0,0 +1,93 @@
+package rate_limiter
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// MemoryStore keeps failure counts in process, each replica counts on its own
+type MemoryStore struct {
+	entries map[string]Entry
+
+	// mu is named rather than embedded, Lock is taken by the Store method
+	mu sync.Mutex
+}
+
+func NewMemoryStore(ctx context.Context) *MemoryStore {
+	s := MemoryStore{entries: make(map[string]Entry)}
+
+	go s.Retention(ctx)
+
+	return &s
+}
+
+func (s *MemoryStore) Get(ctx context.Context, key string, now time.Time) (Entry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry, ok := s.entries[key]
+	if !ok || !now.Before(entry.ExpiresAt) {
+		return Entry{Key: key}, nil
+	}
+	return entry, nil
+}
+
+func (s *MemoryStore) Fail(ctx context.Context, key string, now time.Time, window time.Duration) (Entry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry, ok := s.entries[key]
+	if !ok || !now.Before(entry.ExpiresAt) {
+		entry = Entry{Key: key}
+	}
+	entry.Failures++
+	if expiresAt := now.Add(window); expiresAt.After(entry.ExpiresAt) {
+		entry.ExpiresAt = expiresAt
+	}
+	s.entries[key] = entry
+	return entry, nil
+}
+
+func (s *MemoryStore) Lock(ctx context.Context, key string, lockedUntil time.Time, window time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry := s.entries[key]
+	entry.Key = key
+	entry.LockedUntil = lockedUntil
+	if expiresAt := lockedUntil.Add(window); expiresAt.After(entry.ExpiresAt) {
+		entry.ExpiresAt = expiresAt
+	}
+	s.entries[key] = entry
+	return nil
+}
+
+func (s *MemoryStore) Delete(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.entries, key)
+	return nil
+}
+
+// Retention drops expired entries until ctx is done
+func (s *MemoryStore) Retention(ctx context.Context) {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			now := time.Now()
+			s.mu.Lock()
+			for key, entry := range s.entries {
+				if !now.Before(entry.ExpiresAt) {
+					delete(s.entries, key)
+				}
+			}
+			s.mu.Unlock()
+		case <-ctx.Done():
+			return
+		}
+	}
+}
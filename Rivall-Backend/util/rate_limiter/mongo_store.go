@@ -0,0 +1,68 @@
+package rate_limiter
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// MongoStore keeps failure counts in MongoDB so every replica sees the same lockouts.
+// A TTL index removes expired entries.
+type MongoStore struct {
+	entries *mongo.Collection
+}
+
+func NewMongoStore(ctx context.Context, collection *mongo.Collection) (*MongoStore, error) {
+	_, err := collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "expires_at", Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(0),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &MongoStore{entries: collection}, nil
+}
+
+func (s *MongoStore) Get(ctx context.Context, key string, now time.Time) (Entry, error) {
+	var entry Entry
+	// the TTL monitor only runs about once a minute
+	err := s.entries.FindOne(ctx, bson.M{"_id": key, "expires_at": bson.M{"$gt": now}}).Decode(&entry)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return Entry{Key: key}, nil
+	}
+	return entry, err
+}
+
+func (s *MongoStore) Fail(ctx context.Context, key string, now time.Time, window time.Duration) (Entry, error) {
+	// start over from an expired entry the TTL monitor hasn't removed yet
+	if _, err := s.entries.DeleteOne(ctx, bson.M{"_id": key, "expires_at": bson.M{"$lte": now}}); err != nil {
+		return Entry{}, err
+	}
+
+	var entry Entry
+	update := bson.M{
+		"$inc": bson.M{"failures": 1},
+		"$max": bson.M{"expires_at": now.Add(window)},
+	}
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+	err := s.entries.FindOneAndUpdate(ctx, bson.M{"_id": key}, update, opts).Decode(&entry)
+	return entry, err
+}
+
+func (s *MongoStore) Lock(ctx context.Context, key string, lockedUntil time.Time, window time.Duration) error {
+	update := bson.M{
+		"$set": bson.M{"locked_until": lockedUntil},
+		"$max": bson.M{"expires_at": lockedUntil.Add(window)},
+	}
+	_, err := s.entries.UpdateOne(ctx, bson.M{"_id": key}, update, options.UpdateOne().SetUpsert(true))
+	return err
+}
+
+func (s *MongoStore) Delete(ctx context.Context, key string) error {
+	_, err := s.entries.DeleteOne(ctx, bson.M{"_id": key})
+	return err
+}
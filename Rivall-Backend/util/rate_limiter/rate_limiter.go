@@ -0,0 +1,143 @@
+package rate_limiter
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+const (
+	KIND_IP      = "ip"
+	KIND_ACCOUNT = "account"
+)
+
+var ErrLimited = errors.New("too many attempts, try again later")
+
+// Policy decides when failures lock a key out. The first lockout lasts BaseDelay and
+// every failure after it doubles the next one, up to MaxDelay. Failures are forgotten
+// Window after the last one or after the lockout ends. A zero Threshold disables the policy.
+type Policy struct {
+	Threshold int
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+	Window    time.Duration
+}
+
+func (p Policy) delay(failures int) time.Duration {
+	delay := p.BaseDelay
+	for i := p.Threshold; i < failures && delay < p.MaxDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, p.MaxDelay)
+}
+
+// Lockout describes a key that was just locked out
+type Lockout struct {
+	Limiter     string
+	Kind        string
+	Value       string
+	Failures    int
+	LockedUntil time.Time
+}
+
+// Limiter throttles one endpoint by the client IP and the account it acts on
+type Limiter struct {
+	store   Store
+	name    string
+	ip      Policy
+	account Policy
+	// onLockout is called whenever a key gets locked out, to keep an audit trail
+	onLockout func(Lockout)
+}
+
+func New(store Store, name string, ip Policy, account Policy, onLockout func(Lockout)) *Limiter {
+	return &Limiter{
+		store:     store,
+		name:      name,
+		ip:        ip,
+		account:   account,
+		onLockout: onLockout,
+	}
+}
+
+type limitKey struct {
+	kind   string
+	value  string
+	policy Policy
+}
+
+func (l *Limiter) keys(ip string, account string) []limitKey {
+	keys := []limitKey{}
+	if l.ip.Threshold > 0 && ip != "" {
+		keys = append(keys, limitKey{kind: KIND_IP, value: ip, policy: l.ip})
+	}
+	if l.account.Threshold > 0 && account != "" {
+		keys = append(keys, limitKey{kind: KIND_ACCOUNT, value: account, policy: l.account})
+	}
+	return keys
+}
+
+func (l *Limiter) storeKey(key limitKey) string {
+	return l.name + ":" + key.kind + ":" + key.value
+}
+
+// Check returns ErrLimited and how long to wait when the IP or the account is locked out.
+// An empty ip or account is not checked.
+func (l *Limiter) Check(ctx context.Context, ip string, account string) (time.Duration, error) {
+	now := time.Now()
+	var wait time.Duration
+
+	for _, key := range l.keys(ip, account) {
+		entry, err := l.store.Get(ctx, l.storeKey(key), now)
+		if err != nil {
+			return 0, err
+		}
+		wait = max(wait, entry.LockedUntil.Sub(now))
+	}
+
+	if wait > 0 {
+		return wait, ErrLimited
+	}
+	return 0, nil
+}
+
+// Fail records a failed attempt against the IP and the account, locking out any that
+// reach their threshold
+func (l *Limiter) Fail(ctx context.Context, ip string, account string) error {
+	now := time.Now()
+
+	for _, key := range l.keys(ip, account) {
+		storeKey := l.storeKey(key)
+		entry, err := l.store.Fail(ctx, storeKey, now, key.policy.Window)
+		if err != nil {
+			return err
+		}
+		if entry.Failures < key.policy.Threshold {
+			continue
+		}
+
+		lockedUntil := now.Add(key.policy.delay(entry.Failures))
+		if err := l.store.Lock(ctx, storeKey, lockedUntil, key.policy.Window); err != nil {
+			return err
+		}
+		if l.onLockout != nil {
+			l.onLockout(Lockout{
+				Limiter:     l.name,
+				Kind:        key.kind,
+				Value:       key.value,
+				Failures:    entry.Failures,
+				LockedUntil: lockedUntil,
+			})
+		}
+	}
+	return nil
+}
+
+// Reset forgets the failures of an account after it succeeds. IPs are never reset, one
+// good login must not clear the way for guessing at other accounts.
+func (l *Limiter) Reset(ctx context.Context, account string) error {
+	if l.account.Threshold == 0 || account == "" {
+		return nil
+	}
+	return l.store.Delete(ctx, l.storeKey(limitKey{kind: KIND_ACCOUNT, value: account}))
+}
@@ -0,0 +1,123 @@
+package rate_limiter_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"Rivall-Backend/util/rate_limiter"
+	"Rivall-Backend/util/test"
+)
+
+var policy = rate_limiter.Policy{
+	Threshold: 3,
+	BaseDelay: time.Millisecond * 40,
+	MaxDelay:  time.Millisecond * 100,
+	Window:    time.Second,
+}
+
+type lockouts struct {
+	seen []rate_limiter.Lockout
+	sync.Mutex
+}
+
+func (l *lockouts) record(lockout rate_limiter.Lockout) {
+	l.Lock()
+	defer l.Unlock()
+	l.seen = append(l.seen, lockout)
+}
+
+func newLimiter(t *testing.T, ip rate_limiter.Policy, account rate_limiter.Policy) (*rate_limiter.Limiter, *lockouts) {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	l := &lockouts{}
+	return rate_limiter.New(rate_limiter.NewMemoryStore(ctx), "login", ip, account, l.record), l
+}
+
+func TestAccountLockout(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	limiter, l := newLimiter(t, rate_limiter.Policy{}, policy)
+
+	for i := 0; i < policy.Threshold-1; i++ {
+		test.NoError(t, limiter.Fail(ctx, "10.0.0.1", "sam@example.com"))
+	}
+	_, err := limiter.Check(ctx, "10.0.0.1", "sam@example.com")
+	test.NoError(t, err)
+
+	test.NoError(t, limiter.Fail(ctx, "10.0.0.1", "sam@example.com"))
+	wait, err := limiter.Check(ctx, "10.0.0.2", "sam@example.com")
+	test.Equal(t, err, rate_limiter.ErrLimited)
+	if wait <= 0 || wait > policy.BaseDelay {
+		t.Fatalf("expected to wait up to %v, got %v", policy.BaseDelay, wait)
+	}
+
+	// other accounts are untouched
+	_, err = limiter.Check(ctx, "10.0.0.1", "alex@example.com")
+	test.NoError(t, err)
+
+	test.Equal(t, len(l.seen), 1)
+	test.Equal(t, l.seen[0].Kind, rate_limiter.KIND_ACCOUNT)
+	test.Equal(t, l.seen[0].Value, "sam@example.com")
+	test.Equal(t, l.seen[0].Failures, policy.Threshold)
+}
+
+func TestBackoff(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	limiter, l := newLimiter(t, rate_limiter.Policy{}, policy)
+
+	for i := 0; i < policy.Threshold; i++ {
+		test.NoError(t, limiter.Fail(ctx, "", "sam@example.com"))
+	}
+	time.Sleep(policy.BaseDelay)
+	_, err := limiter.Check(ctx, "", "sam@example.com")
+	test.NoError(t, err)
+
+	// each failure after the lockout doubles the next one, up to the max
+	test.NoError(t, limiter.Fail(ctx, "", "sam@example.com"))
+	test.NoError(t, limiter.Fail(ctx, "", "sam@example.com"))
+
+	test.Equal(t, len(l.seen), 3)
+	first := l.seen[0].LockedUntil.Sub(time.Now())
+	second := l.seen[1].LockedUntil.Sub(time.Now())
+	third := l.seen[2].LockedUntil.Sub(time.Now())
+	if second <= first || third > policy.MaxDelay {
+		t.Fatalf("expected growing lockouts capped at %v, got %v, %v, %v", policy.MaxDelay, first, second, third)
+	}
+}
+
+func TestIPLockoutAcrossAccounts(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	limiter, _ := newLimiter(t, policy, rate_limiter.Policy{Threshold: 100, BaseDelay: time.Second, MaxDelay: time.Second, Window: time.Second})
+
+	for _, account := range []string{"a@example.com", "b@example.com", "c@example.com"} {
+		test.NoError(t, limiter.Fail(ctx, "10.0.0.1", account))
+	}
+
+	_, err := limiter.Check(ctx, "10.0.0.1", "d@example.com")
+	test.Equal(t, err, rate_limiter.ErrLimited)
+	_, err = limiter.Check(ctx, "10.0.0.2", "d@example.com")
+	test.NoError(t, err)
+}
+
+func TestReset(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	limiter, _ := newLimiter(t, policy, policy)
+
+	for i := 0; i < policy.Threshold-1; i++ {
+		test.NoError(t, limiter.Fail(ctx, "10.0.0.1", "sam@example.com"))
+	}
+	test.NoError(t, limiter.Reset(ctx, "sam@example.com"))
+
+	// the account starts over, the IP does not
+	test.NoError(t, limiter.Fail(ctx, "10.0.0.1", "sam@example.com"))
+	_, err := limiter.Check(ctx, "", "sam@example.com")
+	test.NoError(t, err)
+	_, err = limiter.Check(ctx, "10.0.0.1", "")
+	test.Equal(t, err, rate_limiter.ErrLimited)
+}
@@ -0,0 +1,25 @@
+package rate_limiter
+
+import (
+	"context"
+	"time"
+)
+
+// Entry counts the failures of one key
+type Entry struct {
+	Key         string    `bson:"_id"`
+	Failures    int       `bson:"failures"`
+	LockedUntil time.Time `bson:"locked_until"`
+	// ExpiresAt is when the failures are forgotten
+	ExpiresAt time.Time `bson:"expires_at"`
+}
+
+// Store keeps failure counts. Get returns a zero Entry for unknown or expired keys.
+type Store interface {
+	Get(ctx context.Context, key string, now time.Time) (Entry, error)
+	// Fail counts a failure, starting over if the key expired, and keeps it for at least window
+	Fail(ctx context.Context, key string, now time.Time, window time.Duration) (Entry, error)
+	// Lock locks a key out until lockedUntil and keeps it for window after that
+	Lock(ctx context.Context, key string, lockedUntil time.Time, window time.Duration) error
+	Delete(ctx context.Context, key string) error
+}
@@ -0,0 +1,170 @@
+package recommender
+
+import (
+	"math"
+	"sort"
+)
+
+// Template holds the signals of a published challenge template used for ranking
+type Template struct {
+	ID               string
+	MetricType       string
+	AverageRating    float64
+	RatingCount      int
+	ParticipantCount int
+	CompletionRate   float64
+	TimesUsed        int
+}
+
+// PastChallenge is a challenge a group has already run
+type PastChallenge struct {
+	TemplateID       string
+	MetricType       string
+	ParticipantCount int
+	CompletionRate   float64
+	Completed        bool
+}
+
+// GroupHistory is what we know about the group we are recommending for
+type GroupHistory struct {
+	MemberCount int
+	Challenges  []PastChallenge
+}
+
+type Scored struct {
+	Template Template
+	Score    float64
+}
+
+const (
+	// Weights of each signal, they add up to 1
+	weightRating        = 0.35
+	weightCompletion    = 0.25
+	weightMetricType    = 0.20
+	weightParticipation = 0.15
+	weightPopularity    = 0.05
+
+	// Bayesian prior so a single 5 star rating does not beat many 4.5 star ratings
+	priorRating = 3.0
+	priorWeight = 5.0
+
+	// Templates the group has already used are pushed down the list
+	reusePenalty = 0.5
+
+	// Score given to a signal we have no history for
+	neutralScore = 0.5
+)
+
+// Rank orders templates from most to least suitable for the group
+func Rank(templates []Template, history GroupHistory) []Scored {
+	metricAffinity := metricAffinities(history)
+	usedTemplates := make(map[string]bool)
+	for _, challenge := range history.Challenges {
+		if challenge.TemplateID != "" {
+			usedTemplates[challenge.TemplateID] = true
+		}
+	}
+	groupSize := groupSize(history)
+
+	maxUsed := 0
+	for _, template := range templates {
+		if template.TimesUsed > maxUsed {
+			maxUsed = template.TimesUsed
+		}
+	}
+
+	scored := make([]Scored, 0, len(templates))
+	for _, template := range templates {
+		score := weightRating*ratingScore(template) +
+			weightCompletion*clamp(template.CompletionRate) +
+			weightMetricType*metricScore(template.MetricType, metricAffinity) +
+			weightParticipation*participationScore(template.ParticipantCount, groupSize) +
+			weightPopularity*popularityScore(template.TimesUsed, maxUsed)
+
+		if usedTemplates[template.ID] {
+			score *= reusePenalty
+		}
+
+		scored = append(scored, Scored{Template: template, Score: score})
+	}
+
+	sort.SliceStable(scored, func(i, j int) bool {
+		if scored[i].Score != scored[j].Score {
+			return scored[i].Score > scored[j].Score
+		}
+		return scored[i].Template.ID < scored[j].Template.ID
+	})
+
+	return scored
+}
+
+func ratingScore(template Template) float64 {
+	n := float64(template.RatingCount)
+	weighted := (priorWeight*priorRating + n*template.AverageRating) / (priorWeight + n)
+	return clamp(weighted / 5)
+}
+
+// metricAffinities scores each metric type by how often the group used it and how well those went
+func metricAffinities(history GroupHistory) map[string]float64 {
+	affinity := make(map[string]float64)
+	if len(history.Challenges) == 0 {
+		return affinity
+	}
+
+	counts := make(map[string]int)
+	completion := make(map[string]float64)
+	for _, challenge := range history.Challenges {
+		counts[challenge.MetricType]++
+		completion[challenge.MetricType] += challenge.CompletionRate
+	}
+
+	for metricType, count := range counts {
+		share := float64(count) / float64(len(history.Challenges))
+		avgCompletion := completion[metricType] / float64(count)
+		affinity[metricType] = clamp(0.5*share + 0.5*avgCompletion)
+	}
+	return affinity
+}
+
+func metricScore(metricType string, affinity map[string]float64) float64 {
+	if len(affinity) == 0 {
+		return neutralScore
+	}
+	return affinity[metricType]
+}
+
+// groupSize prefers the group's actual turnout over its member count
+func groupSize(history GroupHistory) int {
+	total, runs := 0, 0
+	for _, challenge := range history.Challenges {
+		if challenge.ParticipantCount > 0 {
+			total += challenge.ParticipantCount
+			runs++
+		}
+	}
+	if runs > 0 {
+		return int(math.Round(float64(total) / float64(runs)))
+	}
+	return history.MemberCount
+}
+
+// participationScore is 1 when the template was run with as many people as the group has,
+// and falls off as the sizes differ by orders of magnitude
+func participationScore(templateSize int, groupSize int) float64 {
+	if templateSize <= 0 || groupSize <= 0 {
+		return neutralScore
+	}
+	ratio := math.Abs(math.Log(float64(templateSize) / float64(groupSize)))
+	return clamp(1 - ratio/math.Log(10))
+}
+
+func popularityScore(timesUsed int, maxUsed int) float64 {
+	if maxUsed <= 0 {
+		return 0
+	}
+	return math.Log1p(float64(timesUsed)) / math.Log1p(float64(maxUsed))
+}
+
+func clamp(v float64) float64 {
+	return math.Max(0, math.Min(1, v))
+}
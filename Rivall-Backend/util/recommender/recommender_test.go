@@ -0,0 +1,78 @@
+package recommender_test
+
+import (
+	"testing"
+
+	"Rivall-Backend/util/recommender"
+	"Rivall-Backend/util/test"
+)
+
+type testCase struct {
+	name      string
+	templates []recommender.Template
+	history   recommender.GroupHistory
+	expected  string
+}
+
+var tests = []*testCase{
+	{
+		name: `many good ratings beat one perfect rating`,
+		templates: []recommender.Template{
+			{ID: "a", MetricType: "count", AverageRating: 5, RatingCount: 1, CompletionRate: 0.8, ParticipantCount: 4},
+			{ID: "b", MetricType: "count", AverageRating: 4.6, RatingCount: 40, CompletionRate: 0.8, ParticipantCount: 4},
+		},
+		history:  recommender.GroupHistory{MemberCount: 4},
+		expected: "b",
+	},
+	{
+		name: `metric type the group completes`,
+		templates: []recommender.Template{
+			{ID: "a", MetricType: "distance", AverageRating: 4.5, RatingCount: 10, CompletionRate: 0.7, ParticipantCount: 5},
+			{ID: "b", MetricType: "weight", AverageRating: 4.5, RatingCount: 10, CompletionRate: 0.7, ParticipantCount: 5},
+		},
+		history: recommender.GroupHistory{
+			MemberCount: 5,
+			Challenges: []recommender.PastChallenge{
+				{MetricType: "weight", ParticipantCount: 5, CompletionRate: 0.9, Completed: true},
+				{MetricType: "weight", ParticipantCount: 5, CompletionRate: 1, Completed: true},
+				{MetricType: "distance", ParticipantCount: 5, CompletionRate: 0.1, Completed: true},
+			},
+		},
+		expected: "b",
+	},
+	{
+		name: `similar group size`,
+		templates: []recommender.Template{
+			{ID: "a", MetricType: "count", AverageRating: 4.5, RatingCount: 10, CompletionRate: 0.7, ParticipantCount: 80},
+			{ID: "b", MetricType: "count", AverageRating: 4.5, RatingCount: 10, CompletionRate: 0.7, ParticipantCount: 3},
+		},
+		history:  recommender.GroupHistory{MemberCount: 3},
+		expected: "b",
+	},
+	{
+		name: `already used template is pushed down`,
+		templates: []recommender.Template{
+			{ID: "a", MetricType: "count", AverageRating: 4.8, RatingCount: 20, CompletionRate: 0.9, ParticipantCount: 4},
+			{ID: "b", MetricType: "count", AverageRating: 4.4, RatingCount: 20, CompletionRate: 0.8, ParticipantCount: 4},
+		},
+		history: recommender.GroupHistory{
+			MemberCount: 4,
+			Challenges: []recommender.PastChallenge{
+				{TemplateID: "a", MetricType: "count", ParticipantCount: 4, CompletionRate: 0.75, Completed: true},
+			},
+		},
+		expected: "b",
+	},
+}
+
+func TestRank(t *testing.T) {
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			ranked := recommender.Rank(tc.templates, tc.history)
+			test.Equal(t, len(ranked), len(tc.templates))
+			test.Equal(t, ranked[0].Template.ID, tc.expected)
+		})
+	}
+}
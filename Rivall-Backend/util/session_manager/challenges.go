@@ -0,0 +1,128 @@
+package session_manager
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// CHALLENGE_TIMEOUT is how long a user has to enter their second factor after their password
+const CHALLENGE_TIMEOUT = time.Minute * 5
+
+// CHALLENGE_MAX_ATTEMPTS wrong codes end a challenge, the user has to log in again
+const CHALLENGE_MAX_ATTEMPTS = 5
+
+// RESET_TOKEN_TIMEOUT is how long a recovered user has to set a new password
+const RESET_TOKEN_TIMEOUT = time.Minute * 15
+
+const (
+	TYPE_CHALLENGE = "challenge"
+	// TYPE_RECOVERY_CHALLENGE is a challenge for a login through account recovery
+	TYPE_RECOVERY_CHALLENGE = "recovery_challenge"
+	TYPE_RESET_TOKEN        = "reset_token"
+)
+
+var (
+	ErrChallengeNotFound  = errors.New("challenge not found")
+	ErrResetTokenNotFound = errors.New("password reset token not found")
+)
+
+// newOpaqueSession saves a single use session for a step of logging in. Its token is
+// opaque rather than a JWT, so it can never pass as an access token.
+func (s *Sessions) newOpaqueSession(userID string, sessionType string, timeout time.Duration) (Session, error) {
+	token := newID() + newID()
+	session := Session{
+		UserID:         userID,
+		Token:          token,
+		TokenHash:      HashToken(token),
+		TokenExpiresAt: time.Now().Add(timeout),
+		Type:           sessionType,
+	}
+
+	if err := s.store.Save(context.Background(), session); err != nil {
+		return Session{}, err
+	}
+	return session, nil
+}
+
+// NewChallenge starts a login that is waiting on a second factor, recovered marks a login
+// through account recovery
+func (s *Sessions) NewChallenge(userID string, recovered bool) (Session, error) {
+	if recovered {
+		return s.newOpaqueSession(userID, TYPE_RECOVERY_CHALLENGE, CHALLENGE_TIMEOUT)
+	}
+	return s.newOpaqueSession(userID, TYPE_CHALLENGE, CHALLENGE_TIMEOUT)
+}
+
+func (s *Sessions) GetChallenge(token string) (Session, error) {
+	challenge, ok, err := s.store.Get(context.Background(), HashToken(token))
+	if err != nil {
+		return Session{}, err
+	}
+	if !ok || challenge.Used || challenge.TokenExpiresAt.Before(time.Now()) {
+		return Session{}, ErrChallengeNotFound
+	}
+	if challenge.Type != TYPE_CHALLENGE && challenge.Type != TYPE_RECOVERY_CHALLENGE {
+		return Session{}, ErrChallengeNotFound
+	}
+	return challenge, nil
+}
+
+// FailChallenge counts a wrong code against a challenge, ending it after CHALLENGE_MAX_ATTEMPTS
+func (s *Sessions) FailChallenge(token string) error {
+	ctx := context.Background()
+	tokenHash := HashToken(token)
+
+	attempts, err := s.store.AddAttempt(ctx, tokenHash)
+	if err != nil {
+		return err
+	}
+	if attempts >= CHALLENGE_MAX_ATTEMPTS {
+		return s.store.Delete(ctx, tokenHash)
+	}
+	return nil
+}
+
+// CompleteChallenge ends a challenge that was answered, only one caller can complete it
+func (s *Sessions) CompleteChallenge(token string) error {
+	ctx := context.Background()
+	tokenHash := HashToken(token)
+
+	marked, err := s.store.MarkUsed(ctx, tokenHash)
+	if err != nil {
+		return err
+	}
+	if !marked {
+		return ErrChallengeNotFound
+	}
+	return s.store.Delete(ctx, tokenHash)
+}
+
+// NewResetToken lets a user who logged in through account recovery set a new password
+// without knowing their current one
+func (s *Sessions) NewResetToken(userID string) (Session, error) {
+	return s.newOpaqueSession(userID, TYPE_RESET_TOKEN, RESET_TOKEN_TIMEOUT)
+}
+
+// UseResetToken spends a reset token of userID
+func (s *Sessions) UseResetToken(token string, userID string) error {
+	ctx := context.Background()
+	tokenHash := HashToken(token)
+
+	session, ok, err := s.store.Get(ctx, tokenHash)
+	if err != nil {
+		return err
+	}
+	if !ok || session.Type != TYPE_RESET_TOKEN || session.UserID != userID || session.TokenExpiresAt.Before(time.Now()) {
+		return ErrResetTokenNotFound
+	}
+
+	marked, err := s.store.MarkUsed(ctx, tokenHash)
+	if err != nil {
+		return err
+	}
+	if !marked {
+		return ErrResetTokenNotFound
+	}
+	return s.store.Delete(ctx, tokenHash)
+}
@@ -0,0 +1,101 @@
+package session_manager_test
+
+import (
+	"testing"
+
+	"Rivall-Backend/util/session_manager"
+	"Rivall-Backend/util/test"
+)
+
+func TestChallenge(t *testing.T) {
+	t.Parallel()
+	sessions, _ := newSessions(t)
+
+	challenge, err := sessions.NewChallenge("user", false)
+	test.NoError(t, err)
+	test.Equal(t, challenge.Type, session_manager.TYPE_CHALLENGE)
+
+	// challenge tokens are no use as access tokens
+	_, ok := sessions.ValidateJWTToken(challenge.Token)
+	test.Equal(t, ok, false)
+
+	found, err := sessions.GetChallenge(challenge.Token)
+	test.NoError(t, err)
+	test.Equal(t, found.UserID, "user")
+
+	test.NoError(t, sessions.CompleteChallenge(challenge.Token))
+	test.Equal(t, sessions.CompleteChallenge(challenge.Token), session_manager.ErrChallengeNotFound)
+	_, err = sessions.GetChallenge(challenge.Token)
+	test.Equal(t, err, session_manager.ErrChallengeNotFound)
+}
+
+func TestChallengeAttempts(t *testing.T) {
+	t.Parallel()
+	sessions, _ := newSessions(t)
+
+	challenge, err := sessions.NewChallenge("user", false)
+	test.NoError(t, err)
+
+	for i := 1; i < session_manager.CHALLENGE_MAX_ATTEMPTS; i++ {
+		test.NoError(t, sessions.FailChallenge(challenge.Token))
+		_, err = sessions.GetChallenge(challenge.Token)
+		test.NoError(t, err)
+	}
+
+	test.NoError(t, sessions.FailChallenge(challenge.Token))
+	_, err = sessions.GetChallenge(challenge.Token)
+	test.Equal(t, err, session_manager.ErrChallengeNotFound)
+}
+
+func TestChallengeIsNotARefreshToken(t *testing.T) {
+	t.Parallel()
+	sessions, _ := newSessions(t)
+
+	challenge, err := sessions.NewChallenge("user", false)
+	test.NoError(t, err)
+
+	_, _, err = sessions.RotateRefreshSession(challenge.Token, "user")
+	test.Equal(t, err, session_manager.ErrNotRefreshSession)
+}
+
+func TestResetToken(t *testing.T) {
+	t.Parallel()
+	sessions, _ := newSessions(t)
+
+	reset, err := sessions.NewResetToken("user")
+	test.NoError(t, err)
+
+	// reset tokens are not challenges and only work for their user, once
+	_, err = sessions.GetChallenge(reset.Token)
+	test.Equal(t, err, session_manager.ErrChallengeNotFound)
+	test.Equal(t, sessions.UseResetToken(reset.Token, "other"), session_manager.ErrResetTokenNotFound)
+	test.NoError(t, sessions.UseResetToken(reset.Token, "user"))
+	test.Equal(t, sessions.UseResetToken(reset.Token, "user"), session_manager.ErrResetTokenNotFound)
+
+	// nor can a challenge reset a password
+	challenge, err := sessions.NewChallenge("user", true)
+	test.NoError(t, err)
+	test.Equal(t, sessions.UseResetToken(challenge.Token, "user"), session_manager.ErrResetTokenNotFound)
+}
+
+func TestOIDCState(t *testing.T) {
+	t.Parallel()
+	sessions, _ := newSessions(t)
+
+	state, err := sessions.NewOIDCState("google", "", "nonce", "verifier")
+	test.NoError(t, err)
+
+	// a login state can't finish a link, or a sign in with another provider
+	_, err = sessions.UseOIDCState(state.Token, "google", "user")
+	test.Equal(t, err, session_manager.ErrOIDCStateNotFound)
+	_, err = sessions.UseOIDCState(state.Token, "other", "")
+	test.Equal(t, err, session_manager.ErrOIDCStateNotFound)
+
+	used, err := sessions.UseOIDCState(state.Token, "google", "")
+	test.NoError(t, err)
+	test.Equal(t, used.Nonce, "nonce")
+	test.Equal(t, used.CodeVerifier, "verifier")
+
+	_, err = sessions.UseOIDCState(state.Token, "google", "")
+	test.Equal(t, err, session_manager.ErrOIDCStateNotFound)
+}
@@ -0,0 +1,133 @@
+package session_manager
+
+import (
+	"context"
+	"log"
+	"sort"
+	"sync"
+	"time"
+)
+
+// DeviceSession is a device a user is logged in on. Its ID is the family ID of
+// the sessions that login created, so revoking it ends all of them.
+type DeviceSession struct {
+	ID         string    `json:"session_id"   bson:"_id"`
+	UserID     string    `json:"-"            bson:"user_id"`
+	Name       string    `json:"name"         bson:"name"`
+	Platform   string    `json:"platform"     bson:"platform"`
+	IP         string    `json:"ip"           bson:"ip"`
+	CreatedAt  time.Time `json:"created_at"   bson:"created_at"`
+	LastUsedAt time.Time `json:"last_used_at" bson:"last_used_at"`
+	// ExpiresAt follows the newest refresh token of the family
+	ExpiresAt time.Time `json:"expires_at"   bson:"expires_at"`
+}
+
+// Device describes where a login came from
+type Device struct {
+	Name     string
+	Platform string
+	IP       string
+}
+
+// DEVICE_TOUCH_INTERVAL limits how often using a session writes its last used time
+const DEVICE_TOUCH_INTERVAL = time.Minute
+
+type deviceTouches struct {
+	// lastTouched is a map of device session ID to when it was last written
+	lastTouched map[string]time.Time
+	sync.Mutex
+}
+
+func (d *deviceTouches) due(id string, now time.Time) bool {
+	d.Lock()
+	defer d.Unlock()
+
+	if last, ok := d.lastTouched[id]; ok && now.Sub(last) < DEVICE_TOUCH_INTERVAL {
+		return false
+	}
+	// drop entries that can no longer throttle anything so the map stays small
+	for key, last := range d.lastTouched {
+		if now.Sub(last) >= DEVICE_TOUCH_INTERVAL {
+			delete(d.lastTouched, key)
+		}
+	}
+	d.lastTouched[id] = now
+	return true
+}
+
+// TouchDeviceSession records that a device session was just used
+func (s *Sessions) TouchDeviceSession(id string) {
+	now := time.Now()
+	if id == "" || !s.touches.due(id, now) {
+		return
+	}
+
+	if err := s.store.TouchDevice(context.Background(), id, now); err != nil {
+		log.Println(err)
+	}
+}
+
+// ListDeviceSessions returns the devices userID is logged in on, most recently used first
+func (s *Sessions) ListDeviceSessions(userID string) ([]DeviceSession, error) {
+	devices, err := s.store.ListDevices(context.Background(), userID)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	active := []DeviceSession{}
+	for _, device := range devices {
+		if device.ExpiresAt.After(now) {
+			active = append(active, device)
+		}
+	}
+	sort.Slice(active, func(i, j int) bool {
+		return active[i].LastUsedAt.After(active[j].LastUsedAt)
+	})
+
+	return active, nil
+}
+
+// RevokeDeviceSession logs userID out of one device
+func (s *Sessions) RevokeDeviceSession(userID string, id string) error {
+	ctx := context.Background()
+
+	device, ok, err := s.store.GetDevice(ctx, id)
+	if err != nil {
+		return err
+	}
+	if !ok || device.UserID != userID {
+		return ErrSessionNotFound
+	}
+
+	return s.store.DeleteFamily(ctx, id)
+}
+
+// RevokeOtherDeviceSessions logs userID out of every device except keepID, returning the revoked IDs
+func (s *Sessions) RevokeOtherDeviceSessions(userID string, keepID string) ([]string, error) {
+	ctx := context.Background()
+
+	devices, err := s.store.ListDevices(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	revoked := []string{}
+	for _, device := range devices {
+		if device.ID == keepID {
+			continue
+		}
+		if err := s.store.DeleteFamily(ctx, device.ID); err != nil {
+			return revoked, err
+		}
+		revoked = append(revoked, device.ID)
+	}
+
+	return revoked, nil
+}
+
+// RevokeUserSessions logs userID out everywhere, including sessions no device lists like
+// challenges and reset tokens
+func (s *Sessions) RevokeUserSessions(userID string) error {
+	return s.store.DeleteUser(context.Background(), userID)
+}
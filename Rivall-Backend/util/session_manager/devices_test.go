@@ -0,0 +1,89 @@
+package session_manager_test
+
+import (
+	"errors"
+	"testing"
+
+	"Rivall-Backend/util/session_manager"
+	"Rivall-Backend/util/test"
+)
+
+func TestDeviceSessions(t *testing.T) {
+	t.Parallel()
+	sessions, _ := newSessions(t)
+
+	phone, _, err := sessions.NewSessionFamily("user", session_manager.Device{Name: "Phone", Platform: "ios", IP: "10.0.0.1"})
+	test.NoError(t, err)
+	laptop, _, err := sessions.NewSessionFamily("user", session_manager.Device{Name: "Laptop", Platform: "web", IP: "10.0.0.2"})
+	test.NoError(t, err)
+	_, _, err = sessions.NewSessionFamily("other", session_manager.Device{Name: "Tablet"})
+	test.NoError(t, err)
+
+	devices, err := sessions.ListDeviceSessions("user")
+	test.NoError(t, err)
+	test.Equal(t, len(devices), 2)
+
+	// another user's device can't be revoked
+	err = sessions.RevokeDeviceSession("other", phone.FamilyID)
+	if !errors.Is(err, session_manager.ErrSessionNotFound) {
+		t.Fatalf(`Expected:"%v", Got:"%v"`, session_manager.ErrSessionNotFound, err)
+	}
+
+	test.NoError(t, sessions.RevokeDeviceSession("user", phone.FamilyID))
+	_, ok := sessions.GetSession(phone.Token)
+	test.Equal(t, ok, false)
+
+	devices, err = sessions.ListDeviceSessions("user")
+	test.NoError(t, err)
+	test.Equal(t, len(devices), 1)
+	test.Equal(t, devices[0].Name, "Laptop")
+	test.Equal(t, devices[0].ID, laptop.FamilyID)
+}
+
+func TestRevokeOtherDeviceSessions(t *testing.T) {
+	t.Parallel()
+	sessions, _ := newSessions(t)
+
+	current, _, err := sessions.NewSessionFamily("user", session_manager.Device{Name: "Phone"})
+	test.NoError(t, err)
+	old, _, err := sessions.NewSessionFamily("user", session_manager.Device{Name: "Laptop"})
+	test.NoError(t, err)
+
+	revoked, err := sessions.RevokeOtherDeviceSessions("user", current.FamilyID)
+	test.NoError(t, err)
+	test.Equal(t, len(revoked), 1)
+	test.Equal(t, revoked[0], old.FamilyID)
+
+	_, ok := sessions.GetSession(current.Token)
+	test.Equal(t, ok, true)
+	_, ok = sessions.GetSession(old.Token)
+	test.Equal(t, ok, false)
+}
+
+func TestRevokeUserSessions(t *testing.T) {
+	t.Parallel()
+	sessions, _ := newSessions(t)
+
+	phone, refresh, err := sessions.NewSessionFamily("user", session_manager.Device{Name: "Phone"})
+	test.NoError(t, err)
+	// a session whose family no device lists, revoking devices alone would miss it
+	stray, err := sessions.NewAccessSession("user", "family")
+	test.NoError(t, err)
+	challenge, err := sessions.NewChallenge("user", false)
+	test.NoError(t, err)
+	other, _, err := sessions.NewSessionFamily("other", session_manager.Device{Name: "Laptop"})
+	test.NoError(t, err)
+
+	test.NoError(t, sessions.RevokeUserSessions("user"))
+
+	for _, token := range []string{phone.Token, refresh.Token, stray.Token, challenge.Token} {
+		_, ok := sessions.GetSession(token)
+		test.Equal(t, ok, false)
+	}
+	devices, err := sessions.ListDeviceSessions("user")
+	test.NoError(t, err)
+	test.Equal(t, len(devices), 0)
+
+	_, ok := sessions.GetSession(other.Token)
+	test.Equal(t, ok, true)
+}
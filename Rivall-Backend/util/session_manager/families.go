@@ -0,0 +1,78 @@
+package session_manager
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+)
+
+var (
+	ErrSessionNotFound     = errors.New("session not found")
+	ErrNotRefreshSession   = errors.New("session is not a refresh token")
+	ErrSessionUserMismatch = errors.New("session does not belong to the user")
+	ErrRefreshTokenReused  = errors.New("refresh token was already used")
+)
+
+// RotateRefreshSession trades a refresh token of userID for a new access and refresh
+// session in the same family. A refresh token can only be used once, replaying one
+// means it leaked, so the whole family is revoked and the user has to log in again.
+func (s *Sessions) RotateRefreshSession(refreshToken string, userID string) (Session, Session, error) {
+	ctx := context.Background()
+	tokenHash := HashToken(refreshToken)
+
+	session, ok, err := s.store.Get(ctx, tokenHash)
+	if err != nil {
+		return Session{}, Session{}, err
+	}
+	if !ok || session.TokenExpiresAt.Before(time.Now()) {
+		return Session{}, Session{}, ErrSessionNotFound
+	}
+	if session.Type != "refresh" {
+		return Session{}, Session{}, ErrNotRefreshSession
+	}
+	if session.UserID != userID {
+		return Session{}, Session{}, ErrSessionUserMismatch
+	}
+
+	marked, err := s.store.MarkUsed(ctx, tokenHash)
+	if err != nil {
+		return Session{}, Session{}, err
+	}
+	if !marked {
+		log.Printf("refresh token reused, revoking session family %s", session.FamilyID)
+		if err := s.store.DeleteFamily(ctx, session.FamilyID); err != nil {
+			return Session{}, Session{}, err
+		}
+		return Session{}, Session{}, ErrRefreshTokenReused
+	}
+
+	accessSession, err := s.NewAccessSession(userID, session.FamilyID)
+	if err != nil {
+		return Session{}, Session{}, err
+	}
+	refreshSession, err := s.NewRefreshSession(userID, session.FamilyID)
+	if err != nil {
+		return Session{}, Session{}, err
+	}
+
+	// the device stays logged in for as long as its newest refresh token
+	device, ok, err := s.store.GetDevice(ctx, session.FamilyID)
+	if err != nil {
+		return Session{}, Session{}, err
+	}
+	if ok {
+		device.LastUsedAt = time.Now()
+		device.ExpiresAt = refreshSession.TokenExpiresAt
+		if err := s.store.SaveDevice(ctx, device); err != nil {
+			return Session{}, Session{}, err
+		}
+	}
+
+	return accessSession, refreshSession, nil
+}
+
+// RevokeSessionFamily ends every session descending from the login familyID started
+func (s *Sessions) RevokeSessionFamily(familyID string) error {
+	return s.store.DeleteFamily(context.Background(), familyID)
+}
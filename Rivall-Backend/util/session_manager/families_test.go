@@ -0,0 +1,80 @@
+package session_manager_test
+
+import (
+	"errors"
+	"testing"
+
+	"Rivall-Backend/util/session_manager"
+	"Rivall-Backend/util/test"
+)
+
+func TestRotateRefreshSession(t *testing.T) {
+	t.Parallel()
+	sessions, _ := newSessions(t)
+
+	_, refresh, err := sessions.NewSessionFamily("user", session_manager.Device{})
+	test.NoError(t, err)
+
+	access, rotated, err := sessions.RotateRefreshSession(refresh.Token, "user")
+	test.NoError(t, err)
+	test.Equal(t, rotated.FamilyID, refresh.FamilyID)
+	if rotated.Token == refresh.Token {
+		t.Fatal("expected a new refresh token")
+	}
+
+	// the rotated token can't be used anywhere
+	_, ok := sessions.GetSession(refresh.Token)
+	test.Equal(t, ok, false)
+	_, ok = sessions.GetSession(access.Token)
+	test.Equal(t, ok, true)
+}
+
+func TestRotateRefreshSessionReuseRevokesFamily(t *testing.T) {
+	t.Parallel()
+	sessions, _ := newSessions(t)
+
+	firstAccess, refresh, err := sessions.NewSessionFamily("user", session_manager.Device{})
+	test.NoError(t, err)
+	access, rotated, err := sessions.RotateRefreshSession(refresh.Token, "user")
+	test.NoError(t, err)
+
+	_, _, err = sessions.RotateRefreshSession(refresh.Token, "user")
+	if !errors.Is(err, session_manager.ErrRefreshTokenReused) {
+		t.Fatalf(`Expected:"%v", Got:"%v"`, session_manager.ErrRefreshTokenReused, err)
+	}
+
+	for _, token := range []string{firstAccess.Token, access.Token, rotated.Token} {
+		_, ok := sessions.GetSession(token)
+		test.Equal(t, ok, false)
+	}
+}
+
+func TestRotateRefreshSessionChecks(t *testing.T) {
+	t.Parallel()
+	sessions, _ := newSessions(t)
+
+	access, refresh, err := sessions.NewSessionFamily("user", session_manager.Device{})
+	test.NoError(t, err)
+
+	tests := []struct {
+		name     string
+		token    string
+		userID   string
+		expected error
+	}{
+		{"unknown token", "not-a-token", "user", session_manager.ErrSessionNotFound},
+		{"access token", access.Token, "user", session_manager.ErrNotRefreshSession},
+		{"other user", refresh.Token, "other", session_manager.ErrSessionUserMismatch},
+	}
+
+	for _, tc := range tests {
+		_, _, err := sessions.RotateRefreshSession(tc.token, tc.userID)
+		if !errors.Is(err, tc.expected) {
+			t.Fatalf(`%s: Expected:"%v", Got:"%v"`, tc.name, tc.expected, err)
+		}
+	}
+
+	// failed checks don't use up the token
+	_, _, err = sessions.RotateRefreshSession(refresh.Token, "user")
+	test.NoError(t, err)
+}
@@ -2,32 +2,50 @@ package session_manager
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
 	"log"
-	"sync"
 	"time"
 
-	"github.com/dgrijalva/jwt-go"
+	"Rivall-Backend/util/keyring"
+
+	"github.com/golang-jwt/jwt/v5"
 )
 
 type Sessions struct {
-	// sessions is a map of user_id to session_id
-	Sessions map[string]Session
+	// store keeps the sessions, keyed by the hash of their token
+	store Store
+
+	// keys sign new tokens and verify presented ones
+	keys *keyring.Keyring
 
-	// JWTSecretKey is the secret key used to sign JWT tokens
-	JWTSecretKey string
+	// issuer and audience are set on every token and required when parsing one
+	issuer   string
+	audience string
 
-	// Using a syncMutex here to be able to lock state before editing clients
-	// Could also use Channels to block
-	sync.RWMutex
+	touches deviceTouches
 }
 
 type Session struct {
 	// session is a struct that holds the user_id and session_id
-	UserID         string
-	SessionID      string
-	Token          string
-	TokenExpiresAt time.Time
-	Type           string
+	UserID    string `bson:"user_id"`
+	SessionID string `bson:"session_id"`
+	// Token is only set on new sessions, stores only keep TokenHash
+	Token          string    `bson:"-"`
+	TokenHash      string    `bson:"_id"`
+	TokenExpiresAt time.Time `bson:"expires_at"`
+	Type           string    `bson:"type"`
+	// FamilyID ties together the sessions descending from one login
+	FamilyID string `bson:"family_id"`
+	// Used marks a refresh session that was already rotated
+	Used bool `bson:"used"`
+	// Attempts counts wrong codes entered against a challenge
+	Attempts int `bson:"attempts"`
+	// Provider, Nonce and CodeVerifier belong to a sign in with an OpenID Connect provider
+	Provider     string `bson:"provider,omitempty"`
+	Nonce        string `bson:"nonce,omitempty"`
+	CodeVerifier string `bson:"code_verifier,omitempty"`
 }
 
 const ACCESS_TOKEN_TIMEOUT = time.Minute * 30
@@ -35,29 +53,31 @@ const ACCESS_TOKEN_TIMEOUT = time.Minute * 30
 // const ACCESS_TOKEN_TIMEOUT = time.Second * 10
 const REFRESH_TOKEN_TIMEOUT = time.Hour * 24
 
-func NewSessionsManager(ctx context.Context, JWTSecretKey string) *Sessions {
+// TOKEN_LEEWAY allows for clock skew between replicas when checking exp, nbf and iat
+const TOKEN_LEEWAY = time.Second * 30
+
+func NewSessionsManager(keys *keyring.Keyring, issuer string, audience string, store Store) *Sessions {
 	s := Sessions{
-		Sessions:     make(map[string]Session),
-		JWTSecretKey: JWTSecretKey,
+		store:    store,
+		keys:     keys,
+		issuer:   issuer,
+		audience: audience,
+		touches:  deviceTouches{lastTouched: make(map[string]time.Time)},
 	}
 
-	go s.ExpireSessions(ctx)
-
 	return &s
 }
 
-func (s *Sessions) GetJWTSecretKey() string {
-	return s.JWTSecretKey
-}
-
-func (s *Sessions) CreateAccessToken(userID string) (string, time.Time) {
+func (s *Sessions) CreateAccessToken(userID string) (string, time.Time, error) {
 	exp := s.GetAccessTokenTimeout()
-	return s.CreateJWTToken(userID, exp), exp
+	token, err := s.CreateJWTToken(userID, exp)
+	return token, exp, err
 }
 
-func (s *Sessions) CreateRefreshToken(userID string) (string, time.Time) {
+func (s *Sessions) CreateRefreshToken(userID string) (string, time.Time, error) {
 	exp := s.GetRefreshTokenTimeout()
-	return s.CreateJWTToken(userID, exp), exp
+	token, err := s.CreateJWTToken(userID, exp)
+	return token, exp, err
 }
 
 func (s *Sessions) GetAccessTokenTimeout() time.Time {
@@ -68,23 +88,65 @@ func (s *Sessions) GetRefreshTokenTimeout() time.Time {
 	return time.Now().Add(REFRESH_TOKEN_TIMEOUT)
 }
 
-func (s *Sessions) CreateJWTToken(userID string, exp time.Time) string {
+func signingMethod(algorithm string) (jwt.SigningMethod, error) {
+	switch algorithm {
+	case keyring.ALGORITHM_EDDSA:
+		return jwt.SigningMethodEdDSA, nil
+	case keyring.ALGORITHM_RS256:
+		return jwt.SigningMethodRS256, nil
+	default:
+		return nil, keyring.ErrUnsupportedAlgorithm
+	}
+}
+
+func (s *Sessions) CreateJWTToken(userID string, exp time.Time) (string, error) {
+	key, err := s.keys.SigningKey()
+	if err != nil {
+		return "", err
+	}
+	method, err := signingMethod(key.Algorithm)
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
 	claims := jwt.MapClaims{}
 	claims["user_id"] = userID
+	claims["sub"] = userID
+	claims["iss"] = s.issuer
+	claims["aud"] = s.audience
+	claims["iat"] = now.Unix()
+	claims["nbf"] = now.Unix()
 	claims["exp"] = exp.Unix()
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	tokenString, err := token.SignedString([]byte(s.JWTSecretKey))
-	if err != nil {
-		log.Println(err)
-	}
-	return tokenString
+	// a unique id keeps tokens issued in the same second apart
+	claims["jti"] = newID()
+
+	token := jwt.NewWithClaims(method, claims)
+	token.Header["kid"] = key.ID
+	return token.SignedString(key.Private)
 }
 
 func (s *Sessions) ValidateJWTToken(tokenString string) (jwt.MapClaims, bool) {
-	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-		return []byte(s.JWTSecretKey), nil
+	parser := jwt.NewParser(
+		jwt.WithValidMethods([]string{s.keys.Algorithm()}),
+		jwt.WithIssuer(s.issuer),
+		jwt.WithAudience(s.audience),
+		jwt.WithIssuedAt(),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(TOKEN_LEEWAY),
+	)
+
+	token, err := parser.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+		kid, ok := token.Header["kid"].(string)
+		if !ok {
+			return nil, errors.New("token has no kid")
+		}
+		key, ok := s.keys.VerificationKey(kid, time.Now())
+		if !ok {
+			return nil, errors.New("token signed by unknown or retired key")
+		}
+		return key.Public(), nil
 	})
-
 	if err != nil {
 		return nil, false
 	}
@@ -94,74 +156,108 @@ func (s *Sessions) ValidateJWTToken(tokenString string) (jwt.MapClaims, bool) {
 		return nil, false
 	}
 
+	// the parser only checks iat and nbf when present, tokens we issue always carry them
+	if iat, err := claims.GetIssuedAt(); err != nil || iat == nil {
+		return nil, false
+	}
+	if nbf, err := claims.GetNotBefore(); err != nil || nbf == nil {
+		return nil, false
+	}
+
 	return claims, true
 }
 
-func (s *Sessions) NewAccessSession(userID string) Session {
-	s.Lock()
-	defer s.Unlock()
+func newID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		log.Println(err)
+	}
+	return hex.EncodeToString(b)
+}
 
-	accessToken, accessTokenExpiresAt := s.CreateAccessToken(userID)
+// NewSessionFamily starts a new family with an access and refresh session for a login
+// on device, recording it as one of the user's device sessions
+func (s *Sessions) NewSessionFamily(userID string, device Device) (Session, Session, error) {
+	familyID := newID()
 
-	new_session := Session{
-		UserID:         userID,
-		Token:          accessToken,
-		TokenExpiresAt: accessTokenExpiresAt,
-		Type:           "access",
+	accessSession, err := s.NewAccessSession(userID, familyID)
+	if err != nil {
+		return Session{}, Session{}, err
+	}
+	refreshSession, err := s.NewRefreshSession(userID, familyID)
+	if err != nil {
+		return Session{}, Session{}, err
 	}
 
-	s.Sessions[accessToken] = new_session
+	now := time.Now()
+	err = s.store.SaveDevice(context.Background(), DeviceSession{
+		ID:         familyID,
+		UserID:     userID,
+		Name:       device.Name,
+		Platform:   device.Platform,
+		IP:         device.IP,
+		CreatedAt:  now,
+		LastUsedAt: now,
+		ExpiresAt:  refreshSession.TokenExpiresAt,
+	})
+	if err != nil {
+		return Session{}, Session{}, err
+	}
 
-	return new_session
+	return accessSession, refreshSession, nil
 }
 
-func (s *Sessions) NewRefreshSession(userID string) Session {
-	s.Lock()
-	defer s.Unlock()
+func (s *Sessions) NewAccessSession(userID string, familyID string) (Session, error) {
+	accessToken, accessTokenExpiresAt, err := s.CreateAccessToken(userID)
+	if err != nil {
+		return Session{}, err
+	}
+	return s.newSession(userID, familyID, accessToken, accessTokenExpiresAt, "access")
+}
 
-	refreshToken, refreshTokenExpiresAt := s.CreateRefreshToken(userID)
+func (s *Sessions) NewRefreshSession(userID string, familyID string) (Session, error) {
+	refreshToken, refreshTokenExpiresAt, err := s.CreateRefreshToken(userID)
+	if err != nil {
+		return Session{}, err
+	}
+	return s.newSession(userID, familyID, refreshToken, refreshTokenExpiresAt, "refresh")
+}
 
+func (s *Sessions) newSession(userID string, familyID string, token string, expiresAt time.Time, sessionType string) (Session, error) {
 	new_session := Session{
 		UserID:         userID,
-		Token:          refreshToken,
-		TokenExpiresAt: refreshTokenExpiresAt,
-		Type:           "refresh",
+		Token:          token,
+		TokenHash:      HashToken(token),
+		TokenExpiresAt: expiresAt,
+		Type:           sessionType,
+		FamilyID:       familyID,
 	}
 
-	s.Sessions[refreshToken] = new_session
+	if err := s.store.Save(context.Background(), new_session); err != nil {
+		return Session{}, err
+	}
 
-	return new_session
+	return new_session, nil
 }
 
 func (s *Sessions) GetSession(token string) (Session, bool) {
-	s.Lock()
-	defer s.Unlock()
+	session, ok, err := s.store.Get(context.Background(), HashToken(token))
+	if err != nil {
+		log.Println(err)
+		return Session{}, false
+	}
+
+	// stores may hold on to expired sessions until they are swept, and keep
+	// rotated refresh sessions only to catch them being replayed
+	if !ok || session.Used || session.TokenExpiresAt.Before(time.Now()) {
+		return Session{}, false
+	}
 
-	session, ok := s.Sessions[token]
-	return session, ok
+	return session, true
 }
 
 func (s *Sessions) DeleteSession(token string) {
-	s.Lock()
-	defer s.Unlock()
-
-	delete(s.Sessions, token)
-}
-
-func (s *Sessions) ExpireSessions(ctx context.Context) {
-	ticker := time.NewTicker(400 * time.Millisecond)
-	for {
-		select {
-		case <-ticker.C:
-			s.Lock()
-			for key, session := range s.Sessions {
-				if session.TokenExpiresAt.Before(time.Now()) {
-					delete(s.Sessions, key)
-				}
-			}
-			s.Unlock()
-		case <-ctx.Done():
-			return
-		}
+	if err := s.store.Delete(context.Background(), HashToken(token)); err != nil {
+		log.Println(err)
 	}
 }
@@ -0,0 +1,97 @@
+package session_manager_test
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"testing"
+	"time"
+
+	"Rivall-Backend/util/keyring"
+	"Rivall-Backend/util/session_manager"
+	"Rivall-Backend/util/test"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func validClaims() jwt.MapClaims {
+	now := time.Now()
+	return jwt.MapClaims{
+		"user_id": "user",
+		"iss":     "rivall",
+		"aud":     "rivall-api",
+		"iat":     now.Unix(),
+		"nbf":     now.Unix(),
+		"exp":     now.Add(time.Minute).Unix(),
+	}
+}
+
+func TestValidateJWTToken(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	keys, err := keyring.New(ctx, keyring.ALGORITHM_EDDSA, time.Hour, session_manager.REFRESH_TOKEN_TIMEOUT, keyring.NewMemoryStore())
+	test.NoError(t, err)
+	sessions := session_manager.NewSessionsManager(keys, "rivall", "rivall-api", session_manager.NewMemoryStore(ctx))
+
+	key, err := keys.SigningKey()
+	test.NoError(t, err)
+	_, foreignKey, err := ed25519.GenerateKey(rand.Reader)
+	test.NoError(t, err)
+
+	sign := func(method jwt.SigningMethod, signingKey any, kid string, change func(jwt.MapClaims)) string {
+		claims := validClaims()
+		if change != nil {
+			change(claims)
+		}
+		token := jwt.NewWithClaims(method, claims)
+		if kid != "" {
+			token.Header["kid"] = kid
+		}
+		s, err := token.SignedString(signingKey)
+		test.NoError(t, err)
+		return s
+	}
+
+	tests := []struct {
+		name     string
+		token    string
+		expected bool
+	}{
+		{"valid", sign(jwt.SigningMethodEdDSA, key.Private, key.ID, nil), true},
+		{"none algorithm", sign(jwt.SigningMethodNone, jwt.UnsafeAllowNoneSignatureType, key.ID, nil), false},
+		{"hmac algorithm", sign(jwt.SigningMethodHS256, []byte("0123456789abcdef0123456789abcdef"), key.ID, nil), false},
+		{"missing kid", sign(jwt.SigningMethodEdDSA, key.Private, "", nil), false},
+		{"unknown kid", sign(jwt.SigningMethodEdDSA, foreignKey, "foreign", nil), false},
+		{"foreign key with known kid", sign(jwt.SigningMethodEdDSA, foreignKey, key.ID, nil), false},
+		{"wrong issuer", sign(jwt.SigningMethodEdDSA, key.Private, key.ID, func(c jwt.MapClaims) { c["iss"] = "someone" }), false},
+		{"wrong audience", sign(jwt.SigningMethodEdDSA, key.Private, key.ID, func(c jwt.MapClaims) { c["aud"] = "other-api" }), false},
+		{"missing iat", sign(jwt.SigningMethodEdDSA, key.Private, key.ID, func(c jwt.MapClaims) { delete(c, "iat") }), false},
+		{"missing nbf", sign(jwt.SigningMethodEdDSA, key.Private, key.ID, func(c jwt.MapClaims) { delete(c, "nbf") }), false},
+		{"not yet valid", sign(jwt.SigningMethodEdDSA, key.Private, key.ID, func(c jwt.MapClaims) { c["nbf"] = time.Now().Add(time.Hour).Unix() }), false},
+		{"issued in the future", sign(jwt.SigningMethodEdDSA, key.Private, key.ID, func(c jwt.MapClaims) { c["iat"] = time.Now().Add(time.Hour).Unix() }), false},
+		{"expired", sign(jwt.SigningMethodEdDSA, key.Private, key.ID, func(c jwt.MapClaims) { c["exp"] = time.Now().Add(-time.Hour).Unix() }), false},
+		{"missing exp", sign(jwt.SigningMethodEdDSA, key.Private, key.ID, func(c jwt.MapClaims) { delete(c, "exp") }), false},
+	}
+
+	for _, tc := range tests {
+		_, ok := sessions.ValidateJWTToken(tc.token)
+		if ok != tc.expected {
+			t.Errorf("%s: ValidateJWTToken() = %v, want %v", tc.name, ok, tc.expected)
+		}
+	}
+}
+
+func TestIssuedTokensValidate(t *testing.T) {
+	t.Parallel()
+	sessions, _ := newSessions(t)
+
+	access, err := sessions.NewAccessSession("user", "family")
+	test.NoError(t, err)
+
+	claims, ok := sessions.ValidateJWTToken(access.Token)
+	test.Equal(t, ok, true)
+	test.Equal(t, claims["user_id"], any("user"))
+}
@@ -0,0 +1,176 @@
+package session_manager
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// MemoryStore keeps sessions in process. Sessions are lost on restart and
+// are not shared between replicas, use it for development and tests.
+type MemoryStore struct {
+	// sessions is a map of token hash to session
+	sessions map[string]Session
+
+	// devices is a map of device session ID to device session
+	devices map[string]DeviceSession
+
+	// Using a syncMutex here to be able to lock state before editing sessions
+	sync.RWMutex
+}
+
+func NewMemoryStore(ctx context.Context) *MemoryStore {
+	s := MemoryStore{
+		sessions: make(map[string]Session),
+		devices:  make(map[string]DeviceSession),
+	}
+
+	go s.ExpireSessions(ctx)
+
+	return &s
+}
+
+func (s *MemoryStore) Save(ctx context.Context, session Session) error {
+	s.Lock()
+	defer s.Unlock()
+
+	session.Token = ""
+	s.sessions[session.TokenHash] = session
+	return nil
+}
+
+func (s *MemoryStore) Get(ctx context.Context, tokenHash string) (Session, bool, error) {
+	s.RLock()
+	defer s.RUnlock()
+
+	session, ok := s.sessions[tokenHash]
+	return session, ok, nil
+}
+
+func (s *MemoryStore) Delete(ctx context.Context, tokenHash string) error {
+	s.Lock()
+	defer s.Unlock()
+
+	delete(s.sessions, tokenHash)
+	return nil
+}
+
+func (s *MemoryStore) MarkUsed(ctx context.Context, tokenHash string) (bool, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	session, ok := s.sessions[tokenHash]
+	if !ok || session.Used {
+		return false, nil
+	}
+	session.Used = true
+	s.sessions[tokenHash] = session
+	return true, nil
+}
+
+func (s *MemoryStore) AddAttempt(ctx context.Context, tokenHash string) (int, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	session, ok := s.sessions[tokenHash]
+	if !ok {
+		return 0, nil
+	}
+	session.Attempts++
+	s.sessions[tokenHash] = session
+	return session.Attempts, nil
+}
+
+func (s *MemoryStore) DeleteFamily(ctx context.Context, familyID string) error {
+	s.Lock()
+	defer s.Unlock()
+
+	for key, session := range s.sessions {
+		if session.FamilyID == familyID {
+			delete(s.sessions, key)
+		}
+	}
+	delete(s.devices, familyID)
+	return nil
+}
+
+func (s *MemoryStore) DeleteUser(ctx context.Context, userID string) error {
+	s.Lock()
+	defer s.Unlock()
+
+	for key, session := range s.sessions {
+		if session.UserID == userID {
+			delete(s.sessions, key)
+		}
+	}
+	for key, device := range s.devices {
+		if device.UserID == userID {
+			delete(s.devices, key)
+		}
+	}
+	return nil
+}
+
+func (s *MemoryStore) SaveDevice(ctx context.Context, device DeviceSession) error {
+	s.Lock()
+	defer s.Unlock()
+
+	s.devices[device.ID] = device
+	return nil
+}
+
+func (s *MemoryStore) GetDevice(ctx context.Context, id string) (DeviceSession, bool, error) {
+	s.RLock()
+	defer s.RUnlock()
+
+	device, ok := s.devices[id]
+	return device, ok, nil
+}
+
+func (s *MemoryStore) ListDevices(ctx context.Context, userID string) ([]DeviceSession, error) {
+	s.RLock()
+	defer s.RUnlock()
+
+	devices := []DeviceSession{}
+	for _, device := range s.devices {
+		if device.UserID == userID {
+			devices = append(devices, device)
+		}
+	}
+	return devices, nil
+}
+
+func (s *MemoryStore) TouchDevice(ctx context.Context, id string, lastUsedAt time.Time) error {
+	s.Lock()
+	defer s.Unlock()
+
+	if device, ok := s.devices[id]; ok {
+		device.LastUsedAt = lastUsedAt
+		s.devices[id] = device
+	}
+	return nil
+}
+
+func (s *MemoryStore) ExpireSessions(ctx context.Context) {
+	ticker := time.NewTicker(400 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			s.Lock()
+			for key, session := range s.sessions {
+				if session.TokenExpiresAt.Before(time.Now()) {
+					delete(s.sessions, key)
+				}
+			}
+			for key, device := range s.devices {
+				if device.ExpiresAt.Before(time.Now()) {
+					delete(s.devices, key)
+				}
+			}
+			s.Unlock()
+		case <-ctx.Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package session_manager_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"Rivall-Backend/util/keyring"
+	"Rivall-Backend/util/session_manager"
+	"Rivall-Backend/util/test"
+)
+
+func newSessions(t *testing.T) (*session_manager.Sessions, *session_manager.MemoryStore) {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	keys, err := keyring.New(ctx, keyring.ALGORITHM_EDDSA, time.Hour, session_manager.REFRESH_TOKEN_TIMEOUT, keyring.NewMemoryStore())
+	test.NoError(t, err)
+
+	store := session_manager.NewMemoryStore(ctx)
+	return session_manager.NewSessionsManager(keys, "rivall", "rivall-api", store), store
+}
+
+func TestSessionRoundTrip(t *testing.T) {
+	t.Parallel()
+	sessions, _ := newSessions(t)
+
+	access, err := sessions.NewAccessSession("user", "family")
+	test.NoError(t, err)
+
+	session, ok := sessions.GetSession(access.Token)
+	test.Equal(t, ok, true)
+	test.Equal(t, session.UserID, "user")
+	test.Equal(t, session.Type, "access")
+
+	sessions.DeleteSession(access.Token)
+	_, ok = sessions.GetSession(access.Token)
+	test.Equal(t, ok, false)
+}
+
+func TestStoreKeepsOnlyTokenHash(t *testing.T) {
+	t.Parallel()
+	sessions, store := newSessions(t)
+
+	refresh, err := sessions.NewRefreshSession("user", "family")
+	test.NoError(t, err)
+
+	// the raw token is not a key and is not kept on the stored session
+	_, ok, err := store.Get(context.Background(), refresh.Token)
+	test.NoError(t, err)
+	test.Equal(t, ok, false)
+
+	stored, ok, err := store.Get(context.Background(), session_manager.HashToken(refresh.Token))
+	test.NoError(t, err)
+	test.Equal(t, ok, true)
+	test.Equal(t, stored.Token, "")
+}
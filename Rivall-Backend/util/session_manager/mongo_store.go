@@ -0,0 +1,149 @@
+package session_manager
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// MongoStore keeps sessions in MongoDB so they survive restarts and are shared
+// between replicas. TTL indexes remove expired sessions and devices.
+type MongoStore struct {
+	sessions *mongo.Collection
+	devices  *mongo.Collection
+}
+
+func NewMongoStore(ctx context.Context, database *mongo.Database) (*MongoStore, error) {
+	s := MongoStore{
+		sessions: database.Collection("Sessions"),
+		devices:  database.Collection("DeviceSessions"),
+	}
+
+	// expire documents at their expires_at time, mongo sweeps about once a minute
+	expireIndex := mongo.IndexModel{
+		Keys:    bson.D{{Key: "expires_at", Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(0),
+	}
+	userIndex := mongo.IndexModel{
+		Keys: bson.D{{Key: "user_id", Value: 1}},
+	}
+
+	_, err := s.sessions.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		expireIndex,
+		userIndex,
+		{
+			Keys: bson.D{{Key: "family_id", Value: 1}},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = s.devices.Indexes().CreateMany(ctx, []mongo.IndexModel{expireIndex, userIndex})
+	if err != nil {
+		return nil, err
+	}
+
+	return &s, nil
+}
+
+func (s *MongoStore) Save(ctx context.Context, session Session) error {
+	opts := options.Replace().SetUpsert(true)
+	_, err := s.sessions.ReplaceOne(ctx, bson.M{"_id": session.TokenHash}, session, opts)
+	return err
+}
+
+func (s *MongoStore) Get(ctx context.Context, tokenHash string) (Session, bool, error) {
+	var session Session
+	err := s.sessions.FindOne(ctx, bson.M{"_id": tokenHash}).Decode(&session)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return session, false, nil
+	}
+	if err != nil {
+		return session, false, err
+	}
+	return session, true, nil
+}
+
+func (s *MongoStore) Delete(ctx context.Context, tokenHash string) error {
+	_, err := s.sessions.DeleteOne(ctx, bson.M{"_id": tokenHash})
+	return err
+}
+
+func (s *MongoStore) MarkUsed(ctx context.Context, tokenHash string) (bool, error) {
+	// only one caller can flip used, concurrent rotations of a token lose
+	result, err := s.sessions.UpdateOne(ctx, bson.M{"_id": tokenHash, "used": false}, bson.M{"$set": bson.M{"used": true}})
+	if err != nil {
+		return false, err
+	}
+	return result.ModifiedCount == 1, nil
+}
+
+func (s *MongoStore) AddAttempt(ctx context.Context, tokenHash string) (int, error) {
+	var session Session
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	err := s.sessions.FindOneAndUpdate(ctx, bson.M{"_id": tokenHash}, bson.M{"$inc": bson.M{"attempts": 1}}, opts).Decode(&session)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return session.Attempts, nil
+}
+
+func (s *MongoStore) DeleteFamily(ctx context.Context, familyID string) error {
+	if _, err := s.sessions.DeleteMany(ctx, bson.M{"family_id": familyID}); err != nil {
+		return err
+	}
+	_, err := s.devices.DeleteOne(ctx, bson.M{"_id": familyID})
+	return err
+}
+
+func (s *MongoStore) DeleteUser(ctx context.Context, userID string) error {
+	if _, err := s.sessions.DeleteMany(ctx, bson.M{"user_id": userID}); err != nil {
+		return err
+	}
+	_, err := s.devices.DeleteMany(ctx, bson.M{"user_id": userID})
+	return err
+}
+
+func (s *MongoStore) SaveDevice(ctx context.Context, device DeviceSession) error {
+	opts := options.Replace().SetUpsert(true)
+	_, err := s.devices.ReplaceOne(ctx, bson.M{"_id": device.ID}, device, opts)
+	return err
+}
+
+func (s *MongoStore) GetDevice(ctx context.Context, id string) (DeviceSession, bool, error) {
+	var device DeviceSession
+	err := s.devices.FindOne(ctx, bson.M{"_id": id}).Decode(&device)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return device, false, nil
+	}
+	if err != nil {
+		return device, false, err
+	}
+	return device, true, nil
+}
+
+func (s *MongoStore) ListDevices(ctx context.Context, userID string) ([]DeviceSession, error) {
+	cursor, err := s.devices.Find(ctx, bson.M{"user_id": userID})
+	if err != nil {
+		return nil, err
+	}
+
+	devices := []DeviceSession{}
+	if err := cursor.All(ctx, &devices); err != nil {
+		return nil, err
+	}
+	return devices, nil
+}
+
+func (s *MongoStore) TouchDevice(ctx context.Context, id string, lastUsedAt time.Time) error {
+	_, err := s.devices.UpdateOne(ctx, bson.M{"_id": id}, bson.M{"$set": bson.M{"last_used_at": lastUsedAt}})
+	return err
+}
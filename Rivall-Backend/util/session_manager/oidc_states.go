@@ -0,0 +1,59 @@
+package session_manager
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// OIDC_STATE_TIMEOUT is how long a user has to sign in with a provider
+const OIDC_STATE_TIMEOUT = time.Minute * 10
+
+const TYPE_OIDC_STATE = "oidc_state"
+
+var ErrOIDCStateNotFound = errors.New("oidc state not found")
+
+// NewOIDCState starts a sign in with provider, its token is the state sent to the provider.
+// userID is set when an account is linking the provider rather than logging in.
+func (s *Sessions) NewOIDCState(provider string, userID string, nonce string, codeVerifier string) (Session, error) {
+	token := newID() + newID()
+	state := Session{
+		UserID:         userID,
+		Token:          token,
+		TokenHash:      HashToken(token),
+		TokenExpiresAt: time.Now().Add(OIDC_STATE_TIMEOUT),
+		Type:           TYPE_OIDC_STATE,
+		Provider:       provider,
+		Nonce:          nonce,
+		CodeVerifier:   codeVerifier,
+	}
+
+	if err := s.store.Save(context.Background(), state); err != nil {
+		return Session{}, err
+	}
+	return state, nil
+}
+
+// UseOIDCState spends the state of a sign in with provider started by userID, which is
+// empty for logins
+func (s *Sessions) UseOIDCState(token string, provider string, userID string) (Session, error) {
+	ctx := context.Background()
+	tokenHash := HashToken(token)
+
+	state, ok, err := s.store.Get(ctx, tokenHash)
+	if err != nil {
+		return Session{}, err
+	}
+	if !ok || state.Type != TYPE_OIDC_STATE || state.Provider != provider || state.UserID != userID || state.TokenExpiresAt.Before(time.Now()) {
+		return Session{}, ErrOIDCStateNotFound
+	}
+
+	marked, err := s.store.MarkUsed(ctx, tokenHash)
+	if err != nil {
+		return Session{}, err
+	}
+	if !marked {
+		return Session{}, ErrOIDCStateNotFound
+	}
+	return state, s.store.Delete(ctx, tokenHash)
+}
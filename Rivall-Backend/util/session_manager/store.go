@@ -0,0 +1,35 @@
+package session_manager
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+)
+
+// Store keeps sessions keyed by the hash of their token, so a leaked store
+// can't be replayed as bearer tokens.
+type Store interface {
+	Save(ctx context.Context, session Session) error
+	Get(ctx context.Context, tokenHash string) (Session, bool, error)
+	Delete(ctx context.Context, tokenHash string) error
+	// MarkUsed flags a session as used, reporting false if it already was
+	MarkUsed(ctx context.Context, tokenHash string) (bool, error)
+	// AddAttempt counts a failed use of a session, returning the new count
+	AddAttempt(ctx context.Context, tokenHash string) (int, error)
+	// DeleteFamily removes every session of a family along with its device session
+	DeleteFamily(ctx context.Context, familyID string) error
+	// DeleteUser removes every session and device session of a user, whatever their type
+	DeleteUser(ctx context.Context, userID string) error
+
+	SaveDevice(ctx context.Context, device DeviceSession) error
+	GetDevice(ctx context.Context, id string) (DeviceSession, bool, error)
+	ListDevices(ctx context.Context, userID string) ([]DeviceSession, error)
+	TouchDevice(ctx context.Context, id string, lastUsedAt time.Time) error
+}
+
+// HashToken returns the key a token's session is stored under
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
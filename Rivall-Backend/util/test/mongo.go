@@ -0,0 +1,49 @@
+package test
+
+import (
+	"context"
+	"os"
+	"sync"
+	"testing"
+
+	"Rivall-Backend/db"
+	"Rivall-Backend/globals"
+
+	"github.com/rs/xid"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+var (
+	mongoOnce   sync.Once
+	mongoClient *mongo.Client
+	mongoErr    error
+)
+
+// Mongo points the db package at an empty database on the server at TEST_MONGO_URI, which
+// is dropped when the test ends. Tests using it can't run in parallel, they share
+// db.Database. Without TEST_MONGO_URI the test is skipped.
+func Mongo(t *testing.T) *mongo.Database {
+	uri := os.Getenv("TEST_MONGO_URI")
+	if uri == "" {
+		t.Skip("TEST_MONGO_URI is not set")
+	}
+
+	mongoOnce.Do(func() {
+		mongoClient, mongoErr = mongo.Connect(options.Client().ApplyURI(uri))
+	})
+	NoError(t, mongoErr)
+
+	previous := db.Database
+	globals.MongoClient = mongoClient
+	db.Database = "rivall_test_" + xid.New().String()
+	database := mongoClient.Database(db.Database)
+
+	t.Cleanup(func() {
+		if err := database.Drop(context.Background()); err != nil {
+			t.Errorf("Failed to drop test database: %v", err)
+		}
+		db.Database = previous
+	})
+	return database
+}
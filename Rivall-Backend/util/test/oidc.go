@@ -0,0 +1,121 @@
+package test
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"Rivall-Backend/util/oidc"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// OIDC_CLIENT_ID is the client the fake provider issues id tokens to
+const OIDC_CLIENT_ID = "rivall"
+
+// OIDCProvider is a stand-in OpenID Connect provider that hands out codes for the claims
+// a test asks for
+type OIDCProvider struct {
+	*httptest.Server
+	key *rsa.PrivateKey
+
+	mu    sync.Mutex
+	codes map[string]oidcGrant
+}
+
+type oidcGrant struct {
+	challenge string
+	claims    jwt.MapClaims
+}
+
+func NewOIDCProvider(t *testing.T) *OIDCProvider {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	NoError(t, err)
+
+	p := &OIDCProvider{key: key, codes: make(map[string]oidcGrant)}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 p.URL,
+			"authorization_endpoint": p.URL + "/authorize",
+			"token_endpoint":         p.URL + "/token",
+			"jwks_uri":               p.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{"keys": []map[string]string{{
+			"kty": "RSA",
+			"use": "sig",
+			"kid": "test",
+			"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	})
+	mux.HandleFunc("/token", p.token)
+	p.Server = httptest.NewServer(mux)
+	t.Cleanup(p.Close)
+	return p
+}
+
+// Discover sets up a client of the fake provider under name
+func (p *OIDCProvider) Discover(t *testing.T, name string) *oidc.Provider {
+	provider, err := oidc.Discover(context.Background(), oidc.Config{
+		Name:        name,
+		Issuer:      p.URL,
+		ClientID:    OIDC_CLIENT_ID,
+		RedirectURL: "rivall://oidc/callback",
+	}, p.Client())
+	NoError(t, err)
+	return provider
+}
+
+// Grant issues a code as if the user signed in, claims are added to the usual ones
+func (p *OIDCProvider) Grant(challenge string, nonce string, claims jwt.MapClaims) string {
+	full := jwt.MapClaims{
+		"iss":   p.URL,
+		"aud":   OIDC_CLIENT_ID,
+		"sub":   "subject-1",
+		"iat":   time.Now().Unix(),
+		"exp":   time.Now().Add(time.Minute).Unix(),
+		"nonce": nonce,
+	}
+	for k, v := range claims {
+		full[k] = v
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	code, _ := oidc.NewNonce()
+	p.codes[code] = oidcGrant{challenge: challenge, claims: full}
+	return code
+}
+
+func (p *OIDCProvider) token(w http.ResponseWriter, r *http.Request) {
+	r.ParseForm()
+
+	p.mu.Lock()
+	grant, ok := p.codes[r.PostForm.Get("code")]
+	delete(p.codes, r.PostForm.Get("code"))
+	p.mu.Unlock()
+
+	sum := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+	if !ok || base64.RawURLEncoding.EncodeToString(sum[:]) != grant.challenge {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+		return
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, grant.claims)
+	token.Header["kid"] = "test"
+	signed, _ := token.SignedString(p.key)
+	json.NewEncoder(w).Encode(map[string]string{"id_token": signed, "token_type": "Bearer"})
+}
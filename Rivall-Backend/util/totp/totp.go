@@ -0,0 +1,135 @@
+package totp
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Codes follow RFC 6238 with the defaults authenticator apps expect
+const (
+	DIGITS      = 6
+	PERIOD      = 30
+	SECRET_SIZE = 20
+	// SKEW is how many periods either side of now a code is still accepted in
+	SKEW = 1
+
+	RECOVERY_CODE_COUNT = 10
+	// RECOVERY_CODE_ALPHABET leaves out characters that are easy to misread, like 0/O and 1/I
+	RECOVERY_CODE_ALPHABET = "23456789ABCDEFGHJKLMNPQRSTUVWXYZ"
+	RECOVERY_CODE_LENGTH   = 10
+)
+
+var ErrInvalidSecret = errors.New("invalid totp secret")
+
+var encoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// NewSecret returns a random base32 secret
+func NewSecret() (string, error) {
+	b := make([]byte, SECRET_SIZE)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return encoding.EncodeToString(b), nil
+}
+
+// ProvisioningURI returns the otpauth URI authenticator apps read from a QR code
+func ProvisioningURI(secret string, issuer string, account string) string {
+	label := url.PathEscape(issuer) + ":" + url.PathEscape(account)
+
+	params := url.Values{}
+	params.Set("secret", secret)
+	params.Set("issuer", issuer)
+	params.Set("algorithm", "SHA1")
+	params.Set("digits", fmt.Sprint(DIGITS))
+	params.Set("period", fmt.Sprint(PERIOD))
+
+	return "otpauth://totp/" + label + "?" + params.Encode()
+}
+
+// Step returns the period t falls in
+func Step(t time.Time) int64 {
+	return t.Unix() / PERIOD
+}
+
+// CodeAt returns the code for a step
+func CodeAt(secret string, step int64) (string, error) {
+	key, err := encoding.DecodeString(strings.ToUpper(secret))
+	if err != nil {
+		return "", ErrInvalidSecret
+	}
+
+	var counter [8]byte
+	binary.BigEndian.PutUint64(counter[:], uint64(step))
+	mac := hmac.New(sha1.New, key)
+	mac.Write(counter[:])
+	sum := mac.Sum(nil)
+
+	// dynamic truncation from RFC 4226
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+
+	mod := uint32(1)
+	for i := 0; i < DIGITS; i++ {
+		mod *= 10
+	}
+	return fmt.Sprintf("%0*d", DIGITS, value%mod), nil
+}
+
+// Validate checks code against the steps around now, returning the step it matched so
+// callers can refuse to accept the same step twice
+func Validate(secret string, code string, now time.Time) (int64, bool) {
+	code = strings.ReplaceAll(code, " ", "")
+	if len(code) != DIGITS {
+		return 0, false
+	}
+
+	current := Step(now)
+	for step := current - SKEW; step <= current+SKEW; step++ {
+		expected, err := CodeAt(secret, step)
+		if err != nil {
+			return 0, false
+		}
+		if subtle.ConstantTimeCompare([]byte(expected), []byte(code)) == 1 {
+			return step, true
+		}
+	}
+	return 0, false
+}
+
+// NewRecoveryCodes returns single use codes for when the authenticator is lost
+func NewRecoveryCodes() ([]string, error) {
+	codes := make([]string, RECOVERY_CODE_COUNT)
+	max := big.NewInt(int64(len(RECOVERY_CODE_ALPHABET)))
+	for i := range codes {
+		b := make([]byte, RECOVERY_CODE_LENGTH)
+		for j := range b {
+			n, err := rand.Int(rand.Reader, max)
+			if err != nil {
+				return nil, err
+			}
+			b[j] = RECOVERY_CODE_ALPHABET[n.Int64()]
+		}
+		half := RECOVERY_CODE_LENGTH / 2
+		codes[i] = string(b[:half]) + "-" + string(b[half:])
+	}
+	return codes, nil
+}
+
+// HashRecoveryCode returns the form recovery codes are stored in, ignoring case and dashes
+func HashRecoveryCode(code string) string {
+	code = strings.ToUpper(strings.ReplaceAll(strings.ReplaceAll(code, "-", ""), " ", ""))
+	sum := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,87 @@
+package totp_test
+
+import (
+	"encoding/base32"
+	"strings"
+	"testing"
+	"time"
+
+	"Rivall-Backend/util/test"
+	"Rivall-Backend/util/totp"
+)
+
+// rfcSecret is the SHA1 key from the RFC 6238 test vectors
+var rfcSecret = base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString([]byte("12345678901234567890"))
+
+func TestCodeAt(t *testing.T) {
+	t.Parallel()
+
+	// the RFC lists 8 digit codes, 6 digit codes are their last 6 digits
+	tests := []struct {
+		unix int64
+		code string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+	}
+
+	for _, tt := range tests {
+		code, err := totp.CodeAt(rfcSecret, totp.Step(time.Unix(tt.unix, 0)))
+		test.NoError(t, err)
+		test.Equal(t, code, tt.code)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	t.Parallel()
+	now := time.Unix(1111111111, 0)
+
+	step, ok := totp.Validate(rfcSecret, "050471", now)
+	test.Equal(t, ok, true)
+	test.Equal(t, step, totp.Step(now))
+
+	// a code from the previous period is still accepted for clock drift
+	_, ok = totp.Validate(rfcSecret, "050471", now.Add(totp.PERIOD*time.Second))
+	test.Equal(t, ok, true)
+
+	_, ok = totp.Validate(rfcSecret, "050471", now.Add(5*totp.PERIOD*time.Second))
+	test.Equal(t, ok, false)
+
+	_, ok = totp.Validate(rfcSecret, "000000", now)
+	test.Equal(t, ok, false)
+
+	_, ok = totp.Validate("not base32!", "050471", now)
+	test.Equal(t, ok, false)
+}
+
+func TestProvisioningURI(t *testing.T) {
+	t.Parallel()
+
+	secret, err := totp.NewSecret()
+	test.NoError(t, err)
+
+	uri := totp.ProvisioningURI(secret, "Rivall", "sam@example.com")
+	test.Equal(t, strings.HasPrefix(uri, "otpauth://totp/Rivall:sam@example.com?"), true)
+	test.Equal(t, strings.Contains(uri, "secret="+secret), true)
+	test.Equal(t, strings.Contains(uri, "issuer=Rivall"), true)
+}
+
+func TestRecoveryCodes(t *testing.T) {
+	t.Parallel()
+
+	codes, err := totp.NewRecoveryCodes()
+	test.NoError(t, err)
+	test.Equal(t, len(codes), totp.RECOVERY_CODE_COUNT)
+
+	seen := map[string]bool{}
+	for _, code := range codes {
+		test.Equal(t, seen[code], false)
+		seen[code] = true
+	}
+
+	// users may type codes in lower case or without the dash
+	test.Equal(t, totp.HashRecoveryCode(strings.ToLower(strings.ReplaceAll(codes[0], "-", ""))), totp.HashRecoveryCode(codes[0]))
+}
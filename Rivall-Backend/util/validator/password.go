@@ -0,0 +1,164 @@
+package validator
+
+import (
+	"bufio"
+	_ "embed"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/go-playground/validator/v10"
+)
+
+// PasswordPolicy sets the rules passwords are validated against
+type PasswordPolicy struct {
+	MinLength int
+	MaxLength int
+	// MaxBytes is the longest password in bytes the password hasher uses in full, 0 when it
+	// has no limit. Characters outside ASCII take several bytes each.
+	MaxBytes int
+	// MinClasses is how many of lowercase letters, uppercase letters, digits and symbols a
+	// password has to use
+	MinClasses int
+}
+
+// MIN_PERSONAL_LENGTH is the shortest name or email part passwords are checked for
+const MIN_PERSONAL_LENGTH = 3
+
+// passwordPolicy is set before New is called, ToErrResponse reports against it
+var passwordPolicy = PasswordPolicy{
+	MinLength:  10,
+	MaxLength:  72,
+	MinClasses: 3,
+}
+
+//go:embed common_passwords.txt
+var commonPasswordsFile string
+
+var commonPasswords = parseCommonPasswords(commonPasswordsFile)
+
+func parseCommonPasswords(file string) map[string]bool {
+	passwords := make(map[string]bool)
+	scanner := bufio.NewScanner(strings.NewReader(file))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		passwords[strings.ToLower(line)] = true
+	}
+	return passwords
+}
+
+func SetPasswordPolicy(policy PasswordPolicy) {
+	passwordPolicy = policy
+}
+
+func registerPasswordValidations(validate *validator.Validate) {
+	validate.RegisterValidation("password_length", isPasswordLength)
+	validate.RegisterValidation("password_classes", hasPasswordClasses)
+	validate.RegisterValidation("password_personal", isPasswordImpersonal)
+	validate.RegisterValidation("password_common", isPasswordUncommon)
+}
+
+func isPasswordLength(fl validator.FieldLevel) bool {
+	password := fl.Field().String()
+	if passwordPolicy.MaxBytes > 0 && len(password) > passwordPolicy.MaxBytes {
+		return false
+	}
+	length := utf8.RuneCountInString(password)
+	return length >= passwordPolicy.MinLength && length <= passwordPolicy.MaxLength
+}
+
+func hasPasswordClasses(fl validator.FieldLevel) bool {
+	var lower, upper, digit, symbol bool
+	for _, r := range fl.Field().String() {
+		switch {
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsDigit(r):
+			digit = true
+		default:
+			symbol = true
+		}
+	}
+
+	classes := 0
+	for _, used := range []bool{lower, upper, digit, symbol} {
+		if used {
+			classes++
+		}
+	}
+	return classes >= passwordPolicy.MinClasses
+}
+
+// normalizePersonal keeps only the letters and digits of s, in lower case
+func normalizePersonal(s string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return unicode.ToLower(r)
+		}
+		return -1
+	}, s)
+}
+
+// isPasswordImpersonal checks the password doesn't contain the first_name, last_name or
+// email of the struct it is on
+func isPasswordImpersonal(fl validator.FieldLevel) bool {
+	password := normalizePersonal(fl.Field().String())
+
+	parent := fl.Parent()
+	personal := []string{}
+	for _, name := range []string{"FirstName", "LastName", "Email"} {
+		field := parent.FieldByName(name)
+		if !field.IsValid() {
+			continue
+		}
+		value := field.String()
+		if name == "Email" {
+			value, _, _ = strings.Cut(value, "@")
+		}
+		personal = append(personal, value)
+	}
+
+	for _, value := range personal {
+		value = normalizePersonal(value)
+		if len(value) >= MIN_PERSONAL_LENGTH && strings.Contains(password, value) {
+			return false
+		}
+	}
+	return true
+}
+
+// unleet undoes the usual swaps of letters for digits and symbols, 1 can stand for i or l
+var unleet = []*strings.Replacer{
+	strings.NewReplacer("0", "o", "1", "i", "3", "e", "4", "a", "5", "s", "7", "t", "@", "a", "$", "s", "!", "i"),
+	strings.NewReplacer("0", "o", "1", "l", "3", "e", "4", "a", "5", "s", "7", "t", "@", "a", "$", "s", "!", "i"),
+}
+
+// isPasswordUncommon checks the password isn't a common one, or a common one with digits
+// or symbols added to the end or look-alikes swapped in
+func isPasswordUncommon(fl validator.FieldLevel) bool {
+	password := strings.ToLower(fl.Field().String())
+	if commonPasswords[password] {
+		return false
+	}
+
+	base := strings.TrimRightFunc(password, func(r rune) bool {
+		return unicode.IsDigit(r) || unicode.IsPunct(r) || unicode.IsSymbol(r) || unicode.IsSpace(r)
+	})
+	if base == "" {
+		return true
+	}
+	if commonPasswords[base] {
+		return false
+	}
+	for _, replacer := range unleet {
+		if commonPasswords[replacer.Replace(base)] {
+			return false
+		}
+	}
+	return true
+}
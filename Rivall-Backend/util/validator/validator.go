@@ -2,6 +2,7 @@ package validator
 
 import (
 	"fmt"
+	"net/url"
 	"reflect"
 	"regexp"
 	"strings"
@@ -31,44 +32,111 @@ func New() *validator.Validate {
 	})
 
 	validate.RegisterValidation("alpha_space", isAlphaSpace)
+	validate.RegisterValidation("https_url", isHTTPSURL)
+	registerPasswordValidations(validate)
 
 	return validate
 }
 
+// FieldError is a failed validation of one field, Field is its JSON path
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
 func ToErrResponse(err error) *ErrResponse {
-	if fieldErrors, ok := err.(validator.ValidationErrors); ok {
-		resp := ErrResponse{
-			Errors: make([]string, len(fieldErrors)),
-		}
+	fieldErrors := ToFieldErrors(err)
+	if fieldErrors == nil {
+		return nil
+	}
 
-		for i, err := range fieldErrors {
-			switch err.Tag() {
-			case "required":
-				resp.Errors[i] = fmt.Sprintf("%s is a required field", err.Field())
-			case "max":
-				resp.Errors[i] = fmt.Sprintf("%s must be a maximum of %s in length", err.Field(), err.Param())
-			case "url":
-				resp.Errors[i] = fmt.Sprintf("%s must be a valid URL", err.Field())
-			case "alpha_space":
-				resp.Errors[i] = fmt.Sprintf("%s can only contain alphabetic and space characters", err.Field())
-			case "datetime":
-				if err.Param() == "2006-01-02" {
-					resp.Errors[i] = fmt.Sprintf("%s must be a valid date", err.Field())
-				} else {
-					resp.Errors[i] = fmt.Sprintf("%s must follow %s format", err.Field(), err.Param())
-				}
-			default:
-				resp.Errors[i] = fmt.Sprintf("something wrong on %s; %s", err.Field(), err.Tag())
-			}
-		}
+	resp := ErrResponse{
+		Errors: make([]string, len(fieldErrors)),
+	}
+	for i, fieldError := range fieldErrors {
+		resp.Errors[i] = fieldError.Message
+	}
+	return &resp
+}
 
-		return &resp
+// ToFieldErrors describes each field that failed validation, it returns nil when err is not
+// a validation error
+func ToFieldErrors(err error) []FieldError {
+	fieldErrors, ok := err.(validator.ValidationErrors)
+	if !ok {
+		return nil
 	}
 
-	return nil
+	resp := make([]FieldError, len(fieldErrors))
+	for i, err := range fieldErrors {
+		resp[i] = FieldError{Field: jsonPath(err), Message: fieldErrorMessage(err)}
+	}
+	return resp
+}
+
+// jsonPath is the namespace of a field without the name of the validated struct, which
+// clients never see. Anonymous structs have no name to remove.
+func jsonPath(err validator.FieldError) string {
+	typeName, path, nested := strings.Cut(err.Namespace(), ".")
+	structName, _, _ := strings.Cut(err.StructNamespace(), ".")
+	if !nested || typeName != structName {
+		return err.Namespace()
+	}
+	return path
+}
+
+func fieldErrorMessage(err validator.FieldError) string {
+	switch err.Tag() {
+	case "required":
+		return fmt.Sprintf("%s is a required field", err.Field())
+	case "max":
+		return fmt.Sprintf("%s must be a maximum of %s in length", err.Field(), err.Param())
+	case "min":
+		return fmt.Sprintf("%s must be a minimum of %s in length", err.Field(), err.Param())
+	case "url":
+		return fmt.Sprintf("%s must be a valid URL", err.Field())
+	case "eq=|url":
+		return fmt.Sprintf("%s must be a valid URL or empty", err.Field())
+	case "https_url":
+		return fmt.Sprintf("%s must be an https URL", err.Field())
+	case "eq=|https_url":
+		return fmt.Sprintf("%s must be an https URL or empty", err.Field())
+	case "oneof":
+		return fmt.Sprintf("%s must be one of %s", err.Field(), strings.ReplaceAll(err.Param(), " ", ", "))
+	case "email":
+		return fmt.Sprintf("%s must be a valid email address", err.Field())
+	case "password_length":
+		if value, ok := err.Value().(string); ok && passwordPolicy.MaxBytes > 0 && len(value) > passwordPolicy.MaxBytes {
+			return fmt.Sprintf("%s must be at most %d bytes, characters outside ASCII count as several", err.Field(), passwordPolicy.MaxBytes)
+		}
+		return fmt.Sprintf("%s must be between %d and %d characters", err.Field(), passwordPolicy.MinLength, passwordPolicy.MaxLength)
+	case "password_classes":
+		return fmt.Sprintf("%s must use at least %d of lowercase letters, uppercase letters, digits and symbols", err.Field(), passwordPolicy.MinClasses)
+	case "password_personal":
+		return fmt.Sprintf("%s must not contain your name or email", err.Field())
+	case "password_common":
+		return fmt.Sprintf("%s is too common, choose one that is harder to guess", err.Field())
+	case "alpha_space":
+		return fmt.Sprintf("%s can only contain alphabetic and space characters", err.Field())
+	case "datetime":
+		if err.Param() == "2006-01-02" {
+			return fmt.Sprintf("%s must be a valid date", err.Field())
+		} else {
+			return fmt.Sprintf("%s must follow %s format", err.Field(), err.Param())
+		}
+	default:
+		return fmt.Sprintf("something wrong on %s; %s", err.Field(), err.Tag())
+	}
 }
 
 func isAlphaSpace(fl validator.FieldLevel) bool {
 	reg := regexp.MustCompile(alphaSpaceRegexString)
 	return reg.MatchString(fl.Field().String())
 }
+
+// isHTTPSURL only accepts absolute https URLs, the url tag also takes javascript: and data:
+// URLs that clients would load
+func isHTTPSURL(fl validator.FieldLevel) bool {
+	u, err := url.Parse(fl.Field().String())
+	return err == nil && u.Scheme == "https" && u.Host != ""
+}
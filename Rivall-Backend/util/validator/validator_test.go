@@ -1,6 +1,7 @@
 package validator_test
 
 import (
+	"strings"
 	"testing"
 
 	"Rivall-Backend/util/validator"
@@ -34,6 +35,41 @@ var tests = []*testCase{
 		}{Image: "image.png"},
 		expected: "image must be a valid URL",
 	},
+	{
+		name: `min`,
+		input: struct {
+			Name string `json:"name" form:"min=1"`
+		}{},
+		expected: "name must be a minimum of 1 in length",
+	},
+	{
+		name: `url or empty`,
+		input: struct {
+			Image string `json:"image" form:"eq=|url"`
+		}{Image: "image.png"},
+		expected: "image must be a valid URL or empty",
+	},
+	{
+		name: `https_url`,
+		input: struct {
+			Image string `json:"image" form:"https_url"`
+		}{Image: "javascript:alert(1)"},
+		expected: "image must be an https URL",
+	},
+	{
+		name: `https_url or empty`,
+		input: struct {
+			Image string `json:"image" form:"eq=|https_url"`
+		}{Image: "http://example.com/avatar.png"},
+		expected: "image must be an https URL or empty",
+	},
+	{
+		name: `oneof`,
+		input: struct {
+			Who string `json:"who" form:"oneof=everyone nobody"`
+		}{Who: "somebody"},
+		expected: "who must be one of everyone, nobody",
+	},
 	{
 		name: `alpha_space`,
 		input: struct {
@@ -48,6 +84,147 @@ var tests = []*testCase{
 		}{Date: "2020-02-31"},
 		expected: "date must be a valid date",
 	},
+	{
+		name: `password_length`,
+		input: struct {
+			Password string `json:"password" form:"password_length"`
+		}{Password: "Sh0rt!"},
+		expected: "password must be between 10 and 72 characters",
+	},
+	{
+		name: `password_classes`,
+		input: struct {
+			Password string `json:"password" form:"password_classes"`
+		}{Password: "onlylowercaseletters"},
+		expected: "password must use at least 3 of lowercase letters, uppercase letters, digits and symbols",
+	},
+	{
+		name: `password_personal_name`,
+		input: struct {
+			FirstName string `json:"first_name"`
+			Password  string `json:"password" form:"password_personal"`
+		}{FirstName: "Sam", Password: "Go-Sam-2025!"},
+		expected: "password must not contain your name or email",
+	},
+	{
+		name: `password_personal_email`,
+		input: struct {
+			Email    string `json:"email"`
+			Password string `json:"password" form:"password_personal"`
+		}{Email: "sam.lee@example.com", Password: "SAMLEE#rocks9"},
+		expected: "password must not contain your name or email",
+	},
+	{
+		name: `password_common`,
+		input: struct {
+			Password string `json:"password" form:"password_common"`
+		}{Password: "P@ssw0rd1"},
+		expected: "password is too common, choose one that is harder to guess",
+	},
+}
+
+func TestPasswordPolicyAccepts(t *testing.T) {
+	t.Parallel()
+	vr := validator.New()
+
+	input := struct {
+		FirstName string `json:"first_name"`
+		LastName  string `json:"last_name"`
+		Email     string `json:"email"`
+		Password  string `json:"password" form:"required,password_length,password_classes,password_personal,password_common"`
+	}{FirstName: "Sam", LastName: "Lee", Email: "sam.lee@example.com", Password: "correct-Horse-battery-7"}
+
+	if err := vr.Struct(input); err != nil {
+		t.Fatalf("Expected the password to pass, got %v", validator.ToErrResponse(err))
+	}
+}
+
+func TestHTTPSURL(t *testing.T) {
+	t.Parallel()
+	vr := validator.New()
+
+	tests := []struct {
+		url   string
+		valid bool
+	}{
+		{url: "https://example.com/avatar.png", valid: true},
+		{url: "HTTPS://example.com/avatar.png", valid: true},
+		{url: "http://example.com/avatar.png", valid: false},
+		{url: "javascript:alert(1)", valid: false},
+		{url: "data:image/png;base64,AAAA", valid: false},
+		{url: "https:avatar.png", valid: false},
+		{url: "//example.com/avatar.png", valid: false},
+	}
+
+	for _, tc := range tests {
+		input := struct {
+			Image string `json:"image" form:"https_url"`
+		}{Image: tc.url}
+		if err := vr.Struct(input); (err == nil) != tc.valid {
+			t.Errorf("Expected %q valid to be %v, got %v", tc.url, tc.valid, err)
+		}
+	}
+}
+
+func TestCommonPasswordVariants(t *testing.T) {
+	t.Parallel()
+	vr := validator.New()
+
+	tests := []struct {
+		password string
+		common   bool
+	}{
+		{password: "Password2024!", common: true},
+		{password: "Sunshine-123", common: true},
+		{password: "P@ssw0rd", common: true},
+		{password: "L3tm31n!!", common: true},
+		{password: "FOOTBALL", common: true},
+		{password: "correct-Horse-battery-7", common: false},
+		{password: "2024Password!", common: false},
+		{password: "Qwerty12345!", common: true},
+	}
+
+	for _, tc := range tests {
+		input := struct {
+			Password string `json:"password" form:"password_common"`
+		}{Password: tc.password}
+		if err := vr.Struct(input); (err != nil) != tc.common {
+			t.Errorf("Expected %q common to be %v, got %v", tc.password, tc.common, err)
+		}
+	}
+}
+
+// TestPasswordLengthInBytes changes the policy, it must not run in parallel with the
+// other password tests
+func TestPasswordLengthInBytes(t *testing.T) {
+	validator.SetPasswordPolicy(validator.PasswordPolicy{MinLength: 10, MaxLength: 72, MaxBytes: 72, MinClasses: 3})
+	t.Cleanup(func() {
+		validator.SetPasswordPolicy(validator.PasswordPolicy{MinLength: 10, MaxLength: 72, MinClasses: 3})
+	})
+	vr := validator.New()
+
+	type input struct {
+		Password string `json:"password" form:"password_length"`
+	}
+
+	// 72 characters, but 144 bytes that bcrypt would refuse
+	long := strings.Repeat("é", 72)
+	errResp := validator.ToErrResponse(vr.Struct(input{Password: long}))
+	if errResp == nil || len(errResp.Errors) != 1 {
+		t.Fatalf("Expected %d multibyte characters to be too long, got %v", len(long), errResp)
+	}
+	if expected := "password must be at most 72 bytes, characters outside ASCII count as several"; errResp.Errors[0] != expected {
+		t.Fatalf(`Expected:"%v", Got:"%v"`, expected, errResp.Errors[0])
+	}
+
+	// short in characters and bytes alike
+	if err := vr.Struct(input{Password: strings.Repeat("é", 36)}); err != nil {
+		t.Fatalf("Expected 72 bytes to pass, got %v", validator.ToErrResponse(err))
+	}
+	// the minimum still counts characters, not bytes
+	if err := vr.Struct(input{Password: strings.Repeat("é", 9)}); err == nil {
+		t.Fatal("Expected 9 characters to be too short")
+	}
 }
 
 func TestToErrResponse(t *testing.T) {
@@ -66,3 +243,29 @@ func TestToErrResponse(t *testing.T) {
 		})
 	}
 }
+
+type fieldErrorsReq struct {
+	Title    string `json:"title" form:"required"`
+	Settings struct {
+		Presence string `json:"presence" form:"oneof=everyone nobody"`
+	} `json:"settings"`
+}
+
+func TestToFieldErrors(t *testing.T) {
+	vr := validator.New()
+
+	fieldErrors := validator.ToFieldErrors(vr.Struct(fieldErrorsReq{}))
+	if len(fieldErrors) != 2 {
+		t.Fatalf("Expected 2 field errors, got %v", fieldErrors)
+	}
+	if fieldErrors[0].Field != "title" || fieldErrors[0].Message != "title is a required field" {
+		t.Fatalf("Unexpected field error %v", fieldErrors[0])
+	}
+	if fieldErrors[1].Field != "settings.presence" {
+		t.Fatalf(`Expected "settings.presence", got %q`, fieldErrors[1].Field)
+	}
+
+	if validator.ToFieldErrors(nil) != nil {
+		t.Fatal("Expected no field errors without a validation error")
+	}
+}